@@ -15,6 +15,7 @@ import "react-toastify/dist/ReactToastify.css";
 import LoginPage from "./pages/Auth/Login";
 import RegisterPage from "./pages/Auth/Register";
 import AuthCallbackPage from "./pages/Auth/Callback";
+import TwoFactorPage from "./pages/Auth/TwoFactor";
 
 // メインページ（遅延読み込み）
 const HomePage = lazy(() => import("./pages/Home"));
@@ -81,6 +82,7 @@ function App() {
           }
         />
         <Route path="/auth/callback" element={<AuthCallbackPage />} />
+        <Route path="/auth/two-factor" element={<TwoFactorPage />} />
 
         {/* 認証が必要なルート */}
         <Route
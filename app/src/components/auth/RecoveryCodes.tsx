@@ -0,0 +1,35 @@
+import { toast } from "react-toastify";
+import { Button } from "../common/Button";
+
+interface RecoveryCodesProps {
+  codes: string[];
+}
+
+// リカバリーコードの表示（平文を確認できるのは発行時のみ）
+export function RecoveryCodes({ codes }: RecoveryCodesProps) {
+  const handleCopy = async () => {
+    try {
+      await navigator.clipboard.writeText(codes.join("\n"));
+      toast.success("リカバリーコードをコピーしました");
+    } catch {
+      toast.error("コピーに失敗しました");
+    }
+  };
+
+  return (
+    <div className="space-y-3">
+      <p className="text-sm text-[var(--color-text-secondary)]">
+        認証アプリを使えなくなった場合に、認証コードの代わりに使えます（各コード1回限り）。
+        この画面を閉じると再表示できないため、安全な場所に保管してください。
+      </p>
+      <ul className="grid grid-cols-2 gap-2 font-mono text-sm p-3 bg-[var(--color-background)] border border-[var(--color-border)] rounded-lg">
+        {codes.map((code) => (
+          <li key={code}>{code}</li>
+        ))}
+      </ul>
+      <Button type="button" variant="secondary" size="sm" onClick={handleCopy}>
+        コピー
+      </Button>
+    </div>
+  );
+}
@@ -0,0 +1,239 @@
+import { FormEvent, useCallback, useEffect, useState } from "react";
+import { toast } from "react-toastify";
+import { Card } from "../common/Card";
+import { Button } from "../common/Button";
+import { Loading } from "../common/Loading";
+import { RecoveryCodes } from "./RecoveryCodes";
+import { TwoFactorSetupForm } from "./TwoFactorSetupForm";
+import { api } from "../../utils/api";
+import { TwoFactorSetup, TwoFactorStatus } from "../../types/auth";
+
+type Mode = "idle" | "setup" | "disable" | "regenerate";
+
+const inputClassName =
+  "w-full px-4 py-2 bg-[var(--color-background)] border border-[var(--color-border)] rounded-lg text-[var(--color-text)] focus:outline-none focus:ring-2 focus:ring-[var(--color-primary)]";
+
+// アカウント設定の2段階認証（認証アプリの登録・無効化・リカバリーコードの再発行）
+export function TwoFactorSettings() {
+  const [status, setStatus] = useState<TwoFactorStatus | null>(null);
+  const [mode, setMode] = useState<Mode>("idle");
+  const [setup, setSetup] = useState<TwoFactorSetup | null>(null);
+  const [recoveryCodes, setRecoveryCodes] = useState<string[] | null>(null);
+  const [password, setPassword] = useState("");
+  const [code, setCode] = useState("");
+  const [submitting, setSubmitting] = useState(false);
+
+  const loadStatus = useCallback(async () => {
+    try {
+      setStatus(await api.auth.twoFactor.status());
+    } catch (error: any) {
+      toast.error(error.message || "2段階認証の状態の取得に失敗しました");
+    }
+  }, []);
+
+  useEffect(() => {
+    loadStatus();
+  }, [loadStatus]);
+
+  const resetForm = () => {
+    setMode("idle");
+    setSetup(null);
+    setPassword("");
+    setCode("");
+  };
+
+  const handleStartSetup = async () => {
+    setSubmitting(true);
+    try {
+      setSetup(await api.auth.twoFactor.setup());
+      setRecoveryCodes(null);
+      setMode("setup");
+    } catch (error: any) {
+      toast.error(error.message || "登録の開始に失敗しました");
+    } finally {
+      setSubmitting(false);
+    }
+  };
+
+  const handleEnable = async (value: string) => {
+    setSubmitting(true);
+    try {
+      const result = await api.auth.twoFactor.enable(value);
+      toast.success("2段階認証を有効にしました");
+      setRecoveryCodes(result.recoveryCodes);
+      resetForm();
+      await loadStatus();
+    } catch (error: any) {
+      toast.error(error.message || "2段階認証の有効化に失敗しました");
+    } finally {
+      setSubmitting(false);
+    }
+  };
+
+  const handleDisable = async (e: FormEvent) => {
+    e.preventDefault();
+    if (!code.trim()) {
+      toast.error("認証コードを入力してください");
+      return;
+    }
+    setSubmitting(true);
+    try {
+      await api.auth.twoFactor.disable({
+        password: password || undefined,
+        code: code.trim(),
+      });
+      toast.success("2段階認証を無効にしました");
+      setRecoveryCodes(null);
+      resetForm();
+      await loadStatus();
+    } catch (error: any) {
+      toast.error(error.message || "2段階認証の無効化に失敗しました");
+    } finally {
+      setSubmitting(false);
+    }
+  };
+
+  const handleRegenerate = async (e: FormEvent) => {
+    e.preventDefault();
+    if (!code.trim()) {
+      toast.error("認証コードを入力してください");
+      return;
+    }
+    setSubmitting(true);
+    try {
+      const result = await api.auth.twoFactor.regenerateRecoveryCodes(
+        code.trim()
+      );
+      toast.success("リカバリーコードを再発行しました");
+      setRecoveryCodes(result.recoveryCodes);
+      resetForm();
+      await loadStatus();
+    } catch (error: any) {
+      toast.error(error.message || "リカバリーコードの再発行に失敗しました");
+    } finally {
+      setSubmitting(false);
+    }
+  };
+
+  const renderContent = () => {
+    if (!status) {
+      return <Loading />;
+    }
+
+    if (mode === "setup" && setup) {
+      return (
+        <TwoFactorSetupForm
+          setup={setup}
+          submitting={submitting}
+          onSubmit={handleEnable}
+          onCancel={resetForm}
+        />
+      );
+    }
+
+    if (mode === "disable" || mode === "regenerate") {
+      return (
+        <form
+          onSubmit={mode === "disable" ? handleDisable : handleRegenerate}
+          className="space-y-4"
+        >
+          {mode === "disable" && (
+            <div>
+              <label className="block text-sm font-medium mb-2">
+                パスワード（設定している場合）
+              </label>
+              <input
+                type="password"
+                value={password}
+                onChange={(e) => setPassword(e.target.value)}
+                className={inputClassName}
+                placeholder="現在のパスワードを入力"
+                autoComplete="current-password"
+                disabled={submitting}
+              />
+            </div>
+          )}
+          <div>
+            <label className="block text-sm font-medium mb-2">
+              認証コード
+            </label>
+            <input
+              type="text"
+              value={code}
+              onChange={(e) => setCode(e.target.value)}
+              className={inputClassName}
+              placeholder="6桁のコードまたはリカバリーコード"
+              autoComplete="one-time-code"
+              disabled={submitting}
+            />
+          </div>
+          <div className="flex justify-end gap-2">
+            <Button
+              type="button"
+              variant="secondary"
+              onClick={resetForm}
+              disabled={submitting}
+            >
+              キャンセル
+            </Button>
+            <Button
+              type="submit"
+              variant={mode === "disable" ? "danger" : "primary"}
+              disabled={submitting}
+            >
+              {mode === "disable" ? "無効にする" : "再発行する"}
+            </Button>
+          </div>
+        </form>
+      );
+    }
+
+    return (
+      <div className="space-y-4">
+        <p className="text-sm">
+          {status.enabled
+            ? `有効（リカバリーコード残り${status.recoveryCodesRemaining}件）`
+            : "無効"}
+        </p>
+        {status.required && (
+          <p className="text-sm text-[var(--color-text-secondary)]">
+            所属店舗の設定により、2段階認証は必須です。
+          </p>
+        )}
+        {recoveryCodes && <RecoveryCodes codes={recoveryCodes} />}
+        <div className="flex flex-wrap justify-end gap-2">
+          {status.enabled ? (
+            <>
+              <Button
+                type="button"
+                variant="secondary"
+                onClick={() => setMode("regenerate")}
+              >
+                リカバリーコードを再発行
+              </Button>
+              {!status.required && (
+                <Button
+                  type="button"
+                  variant="danger"
+                  onClick={() => setMode("disable")}
+                >
+                  無効にする
+                </Button>
+              )}
+            </>
+          ) : (
+            <Button
+              type="button"
+              onClick={handleStartSetup}
+              disabled={submitting}
+            >
+              認証アプリを登録
+            </Button>
+          )}
+        </div>
+      </div>
+    );
+  };
+
+  return <Card title="2段階認証">{renderContent()}</Card>;
+}
@@ -0,0 +1,74 @@
+import { FormEvent, useState } from "react";
+import { TwoFactorSetup } from "../../types/auth";
+import { Button } from "../common/Button";
+
+interface TwoFactorSetupFormProps {
+  setup: TwoFactorSetup;
+  submitting: boolean;
+  onSubmit: (code: string) => void;
+  onCancel?: () => void;
+}
+
+// 認証アプリへの登録（シークレットの表示と確認コードの入力）
+export function TwoFactorSetupForm({
+  setup,
+  submitting,
+  onSubmit,
+  onCancel,
+}: TwoFactorSetupFormProps) {
+  const [code, setCode] = useState("");
+
+  const handleSubmit = (e: FormEvent) => {
+    e.preventDefault();
+    if (code.trim()) {
+      onSubmit(code.trim());
+    }
+  };
+
+  return (
+    <form onSubmit={handleSubmit} className="space-y-4">
+      <ol className="list-decimal list-inside space-y-2 text-sm text-[var(--color-text-secondary)]">
+        <li>
+          認証アプリ（Google Authenticator など）で
+          <a
+            href={setup.otpauthUri}
+            className="text-[var(--color-primary)] hover:underline mx-1"
+          >
+            このリンクを開く
+          </a>
+          か、次のキーを入力して登録してください。
+        </li>
+        <li>アプリに表示された6桁のコードを入力してください。</li>
+      </ol>
+      <p className="font-mono text-sm break-all p-3 bg-[var(--color-background)] border border-[var(--color-border)] rounded-lg select-all">
+        {setup.secret}
+      </p>
+      <input
+        type="text"
+        value={code}
+        onChange={(e) => setCode(e.target.value)}
+        className="w-full px-4 py-2 bg-[var(--color-background)] border border-[var(--color-border)] rounded-lg text-[var(--color-text)] focus:outline-none focus:ring-2 focus:ring-[var(--color-primary)]"
+        placeholder="6桁のコード"
+        inputMode="numeric"
+        autoComplete="one-time-code"
+        maxLength={6}
+        disabled={submitting}
+      />
+      <div className="flex justify-end gap-2">
+        {onCancel && (
+          <Button
+            type="button"
+            variant="secondary"
+            onClick={onCancel}
+            disabled={submitting}
+          >
+            キャンセル
+          </Button>
+        )}
+        <Button type="submit" disabled={submitting || !code.trim()}>
+          {submitting ? "確認中..." : "登録する"}
+        </Button>
+      </div>
+    </form>
+  );
+}
@@ -89,6 +89,7 @@ export const SalesInfoForm: React.FC<SalesInfoFormProps> = ({
     const firstItem = menuList[0];
     if (!firstItem) return;
     const newItem: OrderItem = {
+      menuId: firstItem.id,
       name: firstItem.name,
       quantity: 1,
       unitPrice: firstItem.price,
@@ -201,6 +202,7 @@ export const SalesInfoForm: React.FC<SalesInfoFormProps> = ({
                     const updated = [...localSalesInfo.orderItems];
                     updated[index] = {
                       ...updated[index],
+                      menuId: selected.id,
                       name: selected.name,
                       unitPrice: selected.price,
                       amount: updated[index].quantity * selected.price,
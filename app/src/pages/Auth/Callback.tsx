@@ -1,4 +1,4 @@
-import { useEffect } from 'react';
+import { useEffect, useRef } from 'react';
 import { useNavigate, useSearchParams } from 'react-router-dom';
 import { useAuthStore } from '../../stores/authStore';
 import { toast } from 'react-toastify';
@@ -7,14 +7,24 @@ import { Loading } from '../../components/common/Loading';
 export default function AuthCallbackPage() {
   const navigate = useNavigate();
   const [searchParams] = useSearchParams();
-  const { loginWithToken } = useAuthStore();
+  const { exchangeLoginCode } = useAuthStore();
+  const exchanged = useRef(false);
 
   useEffect(() => {
-    const token = searchParams.get('token');
-    
-    if (token) {
-      loginWithToken(token)
-        .then(() => {
+    // ログインコードは1回しか使えないため、再実行（StrictModeなど）では交換しない
+    if (exchanged.current) return;
+    exchanged.current = true;
+
+    // トークンはURLに含めず、1回限りのログインコードと交換する
+    const code = searchParams.get('code');
+
+    if (code) {
+      exchangeLoginCode(code)
+        .then((challenge) => {
+          if (challenge) {
+            navigate('/auth/two-factor', { replace: true, state: { challenge } });
+            return;
+          }
           toast.success('ログインしました');
           navigate('/', { replace: true });
         })
@@ -23,10 +33,10 @@ export default function AuthCallbackPage() {
           navigate('/auth/login', { replace: true });
         });
     } else {
-      toast.error('トークンが取得できませんでした');
+      toast.error('ログインコードが取得できませんでした');
       navigate('/auth/login', { replace: true });
     }
-  }, [searchParams, loginWithToken, navigate]);
+  }, [searchParams, exchangeLoginCode, navigate]);
 
   return (
     <div className="min-h-screen flex items-center justify-center bg-[var(--color-background)]">
@@ -24,10 +24,14 @@ export default function LoginPage() {
     }
 
     try {
-      await login(username, password);
-      toast.success("ログインしました");
       // 元のページに戻る、またはホームに遷移
       const from = (location.state as any)?.from?.pathname || "/";
+      const challenge = await login(username, password);
+      if (challenge) {
+        navigate("/auth/two-factor", { state: { challenge, from } });
+        return;
+      }
+      toast.success("ログインしました");
       navigate(from, { replace: true });
     } catch (error) {
       toast.error(
@@ -58,9 +62,14 @@ export default function LoginPage() {
         }
 
         const data = await response.json();
-        await loginWithToken(data.token);
-        toast.success("Googleでログインしました");
         const from = (location.state as any)?.from?.pathname || "/";
+        // 店舗で2段階認証が必須の場合はGoogleでのログインでも確認する
+        if (data.twoFactorRequired) {
+          navigate("/auth/two-factor", { state: { challenge: data, from } });
+          return;
+        }
+        await loginWithToken(data.token, data.refreshToken);
+        toast.success("Googleでログインしました");
         navigate(from, { replace: true });
       } catch (error) {
         toast.error(
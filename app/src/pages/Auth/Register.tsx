@@ -43,8 +43,12 @@ export default function RegisterPage() {
     }
 
     try {
-      await register(username, email, password);
+      const challenge = await register(username, email, password);
       toast.success("アカウントを作成しました");
+      if (challenge) {
+        navigate("/auth/two-factor", { state: { challenge } });
+        return;
+      }
       navigate("/");
     } catch (error) {
       toast.error(
@@ -0,0 +1,172 @@
+import { useEffect, useState, FormEvent } from "react";
+import { Navigate, useLocation, useNavigate } from "react-router-dom";
+import { toast } from "react-toastify";
+import { Card } from "../../components/common/Card";
+import { Button } from "../../components/common/Button";
+import { Loading } from "../../components/common/Loading";
+import { RecoveryCodes } from "../../components/auth/RecoveryCodes";
+import { TwoFactorSetupForm } from "../../components/auth/TwoFactorSetupForm";
+import { useAuthStore } from "../../stores/authStore";
+import { api } from "../../utils/api";
+import { TwoFactorChallenge, TwoFactorSetup } from "../../types/auth";
+
+interface TwoFactorLocationState {
+  challenge: TwoFactorChallenge;
+  from?: string;
+}
+
+// ログイン時の2段階認証（店舗で必須・未登録の場合は認証アプリの登録から行う）
+export default function TwoFactorPage() {
+  const navigate = useNavigate();
+  const location = useLocation();
+  const state = location.state as TwoFactorLocationState | null;
+  const { verifyTwoFactor } = useAuthStore();
+  const [setup, setSetup] = useState<TwoFactorSetup | null>(null);
+  const [code, setCode] = useState("");
+  const [useRecoveryCode, setUseRecoveryCode] = useState(false);
+  const [submitting, setSubmitting] = useState(false);
+  const [recoveryCodes, setRecoveryCodes] = useState<string[] | null>(null);
+
+  const challenge = state?.challenge;
+  const from = state?.from || "/";
+
+  useEffect(() => {
+    if (!challenge?.setupRequired) return;
+    api.auth.twoFactor
+      .challengeSetup(challenge.challengeToken)
+      .then(setSetup)
+      .catch((error) => {
+        toast.error(
+          error instanceof Error ? error.message : "登録の開始に失敗しました"
+        );
+        navigate("/auth/login", { replace: true });
+      });
+  }, [challenge, navigate]);
+
+  if (!challenge) {
+    return <Navigate to="/auth/login" replace />;
+  }
+
+  const verify = async (value: string) => {
+    setSubmitting(true);
+    try {
+      const codes = await verifyTwoFactor(challenge.challengeToken, value);
+      toast.success("ログインしました");
+      if (codes && codes.length > 0) {
+        // 登録時はリカバリーコードを控えてもらってから遷移する
+        setRecoveryCodes(codes);
+        return;
+      }
+      navigate(from, { replace: true });
+    } catch (error) {
+      toast.error(
+        error instanceof Error ? error.message : "認証に失敗しました"
+      );
+    } finally {
+      setSubmitting(false);
+    }
+  };
+
+  const handleSubmit = (e: FormEvent) => {
+    e.preventDefault();
+    if (!code.trim()) {
+      toast.error("認証コードを入力してください");
+      return;
+    }
+    verify(code.trim());
+  };
+
+  const renderContent = () => {
+    if (recoveryCodes) {
+      return (
+        <div className="space-y-4">
+          <RecoveryCodes codes={recoveryCodes} />
+          <Button
+            type="button"
+            className="w-full"
+            onClick={() => navigate(from, { replace: true })}
+          >
+            保管しました
+          </Button>
+        </div>
+      );
+    }
+
+    if (challenge.setupRequired) {
+      if (!setup) {
+        return <Loading />;
+      }
+      return (
+        <div className="space-y-4">
+          <p className="text-sm text-[var(--color-text-secondary)]">
+            所属店舗の設定により、2段階認証の登録が必要です。
+          </p>
+          <TwoFactorSetupForm
+            setup={setup}
+            submitting={submitting}
+            onSubmit={verify}
+          />
+        </div>
+      );
+    }
+
+    return (
+      <form onSubmit={handleSubmit} className="space-y-4">
+        <p className="text-sm text-[var(--color-text-secondary)]">
+          {useRecoveryCode
+            ? "保管しているリカバリーコードを入力してください。"
+            : "認証アプリに表示された6桁のコードを入力してください。"}
+        </p>
+        <input
+          type="text"
+          value={code}
+          onChange={(e) => setCode(e.target.value)}
+          className="w-full px-4 py-2 bg-[var(--color-background)] border border-[var(--color-border)] rounded-lg text-[var(--color-text)] focus:outline-none focus:ring-2 focus:ring-[var(--color-primary)]"
+          placeholder={useRecoveryCode ? "xxxxx-xxxxx" : "6桁のコード"}
+          inputMode={useRecoveryCode ? "text" : "numeric"}
+          autoComplete="one-time-code"
+          disabled={submitting}
+          autoFocus
+        />
+        <Button
+          type="submit"
+          disabled={submitting}
+          className="w-full"
+          variant="primary"
+        >
+          {submitting ? "確認中..." : "確認"}
+        </Button>
+        <button
+          type="button"
+          onClick={() => {
+            setUseRecoveryCode(!useRecoveryCode);
+            setCode("");
+          }}
+          className="w-full text-sm text-[var(--color-primary)] hover:underline"
+        >
+          {useRecoveryCode
+            ? "認証アプリのコードを使う"
+            : "認証アプリを使えない場合（リカバリーコード）"}
+        </button>
+      </form>
+    );
+  };
+
+  return (
+    <div className="min-h-screen flex items-center justify-center bg-[var(--color-background)] px-4">
+      <div className="w-full max-w-md">
+        <Card>
+          <div className="space-y-6">
+            <div className="text-center">
+              <h1 className="text-3xl font-bold mb-2">HostNote</h1>
+              <p className="text-[var(--color-text-secondary)]">
+                2段階認証
+              </p>
+            </div>
+            {renderContent()}
+          </div>
+        </Card>
+      </div>
+    </div>
+  );
+}
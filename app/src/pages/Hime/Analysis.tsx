@@ -27,6 +27,7 @@ import { ja } from "date-fns/locale/ja";
 import { toast } from "react-toastify";
 import { logError } from "../../utils/errorHandler";
 import { TableRecordWithDetails } from "../../types/table";
+import { himeShare } from "../../utils/sales";
 import { useMenuStore } from "../../stores/menuStore";
 import {
   LineChart,
@@ -216,23 +217,22 @@ export default function HimeAnalysisPage() {
           });
         }
 
-        // 売上を計算（対象の姫に均等に分配）
+        // 売上を計算（各姫の負担額、カテゴリー別の売上は負担額の比率で分配）
         const totalSales = table.salesInfo.total || 0;
-        const himeCount = targetHimesInTable.length;
-        const salesPerHime = himeCount > 0 ? totalSales / himeCount : 0;
 
         targetHimesInTable.forEach((hime) => {
           if (!hime.id) return;
           const existing = analysisMap.get(hime.id);
           if (existing) {
+            const share = himeShare(table, hime.id);
+            const ratio = totalSales > 0 ? share / totalSales : 0;
             existing.visitCount += 1;
-            existing.sales += Math.round(salesPerHime);
+            existing.sales += Math.round(share);
 
-            // カテゴリー別の売上も分配
             categories.forEach((cat) => {
               if (categorySales[cat]) {
                 existing.salesByCategory[cat] += Math.round(
-                  categorySales[cat] / himeCount
+                  categorySales[cat] * ratio
                 );
               }
             });
@@ -307,11 +307,14 @@ export default function HimeAnalysisPage() {
 
         const existing = timeSeriesMap.get(dateKey);
         if (existing) {
-          // 対象の姫に分配した売上を集計
+          // 対象の姫の負担額を集計
           const totalSales = table.salesInfo.total || 0;
-          const himeCount = targetHimesInTable.length;
-          const salesPerHime = himeCount > 0 ? totalSales / himeCount : 0;
-          existing.sales += Math.round(salesPerHime);
+          const targetSales = targetHimesInTable.reduce(
+            (sum, hime) => sum + (hime.id ? himeShare(table, hime.id) : 0),
+            0
+          );
+          const ratio = totalSales > 0 ? targetSales / totalSales : 0;
+          existing.sales += Math.round(targetSales);
           existing.visitCount += targetHimesInTable.length;
 
           // カテゴリー別の売上を集計（対象の姫に分配）
@@ -333,7 +336,7 @@ export default function HimeAnalysisPage() {
 
             categories.forEach((cat) => {
               if (categorySales[cat] && existing[cat] !== undefined) {
-                existing[cat] += Math.round(categorySales[cat] / himeCount);
+                existing[cat] += Math.round(categorySales[cat] * ratio);
               }
             });
           }
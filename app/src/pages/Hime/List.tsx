@@ -15,6 +15,7 @@ import { Pagination } from "../../components/common/Pagination";
 import { HimeAddModal } from "./HimeAddModal";
 import { useOptionStore } from "../../stores/optionStore";
 import { api } from "../../utils/api";
+import { himeShare } from "../../utils/sales";
 import { Cast } from "../../types/cast";
 
 export default function HimeListPage() {
@@ -217,10 +218,9 @@ export default function HimeListPage() {
 
         const himeStat = stats[hime.id];
 
-        // 今月の売上を計算
+        // 今月の売上を計算（卓の合計ではなくこの姫の負担額）
         if (isCurrentMonth && table.salesInfo?.total) {
-          const himeCount = table.himeList?.length || 1;
-          himeStat.sales += table.salesInfo.total / himeCount;
+          himeStat.sales += himeShare(table, hime.id);
         }
 
         // 直近の来店日を更新
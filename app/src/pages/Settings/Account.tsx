@@ -8,6 +8,7 @@ import { Cast } from "../../types/cast";
 import { toast } from "react-toastify";
 import { Button } from "../../components/common/Button";
 import { Avatar } from "../../components/common/Avatar";
+import { TwoFactorSettings } from "../../components/auth/TwoFactorSettings";
 import { resizeImage } from "../../utils/imageUtils";
 
 export default function AccountPage() {
@@ -526,6 +527,8 @@ export default function AccountPage() {
         </form>
       </Card>
 
+      <TwoFactorSettings />
+
       {/* アカウント削除 */}
       <Card>
         <h2 className="text-xl font-bold mb-4 text-red-500">アカウント削除</h2>
@@ -72,7 +72,8 @@ export default function TableDetailPage() {
         action: "handleDelete",
         id,
       });
-      toast.error("削除に失敗しました");
+      // 支払い・発行した書類のある卓は削除できない（理由をそのまま表示する）
+      toast.error(error instanceof Error ? error.message : "削除に失敗しました");
       setDeleting(false);
     }
   };
@@ -139,25 +140,34 @@ export default function TableDetailPage() {
             <p className="text-sm text-[var(--color-text-secondary)] mb-2">
               日時
             </p>
-            <InlineEditable
-              value={new Date(table.datetime).toISOString().slice(0, 16)}
-              onSave={async (newDatetime) => {
-                if (!table.id) return;
-                // datetime-local形式（YYYY-MM-DDTHH:mm）をそのまま送信
-                // サーバー側のparseTime関数がこの形式をサポートしている
-                await api.table.update(table.id, { datetime: newDatetime });
-                await loadData();
-                toast.success("日時を更新しました");
-              }}
-              displayComponent={
-                <h1 className="text-xl sm:text-2xl font-bold">
-                  {format(new Date(table.datetime), "yyyy年MM月dd日 HH:mm", {
-                    locale: ja,
-                  })}
-                </h1>
-              }
-              inputType="datetime-local"
-            />
+            {/* 会計済みの卓の日時は再オープンしないと変更できない */}
+            {table.status === "open" ? (
+              <InlineEditable
+                value={new Date(table.datetime).toISOString().slice(0, 16)}
+                onSave={async (newDatetime) => {
+                  if (!table.id) return;
+                  // datetime-local形式（YYYY-MM-DDTHH:mm）をそのまま送信
+                  // サーバー側のparseTime関数がこの形式をサポートしている
+                  await api.table.update(table.id, { datetime: newDatetime });
+                  await loadData();
+                  toast.success("日時を更新しました");
+                }}
+                displayComponent={
+                  <h1 className="text-xl sm:text-2xl font-bold">
+                    {format(new Date(table.datetime), "yyyy年MM月dd日 HH:mm", {
+                      locale: ja,
+                    })}
+                  </h1>
+                }
+                inputType="datetime-local"
+              />
+            ) : (
+              <h1 className="text-xl sm:text-2xl font-bold">
+                {format(new Date(table.datetime), "yyyy年MM月dd日 HH:mm", {
+                  locale: ja,
+                })}
+              </h1>
+            )}
           </div>
           <div>
             <p className="text-sm text-[var(--color-text-secondary)] mb-2">
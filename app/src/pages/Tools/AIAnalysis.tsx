@@ -10,6 +10,7 @@ import { useCastStore } from "../../stores/castStore";
 import { useOptionStore } from "../../stores/optionStore";
 import { Avatar } from "../../components/common/Avatar";
 import { Skeleton, SkeletonCard } from "../../components/common/Skeleton";
+import { api } from "../../utils/api";
 
 interface AnalysisRequest {
   himeId?: number;
@@ -57,35 +58,12 @@ export default function AIAnalysisPage() {
       setDataLoading(true);
       setAnalysisResult(null);
 
-      // サーバーAPIを呼び出す実装（将来の拡張用）
-      // 現在はモックデータを返す
-      await new Promise((resolve) => setTimeout(resolve, 1500));
-
-      const targetHimeIdForAnalysis = himeId || request.himeId;
-      const himeForAnalysis = targetHimeIdForAnalysis
-        ? himeList.find((h) => h.id === targetHimeIdForAnalysis)
-        : null;
-      const himeName = himeForAnalysis ? himeForAnalysis.name : "";
-      const himeContext = himeName ? `（${himeName}さん）` : "";
-
-      const mockResults: Record<string, string> = {
-        general: himeForAnalysis
-          ? `${himeName}さんの総合分析結果${himeContext}\n\n主なポイント:\n- 来店頻度の傾向\n- 売上パターン\n- 個別の改善提案\n- 好みの傾向分析`
-          : "全体的な分析結果がここに表示されます。\n\n主なポイント:\n- 来店頻度の傾向\n- 売上パターン\n- 改善提案",
-        sales: himeForAnalysis
-          ? `${himeName}さんの売上分析結果${himeContext}\n\n- 月間売上推移\n- 平均単価の傾向\n- 売上向上のポイント\n- 過去の売上パターンとの比較`
-          : "売上分析結果がここに表示されます。\n\n- 月間売上推移\n- 平均単価の傾向\n- 売上向上のポイント",
-        visit: himeForAnalysis
-          ? `${himeName}さんの来店分析結果${himeContext}\n\n- 来店頻度の分析\n- 来店パターンの傾向\n- リピート率の改善点\n- 最適な来店タイミングの予測`
-          : "来店分析結果がここに表示されます。\n\n- 来店頻度の分析\n- 来店パターンの傾向\n- リピート率の改善点",
-        recommendation: himeForAnalysis
-          ? `${himeName}さんへのAI推奨事項${himeContext}\n\n- 個別のアプローチ提案\n- 最適な来店タイミング\n- パーソナライズされた提案\n- 好みに基づいたサービス提案`
-          : "AI推奨事項がここに表示されます。\n\n- 個別のアプローチ提案\n- 最適な来店タイミング\n- パーソナライズされた提案",
-      };
-
-      setAnalysisResult(
-        mockResults[request.analysisType] || "分析結果がありません"
-      );
+      const response = await api.ai.analyze({
+        himeId: targetHimeId,
+        analysisType: request.analysisType,
+        period: request.period,
+      });
+      setAnalysisResult(response.result);
       toast.success("分析が完了しました");
     } catch (error) {
       toast.error("分析に失敗しました");
@@ -1,4 +1,4 @@
-import { useState } from "react";
+import { useEffect, useRef, useState } from "react";
 import { useNavigate } from "react-router-dom";
 import { Card } from "../../components/common/Card";
 import { Button } from "../../components/common/Button";
@@ -6,6 +6,7 @@ import { FaComments, FaRobot, FaLightbulb } from "react-icons/fa";
 import { toast } from "react-toastify";
 import { api } from "../../utils/api";
 import { logError } from "../../utils/errorHandler";
+import { ChatStats } from "../../types/ai";
 
 interface ConversationRequest {
   selfProfile: string;
@@ -26,6 +27,11 @@ export default function AIConversationPage() {
   });
   const [loading, setLoading] = useState(false);
   const [result, setResult] = useState<string | null>(null);
+  const [chatStats, setChatStats] = useState<ChatStats | null>(null);
+  const abortRef = useRef<AbortController | null>(null);
+
+  // 画面を離れたら分析を中断する
+  useEffect(() => () => abortRef.current?.abort(), []);
 
   const handleBack = () => {
     navigate("/tools/ai-tools");
@@ -41,19 +47,30 @@ export default function AIConversationPage() {
       return;
     }
 
+    abortRef.current?.abort();
+    const controller = new AbortController();
+    abortRef.current = controller;
+
     try {
       setLoading(true);
       setResult(null);
-      const res = await api.ai.analyzeConversation({
-        selfProfile: request.selfProfile,
-        partnerProfile: request.partnerProfile,
-        goal: request.goal,
-        extraInfo: request.extraInfo,
-        chatLog: request.chatLog,
-      });
+      setChatStats(null);
+      const res = await api.ai.analyzeConversationStream(
+        {
+          selfProfile: request.selfProfile,
+          partnerProfile: request.partnerProfile,
+          goal: request.goal,
+          extraInfo: request.extraInfo,
+          chatLog: request.chatLog,
+        },
+        (delta) => setResult((prev) => (prev ?? "") + delta),
+        controller.signal
+      );
       setResult(res.result);
+      setChatStats(res.chatStats ?? null);
       toast.success("AI会話分析が完了しました");
     } catch (error) {
+      if (controller.signal.aborted) return;
       toast.error("AI会話分析に失敗しました");
       logError(error, { component: "AIConversationPage", action: "handleAnalyze" });
     } finally {
@@ -152,6 +169,7 @@ export default function AIConversationPage() {
           <h2 className="text-lg font-semibold">5. 会話ログ（LINEなど）</h2>
           <p className="text-xs text-[var(--color-text-secondary)]">
             古い順に上から貼ってください。名前やスタンプはある程度そのままで大丈夫です。
+            LINEの「トーク履歴を送信」で書き出したテキストはそのまま貼ると、返信の早さなども集計して分析します。
           </p>
           <textarea
             className="w-full min-h-[220px] px-4 py-3 border border-[var(--color-border)] rounded-xl bg-[var(--color-surface)] text-sm font-mono"
@@ -187,6 +205,39 @@ export default function AIConversationPage() {
         </Button>
       </div>
 
+      {chatStats && (
+        <Card>
+          <div className="space-y-3">
+            <h2 className="text-lg font-semibold">トーク履歴の集計</h2>
+            <p className="text-xs text-[var(--color-text-secondary)]">
+              {new Date(chatStats.from).toLocaleDateString("ja-JP", { timeZone: "UTC" })}〜
+              {new Date(chatStats.to).toLocaleDateString("ja-JP", { timeZone: "UTC" })}
+              （やり取りのあった日: {chatStats.activeDays}日、メッセージ: {chatStats.messages}件）
+            </p>
+            <div className="grid grid-cols-1 md:grid-cols-2 gap-3">
+              {chatStats.parties.map((party) => (
+                <div
+                  key={party.name}
+                  className="bg-[var(--color-background)] rounded-xl p-4 border border-[var(--color-border)] text-sm space-y-1"
+                >
+                  <p className="font-semibold">{party.name}</p>
+                  <p>
+                    メッセージ {party.messages}件（スタンプ {party.stickers}・写真/動画 {party.photos}）
+                  </p>
+                  {party.replies > 0 && (
+                    <p>
+                      返信までの時間: 平均 {formatMinutes(party.avgReplyMinutes)}・中央値{" "}
+                      {formatMinutes(party.medianReplyMinutes)}
+                    </p>
+                  )}
+                  <p>会話を始めた回数: {party.initiations}回</p>
+                </div>
+              ))}
+            </div>
+          </div>
+        </Card>
+      )}
+
       {result && (
         <Card>
           <div className="space-y-4">
@@ -209,4 +260,10 @@ export default function AIConversationPage() {
   );
 }
 
-
+// 分を「5分」「2時間10分」「1.5日」のように表示する
+function formatMinutes(minutes: number) {
+  const m = Math.round(minutes);
+  if (m < 60) return `${m}分`;
+  if (m < 24 * 60) return m % 60 === 0 ? `${m / 60}時間` : `${Math.floor(m / 60)}時間${m % 60}分`;
+  return `${(minutes / (24 * 60)).toFixed(1)}日`;
+}
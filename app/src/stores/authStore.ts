@@ -1,5 +1,9 @@
 import { create } from "zustand";
 import { persist } from "zustand/middleware";
+import { TwoFactorChallenge } from "../types/auth";
+
+const API_BASE_URL =
+  import.meta.env.VITE_API_BASE_URL || "http://localhost:8080/api/v1";
 
 export interface User {
   id: number;
@@ -7,18 +11,37 @@ export interface User {
   email: string | null;
 }
 
+// ログイン・トークン更新のレスポンス
+export interface AuthTokens {
+  token: string;
+  refreshToken?: string;
+  user?: User;
+}
+
 interface AuthState {
   user: User | null;
   token: string | null;
+  refreshToken: string | null;
   isAuthenticated: boolean;
   isLoading: boolean;
-  login: (username: string, password: string) => Promise<void>;
-  loginWithToken: (token: string) => Promise<void>;
+  // 2段階認証が必要な場合はセッションを発行せずチャレンジを返す
+  login: (
+    username: string,
+    password: string
+  ) => Promise<TwoFactorChallenge | null>;
+  loginWithToken: (token: string, refreshToken?: string) => Promise<void>;
+  exchangeLoginCode: (code: string) => Promise<TwoFactorChallenge | null>;
+  // 認証コード（またはリカバリーコード）でログインを完了し、登録時はリカバリーコードを返す
+  verifyTwoFactor: (
+    challengeToken: string,
+    code: string
+  ) => Promise<string[] | undefined>;
+  setTokens: (tokens: AuthTokens) => void;
   register: (
     username: string,
     email: string,
     password: string
-  ) => Promise<void>;
+  ) => Promise<TwoFactorChallenge | null>;
   logout: () => void;
   checkAuth: () => Promise<void>;
   updateUserEmail: (email: string | null) => void;
@@ -30,6 +53,7 @@ export const useAuthStore = create<AuthState>()(
     (set) => ({
       user: null,
       token: null,
+      refreshToken: null,
       isAuthenticated: false,
       isLoading: true, // 初期状態をtrueにして、認証確認が完了するまでローディングを表示
 
@@ -37,7 +61,7 @@ export const useAuthStore = create<AuthState>()(
         set({ isLoading: true });
         try {
           const response = await fetch(
-            `${import.meta.env.VITE_API_BASE_URL || "http://localhost:8080/api/v1"}/auth/login`,
+            `${API_BASE_URL}/auth/login`,
             {
               method: "POST",
               headers: {
@@ -53,24 +77,30 @@ export const useAuthStore = create<AuthState>()(
           }
 
           const data = await response.json();
+          if (data.twoFactorRequired) {
+            set({ isLoading: false });
+            return data as TwoFactorChallenge;
+          }
           set({
             user: data.user,
             token: data.token,
+            refreshToken: data.refreshToken ?? null,
             isAuthenticated: true,
             isLoading: false,
           });
+          return null;
         } catch (error) {
           set({ isLoading: false });
           throw error;
         }
       },
 
-      loginWithToken: async (token: string) => {
+      loginWithToken: async (token: string, refreshToken?: string) => {
         set({ isLoading: true });
         try {
           // トークンでユーザー情報を取得
           const response = await fetch(
-            `${import.meta.env.VITE_API_BASE_URL || "http://localhost:8080/api/v1"}/auth/me`,
+            `${API_BASE_URL}/auth/me`,
             {
               method: "GET",
               headers: {
@@ -87,6 +117,7 @@ export const useAuthStore = create<AuthState>()(
           set({
             user,
             token,
+            refreshToken: refreshToken ?? null,
             isAuthenticated: true,
             isLoading: false,
           });
@@ -96,11 +127,81 @@ export const useAuthStore = create<AuthState>()(
         }
       },
 
+      // 外部認証のリダイレクトで受け取ったログインコードをトークンと交換（1回限り）
+      exchangeLoginCode: async (code: string) => {
+        set({ isLoading: true });
+        try {
+          const response = await fetch(`${API_BASE_URL}/auth/login-code`, {
+            method: "POST",
+            headers: {
+              "Content-Type": "application/json",
+            },
+            body: JSON.stringify({ code }),
+          });
+
+          if (!response.ok) {
+            const error = await response.json();
+            throw new Error(error.error || "ログインに失敗しました");
+          }
+
+          const data = await response.json();
+          if (data.twoFactorRequired) {
+            set({ isLoading: false });
+            return data as TwoFactorChallenge;
+          }
+          set({
+            user: data.user,
+            token: data.token,
+            refreshToken: data.refreshToken ?? null,
+            isAuthenticated: true,
+            isLoading: false,
+          });
+          return null;
+        } catch (error) {
+          set({ isLoading: false });
+          throw error;
+        }
+      },
+
+      verifyTwoFactor: async (challengeToken: string, code: string) => {
+        const response = await fetch(`${API_BASE_URL}/auth/2fa/verify`, {
+          method: "POST",
+          headers: {
+            "Content-Type": "application/json",
+          },
+          body: JSON.stringify({ challengeToken, code }),
+        });
+
+        if (!response.ok) {
+          const error = await response.json();
+          throw new Error(error.error || "認証に失敗しました");
+        }
+
+        const data = await response.json();
+        set({
+          user: data.user,
+          token: data.token,
+          refreshToken: data.refreshToken ?? null,
+          isAuthenticated: true,
+          isLoading: false,
+        });
+        return data.recoveryCodes;
+      },
+
+      // パスワード変更などでサーバーが発行し直したトークンを保存
+      setTokens: ({ token, refreshToken, user }) => {
+        set((state) => ({
+          token,
+          refreshToken: refreshToken ?? state.refreshToken,
+          user: user ?? state.user,
+        }));
+      },
+
       register: async (username: string, email: string, password: string) => {
         set({ isLoading: true });
         try {
           const response = await fetch(
-            `${import.meta.env.VITE_API_BASE_URL || "http://localhost:8080/api/v1"}/auth/register`,
+            `${API_BASE_URL}/auth/register`,
             {
               method: "POST",
               headers: {
@@ -116,12 +217,18 @@ export const useAuthStore = create<AuthState>()(
           }
 
           const data = await response.json();
+          if (data.twoFactorRequired) {
+            set({ isLoading: false });
+            return data as TwoFactorChallenge;
+          }
           set({
             user: data.user,
             token: data.token,
+            refreshToken: data.refreshToken ?? null,
             isAuthenticated: true,
             isLoading: false,
           });
+          return null;
         } catch (error) {
           set({ isLoading: false });
           throw error;
@@ -129,9 +236,18 @@ export const useAuthStore = create<AuthState>()(
       },
 
       logout: () => {
+        // サーバー側のセッションも取り消す（失敗してもローカルの状態は破棄する）
+        const { token } = useAuthStore.getState();
+        if (token) {
+          fetch(`${API_BASE_URL}/auth/logout`, {
+            method: "POST",
+            headers: { Authorization: `Bearer ${token}` },
+          }).catch(() => undefined);
+        }
         set({
           user: null,
           token: null,
+          refreshToken: null,
           isAuthenticated: false,
         });
       },
@@ -145,20 +261,28 @@ export const useAuthStore = create<AuthState>()(
 
         set({ isLoading: true });
         try {
-          const response = await fetch(
-            `${import.meta.env.VITE_API_BASE_URL || "http://localhost:8080/api/v1"}/auth/me`,
-            {
+          const fetchMe = (token: string | null) =>
+            fetch(`${API_BASE_URL}/auth/me`, {
               method: "GET",
               headers: {
-                Authorization: `Bearer ${state.token}`,
+                Authorization: `Bearer ${token}`,
               },
-            }
-          );
+            });
+
+          let response = await fetchMe(state.token);
+          // アクセストークンの期限切れはリフレッシュトークンで更新して再確認する
+          if (
+            response.status === 401 &&
+            (await refreshAccessToken(state.token))
+          ) {
+            response = await fetchMe(useAuthStore.getState().token);
+          }
 
           if (!response.ok) {
             set({
               user: null,
               token: null,
+              refreshToken: null,
               isAuthenticated: false,
               isLoading: false,
             });
@@ -175,6 +299,7 @@ export const useAuthStore = create<AuthState>()(
           set({
             user: null,
             token: null,
+            refreshToken: null,
             isAuthenticated: false,
             isLoading: false,
           });
@@ -191,7 +316,7 @@ export const useAuthStore = create<AuthState>()(
         set({ isLoading: true });
         try {
           const response = await fetch(
-            `${import.meta.env.VITE_API_BASE_URL || "http://localhost:8080/api/v1"}/auth/account`,
+            `${API_BASE_URL}/auth/account`,
             {
               method: "DELETE",
               headers: {
@@ -211,6 +336,7 @@ export const useAuthStore = create<AuthState>()(
           set({
             user: null,
             token: null,
+            refreshToken: null,
             isAuthenticated: false,
             isLoading: false,
           });
@@ -222,7 +348,11 @@ export const useAuthStore = create<AuthState>()(
     }),
     {
       name: "auth-storage",
-      partialize: (state) => ({ token: state.token, user: state.user }),
+      partialize: (state) => ({
+        token: state.token,
+        refreshToken: state.refreshToken,
+        user: state.user,
+      }),
       onRehydrateStorage: () => (state) => {
         // localStorageからトークンが復元された場合、一時的に認証済みとして扱う
         // 実際の認証確認はcheckAuth()で行われる
@@ -236,3 +366,91 @@ export const useAuthStore = create<AuthState>()(
     }
   )
 );
+
+// 永続化されたトークン（他のタブで更新された値を含む）を読み込む
+function readStoredTokens(): {
+  token: string | null;
+  refreshToken: string | null;
+} {
+  try {
+    const stored = localStorage.getItem("auth-storage");
+    if (stored) {
+      const parsed = JSON.parse(stored);
+      return {
+        token: parsed.state?.token ?? null,
+        refreshToken: parsed.state?.refreshToken ?? null,
+      };
+    }
+  } catch {
+    // 読み込めない場合は未ログインとして扱う
+  }
+  return { token: null, refreshToken: null };
+}
+
+let refreshing: Promise<boolean> | null = null;
+
+// アクセストークンをリフレッシュトークンで更新する
+// 同時に呼ばれても更新リクエストは1回にまとめ（タブをまたいでWeb Locksで排他）、
+// 使用済みのリフレッシュトークンを再送してセッションが取り消されないようにする
+// failedToken には401になったアクセストークンを渡す（既に更新済みなら再利用する）
+export function refreshAccessToken(
+  failedToken: string | null
+): Promise<boolean> {
+  if (!refreshing) {
+    const run = () => doRefresh(failedToken);
+    refreshing = (
+      "locks" in navigator
+        ? navigator.locks.request("auth-refresh", run)
+        : run()
+    ).finally(() => {
+      refreshing = null;
+    });
+  }
+  return refreshing;
+}
+
+async function doRefresh(failedToken: string | null): Promise<boolean> {
+  const stored = readStoredTokens();
+  // 他のタブが先に更新していた場合はその結果を使う
+  if (stored.token && stored.token !== failedToken) {
+    useAuthStore.setState({
+      token: stored.token,
+      refreshToken: stored.refreshToken,
+    });
+    return true;
+  }
+
+  const refreshToken =
+    stored.refreshToken ?? useAuthStore.getState().refreshToken;
+  if (!refreshToken) {
+    return false;
+  }
+
+  try {
+    const response = await fetch(`${API_BASE_URL}/auth/refresh`, {
+      method: "POST",
+      headers: {
+        "Content-Type": "application/json",
+      },
+      body: JSON.stringify({ refreshToken }),
+    });
+    if (!response.ok) {
+      // 取り消し・期限切れの場合はログアウト状態にする
+      if (response.status === 401) {
+        useAuthStore.setState({
+          user: null,
+          token: null,
+          refreshToken: null,
+          isAuthenticated: false,
+        });
+      }
+      return false;
+    }
+
+    const data: AuthTokens = await response.json();
+    useAuthStore.getState().setTokens(data);
+    return true;
+  } catch {
+    return false;
+  }
+}
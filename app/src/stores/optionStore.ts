@@ -9,7 +9,10 @@ interface OptionStore {
   visitTypeOptions: Array<{ value: string; label: string }>;
   analysisTypeOptions: Array<{ value: string; label: string }>;
   periodOptions: Array<{ value: string; label: string }>;
+  draftIntentOptions: Array<{ value: string; label: string }>;
+  draftToneOptions: Array<{ value: string; label: string }>;
   sortOptions: Array<{ value: string; label: string }>;
+  paymentMethodOptions: Array<{ value: string; label: string }>;
   loading: boolean;
   loadOptions: () => Promise<void>;
 }
@@ -35,11 +38,27 @@ const defaultOptions = {
     { value: "month", label: "月" },
     { value: "year", label: "年" },
   ],
+  draftIntentOptions: [
+    { value: "thanks", label: "来店のお礼" },
+    { value: "birthday", label: "誕生日のお祝い" },
+    { value: "dormant", label: "しばらく来ていない姫へ" },
+    { value: "invite", label: "来店・イベントのお誘い" },
+  ],
+  draftToneOptions: [
+    { value: "sweet", label: "甘め" },
+    { value: "friendly", label: "フランク" },
+    { value: "polite", label: "丁寧" },
+  ],
   sortOptions: [
     { value: "recent", label: "直近順" },
     { value: "sales", label: "売上順" },
     { value: "visits", label: "来店回数順" },
   ],
+  paymentMethodOptions: [
+    { value: "cash", label: "現金" },
+    { value: "card", label: "カード" },
+    { value: "tab", label: "売掛" },
+  ],
 };
 
 export const useOptionStore = create<OptionStore>((set) => ({
@@ -94,10 +113,13 @@ export const useOptionStore = create<OptionStore>((set) => ({
           "visitTypeOptions",
           defaultOptions.visitTypeOptions
         ),
-        // analysisTypeOptions, periodOptions, sortOptionsはデータベースから取得せず、デフォルト値を使用
+        // analysisTypeOptions, periodOptions, draft*Options, sortOptions, paymentMethodOptionsはデータベースから取得せず、デフォルト値を使用
         analysisTypeOptions: defaultOptions.analysisTypeOptions,
         periodOptions: defaultOptions.periodOptions,
+        draftIntentOptions: defaultOptions.draftIntentOptions,
+        draftToneOptions: defaultOptions.draftToneOptions,
         sortOptions: defaultOptions.sortOptions,
+        paymentMethodOptions: defaultOptions.paymentMethodOptions,
         loading: false,
       });
     } catch (error) {
@@ -0,0 +1,105 @@
+export type AIAnalysisKind = "customer" | "conversation" | "draft";
+
+export interface AIMessage {
+  role: "user" | "assistant";
+  content: string;
+  createdAt: string;
+}
+
+export interface AIAnalysis {
+  id: number;
+  userId: number;
+  himeId: number | null;
+  kind: AIAnalysisKind;
+  input: Record<string, unknown>;
+  result: string;
+  model: string;
+  promptTokens: number;
+  completionTokens: number;
+  totalTokens: number;
+  createdAt: string;
+  updatedAt: string;
+}
+
+export interface AIAnalysisWithMessages extends AIAnalysis {
+  messages: AIMessage[];
+}
+
+// LINEのトーク履歴として読み込めた場合の送信者ごとの集計
+export interface ChatPartyStats {
+  name: string;
+  messages: number;
+  stickers: number;
+  photos: number;
+  characters: number;
+  replies: number;
+  avgReplyMinutes: number;
+  medianReplyMinutes: number;
+  initiations: number;
+  lastMessageAt: string;
+}
+
+export interface ChatStats {
+  from: string;
+  to: string;
+  activeDays: number;
+  messages: number;
+  parties: ChatPartyStats[];
+}
+
+export interface ConversationAnalysisResult {
+  analysisId?: number;
+  result: string;
+  chatStats?: ChatStats;
+}
+
+export type DraftIntent = "thanks" | "birthday" | "dormant" | "invite";
+export type DraftTone = "sweet" | "friendly" | "polite";
+
+// 口調ごとの営業LINEの下書き（応答を読み取れなかった場合はtoneが空）
+export interface MessageDraft {
+  tone: DraftTone | "";
+  message: string;
+}
+
+export interface MessageDraftResult {
+  analysisId?: number;
+  intent: DraftIntent;
+  drafts: MessageDraft[];
+  lastVisitAt?: string;
+}
+
+// AI利用量（1ユーザーあたり月ごと、上限の0は無制限）
+export interface AIQuota {
+  monthlyTokens: number;
+  monthlyRequests: number;
+}
+
+export interface AIUsage {
+  id: number;
+  userId: number;
+  month: string;
+  requests: number;
+  promptTokens: number;
+  completionTokens: number;
+  totalTokens: number;
+  createdAt: string;
+  updatedAt: string;
+}
+
+export interface AIUsageStatus {
+  month: string;
+  requests: number;
+  promptTokens: number;
+  completionTokens: number;
+  totalTokens: number;
+  quota: AIQuota;
+  resetAt: string;
+  history: AIUsage[];
+}
+
+export interface StoreAIUsage {
+  month: string;
+  quota?: AIQuota;
+  users: Array<AIUsage & { username: string }>;
+}
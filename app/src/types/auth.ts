@@ -0,0 +1,19 @@
+// ログイン時に2段階認証が必要な場合のチャレンジ（店舗で必須・未登録の場合は setupRequired）
+export interface TwoFactorChallenge {
+  twoFactorRequired: true;
+  setupRequired: boolean;
+  challengeToken: string;
+}
+
+// 認証アプリ登録用の情報
+export interface TwoFactorSetup {
+  secret: string;
+  otpauthUri: string;
+}
+
+// 2段階認証の状態
+export interface TwoFactorStatus {
+  enabled: boolean;
+  required: boolean; // 所属店舗で必須か
+  recoveryCodesRemaining: number;
+}
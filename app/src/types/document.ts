@@ -0,0 +1,20 @@
+export type DocumentType = 'receipt' | 'invoice';
+
+// 卓記録に発行した領収書・請求書（同じ種類の2回目以降は再発行）
+export interface IssuedDocument {
+  id: number;
+  userId: number;
+  tableId: number;
+  type: DocumentType;
+  number: string; // R-000001（領収書）、I-000001（請求書）
+  addressee: string;
+  amount: number;
+  reissue: boolean;
+  issuedAt: string;
+}
+
+export interface IssueDocumentFormData {
+  type: DocumentType;
+  addressee?: string; // 領収書で省略した場合は「上様」
+  description?: string; // 領収書の但し書き（省略時は「飲食代」）
+}
@@ -0,0 +1,43 @@
+export type PaymentMethod = 'cash' | 'card' | 'tab';
+
+// 卓記録の支払い（tab は売掛、receivableId を持つ支払いは売掛の回収）
+export interface Payment {
+  id: number;
+  userId: number;
+  tableId: number;
+  himeId: number;
+  receivableId: number | null;
+  method: PaymentMethod;
+  amount: number;
+  dueDate: string | null; // 売掛の回収期限
+  paidAt: string;
+  memo: string | null;
+  createdAt: string;
+  updatedAt: string;
+}
+
+export interface PaymentFormData {
+  himeId?: number; // 卓の姫が1人の場合は省略可
+  method: PaymentMethod;
+  amount: number;
+  dueDate?: string; // YYYY-MM-DD（売掛のみ）
+  paidAt?: string;
+  memo?: string;
+}
+
+// 売掛と回収状況
+export interface Receivable extends Payment {
+  himeName: string;
+  paid: number; // 回収済みの金額
+  outstanding: number; // 未回収の金額
+  overdue: boolean;
+}
+
+// 姫ごとの売掛台帳
+export interface HimeReceivables {
+  himeId: number;
+  himeName: string;
+  outstanding: number;
+  overdue: number; // 期限超過分の未回収金額
+  receivables: Receivable[];
+}
@@ -2,6 +2,8 @@ import { Hime } from './hime';
 import { Cast } from './cast';
 
 export interface OrderItem {
+  menuId?: number; // 注文したメニュー（単価はサーバー側でメニューから決まる）
+  himeId?: number; // 注文した姫（品目ごとの割り勘用、未指定は卓全体）
   name: string;
   quantity: number;
   unitPrice: number;
@@ -13,25 +15,120 @@ export interface SalesInfo {
   orderItems: OrderItem[]; // 注文アイテム
   visitType: 'normal' | 'first' | 'shimei'; // 来店区分
   stayHours: number; // 滞在時間
+  setPrice?: number; // セット料金
+  extensionCount?: number; // 延長回数
+  extensionFee?: number; // 延長料金
+  surcharge?: number; // 時間帯による加算
   shimeiFee: number; // 指名料
   subtotal: number; // 小計
+  serviceChargeRate?: number; // サービス料率（%）
+  serviceCharge?: number; // サービス料
   taxRate: number; // 税率（%）
   tax: number; // 消費税
   total: number; // 合計
+  pricingRuleId?: number; // 計算に使った料金ルール
+  pricingRuleVersion?: number; // 料金ルールのバージョン（0は既定値）
+  splitMode?: SplitMode; // 姫ごとの負担額の決め方（未指定は均等）
 }
 
+// equal: 均等、item: 注文した品目はその姫・卓全体の料金は均等、custom: 金額を指定
+export type SplitMode = 'equal' | 'item' | 'custom';
+
+export interface HimeAllocation {
+  himeId: number;
+  amount: number;
+}
+
+// 時間帯による加算（深夜料金など）
+export interface PricingSurcharge {
+  name: string;
+  start: string; // HH:MM
+  end: string; // HH:MM（startより前の場合は日をまたぐ）
+  rate: number; // セット・延長料金に対する%
+  amount: number;
+}
+
+export type RoundingMode = 'round' | 'floor' | 'ceil';
+
+// 店舗の料金ルール（変更のたびに新しいバージョンになる）
+export interface PricingRule {
+  id?: number;
+  storeId: number;
+  version: number;
+  setMinutes: number; // 1セットの時間（分）
+  setPriceNormal: number;
+  setPriceFirst: number;
+  setPriceShimei: number;
+  firstVisitFlat: boolean; // 初回のセット料金をサービス料・税込みの定額にする
+  extensionMinutes: number; // 延長の単位（分）
+  extensionPrice: number;
+  shimeiFee: number;
+  serviceChargeRate: number; // TAX・サービス料率（%）
+  taxRate: number; // 消費税率（%）
+  roundingMode: RoundingMode;
+  roundingUnit: number; // 端数処理の単位（円）
+  surcharges: PricingSurcharge[];
+  createdBy?: number;
+  createdAt?: string;
+}
+
+export type PricingRuleFormData = Omit<PricingRule, 'id' | 'storeId' | 'version' | 'createdBy' | 'createdAt'>;
+
+// 卓の状態（open: 接客中、closed: 会計済み、voided: 取り消し）
+export type TableStatus = 'open' | 'closed' | 'voided';
+
 export interface TableRecord {
   id?: number;
   datetime: string;
   tableNumber: string | null;
   memo: string | null;
   salesInfo: SalesInfo | null; // 売上情報
+  status: TableStatus;
+  closedAt: string | null; // 会計（取り消し）した日時
   createdAt: string;
   updatedAt: string;
 }
 
+// 接客中の卓の注文
+export interface TableOrder {
+  id: number;
+  tableId: number;
+  menuId: number;
+  himeId?: number; // 注文した姫（省略時は卓全体）
+  quantity: number;
+  unitPrice: number | null; // 注文時の単価（会計ではこの単価を使う）
+  orderedBy: number;
+  orderedAt: string;
+}
+
+export interface TableOrderFormData {
+  menuId: number;
+  quantity?: number;
+  himeId?: number;
+}
+
+export interface OpenTableFormData {
+  datetime?: string; // 入店日時（省略時は現在）
+  tableNumber?: string;
+  himeIds: number[];
+  mainCastId?: number;
+  helpCastIds?: number[];
+  memo?: string;
+  visitType?: SalesInfo['visitType'];
+  splitMode?: SplitMode;
+}
+
+export interface CloseTableFormData {
+  closedAt?: string; // 会計日時（省略時は現在）
+  stayHours?: number; // 滞在時間（省略時は入店から会計日時まで、再オープンした卓は元の滞在時間）
+  useLatestPricingRule?: boolean; // 再オープンした卓を最新の料金ルールで会計し直す（省略時は元の料金ルール）
+  visitType?: SalesInfo['visitType'];
+  splitMode?: SplitMode;
+  allocations?: HimeAllocation[];
+}
+
 export interface TableRecordWithDetails extends TableRecord {
-  himeList: Hime[];
+  himeList: (Hime & { allocation?: number | null })[]; // allocation: 卓の合計のうちこの姫の負担額
   mainCast: Cast | null;
   helpCasts: Cast[];
 }
@@ -40,6 +137,7 @@ export interface TableHime {
   id?: number;
   tableId: number;
   himeId: number;
+  allocation?: number | null; // 卓の合計のうちこの姫の負担額
 }
 
 export interface TableCast {
@@ -57,5 +155,6 @@ export interface TableFormData {
   helpCastIds: number[];
   memo?: string;
   salesInfo?: SalesInfo;
+  allocations?: HimeAllocation[]; // splitMode が custom の場合の姫ごとの負担額
 }
 
@@ -1,6 +1,16 @@
 import { Hime, HimeWithCast } from "../types/hime";
 import { Cast } from "../types/cast";
-import { TableRecordWithDetails, TableFormData } from "../types/table";
+import {
+  TableRecordWithDetails,
+  TableFormData,
+  SalesInfo,
+  TableOrder,
+  TableOrderFormData,
+  OpenTableFormData,
+  CloseTableFormData,
+  PricingRule,
+  PricingRuleFormData,
+} from "../types/table";
 import {
   VisitRecord,
   VisitRecordWithHime,
@@ -8,7 +18,26 @@ import {
 } from "../types/visit";
 import { ScheduleWithHime, ScheduleFormData } from "../types/schedule";
 import { Menu, MenuFormData } from "../types/menu";
+import {
+  Payment,
+  PaymentFormData,
+  Receivable,
+  HimeReceivables,
+} from "../types/payment";
+import { IssuedDocument, IssueDocumentFormData } from "../types/document";
+import { TwoFactorSetup, TwoFactorStatus } from "../types/auth";
+import {
+  AIAnalysis,
+  AIAnalysisWithMessages,
+  AIUsageStatus,
+  ConversationAnalysisResult,
+  DraftIntent,
+  DraftTone,
+  MessageDraftResult,
+  StoreAIUsage,
+} from "../types/ai";
 import { logError } from "./errorHandler";
+import { refreshAccessToken, useAuthStore } from "../stores/authStore";
 
 const API_BASE_URL =
   import.meta.env.VITE_API_BASE_URL || "http://localhost:8080/api/v1";
@@ -37,6 +66,21 @@ function getAuthToken(): string | null {
   return null;
 }
 
+// 認証付きで送信し、アクセストークンの期限切れ（401）の場合は更新して1回だけ再送する
+async function fetchWithAuth(
+  send: (token: string | null) => Promise<Response>
+): Promise<Response> {
+  const token = getAuthToken();
+  const response = await send(token);
+  if (response.status !== 401 || !token) {
+    return response;
+  }
+  if (!(await refreshAccessToken(token))) {
+    return response;
+  }
+  return send(useAuthStore.getState().token);
+}
+
 // タイムアウト付きfetch
 async function fetchWithTimeout(
   url: string,
@@ -183,7 +227,6 @@ async function fetchApi<T>(
   options: RequestInit = {}
 ): Promise<T> {
   const url = `${API_BASE_URL}${endpoint}`;
-  const token = getAuthToken();
 
   const headers: Record<string, string> = {};
 
@@ -197,19 +240,126 @@ async function fetchApi<T>(
     Object.assign(headers, options.headers as Record<string, string>);
   }
 
-  if (token) {
-    headers["Authorization"] = `Bearer ${token}`;
-  }
+  const send = (token: string | null) =>
+    fetchWithTimeout(
+      url,
+      {
+        ...options,
+        headers: token
+          ? { ...headers, Authorization: `Bearer ${token}` }
+          : headers,
+      },
+      30000
+    );
 
   // タイムアウトとリトライ付きでfetch
   return fetchWithRetry(
-    () => fetchWithTimeout(url, { ...options, headers }, 30000),
+    () => fetchWithAuth(send),
     endpoint,
     2, // 最大2回リトライ
     1000 // 1秒待機
   );
 }
 
+// PDFなどファイルで返るAPIを呼び出す
+// 発行の記録が重複しないようリトライはしない
+async function fetchFile(
+  endpoint: string,
+  body: unknown
+): Promise<{ blob: Blob; filename: string }> {
+  const response = await fetchWithAuth((token) =>
+    fetchWithTimeout(
+      `${API_BASE_URL}${endpoint}`,
+      {
+        method: "POST",
+        headers: {
+          "Content-Type": "application/json",
+          ...(token ? { Authorization: `Bearer ${token}` } : {}),
+        },
+        body: JSON.stringify(body),
+      },
+      30000
+    )
+  );
+  if (!response.ok) {
+    let message = response.statusText;
+    try {
+      const errorData = await response.json();
+      message = errorData.error || errorData.message || message;
+    } catch {
+      // JSONでない場合はステータステキストを使う
+    }
+    throw new ApiError(response.status, message);
+  }
+
+  const disposition = response.headers.get("Content-Disposition") || "";
+  const match = disposition.match(/filename="([^"]+)"/);
+  return {
+    blob: await response.blob(),
+    filename: match ? match[1] : "document.pdf",
+  };
+}
+
+// Server-Sent Eventsで返るAPIを呼び出し、イベントごとにコールバックする
+// タイムアウトは設けず、signal で中断する（サーバー側でもAIへのリクエストが中断される）
+async function fetchEventStream(
+  endpoint: string,
+  body: unknown,
+  onEvent: (event: string, data: string) => void,
+  signal?: AbortSignal
+): Promise<void> {
+  const response = await fetchWithAuth((token) =>
+    fetch(`${API_BASE_URL}${endpoint}`, {
+      method: "POST",
+      headers: {
+        "Content-Type": "application/json",
+        Accept: "text/event-stream",
+        ...(token ? { Authorization: `Bearer ${token}` } : {}),
+      },
+      body: JSON.stringify(body),
+      signal,
+    })
+  );
+  if (!response.ok || !response.body) {
+    let message = response.statusText;
+    try {
+      const errorData = await response.json();
+      message = errorData.error || errorData.message || message;
+    } catch {
+      // JSONでない場合はステータステキストを使う
+    }
+    throw new ApiError(response.status, message);
+  }
+
+  const reader = response.body.getReader();
+  const decoder = new TextDecoder();
+  let buffer = "";
+  for (;;) {
+    const { done, value } = await reader.read();
+    if (done) break;
+    buffer += decoder.decode(value, { stream: true });
+
+    // イベントは空行区切り
+    let index;
+    while ((index = buffer.indexOf("\n\n")) >= 0) {
+      const chunk = buffer.slice(0, index);
+      buffer = buffer.slice(index + 2);
+      let event = "message";
+      const data: string[] = [];
+      for (const line of chunk.split("\n")) {
+        if (line.startsWith("event:")) {
+          event = line.slice(6).trim();
+        } else if (line.startsWith("data:")) {
+          data.push(line.slice(5));
+        }
+      }
+      if (data.length > 0) {
+        onEvent(event, data.join("\n"));
+      }
+    }
+  }
+}
+
 export const api = {
   // Hime
   hime: {
@@ -276,6 +426,98 @@ export const api = {
         method: "POST",
         body: JSON.stringify(data),
       }),
+    // 売上情報の会計をサーバー側で計算（保存はしない）
+    calculateBill: (data: SalesInfo & { datetime?: string }) =>
+      fetchApi<SalesInfo>("/table/bill", {
+        method: "POST",
+        body: JSON.stringify(data),
+      }),
+    // 接客中の卓を開く
+    open: (data: OpenTableFormData) =>
+      fetchApi<TableRecordWithDetails>("/table/open", {
+        method: "POST",
+        body: JSON.stringify(data),
+      }),
+    orders: (id: number) => fetchApi<TableOrder[]>(`/table/${id}/orders`),
+    addOrder: (id: number, data: TableOrderFormData) =>
+      fetchApi<{ order: TableOrder; salesInfo: SalesInfo }>(
+        `/table/${id}/orders`,
+        {
+          method: "POST",
+          body: JSON.stringify(data),
+        }
+      ),
+    deleteOrder: (id: number, orderId: number) =>
+      fetchApi<{ salesInfo: SalesInfo }>(`/table/${id}/orders/${orderId}`, {
+        method: "DELETE",
+      }),
+    // 現在の会計（接客中の卓は現在時刻までの滞在時間で計算）
+    runningBill: (id: number) => fetchApi<SalesInfo>(`/table/${id}/bill`),
+    close: (id: number, data: CloseTableFormData = {}) =>
+      fetchApi<TableRecordWithDetails>(`/table/${id}/close`, {
+        method: "POST",
+        body: JSON.stringify(data),
+      }),
+    void: (id: number) =>
+      fetchApi<TableRecordWithDetails>(`/table/${id}/void`, {
+        method: "POST",
+      }),
+    reopen: (id: number) =>
+      fetchApi<TableRecordWithDetails>(`/table/${id}/reopen`, {
+        method: "POST",
+      }),
+  },
+
+  // Payment / Receivable（売掛）
+  payment: {
+    listByTable: (tableId: number) =>
+      fetchApi<Payment[]>(`/table/${tableId}/payments`),
+    create: (tableId: number, data: PaymentFormData) =>
+      fetchApi<Payment>(`/table/${tableId}/payments`, {
+        method: "POST",
+        body: JSON.stringify(data),
+      }),
+    delete: (id: number) =>
+      fetchApi<void>(`/payments/${id}`, { method: "DELETE" }),
+    // 売掛台帳（デフォルトは未回収のみ）
+    receivables: (params?: { all?: boolean; himeId?: number }) => {
+      const query = new URLSearchParams();
+      if (params?.all) query.set("all", "true");
+      if (params?.himeId) query.set("himeId", String(params.himeId));
+      const qs = query.toString();
+      return fetchApi<HimeReceivables[]>(`/receivables${qs ? `?${qs}` : ""}`);
+    },
+    overdue: () => fetchApi<Receivable[]>("/receivables/overdue"),
+    // 売掛の回収（一部回収可）
+    repay: (
+      receivableId: number,
+      data: Omit<PaymentFormData, "himeId" | "dueDate">
+    ) =>
+      fetchApi<Payment>(`/receivables/${receivableId}/payments`, {
+        method: "POST",
+        body: JSON.stringify(data),
+      }),
+  },
+
+  // Receipt / Invoice（領収書・請求書）
+  document: {
+    listByTable: (tableId: number) =>
+      fetchApi<IssuedDocument[]>(`/table/${tableId}/documents`),
+    // PDFを発行する（請求書は設定の登録番号が必要）
+    issue: (tableId: number, data: IssueDocumentFormData) =>
+      fetchFile(`/table/${tableId}/documents`, data),
+  },
+
+  // Pricing rule
+  pricingRule: {
+    get: () => fetchApi<PricingRule>("/pricing-rule"),
+    versions: () => fetchApi<PricingRule[]>("/pricing-rule/versions"),
+    // 変更は新しいバージョンとして保存される
+    update: (data: PricingRuleFormData) =>
+      fetchApi<PricingRule>("/pricing-rule", {
+        method: "PUT",
+        body: JSON.stringify(data),
+      }),
   },
 
   // Schedule
@@ -362,11 +604,22 @@ export const api = {
 
   // Auth
   auth: {
-    changePassword: (data: { currentPassword: string; newPassword: string }) =>
-      fetchApi<{ message: string }>("/auth/change-password", {
+    // 他の端末のセッションは取り消され、この端末には新しいトークンが発行される
+    changePassword: async (data: {
+      currentPassword: string;
+      newPassword: string;
+    }) => {
+      const result = await fetchApi<{
+        message: string;
+        token: string;
+        refreshToken: string;
+      }>("/auth/change-password", {
         method: "POST",
         body: JSON.stringify(data),
-      }),
+      });
+      useAuthStore.getState().setTokens(result);
+      return result;
+    },
     updateEmail: (data: { email: string; password: string }) =>
       fetchApi<{ message: string }>("/auth/email", {
         method: "PUT",
@@ -377,6 +630,33 @@ export const api = {
         method: "DELETE",
         body: JSON.stringify(data),
       }),
+    twoFactor: {
+      status: () => fetchApi<TwoFactorStatus>("/auth/2fa"),
+      setup: () =>
+        fetchApi<TwoFactorSetup>("/auth/2fa/setup", { method: "POST" }),
+      enable: (code: string) =>
+        fetchApi<{ recoveryCodes: string[] }>("/auth/2fa/enable", {
+          method: "POST",
+          body: JSON.stringify({ code }),
+        }),
+      // パスワードを設定していない（Googleのみの）アカウントは password を省略
+      disable: (data: { password?: string; code: string }) =>
+        fetchApi<{ message: string }>("/auth/2fa/disable", {
+          method: "POST",
+          body: JSON.stringify(data),
+        }),
+      regenerateRecoveryCodes: (code: string) =>
+        fetchApi<{ recoveryCodes: string[] }>("/auth/2fa/recovery-codes", {
+          method: "POST",
+          body: JSON.stringify({ code }),
+        }),
+      // ログイン途中（店舗で必須・未登録）の認証アプリ登録
+      challengeSetup: (challengeToken: string) =>
+        fetchApi<TwoFactorSetup>("/auth/2fa/challenge/setup", {
+          method: "POST",
+          body: JSON.stringify({ challengeToken }),
+        }),
+    },
   },
 
   // Push Notification
@@ -396,17 +676,121 @@ export const api = {
 
   // AI
   ai: {
+    analyze: (data: {
+      himeId?: number;
+      analysisType: "general" | "sales" | "visit" | "recommendation";
+      period?: "week" | "month" | "year";
+    }) =>
+      fetchApi<{
+        analysisId?: number;
+        result: string;
+        analysisType: string;
+        period: string;
+        summary: {
+          from: string;
+          to: string;
+          tableCount: number;
+          visitCount: number;
+          himeCount: number;
+          totalSales: number;
+          averageSpend: number;
+          previousTableCount: number;
+          previousTotalSales: number;
+          lastVisitAt?: string;
+        };
+      }>("/ai/analyze", {
+        method: "POST",
+        body: JSON.stringify(data),
+      }),
     analyzeConversation: (data: {
+      himeId?: number;
       selfProfile: string;
       partnerProfile: string;
       goal?: string;
       extraInfo?: string;
       chatLog: string;
     }) =>
-      fetchApi<{ result: string }>("/ai/conversation", {
+      fetchApi<ConversationAnalysisResult>("/ai/conversation", {
+        method: "POST",
+        body: JSON.stringify(data),
+      }),
+    // 分析結果を生成されたそばから onDelta に渡し、完了後に全文を返す
+    analyzeConversationStream: async (
+      data: {
+        himeId?: number;
+        selfProfile: string;
+        partnerProfile: string;
+        goal?: string;
+        extraInfo?: string;
+        chatLog: string;
+      },
+      onDelta: (delta: string) => void,
+      signal?: AbortSignal
+    ): Promise<ConversationAnalysisResult> => {
+      let result = null as ConversationAnalysisResult | null;
+      let streamError = null as string | null;
+      await fetchEventStream(
+        "/ai/conversation/stream",
+        data,
+        (event, payload) => {
+          const parsed = JSON.parse(payload);
+          if (event === "delta") {
+            onDelta(parsed.content);
+          } else if (event === "done") {
+            result = parsed;
+          } else if (event === "error") {
+            streamError = parsed.error;
+          }
+        },
+        signal
+      );
+      if (streamError) {
+        throw new ApiError(502, streamError);
+      }
+      if (!result) {
+        throw new Error("AI分析の結果を受信できませんでした");
+      }
+      return result;
+    },
+    // 姫に送る営業LINEの下書き（口調ごと）
+    drafts: (data: {
+      himeId: number;
+      intent: DraftIntent;
+      tones?: DraftTone[];
+      note?: string;
+    }) =>
+      fetchApi<MessageDraftResult>("/ai/drafts", {
         method: "POST",
         body: JSON.stringify(data),
       }),
+    // 保存したAI分析（姫ごとの履歴）
+    listAnalyses: (params?: { himeId?: number; kind?: string }) => {
+      const query = new URLSearchParams();
+      if (params?.himeId) query.set("himeId", String(params.himeId));
+      if (params?.kind) query.set("kind", params.kind);
+      const qs = query.toString();
+      return fetchApi<AIAnalysis[]>(`/ai/analyses${qs ? `?${qs}` : ""}`);
+    },
+    getAnalysis: (id: number) =>
+      fetchApi<AIAnalysisWithMessages>(`/ai/analyses/${id}`),
+    followUp: (id: number, message: string) =>
+      fetchApi<{ reply: string; analysis: AIAnalysisWithMessages }>(
+        `/ai/analyses/${id}/messages`,
+        { method: "POST", body: JSON.stringify({ message }) }
+      ),
+    deleteAnalysis: (id: number) =>
+      fetchApi<void>(`/ai/analyses/${id}`, { method: "DELETE" }),
+    // 今月のAI利用量と上限
+    usage: (months?: number) =>
+      fetchApi<AIUsageStatus>(`/ai/usage${months ? `?months=${months}` : ""}`),
+    // 店舗メンバーごとのAI利用量（管理者用）
+    storeUsage: (params?: { month?: string; storeId?: number }) => {
+      const query = new URLSearchParams();
+      if (params?.month) query.set("month", params.month);
+      if (params?.storeId) query.set("storeId", String(params.storeId));
+      const qs = query.toString();
+      return fetchApi<StoreAIUsage>(`/ai/usage/users${qs ? `?${qs}` : ""}`);
+    },
   },
 
   // Menu
@@ -0,0 +1,16 @@
+import { TableRecordWithDetails } from "../types/table";
+
+// 卓の合計のうち、その姫の負担額
+// 負担額が記録されていない卓（割り勘の導入前など）は同席した姫で均等に按分する
+// 取り消した卓は売上に含めない
+export const himeShare = (
+  table: TableRecordWithDetails,
+  himeId: number
+): number => {
+  if (table.status === "voided") return 0;
+  const total = table.salesInfo?.total || 0;
+  const hime = table.himeList?.find((h) => h.id === himeId);
+  if (!hime) return 0;
+  if (hime.allocation != null) return hime.allocation;
+  return total / (table.himeList.length || 1);
+};
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/hostnote/server/internal/config"
+	"github.com/hostnote/server/internal/database"
+	"github.com/hostnote/server/internal/fieldcrypt"
+	"github.com/hostnote/server/internal/models"
+	"gorm.io/gorm"
+)
+
+// rawRow 暗号化カラムを復号せずに読み込んだ行
+type rawRow interface {
+	rowID() uint
+	needsUpdate() bool
+}
+
+// rawHime 姫の暗号化カラム
+type rawHime struct {
+	ID        uint
+	Name      string
+	NameIndex *string
+	SnsInfo   *string `gorm:"column:sn_s_info"`
+	Birthday  *string
+	Memos     *string
+}
+
+// rawAIAnalysis AI分析の入力・結果・会話履歴
+type rawAIAnalysis struct {
+	ID       uint
+	Input    *string
+	Result   *string
+	Messages *string
+}
+
+// rawIssuedDocument 発行した書類の宛名
+type rawIssuedDocument struct {
+	ID        uint
+	Addressee *string
+}
+
+// options コマンドライン引数
+type options struct {
+	dryRun    bool
+	batchSize int
+}
+
+// counts テーブルごとの件数
+type counts struct {
+	scanned, updated, failed int
+}
+
+// 暗号化カラムを持つテーブル（姫・AI分析・発行した書類）を現在の鍵で暗号化し直し、姫の名前のブラインドインデックスを作り直す
+// 平文のまま保存されている既存データの暗号化と、鍵のローテーションの両方に使う
+//
+//	go run ./cmd/reencrypt            # 実行
+//	go run ./cmd/reencrypt -dry-run   # 対象件数のみ表示
+func main() {
+	var opts options
+	flag.BoolVar(&opts.dryRun, "dry-run", false, "count rows that need re-encryption without updating")
+	flag.IntVar(&opts.batchSize, "batch", 200, "number of rows per batch")
+	flag.Parse()
+
+	if err := config.Load(); err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+
+	if err := fieldcrypt.Init(config.AppConfig); err != nil {
+		log.Fatalf("failed to initialize field encryption: %v", err)
+	}
+	if !fieldcrypt.Enabled() {
+		log.Fatalf("FIELD_ENCRYPTION_KEYS is not set")
+	}
+
+	db, err := database.Connect()
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+
+	if err := database.Migrate(db); err != nil {
+		log.Fatalf("failed to run migrations: %v", err)
+	}
+
+	tables := []struct {
+		name string
+		run  func() (counts, error)
+	}{
+		{"姫", func() (counts, error) {
+			return reencryptTable[rawHime](db, opts, models.Hime{}.TableName(),
+				"id, name, name_index, sn_s_info, birthday, memos", reencryptHime)
+		}},
+		{"AI分析", func() (counts, error) {
+			return reencryptTable[rawAIAnalysis](db, opts, models.AIAnalysis{}.TableName(),
+				"id, input, result, messages", reencryptAIAnalysis)
+		}},
+		{"発行した書類", func() (counts, error) {
+			return reencryptTable[rawIssuedDocument](db, opts, models.IssuedDocument{}.TableName(),
+				"id, addressee", reencryptIssuedDocument)
+		}},
+	}
+
+	var failed int
+	for _, t := range tables {
+		c, err := t.run()
+		if err != nil {
+			log.Fatalf("failed to scan %s: %v", t.name, err)
+		}
+		failed += c.failed
+		if opts.dryRun {
+			fmt.Printf("🔍 %s: %d件中 %d件が再暗号化の対象です\n", t.name, c.scanned, c.updated)
+			continue
+		}
+		fmt.Printf("🔐 %s: %d件中 %d件を再暗号化しました\n", t.name, c.scanned, c.updated)
+	}
+
+	fmt.Printf("現在の鍵: %s\n", fieldcrypt.ActiveKeyID())
+	if failed > 0 {
+		log.Fatalf("%d件の再暗号化に失敗しました", failed)
+	}
+}
+
+// reencryptTable テーブルを走査し、平文・古い鍵のカラムがある行を update で保存し直す
+func reencryptTable[T rawRow](db *gorm.DB, opts options, table, columns string, update func(tx *gorm.DB, id uint) error) (counts, error) {
+	var c counts
+	var rows []T
+	result := db.Table(table).
+		Select(columns).
+		FindInBatches(&rows, opts.batchSize, func(tx *gorm.DB, batch int) error {
+			for _, row := range rows {
+				c.scanned++
+				if !row.needsUpdate() {
+					continue
+				}
+				if opts.dryRun {
+					c.updated++
+					continue
+				}
+				if err := db.Transaction(func(tx *gorm.DB) error {
+					return update(tx, row.rowID())
+				}); err != nil {
+					log.Printf("failed to re-encrypt %s %d: %v", table, row.rowID(), err)
+					c.failed++
+					continue
+				}
+				c.updated++
+			}
+			return nil
+		})
+	return c, result.Error
+}
+
+// anyNeedsReencrypt 平文・古い鍵で暗号化されたカラムがあるか
+func anyNeedsReencrypt(values ...*string) bool {
+	for _, v := range values {
+		if v != nil && fieldcrypt.NeedsReencrypt(*v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r rawHime) rowID() uint { return r.ID }
+
+// needsUpdate 暗号化カラムの更新か、ブラインドインデックスの作成が必要か
+func (r rawHime) needsUpdate() bool {
+	if r.NameIndex == nil || *r.NameIndex == "" {
+		return true
+	}
+	return anyNeedsReencrypt(&r.Name, r.SnsInfo, r.Birthday, r.Memos)
+}
+
+func (r rawAIAnalysis) rowID() uint { return r.ID }
+
+func (r rawAIAnalysis) needsUpdate() bool {
+	return anyNeedsReencrypt(r.Input, r.Result, r.Messages)
+}
+
+func (r rawIssuedDocument) rowID() uint { return r.ID }
+
+func (r rawIssuedDocument) needsUpdate() bool {
+	return anyNeedsReencrypt(r.Addressee)
+}
+
+// reencryptHime 復号して読み込んだ値を現在の鍵で保存し直す（更新日時は変えない）
+func reencryptHime(tx *gorm.DB, id uint) error {
+	var hime models.Hime
+	if err := tx.First(&hime, id).Error; err != nil {
+		return err
+	}
+	hime.NameIndex = models.HimeNameIndex(string(hime.Name))
+	return tx.Model(&hime).
+		Select("Name", "NameIndex", "SnsInfo", "Birthday", "Memos").
+		UpdateColumns(&hime).Error
+}
+
+// reencryptAIAnalysis 入力・結果・会話履歴を現在の鍵で保存し直す
+func reencryptAIAnalysis(tx *gorm.DB, id uint) error {
+	var analysis models.AIAnalysis
+	if err := tx.First(&analysis, id).Error; err != nil {
+		return err
+	}
+	return tx.Model(&analysis).
+		Select("Input", "Result", "Messages").
+		UpdateColumns(&analysis).Error
+}
+
+// reencryptIssuedDocument 宛名を現在の鍵で保存し直す
+func reencryptIssuedDocument(tx *gorm.DB, id uint) error {
+	var doc models.IssuedDocument
+	if err := tx.First(&doc, id).Error; err != nil {
+		return err
+	}
+	return tx.Model(&doc).
+		Select("Addressee").
+		UpdateColumns(&doc).Error
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/hostnote/server/internal/config"
 	"github.com/hostnote/server/internal/database"
+	"github.com/hostnote/server/internal/fieldcrypt"
 	"github.com/hostnote/server/internal/seed"
 )
 
@@ -19,6 +20,10 @@ func main() {
 		log.Fatalf("failed to load config: %v", err)
 	}
 
+	if err := fieldcrypt.Init(config.AppConfig); err != nil {
+		log.Fatalf("failed to initialize field encryption: %v", err)
+	}
+
 	db, err := database.Connect()
 	if err != nil {
 		log.Fatalf("failed to connect to database: %v", err)
@@ -1,23 +1,62 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// RateLimit 一定時間あたりのリクエスト上限
+type RateLimit struct {
+	Limit  int
+	Window time.Duration
+}
+
+// レート制限の既定値
+var (
+	DefaultAuthRateLimit = RateLimit{Limit: 20, Window: time.Minute}
+	DefaultAPIRateLimit  = RateLimit{Limit: 300, Window: time.Minute}
+	DefaultAIRateLimit   = RateLimit{Limit: 30, Window: time.Hour}
+)
+
 type Config struct {
-	DBHost             string
-	DBPort             string
-	DBUser             string
-	DBPassword         string
-	DBName             string
-	Port               string
-	Env                string
-	GoogleClientID     string
-	GoogleClientSecret string
-	GoogleRedirectURL  string
+	DBHost              string
+	DBPort              string
+	DBUser              string
+	DBPassword          string
+	DBName              string
+	Port                string
+	Env                 string
+	GoogleClientID      string
+	GoogleClientSecret  string
+	GoogleRedirectURL   string
+	AccessTokenTTL      time.Duration // アクセストークンの有効期間
+	RefreshTokenTTL     time.Duration // リフレッシュトークン（セッション）の有効期間
+	MailDriver          string        // smtp, file, memory（未設定はdebugモードのみmemoryとして起動）
+	SMTPHost            string
+	SMTPPort            string
+	SMTPUsername        string
+	SMTPPassword        string
+	MailFrom            string
+	MailFileDir         string        // MAIL_DRIVER=file の保存先
+	AuthRateLimit       RateLimit     // 認証系エンドポイントのIPごとの上限
+	APIRateLimit        RateLimit     // 認証済みAPI全体のユーザーごとの上限
+	AIRateLimit         RateLimit     // AI分析（/ai/*）のユーザーごとの上限
+	FieldEncryptionKeys string        // 個人情報カラムの暗号化鍵（"鍵ID:base64,..."、先頭が現在の鍵）
+	BlindIndexKey       string        // 姫の名前検索用ブラインドインデックスの鍵（base64）
+	ExportDir           string        // 個人データエクスポート（ZIP）の保存先
+	ExportTTL           time.Duration // エクスポートしたZIPのダウンロード期限
+	AIProvider          string        // openai, fake, none（未指定の場合はAPIキーがあれば openai）
+	AIBaseURL           string        // OpenAI互換APIのベースURL（自前のサーバーを使う場合に変更）
+	AIAPIKey            string
+	AIModel             string
+	AITimeout           time.Duration // AI呼び出し1回あたりのタイムアウト
+	PDFFontPath         string        // 領収書・請求書PDFに埋め込む日本語フォント（TrueType、未設定は同梱のフォント）
 }
 
 var AppConfig *Config
@@ -35,16 +74,38 @@ func Load() error {
 	googleClientSecret := getEnv("GOOGLE_CLIENT_SECRET", "")
 
 	AppConfig = &Config{
-		DBHost:             getEnv("MYSQL_HOST", "localhost"),
-		DBPort:             getEnv("MYSQL_PORT", "3306"),
-		DBUser:             getEnv("MYSQL_USER", "hostnote"),
-		DBPassword:         getEnv("MYSQL_PASSWORD", "hostnote_dev"),
-		DBName:             getEnv("MYSQL_DATABASE", "hostnote"),
-		Port:               getEnv("PORT", "8080"),
-		Env:                getEnv("GIN_MODE", "debug"),
-		GoogleClientID:     googleClientID,
-		GoogleClientSecret: googleClientSecret,
-		GoogleRedirectURL:  getEnv("GOOGLE_REDIRECT_URL", "http://localhost:8080/api/v1/auth/google/callback"),
+		DBHost:              getEnv("MYSQL_HOST", "localhost"),
+		DBPort:              getEnv("MYSQL_PORT", "3306"),
+		DBUser:              getEnv("MYSQL_USER", "hostnote"),
+		DBPassword:          getEnv("MYSQL_PASSWORD", "hostnote_dev"),
+		DBName:              getEnv("MYSQL_DATABASE", "hostnote"),
+		Port:                getEnv("PORT", "8080"),
+		Env:                 getEnv("GIN_MODE", "debug"),
+		GoogleClientID:      googleClientID,
+		GoogleClientSecret:  googleClientSecret,
+		GoogleRedirectURL:   getEnv("GOOGLE_REDIRECT_URL", "http://localhost:8080/api/v1/auth/google/callback"),
+		AccessTokenTTL:      getDurationEnv("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL:     getDurationEnv("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		MailDriver:          getEnv("MAIL_DRIVER", ""),
+		SMTPHost:            getEnv("SMTP_HOST", ""),
+		SMTPPort:            getEnv("SMTP_PORT", "587"),
+		SMTPUsername:        getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:        getEnv("SMTP_PASSWORD", ""),
+		MailFrom:            getEnv("MAIL_FROM", ""),
+		MailFileDir:         getEnv("MAIL_FILE_DIR", "tmp/mail"),
+		AuthRateLimit:       getRateLimitEnv("RATE_LIMIT_AUTH", DefaultAuthRateLimit),
+		APIRateLimit:        getRateLimitEnv("RATE_LIMIT_API", DefaultAPIRateLimit),
+		AIRateLimit:         getRateLimitEnv("RATE_LIMIT_AI", DefaultAIRateLimit),
+		FieldEncryptionKeys: getEnv("FIELD_ENCRYPTION_KEYS", ""),
+		BlindIndexKey:       getEnv("BLIND_INDEX_KEY", ""),
+		ExportDir:           getEnv("EXPORT_DIR", "tmp/exports"),
+		ExportTTL:           getDurationEnv("EXPORT_TTL", 24*time.Hour),
+		AIProvider:          getEnv("AI_PROVIDER", ""),
+		AIBaseURL:           getEnv("AI_BASE_URL", "https://api.openai.com/v1"),
+		AIAPIKey:            getEnv("OPENAI_API_KEY", getEnv("OPEN_AI_KEY", "")), // 互換性のため OPEN_AI_KEY も見る
+		AIModel:             getEnv("AI_MODEL", "gpt-4o-mini"),
+		AITimeout:           getDurationEnv("AI_TIMEOUT", 60*time.Second),
+		PDFFontPath:         getEnv("PDF_FONT_PATH", ""),
 	}
 
 	// デバッグログ（本番環境では削除）
@@ -63,3 +124,48 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getDurationEnv 環境変数を時間として取得（"15m", "720h" 形式）
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("⚠️  Invalid %s=%q, using default %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return d
+}
+
+// getRateLimitEnv 環境変数をレート制限として取得（"20/1m" 形式、0で無制限）
+func getRateLimitEnv(key string, defaultValue RateLimit) RateLimit {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	limit, err := ParseRateLimit(value)
+	if err != nil {
+		log.Printf("⚠️  Invalid %s=%q (%v), using default %d/%s", key, value, err, defaultValue.Limit, defaultValue.Window)
+		return defaultValue
+	}
+	return limit
+}
+
+// ParseRateLimit "回数/期間" 形式の文字列をパース（例: "30/1h"）
+func ParseRateLimit(value string) (RateLimit, error) {
+	parts := strings.SplitN(value, "/", 2)
+	if len(parts) != 2 {
+		return RateLimit{}, fmt.Errorf("expected <limit>/<window>")
+	}
+	limit, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || limit < 0 {
+		return RateLimit{}, fmt.Errorf("invalid limit %q", parts[0])
+	}
+	window, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+	if err != nil || window <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid window %q", parts[1])
+	}
+	return RateLimit{Limit: limit, Window: window}, nil
+}
@@ -3,10 +3,13 @@ package database
 import (
 	"fmt"
 	"log"
+	"math"
+	"strconv"
 	"strings"
 
 	"github.com/hostnote/server/internal/config"
 	"github.com/hostnote/server/internal/models"
+	"github.com/hostnote/server/internal/services"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -59,11 +62,26 @@ func Migrate(db *gorm.DB) error {
 		&models.TableRecord{},
 		&models.TableHime{},
 		&models.TableCast{},
+		&models.TableOrder{},
+		&models.IssuedDocument{},
 		&models.Schedule{},
 		&models.VisitRecord{},
 		&models.Setting{},
 		&models.PushToken{},
 		&models.Menu{},
+		&models.Store{},
+		&models.StoreMember{},
+		&models.StoreInvitation{},
+		&models.Session{},
+		&models.RecoveryCode{},
+		&models.PasswordResetToken{},
+		&models.LoginCode{},
+		&models.AuditLog{},
+		&models.ExportJob{},
+		&models.AIAnalysis{},
+		&models.AIUsage{},
+		&models.PricingRule{},
+		&models.Payment{},
 	); err != nil {
 		return fmt.Errorf("failed to auto migrate: %w", err)
 	}
@@ -74,6 +92,16 @@ func Migrate(db *gorm.DB) error {
 		log.Printf("Warning: failed to migrate existing data (this is normal for new deployments): %v", err)
 	}
 
+	// 既存データを店舗に移行
+	if err := migrateStoreScope(db); err != nil {
+		return fmt.Errorf("failed to migrate store scope: %w", err)
+	}
+
+	// 料金ルール導入前の料金設定（billing_*）を料金ルールに移行
+	if err := migrateBillingSettings(db); err != nil {
+		return fmt.Errorf("failed to migrate billing settings: %w", err)
+	}
+
 	// オプション処理（エラーが発生しても続行）
 	_ = removeMenuUserID(db)                // menuテーブルからuser_idカラムを削除
 	_ = makePasswordNullable(db)            // userテーブルのpasswordカラムをNULL許可に変更
@@ -465,3 +493,153 @@ func addOAuthAccountUniqueConstraint(db *gorm.DB) error {
 
 	return nil
 }
+
+// DefaultStore デフォルト店舗を取得（存在しない場合は作成）
+func DefaultStore(db *gorm.DB) (*models.Store, error) {
+	var store models.Store
+	if err := db.Order("id").First(&store).Error; err == nil {
+		return &store, nil
+	} else if err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	store = models.Store{Name: "デフォルト店舗"}
+	if err := db.Create(&store).Error; err != nil {
+		return nil, err
+	}
+	return &store, nil
+}
+
+// migrateStoreScope 既存のユーザー・メニュー・設定・キャストをデフォルト店舗に移行
+func migrateStoreScope(db *gorm.DB) error {
+	store, err := DefaultStore(db)
+	if err != nil {
+		return fmt.Errorf("failed to ensure default store: %w", err)
+	}
+
+	// 店舗に所属していないユーザーをデフォルト店舗に所属させる
+	if err := db.Exec(`
+		INSERT INTO store_member (store_id, user_id, created_at, updated_at)
+		SELECT ?, u.id, NOW(), NOW() FROM `+"`user`"+` u
+		LEFT JOIN store_member sm ON sm.user_id = u.id
+		WHERE sm.id IS NULL AND u.deleted_at IS NULL
+	`, store.ID).Error; err != nil {
+		return fmt.Errorf("failed to migrate store members: %w", err)
+	}
+
+	// 店舗未設定のメニュー・設定をデフォルト店舗に移行
+	for _, tableName := range []string{"menu", "setting"} {
+		query := fmt.Sprintf("UPDATE `%s` SET `store_id` = ? WHERE `store_id` = 0 OR `store_id` IS NULL", tableName)
+		if err := db.Exec(query, store.ID).Error; err != nil {
+			return fmt.Errorf("failed to migrate %s store_id: %w", tableName, err)
+		}
+	}
+
+	// キャストは作成ユーザーの所属店舗に移行
+	if err := db.Exec(`
+		UPDATE `+"`cast`"+` c
+		LEFT JOIN store_member sm ON sm.user_id = c.user_id
+		SET c.store_id = COALESCE(sm.store_id, ?)
+		WHERE c.store_id IS NULL
+	`, store.ID).Error; err != nil {
+		return fmt.Errorf("failed to migrate cast store_id: %w", err)
+	}
+
+	// settingの主キーを (store_id, key) に変更
+	return migrateSettingPrimaryKey(db)
+}
+
+// migrateSettingPrimaryKey settingテーブルの主キーにstore_idが含まれていない場合は付け替える
+func migrateSettingPrimaryKey(db *gorm.DB) error {
+	var count int64
+	query := `SELECT COUNT(*) FROM information_schema.KEY_COLUMN_USAGE
+		WHERE TABLE_SCHEMA = DATABASE()
+		AND TABLE_NAME = 'setting'
+		AND CONSTRAINT_NAME = 'PRIMARY'
+		AND COLUMN_NAME = 'store_id'`
+	if err := db.Raw(query).Scan(&count).Error; err != nil {
+		return fmt.Errorf("failed to check setting primary key: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	if err := db.Exec("ALTER TABLE `setting` DROP PRIMARY KEY, ADD PRIMARY KEY (`store_id`, `key`)").Error; err != nil {
+		return fmt.Errorf("failed to change setting primary key: %w", err)
+	}
+	return nil
+}
+
+// 料金ルール導入前に店舗の設定に保存していた料金設定のキー
+const (
+	legacyBillingTableChargePerHour = "billing_table_charge_per_hour" // 1時間あたりのテーブルチャージ
+	legacyBillingShimeiFee          = "billing_shimei_fee"            // 指名料
+	legacyBillingServiceChargeRate  = "billing_service_charge_rate"   // サービス料率（%）
+	legacyBillingTaxRate            = "billing_tax_rate"              // 消費税率（%）
+)
+
+// migrateBillingSettings 料金設定（billing_*）から店舗ごとに最初の料金ルールを作成し、設定を削除
+// 1時間あたりのテーブルチャージは、60分のセット料金・60分単位の延長料金として引き継ぐ
+// すでに料金ルールがある店舗は、そのルールを優先して設定の削除のみ行う
+func migrateBillingSettings(db *gorm.DB) error {
+	keys := []string{legacyBillingTableChargePerHour, legacyBillingShimeiFee, legacyBillingServiceChargeRate, legacyBillingTaxRate}
+	var settings []models.Setting
+	if err := db.Where("`key` IN ?", keys).Find(&settings).Error; err != nil {
+		return fmt.Errorf("failed to load billing settings: %w", err)
+	}
+	if len(settings) == 0 {
+		return nil
+	}
+
+	byStore := make(map[uint][]models.Setting)
+	for _, s := range settings {
+		byStore[s.StoreID] = append(byStore[s.StoreID], s)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for storeID, storeSettings := range byStore {
+			var count int64
+			if err := tx.Model(&models.PricingRule{}).Where("store_id = ?", storeID).Count(&count).Error; err != nil {
+				return fmt.Errorf("failed to check pricing rules for store %d: %w", storeID, err)
+			}
+			if count > 0 {
+				continue
+			}
+
+			// 未設定・不正な値の項目は既定値（従来の計算と同じ）
+			rule := services.DefaultPricingRule(storeID)
+			rule.Version = 1
+			for _, s := range storeSettings {
+				v, err := strconv.ParseFloat(strings.TrimSpace(s.Value), 64)
+				if err != nil || v < 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+					log.Printf("Invalid billing setting %s=%q for store %d, using default", s.Key, s.Value, s.StoreID)
+					continue
+				}
+				switch s.Key {
+				case legacyBillingTableChargePerHour:
+					rule.SetPriceNormal = v
+					rule.SetPriceFirst = v
+					rule.SetPriceShimei = v
+					rule.ExtensionPrice = v
+				case legacyBillingShimeiFee:
+					rule.ShimeiFee = v
+				case legacyBillingServiceChargeRate:
+					rule.ServiceChargeRate = math.Min(v, 100)
+				case legacyBillingTaxRate:
+					rule.TaxRate = math.Min(v, 100)
+				}
+			}
+			if err := services.ValidatePricingRule(rule); err != nil {
+				return fmt.Errorf("invalid pricing rule for store %d: %w", storeID, err)
+			}
+			if err := tx.Create(rule).Error; err != nil {
+				return fmt.Errorf("failed to create pricing rule for store %d: %w", storeID, err)
+			}
+		}
+
+		if err := tx.Where("`key` IN ?", keys).Delete(&models.Setting{}).Error; err != nil {
+			return fmt.Errorf("failed to delete billing settings: %w", err)
+		}
+		return nil
+	})
+}
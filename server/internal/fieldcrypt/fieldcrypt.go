@@ -0,0 +1,315 @@
+// Package fieldcrypt DBに保存する個人情報カラムのエンベロープ暗号化とブラインドインデックス
+//
+// 値ごとにランダムなデータ鍵（DEK）を生成してAES-256-GCMで暗号化し、
+// DEK自体を設定で与えたマスター鍵（KEK）で暗号化して一緒に保存する。
+// 保存形式: enc:v1:<鍵ID>:<暗号化したDEK>:<暗号文>（いずれもbase64url）
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/hostnote/server/internal/config"
+)
+
+// prefix 暗号化済みの値の先頭に付ける識別子
+const prefix = "enc:v1:"
+
+// keySize マスター鍵・データ鍵・インデックス鍵の長さ（AES-256）
+const keySize = 32
+
+var (
+	// ErrUnknownKey 暗号化に使われた鍵が設定にない
+	ErrUnknownKey = errors.New("fieldcrypt: unknown key id")
+	// ErrMalformed 暗号化済みの値の形式が不正
+	ErrMalformed = errors.New("fieldcrypt: malformed ciphertext")
+)
+
+// Key マスター鍵
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// Keyring 復号に使えるマスター鍵の一覧と、暗号化に使う現在の鍵
+type Keyring struct {
+	keys     map[string][]byte
+	activeID string
+	indexKey []byte
+}
+
+var (
+	mu      sync.RWMutex
+	keyring *Keyring
+)
+
+// NewKeyring 鍵の一覧から作成（先頭の鍵で暗号化し、残りは復号のみに使う）
+func NewKeyring(keys []Key, indexKey []byte) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("fieldcrypt: at least one key is required")
+	}
+	if len(indexKey) != keySize {
+		return nil, fmt.Errorf("fieldcrypt: blind index key must be %d bytes", keySize)
+	}
+
+	k := &Keyring{keys: make(map[string][]byte, len(keys)), activeID: keys[0].ID, indexKey: indexKey}
+	for _, key := range keys {
+		if key.ID == "" || strings.Contains(key.ID, ":") {
+			return nil, fmt.Errorf("fieldcrypt: invalid key id %q", key.ID)
+		}
+		if len(key.Secret) != keySize {
+			return nil, fmt.Errorf("fieldcrypt: key %q must be %d bytes", key.ID, keySize)
+		}
+		if _, dup := k.keys[key.ID]; dup {
+			return nil, fmt.Errorf("fieldcrypt: duplicate key id %q", key.ID)
+		}
+		k.keys[key.ID] = key.Secret
+	}
+	return k, nil
+}
+
+// ParseKeys "鍵ID:base64鍵,鍵ID:base64鍵" 形式の文字列をパース
+func ParseKeys(spec string) ([]Key, error) {
+	var keys []Key
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("fieldcrypt: expected <id>:<base64 key>, got %q", part)
+		}
+		secret, err := decodeKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("fieldcrypt: key %q: %w", id, err)
+		}
+		keys = append(keys, Key{ID: strings.TrimSpace(id), Secret: secret})
+	}
+	return keys, nil
+}
+
+// Init 設定から鍵を読み込む
+// 鍵が未設定の場合は暗号化せずに保存する（開発環境向け、起動時に警告を出す）
+func Init(cfg *config.Config) error {
+	if cfg.FieldEncryptionKeys == "" && cfg.BlindIndexKey == "" {
+		log.Printf("⚠️  Field encryption not configured (FIELD_ENCRYPTION_KEYS is empty), personal data is stored in plaintext")
+		SetKeyring(nil)
+		return nil
+	}
+	if cfg.FieldEncryptionKeys == "" || cfg.BlindIndexKey == "" {
+		return errors.New("FIELD_ENCRYPTION_KEYS and BLIND_INDEX_KEY must be set together")
+	}
+
+	keys, err := ParseKeys(cfg.FieldEncryptionKeys)
+	if err != nil {
+		return err
+	}
+	indexKey, err := decodeKey(cfg.BlindIndexKey)
+	if err != nil {
+		return fmt.Errorf("fieldcrypt: BLIND_INDEX_KEY: %w", err)
+	}
+	k, err := NewKeyring(keys, indexKey)
+	if err != nil {
+		return err
+	}
+	SetKeyring(k)
+	return nil
+}
+
+// SetKeyring 使用する鍵を差し替える（nilで暗号化を無効化、テスト用）
+func SetKeyring(k *Keyring) {
+	mu.Lock()
+	defer mu.Unlock()
+	keyring = k
+}
+
+func current() *Keyring {
+	mu.RLock()
+	defer mu.RUnlock()
+	return keyring
+}
+
+// Enabled 暗号化が有効か
+func Enabled() bool {
+	return current() != nil
+}
+
+// ActiveKeyID 暗号化に使う鍵のID（無効の場合は空）
+func ActiveKeyID() string {
+	if k := current(); k != nil {
+		return k.activeID
+	}
+	return ""
+}
+
+// Encrypt 現在の鍵で暗号化（無効の場合は平文のまま返す）
+func Encrypt(plaintext []byte) (string, error) {
+	k := current()
+	if k == nil {
+		return string(plaintext), nil
+	}
+	return k.Encrypt(plaintext)
+}
+
+// Decrypt 暗号化済みの値を復号（暗号化前の平文はそのまま返す）
+func Decrypt(value string) ([]byte, error) {
+	if !IsEncrypted(value) {
+		return []byte(value), nil
+	}
+	k := current()
+	if k == nil {
+		return nil, ErrUnknownKey
+	}
+	return k.Decrypt(value)
+}
+
+// IsEncrypted 暗号化済みの値か
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// KeyID 暗号化に使われた鍵のID（平文の場合は空）
+func KeyID(value string) string {
+	if !IsEncrypted(value) {
+		return ""
+	}
+	id, _, _ := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	return id
+}
+
+// NeedsReencrypt 現在の鍵で暗号化し直す必要があるか（平文または古い鍵）
+func NeedsReencrypt(value string) bool {
+	active := ActiveKeyID()
+	return active != "" && KeyID(value) != active
+}
+
+// BlindIndex 検索用のブラインドインデックス（正規化した値のHMAC-SHA256）
+// 暗号化が無効の場合も同じ形式で計算し、有効化後は再暗号化コマンドで作り直す
+func BlindIndex(value string) string {
+	var key []byte
+	if k := current(); k != nil {
+		key = k.indexKey
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(normalize(value)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Encrypt 値ごとのデータ鍵で暗号化し、データ鍵を現在のマスター鍵で暗号化する
+func (k *Keyring) Encrypt(plaintext []byte) (string, error) {
+	dek := make([]byte, keySize)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+	wrapped, err := seal(k.keys[k.activeID], dek, []byte(k.activeID))
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(dek, plaintext, nil)
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	return prefix + k.activeID + ":" + enc.EncodeToString(wrapped) + ":" + enc.EncodeToString(ciphertext), nil
+}
+
+// Decrypt 暗号化に使われた鍵でデータ鍵を復号し、値を復号する
+func (k *Keyring) Decrypt(value string) ([]byte, error) {
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+	kek, ok := k.keys[parts[0]]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, parts[0])
+	}
+
+	enc := base64.RawURLEncoding
+	wrapped, err := enc.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	ciphertext, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	dek, err := open(kek, wrapped, []byte(parts[0]))
+	if err != nil {
+		return nil, err
+	}
+	return open(dek, ciphertext, nil)
+}
+
+// seal AES-256-GCMで暗号化（先頭にnonceを付ける）
+func seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(plaintext)+gcm.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open sealで暗号化した値を復号
+func open(key, data, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, ErrMalformed
+	}
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("fieldcrypt: decrypt failed: %w", err)
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// decodeKey base64（標準・URLセーフどちらでも可）の鍵をデコード
+func decodeKey(encoded string) ([]byte, error) {
+	encoded = strings.TrimSpace(encoded)
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if key, err := enc.DecodeString(encoded); err == nil {
+			if len(key) != keySize {
+				return nil, fmt.Errorf("must be %d bytes, got %d", keySize, len(key))
+			}
+			return key, nil
+		}
+	}
+	return nil, errors.New("invalid base64")
+}
+
+// normalize 検索で表記ゆれを吸収するため、前後・途中の空白（全角含む）を除き小文字にする
+func normalize(value string) string {
+	return strings.ToLower(strings.Join(strings.FieldsFunc(value, isSpace), ""))
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '　'
+}
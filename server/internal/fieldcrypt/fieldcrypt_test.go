@@ -0,0 +1,133 @@
+package fieldcrypt
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, keySize)
+}
+
+// TestEncryptDecrypt 暗号化した値が復号でき、鍵のローテーション後も古い鍵で復号できることをテスト
+func TestEncryptDecrypt(t *testing.T) {
+	old, err := NewKeyring([]Key{{ID: "1", Secret: testKey(1)}}, testKey(9))
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	SetKeyring(old)
+	defer SetKeyring(nil)
+
+	encrypted, err := Encrypt([]byte("山田 花子"))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if !IsEncrypted(encrypted) || KeyID(encrypted) != "1" {
+		t.Fatalf("Encrypt() = %q, want enc:v1:1:...", encrypted)
+	}
+	if again, _ := Encrypt([]byte("山田 花子")); again == encrypted {
+		t.Error("同じ平文の暗号文が一致してはいけない")
+	}
+
+	// 鍵2を追加して現在の鍵にする
+	rotated, err := NewKeyring([]Key{{ID: "2", Secret: testKey(2)}, {ID: "1", Secret: testKey(1)}}, testKey(9))
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	SetKeyring(rotated)
+
+	got, err := Decrypt(encrypted)
+	if err != nil || string(got) != "山田 花子" {
+		t.Fatalf("Decrypt() = %q, %v", got, err)
+	}
+	if !NeedsReencrypt(encrypted) {
+		t.Error("古い鍵で暗号化した値は再暗号化が必要")
+	}
+	if !NeedsReencrypt("平文") {
+		t.Error("平文は再暗号化が必要")
+	}
+
+	// 鍵1を外すと復号できない
+	latest, _ := NewKeyring([]Key{{ID: "2", Secret: testKey(2)}}, testKey(9))
+	SetKeyring(latest)
+	if _, err := Decrypt(encrypted); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Decrypt() error = %v, want ErrUnknownKey", err)
+	}
+}
+
+// TestDecryptPlaintext 暗号化前の平文はそのまま返されることをテスト
+func TestDecryptPlaintext(t *testing.T) {
+	SetKeyring(nil)
+	for _, value := range []string{"", "山田", `[{"id":"1"}]`} {
+		got, err := Decrypt(value)
+		if err != nil || string(got) != value {
+			t.Errorf("Decrypt(%q) = %q, %v", value, got, err)
+		}
+	}
+	if encrypted, _ := Encrypt([]byte("山田")); encrypted != "山田" {
+		t.Errorf("暗号化が無効の場合は平文のまま: got %q", encrypted)
+	}
+}
+
+// TestDecryptTampered 改ざんされた値は復号できないことをテスト
+func TestDecryptTampered(t *testing.T) {
+	k, _ := NewKeyring([]Key{{ID: "1", Secret: testKey(1)}}, testKey(9))
+	encrypted, err := k.Encrypt([]byte("secret"))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	tampered := encrypted[:len(encrypted)-2] + "AA"
+	if tampered == encrypted {
+		tampered = encrypted[:len(encrypted)-2] + "BB"
+	}
+	if _, err := k.Decrypt(tampered); err == nil {
+		t.Error("改ざんされた値が復号できてしまった")
+	}
+	if _, err := k.Decrypt("enc:v1:1:broken"); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Decrypt() error = %v, want ErrMalformed", err)
+	}
+}
+
+// TestBlindIndex 空白・大文字小文字の違いを吸収し、鍵が異なれば値も異なることをテスト
+func TestBlindIndex(t *testing.T) {
+	k, _ := NewKeyring([]Key{{ID: "1", Secret: testKey(1)}}, testKey(9))
+	SetKeyring(k)
+	defer SetKeyring(nil)
+
+	base := BlindIndex("山田 花子")
+	for _, v := range []string{"山田花子", " 山田　花子 ", "山田\t花子"} {
+		if got := BlindIndex(v); got != base {
+			t.Errorf("BlindIndex(%q) = %s, want %s", v, got, base)
+		}
+	}
+	if BlindIndex("Aiko") != BlindIndex("aiko") {
+		t.Error("大文字小文字は区別しない")
+	}
+	if BlindIndex("山田花子") == BlindIndex("山田花") {
+		t.Error("異なる名前のインデックスが一致した")
+	}
+
+	other, _ := NewKeyring([]Key{{ID: "1", Secret: testKey(1)}}, testKey(8))
+	SetKeyring(other)
+	if BlindIndex("山田花子") == base {
+		t.Error("インデックス鍵が異なれば値も異なる")
+	}
+}
+
+// TestParseKeys 鍵の設定文字列のパースをテスト
+func TestParseKeys(t *testing.T) {
+	keys, err := ParseKeys("2:AgICAgICAgICAgICAgICAgICAgICAgICAgICAgICAgI=, 1:AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE")
+	if err != nil {
+		t.Fatalf("ParseKeys: %v", err)
+	}
+	if len(keys) != 2 || keys[0].ID != "2" || keys[1].ID != "1" || !bytes.Equal(keys[1].Secret, testKey(1)) {
+		t.Errorf("ParseKeys() = %+v", keys)
+	}
+
+	for _, spec := range []string{"nokey", "1:short", "1:!!!"} {
+		if _, err := ParseKeys(spec); err == nil {
+			t.Errorf("ParseKeys(%q) should fail", spec)
+		}
+	}
+}
@@ -1,10 +1,17 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hostnote/server/internal/models"
 	"github.com/hostnote/server/internal/services"
 	"gorm.io/gorm"
 )
@@ -24,28 +31,90 @@ type AnalyzeRequest struct {
 }
 
 type AnalyzeResponse struct {
-	Result string `json:"result"`
+	AnalysisID   uint                     `json:"analysisId,omitempty"` // 保存した分析（追加の質問に使う）
+	Result       string                   `json:"result"`
+	AnalysisType string                   `json:"analysisType"`
+	Period       string                   `json:"period"`
+	Summary      services.AnalysisSummary `json:"summary"`
 }
 
 // Analyze AI分析を実行
+// 姫（省略時は担当する姫全体）の期間内の来店・卓記録・売上・メモ・好みをもとに分析する
 func (h *AIHandler) Analyze(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+
 	var req AnalyzeRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if req.Period == "" {
+		req.Period = services.AnalysisPeriodMonth
+	}
 
-	// TODO: 実際のAI分析ロジックを実装
-	// 現在はモックレスポンスを返す
-	result := "AI分析結果がここに表示されます。\n\nサーバー側の実装が完了次第、実際の分析結果を返します。"
+	input := services.CustomerAnalysisInput{
+		UserID:       userID,
+		AnalysisType: req.AnalysisType,
+		Period:       req.Period,
+		Now:          time.Now(),
+	}
+	if req.HimeID != nil {
+		if *req.HimeID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid himeId"})
+			return
+		}
+		himeID := uint(*req.HimeID)
+		input.HimeID = &himeID
+	}
+
+	ctx := c.Request.Context()
+	analysis, err := services.CollectCustomerAnalysis(ctx, h.db, input)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidAnalysisType) || errors.Is(err, services.ErrInvalidAnalysisPeriod) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		handleDBError(c, err, "Hime not found")
+		return
+	}
+
+	if !h.checkAIQuota(c, userID) {
+		return
+	}
+	redactor, ok := h.redactor(c, userID)
+	if !ok {
+		return
+	}
+	llmReq := analysis.Request()
+	result, err := services.ChatRedacted(ctx, redactor, llmReq)
+	if err != nil {
+		respondAIError(c, err)
+		return
+	}
+	h.recordAIUsage(c, userID, result)
+
+	saved := h.saveAnalysis(c, services.NewAIAnalysis(userID, input.HimeID, models.AIAnalysisKindCustomer, map[string]interface{}{
+		"analysisType": req.AnalysisType,
+		"period":       req.Period,
+		"summary":      analysis.Summary,
+	}, llmReq, result, time.Now()))
 
 	c.JSON(http.StatusOK, AnalyzeResponse{
-		Result: result,
+		AnalysisID:   saved,
+		Result:       result.Content,
+		AnalysisType: req.AnalysisType,
+		Period:       req.Period,
+		Summary:      analysis.Summary,
 	})
 }
 
 // ConversationAnalyzeRequest は会話ログ分析用のリクエスト
 type ConversationAnalyzeRequest struct {
+	HimeID         *int   `json:"himeId"`                            // 分析を紐付ける姫（省略可）
 	SelfProfile    string `json:"selfProfile" binding:"required"`    // 自分（ホスト）の情報
 	PartnerProfile string `json:"partnerProfile" binding:"required"` // 相手（姫）の情報
 	Goal           string `json:"goal"`                              // この状況からどうしたいか・目標
@@ -55,40 +124,242 @@ type ConversationAnalyzeRequest struct {
 
 // ConversationAnalyzeResponse は会話ログ分析の応答
 type ConversationAnalyzeResponse struct {
-	Result string `json:"result"`
+	AnalysisID uint                `json:"analysisId,omitempty"` // 保存した分析（追加の質問に使う）
+	Result     string              `json:"result"`
+	ChatStats  *services.ChatStats `json:"chatStats,omitempty"` // LINEのトーク履歴として読み込めた場合の集計
 }
 
 // AnalyzeConversation は会話ログをもとにAI分析を実行する
 func (h *AIHandler) AnalyzeConversation(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+
 	var req ConversationAnalyzeRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// リクエスト内容をログ出力（デバッグ用）
-	log.Printf("[AIConversation] request: selfProfile=%q partnerProfile=%q goal=%q extraInfo=%q chatLogLen=%d",
-		req.SelfProfile,
-		req.PartnerProfile,
-		req.Goal,
-		req.ExtraInfo,
-		len(req.ChatLog),
-	)
+	himeID, ok := h.conversationHimeID(c, userID, req.HimeID)
+	if !ok {
+		return
+	}
+	if !h.checkAIQuota(c, userID) {
+		return
+	}
+	redactor, ok := h.redactor(c, userID)
+	if !ok {
+		return
+	}
 
 	ctx := c.Request.Context()
-	result, err := services.AnalyzeConversationWithOpenAI(ctx, services.ConversationAnalysisInput{
-		SelfProfile:    req.SelfProfile,
-		PartnerProfile: req.PartnerProfile,
-		Goal:           req.Goal,
-		ExtraInfo:      req.ExtraInfo,
-		ChatLog:        req.ChatLog,
-	})
+	llmReq, stats := services.ConversationAnalysisRequest(req.input())
+	result, err := services.ChatRedacted(ctx, redactor, llmReq)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondAIError(c, err)
 		return
 	}
+	h.recordAIUsage(c, userID, result)
 
 	c.JSON(http.StatusOK, ConversationAnalyzeResponse{
-		Result: result,
+		AnalysisID: h.saveAnalysis(c, services.NewAIAnalysis(userID, himeID, models.AIAnalysisKindConversation, req.inputMap(stats), llmReq, result, time.Now())),
+		Result:     result.Content,
+		ChatStats:  stats,
 	})
 }
+
+// AnalyzeConversationStream 会話ログの分析結果をServer-Sent Eventsで生成されたそばから返す
+// イベントは delta（{"content": 断片}）を繰り返し、最後に done（{"analysisId": ID, "result": 全文}）
+// 送信開始後に失敗した場合は error（{"error": メッセージ}）を送って終了する
+func (h *AIHandler) AnalyzeConversationStream(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+
+	var req ConversationAnalyzeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	himeID, ok := h.conversationHimeID(c, userID, req.HimeID)
+	if !ok {
+		return
+	}
+	if !h.checkAIQuota(c, userID) {
+		return
+	}
+	redactor, ok := h.redactor(c, userID)
+	if !ok {
+		return
+	}
+
+	// クライアントが切断すると ctx がキャンセルされ、AIへのリクエストも中断される
+	ctx := c.Request.Context()
+	llmReq, stats := services.ConversationAnalysisRequest(req.input())
+	started := false
+	result, err := services.ChatStreamRedacted(ctx, redactor, llmReq, func(delta string) error {
+		if !started {
+			startSSE(c)
+			started = true
+		}
+		c.SSEvent("delta", gin.H{"content": delta})
+		c.Writer.Flush()
+		return ctx.Err()
+	})
+	if err != nil {
+		// 送信前であれば通常のJSONでエラーを返す
+		if !started {
+			respondAIError(c, err)
+			return
+		}
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		log.Printf("AI stream failed: %v", err)
+		c.SSEvent("error", gin.H{"error": "AI分析に失敗しました。しばらくしてから再度お試しください"})
+		c.Writer.Flush()
+		return
+	}
+
+	h.recordAIUsage(c, userID, result)
+	analysisID := h.saveAnalysis(c, services.NewAIAnalysis(userID, himeID, models.AIAnalysisKindConversation, req.inputMap(stats), llmReq, result, time.Now()))
+	if !started {
+		startSSE(c)
+	}
+	c.SSEvent("done", ConversationAnalyzeResponse{AnalysisID: analysisID, Result: result.Content, ChatStats: stats})
+	c.Writer.Flush()
+}
+
+// conversationHimeID 分析を紐付ける姫が自分の姫か確認する
+func (h *AIHandler) conversationHimeID(c *gin.Context, userID uint, himeID *int) (*uint, bool) {
+	if himeID == nil {
+		return nil, true
+	}
+	if *himeID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid himeId"})
+		return nil, false
+	}
+	var hime models.Hime
+	if err := h.db.Select("id").Where("id = ? AND user_id = ?", *himeID, userID).First(&hime).Error; err != nil {
+		handleDBError(c, err, "Hime not found")
+		return nil, false
+	}
+	return &hime.ID, true
+}
+
+// redactor AIに送る前に伏せる個人情報の検出器を作成（自分の姫の名前も伏せる）
+func (h *AIHandler) redactor(c *gin.Context, userID uint) (*services.Redactor, bool) {
+	names, err := services.HimeNames(c.Request.Context(), h.db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return services.NewRedactor(names), true
+}
+
+// checkAIQuota 今月のAI利用量が上限に達していないか確認する（達している場合は429を返す）
+func (h *AIHandler) checkAIQuota(c *gin.Context, userID uint) bool {
+	status, err := services.CheckAIQuota(c.Request.Context(), h.db, userID, time.Now())
+	if errors.Is(err, services.ErrAIQuotaExceeded) {
+		respondAIQuotaExceeded(c, status)
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// recordAIUsage AIの応答で消費したトークン数を今月の利用量に加算する
+// 記録に失敗しても結果は返したいため、ログに残すだけにする
+func (h *AIHandler) recordAIUsage(c *gin.Context, userID uint, result *services.LLMResponse) {
+	if err := services.RecordAIUsage(c.Request.Context(), h.db, userID, result, time.Now()); err != nil {
+		log.Printf("Failed to record AI usage for user %d: %v", userID, err)
+	}
+}
+
+// saveAnalysis 分析結果を保存してIDを返す
+// 保存に失敗しても分析結果は返したいため、ログに残して0を返す
+func (h *AIHandler) saveAnalysis(c *gin.Context, analysis *models.AIAnalysis) uint {
+	if err := h.db.WithContext(c).Create(analysis).Error; err != nil {
+		log.Printf("Failed to save AI analysis: %v", err)
+		return 0
+	}
+	return analysis.ID
+}
+
+// input サービス層の入力に変換
+func (r *ConversationAnalyzeRequest) input() services.ConversationAnalysisInput {
+	return services.ConversationAnalysisInput{
+		SelfProfile:    r.SelfProfile,
+		PartnerProfile: r.PartnerProfile,
+		Goal:           r.Goal,
+		ExtraInfo:      r.ExtraInfo,
+		ChatLog:        r.ChatLog,
+	}
+}
+
+// inputMap 保存する入力内容（トーク履歴の集計があれば一緒に残す）
+func (r *ConversationAnalyzeRequest) inputMap(stats *services.ChatStats) map[string]interface{} {
+	input := map[string]interface{}{
+		"selfProfile":    r.SelfProfile,
+		"partnerProfile": r.PartnerProfile,
+		"goal":           r.Goal,
+		"extraInfo":      r.ExtraInfo,
+		"chatLog":        r.ChatLog,
+	}
+	if stats != nil {
+		input["chatStats"] = stats
+	}
+	return input
+}
+
+// startSSE Server-Sent Eventsのレスポンスヘッダーを送る（プロキシでのバッファリングも無効化）
+func startSSE(c *gin.Context) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+}
+
+// respondAIQuotaExceeded 今月のAI利用量の上限に達したエラー（429）を、翌月1日までのRetry-After付きで返す
+func respondAIQuotaExceeded(c *gin.Context, status *services.AIUsageStatus) {
+	message := fmt.Sprintf("今月のAI利用回数の上限（%d回）に達しました。", status.Quota.MonthlyRequests)
+	if status.TokensExceeded() {
+		message = fmt.Sprintf("今月のAI利用量の上限（%dトークン）に達しました。", status.Quota.MonthlyTokens)
+	}
+	message += status.ResetAt.Format("1月2日") + "から再び利用できます"
+
+	seconds := int(math.Ceil(time.Until(status.ResetAt).Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":      message,
+		"retryAfter": seconds,
+		"usage":      status,
+	})
+}
+
+// respondAIError AI呼び出しのエラーを返す（未設定の場合は503）
+func respondAIError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrAIProviderNotConfigured) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "AI分析が利用できません。サーバーにAIのAPIキーが設定されていません"})
+		return
+	}
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	log.Printf("AI request failed: %v", err)
+	c.JSON(http.StatusBadGateway, gin.H{"error": "AI分析に失敗しました。しばらくしてから再度お試しください"})
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hostnote/server/internal/models"
+	"github.com/hostnote/server/internal/services"
+)
+
+// AIAnalysisResponse 保存したAI分析（会話履歴付き）
+type AIAnalysisResponse struct {
+	models.AIAnalysis
+	Messages []models.AIMessage `json:"messages"`
+}
+
+// FollowUpRequest 保存した分析への追加の質問
+type FollowUpRequest struct {
+	Message string `json:"message" binding:"required"`
+}
+
+// FollowUpResponse 追加の質問への回答と更新後の分析
+type FollowUpResponse struct {
+	Reply    string             `json:"reply"`
+	Analysis AIAnalysisResponse `json:"analysis"`
+}
+
+// ListAnalyses 保存したAI分析の一覧を取得（新しい順、会話履歴は含まない）
+// himeId で姫ごと、kind で種類（customer, conversation）ごとに絞り込める
+// 総件数はX-Total-Countヘッダーで返す
+func (h *AIHandler) ListAnalyses(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+
+	query := h.db.Model(&models.AIAnalysis{}).Where("user_id = ?", userID)
+	if himeIDStr := c.Query("himeId"); himeIDStr != "" {
+		himeID := parseInt(himeIDStr)
+		if himeID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid himeId"})
+			return
+		}
+		query = query.Where("hime_id = ?", himeID)
+	}
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// ページネーション
+	limit := 100 // デフォルトは100件
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if parsedLimit := parseInt(limitStr); parsedLimit > 0 && parsedLimit <= 500 {
+			limit = parsedLimit
+		}
+	}
+	offset := 0
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if parsedOffset := parseInt(offsetStr); parsedOffset >= 0 {
+			offset = parsedOffset
+		}
+	}
+
+	var analyses []models.AIAnalysis
+	if err := query.Omit("messages").
+		Order("created_at DESC, id DESC").
+		Limit(limit).Offset(offset).
+		Find(&analyses).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	c.JSON(http.StatusOK, analyses)
+}
+
+// GetAnalysis 保存したAI分析を会話履歴付きで取得
+func (h *AIHandler) GetAnalysis(c *gin.Context) {
+	analysis, ok := h.findAnalysis(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, analysisResponse(analysis))
+}
+
+// FollowUp 保存した分析に追加で質問する（それまでの会話を踏まえて回答する）
+func (h *AIHandler) FollowUp(c *gin.Context) {
+	analysis, ok := h.findAnalysis(c)
+	if !ok {
+		return
+	}
+
+	var req FollowUpRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	message := strings.TrimSpace(req.Message)
+	if message == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "質問を入力してください"})
+		return
+	}
+	if utf8.RuneCountInString(message) > services.AIFollowUpMaxLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "質問が長すぎます"})
+		return
+	}
+
+	if !h.checkAIQuota(c, analysis.UserID) {
+		return
+	}
+	redactor, ok := h.redactor(c, analysis.UserID)
+	if !ok {
+		return
+	}
+	result, err := services.ChatRedacted(c.Request.Context(), redactor, services.FollowUpRequest(analysis, message))
+	if err != nil {
+		respondAIError(c, err)
+		return
+	}
+	h.recordAIUsage(c, analysis.UserID, result)
+
+	services.AppendAIFollowUp(analysis, message, result, time.Now())
+	if err := h.db.WithContext(c).Model(analysis).Updates(map[string]interface{}{
+		"messages":          analysis.Messages,
+		"model":             analysis.Model,
+		"prompt_tokens":     analysis.PromptTokens,
+		"completion_tokens": analysis.CompletionTokens,
+		"total_tokens":      analysis.TotalTokens,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, FollowUpResponse{Reply: result.Content, Analysis: analysisResponse(analysis)})
+}
+
+// DeleteAnalysis 保存したAI分析を削除
+func (h *AIHandler) DeleteAnalysis(c *gin.Context) {
+	analysis, ok := h.findAnalysis(c)
+	if !ok {
+		return
+	}
+	if err := h.db.WithContext(c).Delete(analysis).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Deleted"})
+}
+
+// findAnalysis 自分のAI分析を取得
+func (h *AIHandler) findAnalysis(c *gin.Context) (*models.AIAnalysis, bool) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return nil, false
+	}
+
+	id, err := parseID(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return nil, false
+	}
+
+	var analysis models.AIAnalysis
+	if err := h.db.Where("id = ? AND user_id = ?", id, userID).First(&analysis).Error; err != nil {
+		handleDBError(c, err, "Analysis not found")
+		return nil, false
+	}
+	return &analysis, true
+}
+
+// analysisResponse 画面表示用の会話履歴を付ける
+func analysisResponse(analysis *models.AIAnalysis) AIAnalysisResponse {
+	return AIAnalysisResponse{AIAnalysis: *analysis, Messages: services.VisibleAIMessages(analysis)}
+}
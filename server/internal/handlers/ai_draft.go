@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hostnote/server/internal/models"
+	"github.com/hostnote/server/internal/services"
+)
+
+// DraftRequest 営業LINEの下書きのリクエスト
+type DraftRequest struct {
+	HimeID int      `json:"himeId" binding:"required"`
+	Intent string   `json:"intent" binding:"required"` // thanks, birthday, dormant, invite
+	Tones  []string `json:"tones"`                     // sweet, friendly, polite（省略時はすべて）
+	Note   string   `json:"note"`                      // 追加の指示
+}
+
+// DraftResponse 口調ごとの下書き
+type DraftResponse struct {
+	AnalysisID  uint                    `json:"analysisId,omitempty"` // 保存した下書き（追加の依頼に使う）
+	Intent      string                  `json:"intent"`
+	Drafts      []services.MessageDraft `json:"drafts"`
+	LastVisitAt *time.Time              `json:"lastVisitAt,omitempty"`
+}
+
+// Drafts 姫の好み・最近の卓記録のメモ・自分のキャスト情報をもとに、口調の違う営業LINEの下書きを作成
+func (h *AIHandler) Drafts(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+
+	var req DraftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.HimeID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid himeId"})
+		return
+	}
+	req.Note = strings.TrimSpace(req.Note)
+	if utf8.RuneCountInString(req.Note) > services.DraftNoteMaxLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "追加の指示が長すぎます"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	drafts, err := services.CollectMessageDrafts(ctx, h.db, services.MessageDraftInput{
+		UserID: userID,
+		HimeID: uint(req.HimeID),
+		Intent: req.Intent,
+		Tones:  req.Tones,
+		Note:   req.Note,
+		Now:    time.Now(),
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidDraftIntent) || errors.Is(err, services.ErrInvalidDraftTone) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		handleDBError(c, err, "Hime not found")
+		return
+	}
+
+	if !h.checkAIQuota(c, userID) {
+		return
+	}
+	redactor, ok := h.redactor(c, userID)
+	if !ok {
+		return
+	}
+	llmReq := drafts.Request()
+	result, err := services.ChatRedacted(ctx, redactor, llmReq)
+	if err != nil {
+		respondAIError(c, err)
+		return
+	}
+	h.recordAIUsage(c, userID, result)
+
+	himeID := uint(req.HimeID)
+	saved := h.saveAnalysis(c, services.NewAIAnalysis(userID, &himeID, models.AIAnalysisKindDraft, map[string]interface{}{
+		"intent": req.Intent,
+		"tones":  drafts.Input.Tones,
+		"note":   req.Note,
+	}, llmReq, result, time.Now()))
+
+	c.JSON(http.StatusOK, DraftResponse{
+		AnalysisID:  saved,
+		Intent:      req.Intent,
+		Drafts:      services.ParseMessageDrafts(result.Content),
+		LastVisitAt: drafts.LastVisitAt,
+	})
+}
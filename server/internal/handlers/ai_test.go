@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hostnote/server/internal/services"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const conversationRequestBody = `{"selfProfile":"レン","partnerProfile":"あいり","chatLog":"あいり: またね"}`
+
+// newDryRunDB SQLを実行しないDB（保存処理を通すためのテスト用）
+func newDryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "test:test@tcp(127.0.0.1:0)/test", SkipInitializeWithVersion: true}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+		Logger:               logger.Discard,
+	})
+	if err != nil {
+		t.Fatalf("gorm.Open: %v", err)
+	}
+	return db
+}
+
+// withUser 認証済みユーザーとして扱う
+func withUser(userID uint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("userID", userID)
+	}
+}
+
+// TestAnalyzeConversationStream 分析結果がdeltaイベントで送られ、最後にdoneイベントが届くことをテスト
+func TestAnalyzeConversationStream(t *testing.T) {
+	services.SetLLMProvider(&services.FakeLLMProvider{Response: "現在の関係性はとても良好です"})
+	defer services.SetLLMProvider(&services.FakeLLMProvider{Err: services.ErrAIProviderNotConfigured})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/ai/conversation/stream", withUser(1), NewAIHandler(newDryRunDB(t)).AnalyzeConversationStream)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/ai/conversation/stream", strings.NewReader(conversationRequestBody))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("status = %d, content-type = %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		"event:delta\ndata:{\"content\":\"現在の関係性はと\"}\n\n",
+		"event:done\ndata:{\"result\":\"現在の関係性はとても良好です\"}\n\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body does not contain %q:\n%s", want, body)
+		}
+	}
+	if strings.Index(body, "event:delta") > strings.Index(body, "event:done") {
+		t.Error("doneはdeltaの後に送る")
+	}
+}
+
+// TestAnalyzeConversationStreamNotConfigured 送信開始前のエラーは通常のJSONで返ることをテスト
+func TestAnalyzeConversationStreamNotConfigured(t *testing.T) {
+	services.SetLLMProvider(&services.FakeLLMProvider{Err: services.ErrAIProviderNotConfigured})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/ai/conversation/stream", withUser(1), NewAIHandler(newDryRunDB(t)).AnalyzeConversationStream)
+
+	for _, tt := range []struct {
+		body string
+		want int
+	}{
+		{conversationRequestBody, http.StatusServiceUnavailable},
+		{`{"selfProfile":"レン"}`, http.StatusBadRequest},
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/ai/conversation/stream", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+
+		if w.Code != tt.want || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+			t.Errorf("status = %d, content-type = %q, want %d JSON", w.Code, w.Header().Get("Content-Type"), tt.want)
+		}
+	}
+}
+
+// TestRespondAIQuotaExceeded 上限に達した場合は翌月1日までのRetry-After付きの429を返すことをテスト
+func TestRespondAIQuotaExceeded(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	resetAt := time.Now().Add(48 * time.Hour)
+	respondAIQuotaExceeded(c, &services.AIUsageStatus{
+		Requests: 3, TotalTokens: 1500,
+		Quota:   services.AIQuota{MonthlyTokens: 1000, MonthlyRequests: 100},
+		ResetAt: resetAt,
+	})
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if retryAfter, _ := strconv.Atoi(w.Header().Get("Retry-After")); retryAfter < 47*3600 || retryAfter > 48*3600 {
+		t.Errorf("Retry-After = %q", w.Header().Get("Retry-After"))
+	}
+	if body := w.Body.String(); !strings.Contains(body, "1000トークン") || !strings.Contains(body, resetAt.Format("1月2日")) {
+		t.Errorf("unexpected body: %s", body)
+	}
+}
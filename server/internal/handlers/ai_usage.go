@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hostnote/server/internal/middleware"
+	"github.com/hostnote/server/internal/models"
+	"github.com/hostnote/server/internal/services"
+	"gorm.io/gorm"
+)
+
+// AIUsageResponse 今月の利用量と上限、過去の月ごとの利用量
+type AIUsageResponse struct {
+	services.AIUsageStatus
+	History []models.AIUsage `json:"history"` // 新しい順（今月を含む）
+}
+
+// UserAIUsage ユーザーごとの利用量（管理者向け）
+type UserAIUsage struct {
+	models.AIUsage
+	Username string `json:"username"`
+}
+
+// StoreAIUsageResponse 店舗のメンバーごとの利用量
+type StoreAIUsageResponse struct {
+	Month string            `json:"month"`
+	Quota *services.AIQuota `json:"quota,omitempty"` // 店舗を指定しない場合（スーパー管理者）は省略
+	Users []UserAIUsage     `json:"users"`           // トークン数の多い順
+}
+
+// Usage 自分のAI利用量と上限を取得
+// months で過去何か月分の履歴を返すか指定できる（既定6、最大24）
+func (h *AIHandler) Usage(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+
+	months := 6
+	if monthsStr := c.Query("months"); monthsStr != "" {
+		if parsed := parseInt(monthsStr); parsed > 0 && parsed <= 24 {
+			months = parsed
+		}
+	}
+
+	now := time.Now()
+	status, err := services.GetAIUsageStatus(c.Request.Context(), h.db, userID, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	from := services.AIUsageMonth(time.Date(now.Year(), now.Month()-time.Month(months-1), 1, 0, 0, 0, 0, now.Location()))
+	var history []models.AIUsage
+	if err := h.db.WithContext(c).Where("user_id = ? AND month >= ?", userID, from).
+		Order("month DESC").Find(&history).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, AIUsageResponse{AIUsageStatus: *status, History: history})
+}
+
+// StoreUsage 店舗のメンバーごとのAI利用量を取得（month で集計月を指定、省略時は今月）
+// 管理者は所属店舗のメンバーのみ、スーパー管理者はstoreIdで店舗を絞り込める
+func (h *AIHandler) StoreUsage(c *gin.Context) {
+	month := services.AIUsageMonth(time.Now())
+	if monthStr := c.Query("month"); monthStr != "" {
+		if _, err := time.Parse(services.AIUsageMonthLayout, monthStr); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid month"})
+			return
+		}
+		month = monthStr
+	}
+
+	query := h.db.WithContext(c).Model(&models.AIUsage{}).Where("month = ?", month)
+	var storeID *uint
+	if middleware.GetRole(c) == models.RoleSuperAdmin {
+		if storeIDStr := c.Query("storeId"); storeIDStr != "" {
+			id, err := strconv.ParseUint(storeIDStr, 10, 32)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid storeId"})
+				return
+			}
+			sid := uint(id)
+			storeID = &sid
+		}
+	} else {
+		sid, ok := getStoreID(c, h.db)
+		if !ok {
+			return
+		}
+		storeID = &sid
+	}
+
+	response := StoreAIUsageResponse{Month: month, Users: []UserAIUsage{}}
+	if storeID != nil {
+		query = query.Where("user_id IN (?)", h.db.Model(&models.StoreMember{}).Select("user_id").Where("store_id = ?", *storeID))
+		quota, err := services.LoadStoreAIQuota(c.Request.Context(), h.db, *storeID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		response.Quota = &quota
+	}
+
+	var usages []models.AIUsage
+	if err := query.
+		Preload("User", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, username")
+		}).
+		Order("total_tokens DESC, user_id").
+		Find(&usages).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, u := range usages {
+		usage := UserAIUsage{AIUsage: u}
+		if u.User != nil {
+			usage.Username = u.User.Username
+		}
+		response.Users = append(response.Users, usage)
+	}
+	c.JSON(http.StatusOK, response)
+}
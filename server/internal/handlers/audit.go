@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hostnote/server/internal/middleware"
+	"github.com/hostnote/server/internal/models"
+	"gorm.io/gorm"
+)
+
+type AuditHandler struct {
+	db *gorm.DB
+}
+
+func NewAuditHandler(db *gorm.DB) *AuditHandler {
+	return &AuditHandler{db: db}
+}
+
+// AuditLogResponse 監査ログ（操作ユーザー名付き）
+type AuditLogResponse struct {
+	models.AuditLog
+	ActorName string `json:"actorName"`
+}
+
+// List 監査ログ一覧を取得（新しい順）
+// 管理者は所属店舗のログのみ、スーパー管理者はstoreIdで店舗を絞り込める
+// 総件数はX-Total-Countヘッダーで返す
+func (h *AuditHandler) List(c *gin.Context) {
+	query := h.db.Model(&models.AuditLog{})
+
+	if middleware.GetRole(c) == models.RoleSuperAdmin {
+		if storeIDStr := c.Query("storeId"); storeIDStr != "" {
+			storeID, err := strconv.ParseUint(storeIDStr, 10, 32)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid storeId"})
+				return
+			}
+			query = query.Where("store_id = ?", storeID)
+		}
+	} else {
+		storeID, ok := getStoreID(c, h.db)
+		if !ok {
+			return
+		}
+		query = query.Where("store_id = ?", storeID)
+	}
+
+	if entity := c.Query("entity"); entity != "" {
+		query = query.Where("entity = ?", entity)
+	}
+	if entityID := c.Query("entityId"); entityID != "" {
+		query = query.Where("entity_id = ?", entityID)
+	}
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if actorIDStr := c.Query("actorId"); actorIDStr != "" {
+		actorID, err := strconv.ParseUint(actorIDStr, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid actorId"})
+			return
+		}
+		query = query.Where("actor_id = ?", actorID)
+	}
+	if fromStr := c.Query("from"); fromStr != "" {
+		from, _, ok := parseAuditTime(fromStr)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from"})
+			return
+		}
+		query = query.Where("created_at >= ?", from)
+	}
+	if toStr := c.Query("to"); toStr != "" {
+		to, dateOnly, ok := parseAuditTime(toStr)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to"})
+			return
+		}
+		// 日付のみ指定された場合はその日の終わりまでを含める
+		if dateOnly {
+			to = to.AddDate(0, 0, 1)
+		}
+		query = query.Where("created_at < ?", to)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// ページネーション
+	limit := 100 // デフォルトは100件
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if parsedLimit := parseInt(limitStr); parsedLimit > 0 && parsedLimit <= 500 {
+			limit = parsedLimit
+		}
+	}
+	offset := 0
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if parsedOffset := parseInt(offsetStr); parsedOffset >= 0 {
+			offset = parsedOffset
+		}
+	}
+
+	var logs []models.AuditLog
+	if err := query.
+		Preload("Actor", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, username")
+		}).
+		Order("created_at DESC, id DESC").
+		Limit(limit).Offset(offset).
+		Find(&logs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make([]AuditLogResponse, len(logs))
+	for i, l := range logs {
+		response[i] = AuditLogResponse{AuditLog: l}
+		if l.Actor != nil {
+			response[i].ActorName = l.Actor.Username
+		}
+	}
+
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	c.JSON(http.StatusOK, response)
+}
+
+// parseAuditTime 絞り込み用の日時を解析（RFC3339または日付のみ）
+func parseAuditTime(s string) (time.Time, bool, bool) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, false, true
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, true, true
+	}
+	return time.Time{}, false, false
+}
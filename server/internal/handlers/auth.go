@@ -3,9 +3,11 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 	"time"
@@ -13,7 +15,9 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/hostnote/server/internal/config"
+	"github.com/hostnote/server/internal/middleware"
 	"github.com/hostnote/server/internal/models"
+	"github.com/hostnote/server/internal/services"
 	"golang.org/x/crypto/bcrypt"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
@@ -22,16 +26,25 @@ import (
 
 type AuthHandler struct {
 	db *gorm.DB
+	// ブルートフォース対策のロックアウト（ユーザー名・2段階認証はuserThrottle、接続元IPはipThrottle）
+	userThrottle *middleware.LoginThrottle
+	ipThrottle   *middleware.LoginThrottle
 }
 
 func NewAuthHandler(db *gorm.DB) *AuthHandler {
-	return &AuthHandler{db: db}
+	return &AuthHandler{
+		db:           db,
+		userThrottle: middleware.NewLoginThrottle(5, 30*time.Second, time.Hour),
+		ipThrottle:   middleware.NewLoginThrottle(20, time.Minute, time.Hour),
+	}
 }
 
 type RegisterRequest struct {
 	Username string `json:"username" binding:"required,min=3,max=50"`
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=6"`
+	// InviteCode 招待コード（指定時は招待先の店舗に所属する）
+	InviteCode string `json:"inviteCode"`
 }
 
 type LoginRequest struct {
@@ -40,14 +53,17 @@ type LoginRequest struct {
 }
 
 type AuthResponse struct {
-	Token string    `json:"token"`
-	User  *UserInfo `json:"user"`
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refreshToken,omitempty"`
+	ExpiresIn    int64     `json:"expiresIn,omitempty"` // アクセストークンの有効期間（秒）
+	User         *UserInfo `json:"user"`
 }
 
 type UserInfo struct {
 	ID       uint    `json:"id"`
 	Username string  `json:"username"`
 	Email    *string `json:"email"`
+	Role     string  `json:"role"`
 }
 
 type ChangePasswordRequest struct {
@@ -90,28 +106,20 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		Username: req.Username,
 		Email:    &req.Email,
 		Password: &password, // BeforeCreateフックでハッシュ化される
+		Role:     models.RoleMember,
 	}
 
-	if err := h.db.Create(&user).Error; err != nil {
+	if err := createUserWithStore(h.db, &user, req.InviteCode); err != nil {
+		if errors.Is(err, errInvalidInvitation) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "ユーザー作成に失敗しました"})
 		return
 	}
 
-	// JWTトークンを生成
-	token, err := generateToken(user.ID, user.Username)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "トークン生成に失敗しました"})
-		return
-	}
-
-	c.JSON(http.StatusCreated, AuthResponse{
-		Token: token,
-		User: &UserInfo{
-			ID:       user.ID,
-			Username: user.Username,
-			Email:    user.Email,
-		},
-	})
+	// 招待先の店舗で2段階認証が必須の場合は、セッションを発行せず登録させる
+	h.respondLogin(c, &user, http.StatusCreated)
 }
 
 // Login ログイン
@@ -122,10 +130,19 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	// ユーザー名・接続元IPごとのロックアウトを確認
+	userKey := "login:" + strings.ToLower(req.Username)
+	ipKey := "login:" + c.ClientIP()
+	if retryAfter, locked := h.loginLocked(userKey, ipKey); locked {
+		middleware.TooManyRequests(c, retryAfter)
+		return
+	}
+
 	// ユーザーを検索
 	var user models.User
 	if err := h.db.Where("username = ?", req.Username).First(&user).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
+			h.loginFailed(userKey, ipKey)
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "ユーザー名またはパスワードが正しくありません"})
 			return
 		}
@@ -135,25 +152,14 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 	// パスワードを検証
 	if !user.CheckPassword(req.Password) {
+		h.loginFailed(userKey, ipKey)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "ユーザー名またはパスワードが正しくありません"})
 		return
 	}
+	h.userThrottle.Reset(userKey)
 
-	// JWTトークンを生成
-	token, err := generateToken(user.ID, user.Username)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "トークン生成に失敗しました"})
-		return
-	}
-
-	c.JSON(http.StatusOK, AuthResponse{
-		Token: token,
-		User: &UserInfo{
-			ID:       user.ID,
-			Username: user.Username,
-			Email:    user.Email,
-		},
-	})
+	// 2段階認証が有効（または店舗で必須）の場合は、コード検証用のチャレンジトークンを返す
+	h.respondLogin(c, &user, http.StatusOK)
 }
 
 // Me 現在のユーザー情報を取得
@@ -178,6 +184,7 @@ func (h *AuthHandler) Me(c *gin.Context) {
 		ID:       user.ID,
 		Username: user.Username,
 		Email:    user.Email,
+		Role:     user.Role,
 	})
 }
 
@@ -216,13 +223,31 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 		return
 	}
 
+	// パスワード更新と同時に全セッションを取り消す（他端末はログアウトされる）
 	hashedStr := string(hashedPassword)
-	if err := h.db.Model(&user).Update("password", hashedStr).Error; err != nil {
+	if err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("password", hashedStr).Error; err != nil {
+			return err
+		}
+		return revokeAllSessions(tx, user.ID)
+	}); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "パスワードの更新に失敗しました"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "パスワードを変更しました"})
+	// 操作中の端末には新しいセッションを発行する
+	resp, err := issueSession(h.db, c, &user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "トークン生成に失敗しました"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "パスワードを変更しました",
+		"token":        resp.Token,
+		"refreshToken": resp.RefreshToken,
+		"expiresIn":    resp.ExpiresIn,
+	})
 }
 
 // UpdateEmail メールアドレスを更新
@@ -270,6 +295,7 @@ func (h *AuthHandler) UpdateEmail(c *gin.Context) {
 		ID:       user.ID,
 		Username: user.Username,
 		Email:    user.Email,
+		Role:     user.Role,
 	})
 }
 
@@ -297,7 +323,9 @@ func (h *AuthHandler) DeleteAccount(c *gin.Context) {
 
 	// トランザクション内で関連データを削除
 	// 外部キー制約を考慮して、子テーブルから親テーブルへ削除する順序が重要
-	err := h.db.Transaction(func(tx *gorm.DB) error {
+	// 削除したデータの写しを残さないよう、gin.Contextを渡さず監査ログの対象外にする
+	var exportJobs []models.ExportJob
+	err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
 		// 1. 中間テーブル（外部キー制約のないテーブル）を削除
 		// TableHime, TableCastはTableRecordに依存しているため、後で削除
 
@@ -315,9 +343,23 @@ func (h *AuthHandler) DeleteAccount(c *gin.Context) {
 			if err := tx.Where("table_id = ?", tr.ID).Delete(&models.TableCast{}).Error; err != nil {
 				return fmt.Errorf("TableCastの削除に失敗: %w", err)
 			}
+			// TableOrderを削除
+			if err := tx.Where("table_id = ?", tr.ID).Delete(&models.TableOrder{}).Error; err != nil {
+				return fmt.Errorf("TableOrderの削除に失敗: %w", err)
+			}
 		}
 
 		// 3. user_idを参照するテーブルを削除（外部キー制約がある）
+		// Paymentを削除（TableRecordより先に削除）
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.Payment{}).Error; err != nil {
+			return fmt.Errorf("支払いの削除に失敗: %w", err)
+		}
+
+		// 発行した領収書・請求書を削除
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.IssuedDocument{}).Error; err != nil {
+			return fmt.Errorf("発行した書類の削除に失敗: %w", err)
+		}
+
 		// TableRecordを削除
 		if err := tx.Where("user_id = ?", user.ID).Delete(&models.TableRecord{}).Error; err != nil {
 			return fmt.Errorf("卓記録の削除に失敗: %w", err)
@@ -333,14 +375,24 @@ func (h *AuthHandler) DeleteAccount(c *gin.Context) {
 			return fmt.Errorf("スケジュールの削除に失敗: %w", err)
 		}
 
+		// AIAnalysisを削除
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.AIAnalysis{}).Error; err != nil {
+			return fmt.Errorf("AI分析の削除に失敗: %w", err)
+		}
+
+		// AIUsageを削除
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.AIUsage{}).Error; err != nil {
+			return fmt.Errorf("AI利用量の削除に失敗: %w", err)
+		}
+
 		// Himeを削除
 		if err := tx.Where("user_id = ?", user.ID).Delete(&models.Hime{}).Error; err != nil {
 			return fmt.Errorf("姫の削除に失敗: %w", err)
 		}
 
-		// Castを削除（user_idがNULL可だが、該当するレコードを削除）
-		if err := tx.Where("user_id = ?", user.ID).Delete(&models.Cast{}).Error; err != nil {
-			return fmt.Errorf("キャストの削除に失敗: %w", err)
+		// Castは店舗で共有し、他のメンバーの卓記録・スケジュールからも参照されるため削除せず、作成者のみ外す
+		if err := tx.Model(&models.Cast{}).Where("user_id = ?", user.ID).Update("user_id", nil).Error; err != nil {
+			return fmt.Errorf("キャストの更新に失敗: %w", err)
 		}
 
 		// 4. OAuthアカウントを削除
@@ -353,7 +405,39 @@ func (h *AuthHandler) DeleteAccount(c *gin.Context) {
 			return fmt.Errorf("プッシュトークンの削除に失敗: %w", err)
 		}
 
-		// 6. 最後にユーザーを論理削除（soft delete）
+		// 6. 店舗の所属を削除
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.StoreMember{}).Error; err != nil {
+			return fmt.Errorf("店舗所属の削除に失敗: %w", err)
+		}
+
+		// 7. すべてのセッションを削除（リフレッシュトークンを無効化）
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.Session{}).Error; err != nil {
+			return fmt.Errorf("セッションの削除に失敗: %w", err)
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.LoginCode{}).Error; err != nil {
+			return fmt.Errorf("ログインコードの削除に失敗: %w", err)
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return fmt.Errorf("リカバリーコードの削除に失敗: %w", err)
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.PasswordResetToken{}).Error; err != nil {
+			return fmt.Errorf("パスワード再設定トークンの削除に失敗: %w", err)
+		}
+
+		// 8. 個人データのエクスポート履歴を削除（ZIPファイルはコミット後に削除）
+		if err := tx.Where("user_id = ?", user.ID).Find(&exportJobs).Error; err != nil {
+			return fmt.Errorf("エクスポート履歴の取得に失敗: %w", err)
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.ExportJob{}).Error; err != nil {
+			return fmt.Errorf("エクスポート履歴の削除に失敗: %w", err)
+		}
+
+		// 9. 過去の操作の監査ログは残し、操作したユーザーのみ匿名化
+		if err := tx.Model(&models.AuditLog{}).Where("actor_id = ?", user.ID).Update("actor_id", nil).Error; err != nil {
+			return fmt.Errorf("監査ログの更新に失敗: %w", err)
+		}
+
+		// 10. 最後にユーザーを削除
 		if err := tx.Delete(&user).Error; err != nil {
 			return fmt.Errorf("ユーザーの削除に失敗: %w", err)
 		}
@@ -366,25 +450,73 @@ func (h *AuthHandler) DeleteAccount(c *gin.Context) {
 		return
 	}
 
+	services.NewExporter(h.db, config.AppConfig).RemoveFiles(exportJobs)
+
 	c.JSON(http.StatusOK, gin.H{"message": "アカウントを削除しました"})
 }
 
-// generateToken JWTトークンを生成
-func generateToken(userID uint, username string) (string, error) {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		secret = "your-secret-key-change-in-production" // 本番環境では必ず変更
+// loginLocked ユーザー名・接続元IPのいずれかがロック中なら残り時間を返す
+func (h *AuthHandler) loginLocked(userKey, ipKey string) (time.Duration, bool) {
+	userWait, userLocked := h.userThrottle.Locked(userKey)
+	ipWait, ipLocked := h.ipThrottle.Locked(ipKey)
+	if ipWait > userWait {
+		userWait = ipWait
 	}
+	return userWait, userLocked || ipLocked
+}
 
+// loginFailed ログイン失敗をユーザー名・接続元IPの両方に記録
+func (h *AuthHandler) loginFailed(userKey, ipKey string) {
+	h.userThrottle.Fail(userKey)
+	h.ipThrottle.Fail(ipKey)
+}
+
+// createUserWithStore ユーザーを作成し、招待先に所属させる
+// 招待コードがなければ自分の店舗を作成してその管理者にする（既存の店舗のデータは見えない）
+func createUserWithStore(db *gorm.DB, user *models.User, inviteCode string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if inviteCode == "" {
+			user.Role = models.RoleAdmin
+		}
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		if inviteCode != "" {
+			return acceptInvitation(tx, inviteCode, user)
+		}
+		store := models.Store{Name: user.Username + "の店舗"}
+		if err := tx.Create(&store).Error; err != nil {
+			return err
+		}
+		return joinStore(tx, user.ID, store.ID)
+	})
+}
+
+// generateToken JWTアクセストークンを生成（短命。更新はリフレッシュトークンで行う）
+func generateToken(userID uint, username, role string, sessionID uint) (string, error) {
+	accessTTL, _ := tokenTTLs()
 	claims := jwt.MapClaims{
 		"userID":   userID,
 		"username": username,
-		"exp":      time.Now().Add(time.Hour * 24 * 7).Unix(), // 7日間有効
+		"role":     role,
+		"exp":      time.Now().Add(accessTTL).Unix(),
 		"iat":      time.Now().Unix(),
 	}
+	if sessionID != 0 {
+		claims["sid"] = sessionID
+	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+	return token.SignedString([]byte(jwtSecret()))
+}
+
+// jwtSecret JWTの署名鍵を取得
+func jwtSecret() string {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		return "your-secret-key-change-in-production" // 本番環境では必ず変更
+	}
+	return secret
 }
 
 // GoogleOAuthConfig Google OAuth設定を取得
@@ -438,6 +570,8 @@ func (h *AuthHandler) GoogleLogin(c *gin.Context) {
 // GoogleCallbackRequest フロントエンドから送信されるGoogleアクセストークン
 type GoogleCallbackRequest struct {
 	AccessToken string `json:"accessToken" binding:"required"`
+	// InviteCode 招待コード（新規ユーザー作成時のみ使用）
+	InviteCode string `json:"inviteCode"`
 }
 
 // GoogleCallbackFromFrontend フロントエンドから送信されたGoogleアクセストークンを処理
@@ -522,8 +656,13 @@ func (h *AuthHandler) GoogleCallbackFromFrontend(c *gin.Context) {
 				Username: username,
 				Email:    &googleUser.Email,
 				Password: nil, // OAuthユーザーはパスワード不要
+				Role:     models.RoleMember,
 			}
-			if err := h.db.Create(&user).Error; err != nil {
+			if err := createUserWithStore(h.db, &user, req.InviteCode); err != nil {
+				if errors.Is(err, errInvalidInvitation) {
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "ユーザー作成に失敗しました"})
 				return
 			}
@@ -544,21 +683,8 @@ func (h *AuthHandler) GoogleCallbackFromFrontend(c *gin.Context) {
 		return
 	}
 
-	// JWTトークンを生成
-	jwtToken, err := generateToken(user.ID, user.Username)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "トークン生成に失敗しました"})
-		return
-	}
-
-	c.JSON(http.StatusOK, AuthResponse{
-		Token: jwtToken,
-		User: &UserInfo{
-			ID:       user.ID,
-			Username: user.Username,
-			Email:    user.Email,
-		},
-	})
+	// パスワードでのログインと同様に2段階認証を確認してからセッションを発行
+	h.respondLogin(c, &user, http.StatusOK)
 }
 
 // GoogleCallback Google OAuth認証のコールバック（サーバーサイドフロー用）
@@ -656,8 +782,9 @@ func (h *AuthHandler) GoogleCallback(c *gin.Context) {
 				Username: username,
 				Email:    &googleUser.Email,
 				Password: nil, // OAuthユーザーはパスワード不要
+				Role:     models.RoleMember,
 			}
-			if err := h.db.Create(&user).Error; err != nil {
+			if err := createUserWithStore(h.db, &user, ""); err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "ユーザー作成に失敗しました"})
 				return
 			}
@@ -678,20 +805,18 @@ func (h *AuthHandler) GoogleCallback(c *gin.Context) {
 		return
 	}
 
-	// JWTトークンを生成
-	jwtToken, err := generateToken(user.ID, user.Username)
+	// トークンはURLに載せず、1回限りのログインコードを渡してPOSTで交換させる
+	loginCode, err := createLoginCode(h.db, user.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "トークン生成に失敗しました"})
 		return
 	}
 
-	// フロントエンドにリダイレクト（トークンをクエリパラメータで渡す）
-	// 本番環境では、より安全な方法（HTTP-only cookieなど）を使用することを推奨
 	frontendURL := os.Getenv("FRONTEND_URL")
 	if frontendURL == "" {
 		frontendURL = "http://localhost:5173"
 	}
-	c.Redirect(http.StatusTemporaryRedirect, fmt.Sprintf("%s/auth/callback?token=%s", frontendURL, jwtToken))
+	c.Redirect(http.StatusTemporaryRedirect, fmt.Sprintf("%s/auth/callback?code=%s", frontendURL, url.QueryEscape(loginCode)))
 }
 
 // generateStateToken CSRF対策用のstateトークンを生成
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hostnote/server/internal/models"
+	"github.com/hostnote/server/internal/services"
+	"gorm.io/gorm"
+)
+
+// TestRegisterCreatesOwnStore 招待コードなしの登録では既存の店舗に所属させず、自分の店舗の管理者にすることをテスト
+func TestRegisterCreatesOwnStore(t *testing.T) {
+	db := newAuthTestDB(t)
+	r := newAuthTestRouter(db)
+	if err := db.Create(&models.Store{ID: 1, Name: "既存の店舗"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	w := postJSON(r, "/auth/register", RegisterRequest{Username: "newcomer", Email: "newcomer@example.com", Password: "password"}, "")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("register: status = %d (%s)", w.Code, w.Body.String())
+	}
+	resp := decodeAuthResponse(t, w)
+
+	var member models.StoreMember
+	if err := db.Where("user_id = ?", resp.User.ID).First(&member).Error; err != nil {
+		t.Fatalf("store member: %v", err)
+	}
+	if member.StoreID == 1 {
+		t.Errorf("joined the existing store, want a new store")
+	}
+	if resp.User.Role != models.RoleAdmin {
+		t.Errorf("role = %q, want admin of the new store", resp.User.Role)
+	}
+}
+
+// newDeleteAccountTestDB アカウント削除のテスト用DB（削除対象のテーブルをすべて作成し、外部キー制約を有効にする）
+func newDeleteAccountTestDB(t *testing.T) *gorm.DB {
+	return newForeignKeyTestDB(t,
+		&models.User{}, &models.OAuthAccount{}, &models.Hime{}, &models.Cast{},
+		&models.TableRecord{}, &models.TableHime{}, &models.TableCast{}, &models.TableOrder{},
+		&models.IssuedDocument{}, &models.Schedule{}, &models.VisitRecord{}, &models.PushToken{},
+		&models.Store{}, &models.StoreMember{}, &models.Session{}, &models.RecoveryCode{},
+		&models.PasswordResetToken{}, &models.LoginCode{}, &models.ExportJob{}, &models.AIAnalysis{}, &models.AIUsage{},
+		&models.Payment{}, &models.AuditLog{},
+	)
+}
+
+// deleteAccount ログイン中のユーザーとしてアカウント削除を呼び出す
+func deleteAccount(db *gorm.DB, userID uint) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.DELETE("/auth/account", withUser(userID), NewAuthHandler(db).DeleteAccount)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/auth/account", nil))
+	return w
+}
+
+// TestDeleteAccountRemovesCredentials アカウント削除でリカバリーコード・パスワード再設定トークンも削除されることをテスト
+func TestDeleteAccountRemovesCredentials(t *testing.T) {
+	db := newDeleteAccountTestDB(t)
+	user := createTestUser(t, db, "leaving")
+	if err := db.Create(&models.RecoveryCode{UserID: user.ID, CodeHash: "hash"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.PasswordResetToken{UserID: user.ID, TokenHash: "hash", ExpiresAt: time.Now().Add(time.Hour)}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if w := deleteAccount(db, user.ID); w.Code != http.StatusOK {
+		t.Fatalf("delete account: status = %d (%s)", w.Code, w.Body.String())
+	}
+
+	var count int64
+	db.Model(&models.RecoveryCode{}).Where("user_id = ?", user.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("recovery codes = %d, want 0", count)
+	}
+	db.Model(&models.PasswordResetToken{}).Where("user_id = ?", user.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("password reset tokens = %d, want 0", count)
+	}
+}
+
+// TestDeleteAccountWithCustomerData 卓記録・姫・監査ログのあるユーザーを外部キー制約に反せず削除でき、共有データは残ることをテスト
+func TestDeleteAccountWithCustomerData(t *testing.T) {
+	db := newDeleteAccountTestDB(t)
+	if err := services.RegisterAuditCallbacks(db); err != nil {
+		t.Fatal(err)
+	}
+	user := createTestUser(t, db, "leaving")
+	other := createTestUser(t, db, "staying")
+	store := models.Store{Name: "店舗"}
+	if err := db.Create(&store).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range []*models.User{user, other} {
+		if err := db.Create(&models.StoreMember{StoreID: store.ID, UserID: u.ID}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 監査ログが記録されるよう、ハンドラーと同じくgin.Contextに操作ユーザーを入れて作成する
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Set("userID", user.ID)
+	cast := models.Cast{UserID: &user.ID, StoreID: &store.ID, Name: "キャスト"}
+	hime := models.Hime{UserID: user.ID, Name: "姫"}
+	if err := db.WithContext(ctx).Create(&cast).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.WithContext(ctx).Create(&hime).Error; err != nil {
+		t.Fatal(err)
+	}
+	table := models.TableRecord{UserID: user.ID, Datetime: time.Now(), Status: models.TableStatusClosed}
+	if err := db.WithContext(ctx).Create(&table).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.TableHime{TableID: table.ID, HimeID: hime.ID}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.TableCast{TableID: table.ID, CastID: cast.ID, Role: "main"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	var logs int64
+	if db.Model(&models.AuditLog{}).Where("actor_id = ?", user.ID).Count(&logs); logs == 0 {
+		t.Fatal("no audit logs were written for the user")
+	}
+
+	if w := deleteAccount(db, user.ID); w.Code != http.StatusOK {
+		t.Fatalf("delete account: status = %d (%s)", w.Code, w.Body.String())
+	}
+
+	var count int64
+	if db.Model(&models.User{}).Where("id = ?", user.ID).Count(&count); count != 0 {
+		t.Errorf("user still exists")
+	}
+	if db.Model(&models.Hime{}).Where("user_id = ?", user.ID).Count(&count); count != 0 {
+		t.Errorf("himes = %d, want 0", count)
+	}
+	if db.Model(&models.TableRecord{}).Where("user_id = ?", user.ID).Count(&count); count != 0 {
+		t.Errorf("table records = %d, want 0", count)
+	}
+	var kept models.Cast
+	if err := db.First(&kept, cast.ID).Error; err != nil {
+		t.Fatalf("shared cast was deleted: %v", err)
+	}
+	if kept.UserID != nil {
+		t.Errorf("cast user_id = %d, want null", *kept.UserID)
+	}
+	if db.Model(&models.AuditLog{}).Where("actor_id = ?", user.ID).Count(&count); count != 0 {
+		t.Errorf("audit logs still reference the deleted user: %d", count)
+	}
+	if db.Model(&models.AuditLog{}).Where("actor_id IS NULL").Count(&count); count != logs {
+		t.Errorf("anonymised audit logs = %d, want %d (the deletion itself must not be audited)", count, logs)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hostnote/server/internal/middleware"
 	"github.com/hostnote/server/internal/models"
 	"gorm.io/gorm"
 )
@@ -40,11 +41,10 @@ type CastListItem struct {
 	UpdatedAt         time.Time       `json:"updatedAt"`
 }
 
-// List キャスト一覧を取得（ページネーション対応、photosとmemosを除外して軽量化）
+// List キャスト一覧を取得（店舗共通、ページネーション対応、photosとmemosを除外して軽量化）
 func (h *CastHandler) List(c *gin.Context) {
-	userID, ok := getUserID(c)
+	storeID, ok := getStoreID(c, h.db)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
 		return
 	}
 
@@ -63,8 +63,8 @@ func (h *CastHandler) List(c *gin.Context) {
 	}
 
 	var casts []models.Cast
-	query := h.db.Select("id, user_id, name, photo_url, sn_s_info, birthday, age, champagne_call_song, drink_preference, favorite_drink_id, ice, carbonation, favorite_mixer_id, smokes, tobacco_type, created_at, updated_at").
-		Where("user_id = ?", userID).Order("created_at DESC")
+	query := h.db.WithContext(c).Select("id, user_id, name, photo_url, sn_s_info, birthday, age, champagne_call_song, drink_preference, favorite_drink_id, ice, carbonation, favorite_mixer_id, smokes, tobacco_type, created_at, updated_at").
+		Where("store_id = ?", storeID).Order("created_at DESC")
 
 	// 件数制限を適用
 	if err := query.Limit(limit).Offset(offset).Find(&casts).Error; err != nil {
@@ -100,9 +100,8 @@ func (h *CastHandler) List(c *gin.Context) {
 
 // Get キャストを取得
 func (h *CastHandler) Get(c *gin.Context) {
-	userID, ok := getUserID(c)
+	storeID, ok := getStoreID(c, h.db)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
 		return
 	}
 
@@ -113,7 +112,7 @@ func (h *CastHandler) Get(c *gin.Context) {
 	}
 
 	var cast models.Cast
-	if err := h.db.Where("user_id = ? AND id = ?", userID, id).First(&cast).Error; err != nil {
+	if err := h.db.WithContext(c).Where("store_id = ? AND id = ?", storeID, id).First(&cast).Error; err != nil {
 		if handleDBError(c, err, "Cast not found") {
 			return
 		}
@@ -128,6 +127,10 @@ func (h *CastHandler) Create(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
 		return
 	}
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
 
 	var cast models.Cast
 	if err := c.ShouldBindJSON(&cast); err != nil {
@@ -137,10 +140,11 @@ func (h *CastHandler) Create(c *gin.Context) {
 
 	// IDを無視（自動生成）
 	cast.ID = 0
-	// ユーザーIDを設定
+	// ユーザーID・店舗IDを設定
 	cast.UserID = &userID
+	cast.StoreID = &storeID
 
-	if err := h.db.Create(&cast).Error; err != nil {
+	if err := h.db.WithContext(c).Create(&cast).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -154,6 +158,10 @@ func (h *CastHandler) Update(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
 		return
 	}
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
 
 	id, err := parseID(c, "id")
 	if err != nil {
@@ -162,11 +170,15 @@ func (h *CastHandler) Update(c *gin.Context) {
 	}
 
 	var cast models.Cast
-	if err := h.db.Where("user_id = ? AND id = ?", userID, id).First(&cast).Error; err != nil {
+	if err := h.db.WithContext(c).Where("store_id = ? AND id = ?", storeID, id).First(&cast).Error; err != nil {
 		if handleDBError(c, err, "Cast not found") {
 			return
 		}
 	}
+	if !canModifyCast(c, &cast, userID) {
+		middleware.Forbidden(c)
+		return
+	}
 
 	var updateData map[string]interface{}
 	if err := c.ShouldBindJSON(&updateData); err != nil {
@@ -174,96 +186,17 @@ func (h *CastHandler) Update(c *gin.Context) {
 		return
 	}
 
-	// JSONのキー名（camelCase）をモデルのフィールド名（PascalCase）に変換
-	// GORMが自動的に正しいカラム名に変換してくれる
-	fieldNameMap := map[string]string{
-		"photoUrl":          "PhotoURL",
-		"snsInfo":           "SnsInfo",
-		"name":              "Name",
-		"photos":            "Photos",
-		"birthday":          "Birthday",
-		"age":               "Age",
-		"champagneCallSong": "ChampagneCallSong",
-		"drinkPreference":   "DrinkPreference",
-		"favoriteDrinkId":   "FavoriteDrinkID",
-		"ice":               "Ice",
-		"carbonation":       "Carbonation",
-		"favoriteMixerId":   "FavoriteMixerID",
-		"smokes":            "Smokes",
-		"tobaccoType":       "TobaccoType",
-		"memos":             "Memos",
-	}
-
-	// キー名を変換
-	convertedData := make(map[string]interface{})
-	for key, value := range updateData {
-		if fieldName, ok := fieldNameMap[key]; ok {
-			convertedData[fieldName] = value
-		} else {
-			// マッピングがない場合はそのまま使用
-			convertedData[key] = value
-		}
-	}
-
-	// snsInfoフィールドがある場合、SnsInfo型に変換
-	if snsInfoData, ok := convertedData["SnsInfo"]; ok {
-		if snsInfoMap, ok := snsInfoData.(map[string]interface{}); ok {
-			var snsInfo models.SnsInfo
-			// JSONに変換してからパース
-			jsonBytes, err := json.Marshal(snsInfoMap)
-			if err == nil {
-				if err := json.Unmarshal(jsonBytes, &snsInfo); err == nil {
-					convertedData["SnsInfo"] = &snsInfo
-				}
-			}
-		} else if snsInfoData == nil {
-			convertedData["SnsInfo"] = (*models.SnsInfo)(nil)
-		}
-	}
-
-	// photosフィールドがある場合、Photos型に変換
-	if photosData, ok := convertedData["Photos"]; ok {
-		if photosArray, ok := photosData.([]interface{}); ok {
-			photos := make(models.Photos, len(photosArray))
-			for i, v := range photosArray {
-				if str, ok := v.(string); ok {
-					photos[i] = str
-				}
-			}
-			convertedData["Photos"] = photos
-		}
-	}
-
-	// memosフィールドがある場合、Memos型に変換
-	if memosData, ok := convertedData["Memos"]; ok {
-		if memosData == nil {
-			convertedData["Memos"] = models.Memos{}
-		} else {
-			// まずJSONにマーシャルしてからMemos型にアンマーシャル
-			jsonBytes, err := json.Marshal(memosData)
-			if err != nil {
-				log.Printf("Error marshaling memos: %v", err)
-				delete(convertedData, "Memos")
-			} else {
-				var memos models.Memos
-				if err := json.Unmarshal(jsonBytes, &memos); err != nil {
-					log.Printf("Error unmarshaling memos: %v, jsonBytes: %s", err, string(jsonBytes))
-					delete(convertedData, "Memos")
-				} else {
-					convertedData["Memos"] = memos
-				}
-			}
-		}
-	}
+	// 更新できる項目のみを反映（店舗・ユーザーなどは変更させない）
+	convertedData := castUpdates(updateData)
 
 	// 部分更新（Select最適化）
-	if err := h.db.Model(&cast).Updates(convertedData).Error; err != nil {
+	if err := h.db.WithContext(c).Model(&cast).Updates(convertedData).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	// 更新後のデータを取得
-	if err := h.db.Where("user_id = ? AND id = ?", userID, id).First(&cast).Error; err != nil {
+	if err := h.db.WithContext(c).Where("store_id = ? AND id = ?", storeID, id).First(&cast).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve updated record"})
 		return
 	}
@@ -278,6 +211,10 @@ func (h *CastHandler) Delete(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
 		return
 	}
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
 
 	id, err := parseID(c, "id")
 	if err != nil {
@@ -285,7 +222,18 @@ func (h *CastHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.Where("user_id = ? AND id = ?", userID, id).Delete(&models.Cast{}).Error; err != nil {
+	var cast models.Cast
+	if err := h.db.WithContext(c).Where("store_id = ? AND id = ?", storeID, id).First(&cast).Error; err != nil {
+		if handleDBError(c, err, "Cast not found") {
+			return
+		}
+	}
+	if !canModifyCast(c, &cast, userID) {
+		middleware.Forbidden(c)
+		return
+	}
+
+	if err := h.db.WithContext(c).Delete(&cast).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -299,6 +247,10 @@ func (h *CastHandler) BulkCreate(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
 		return
 	}
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
 
 	var casts []models.Cast
 	if err := c.ShouldBindJSON(&casts); err != nil {
@@ -306,14 +258,15 @@ func (h *CastHandler) BulkCreate(c *gin.Context) {
 		return
 	}
 
-	// IDを無視（自動生成）、ユーザーIDを設定
+	// IDを無視（自動生成）、ユーザーID・店舗IDを設定
 	for i := range casts {
 		casts[i].ID = 0
 		casts[i].UserID = &userID
+		casts[i].StoreID = &storeID
 	}
 
 	// トランザクション内で一括作成（一貫性のため）
-	if err := h.db.Transaction(func(tx *gorm.DB) error {
+	if err := h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
 		return tx.Create(&casts).Error
 	}); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -321,3 +274,93 @@ func (h *CastHandler) BulkCreate(c *gin.Context) {
 	}
 	c.JSON(http.StatusCreated, casts)
 }
+
+// castFieldNames 更新できる項目（JSONのキー名 → モデルのフィールド名）
+// GORMが自動的に正しいカラム名に変換してくれる
+var castFieldNames = map[string]string{
+	"photoUrl":          "PhotoURL",
+	"snsInfo":           "SnsInfo",
+	"name":              "Name",
+	"photos":            "Photos",
+	"birthday":          "Birthday",
+	"age":               "Age",
+	"champagneCallSong": "ChampagneCallSong",
+	"drinkPreference":   "DrinkPreference",
+	"favoriteDrinkId":   "FavoriteDrinkID",
+	"ice":               "Ice",
+	"carbonation":       "Carbonation",
+	"favoriteMixerId":   "FavoriteMixerID",
+	"smokes":            "Smokes",
+	"tobaccoType":       "TobaccoType",
+	"memos":             "Memos",
+}
+
+// castUpdates 更新リクエストを部分更新の値に変換（更新できない項目は無視する）
+func castUpdates(updateData map[string]interface{}) map[string]interface{} {
+	convertedData := make(map[string]interface{})
+	for key, value := range updateData {
+		if fieldName, ok := castFieldNames[key]; ok {
+			convertedData[fieldName] = value
+		}
+	}
+
+	// snsInfoフィールドがある場合、SnsInfo型に変換
+	if snsInfoData, ok := convertedData["SnsInfo"]; ok {
+		if snsInfoMap, ok := snsInfoData.(map[string]interface{}); ok {
+			var snsInfo models.SnsInfo
+			// JSONに変換してからパース
+			jsonBytes, err := json.Marshal(snsInfoMap)
+			if err == nil {
+				if err := json.Unmarshal(jsonBytes, &snsInfo); err == nil {
+					convertedData["SnsInfo"] = &snsInfo
+				}
+			}
+		} else if snsInfoData == nil {
+			convertedData["SnsInfo"] = (*models.SnsInfo)(nil)
+		}
+	}
+
+	// photosフィールドがある場合、Photos型に変換
+	if photosData, ok := convertedData["Photos"]; ok {
+		if photosArray, ok := photosData.([]interface{}); ok {
+			photos := make(models.Photos, len(photosArray))
+			for i, v := range photosArray {
+				if str, ok := v.(string); ok {
+					photos[i] = str
+				}
+			}
+			convertedData["Photos"] = photos
+		}
+	}
+
+	// memosフィールドがある場合、Memos型に変換
+	if memosData, ok := convertedData["Memos"]; ok {
+		if memosData == nil {
+			convertedData["Memos"] = models.Memos{}
+		} else {
+			// まずJSONにマーシャルしてからMemos型にアンマーシャル
+			jsonBytes, err := json.Marshal(memosData)
+			if err != nil {
+				log.Printf("Error marshaling memos: %v", err)
+				delete(convertedData, "Memos")
+			} else {
+				var memos models.Memos
+				if err := json.Unmarshal(jsonBytes, &memos); err != nil {
+					log.Printf("Error unmarshaling memos: %v, jsonBytes: %s", err, string(jsonBytes))
+					delete(convertedData, "Memos")
+				} else {
+					convertedData["Memos"] = memos
+				}
+			}
+		}
+	}
+	return convertedData
+}
+
+// canModifyCast 他のメンバーが登録したキャストは登録者本人と管理者のみ変更できる
+func canModifyCast(c *gin.Context, cast *models.Cast, userID uint) bool {
+	if cast.UserID == nil || *cast.UserID == userID {
+		return true
+	}
+	return middleware.HasPermission(middleware.GetRole(c), middleware.PermCastManage)
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hostnote/server/internal/models"
+)
+
+// TestCastUpdates 更新できない項目（店舗・ユーザーなど）を無視することをテスト
+func TestCastUpdates(t *testing.T) {
+	got := castUpdates(map[string]interface{}{
+		"name":     "りく",
+		"age":      float64(24),
+		"store_id": float64(2),
+		"storeId":  float64(2),
+		"user_id":  float64(3),
+		"id":       float64(9),
+	})
+	if len(got) != 2 || got["Name"] != "りく" || got["Age"] != float64(24) {
+		t.Errorf("castUpdates() = %v, want only Name and Age", got)
+	}
+}
+
+// TestCanModifyCast 他のメンバーが登録したキャストを変更できるロールをテスト
+func TestCanModifyCast(t *testing.T) {
+	owner := uint(1)
+	cast := &models.Cast{UserID: &owner}
+	tests := []struct {
+		role   string
+		userID uint
+		want   bool
+	}{
+		{models.RoleMember, 1, true},
+		{models.RoleMember, 2, false},
+		{models.RoleLeader, 2, false},
+		{models.RoleAdmin, 2, true},
+		{models.RoleSuperAdmin, 2, true},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(nil)
+		c.Set("role", tt.role)
+		if got := canModifyCast(c, cast, tt.userID); got != tt.want {
+			t.Errorf("%s (user %d): got %v, want %v", tt.role, tt.userID, got, tt.want)
+		}
+	}
+}
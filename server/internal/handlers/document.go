@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hostnote/server/internal/models"
+	"github.com/hostnote/server/internal/services"
+	"gorm.io/gorm"
+)
+
+type DocumentHandler struct {
+	db *gorm.DB
+}
+
+func NewDocumentHandler(db *gorm.DB) *DocumentHandler {
+	return &DocumentHandler{db: db}
+}
+
+// IssueDocumentRequest 領収書・請求書の発行リクエスト
+type IssueDocumentRequest struct {
+	Type        string `json:"type" binding:"required"` // receipt, invoice
+	Addressee   string `json:"addressee"`               // 宛名（領収書で省略した場合は「上様」）
+	Description string `json:"description"`             // 領収書の但し書き（省略時は「飲食代」）
+}
+
+// ListTableDocuments 卓記録に発行した書類の一覧を取得
+func (h *DocumentHandler) ListTableDocuments(c *gin.Context) {
+	userID, ok := getReadUserID(c, h.db)
+	if !ok {
+		return
+	}
+
+	id, err := parseID(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var documents []models.IssuedDocument
+	if err := h.db.WithContext(c).
+		Where("user_id = ? AND table_id = ?", userID, id).
+		Order("issued_at, id").
+		Find(&documents).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, documents)
+}
+
+// IssueTableDocument 会計済みの卓記録の領収書・請求書（適格請求書）をPDFで発行
+func (h *DocumentHandler) IssueTableDocument(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
+
+	id, err := parseID(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req IssueDocumentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	doc, pdf, err := services.IssueTableDocument(c, h.db, services.TableDocumentInput{
+		UserID:      userID,
+		StoreID:     storeID,
+		TableID:     id,
+		Type:        req.Type,
+		Addressee:   req.Addressee,
+		Description: req.Description,
+		Now:         time.Now(),
+	})
+	if err != nil {
+		respondDocumentError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.pdf"`, doc.Type, doc.Number))
+	c.Header("X-Document-Number", doc.Number)
+	c.Data(http.StatusCreated, "application/pdf", pdf)
+}
+
+// respondDocumentError 書類の発行のエラーを返す（発行できない状態は409）
+func respondDocumentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidDocumentType):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTableNotClosed),
+		errors.Is(err, services.ErrNothingReceived),
+		errors.Is(err, services.ErrNothingOutstanding),
+		errors.Is(err, services.ErrRegistrationNumberRequired),
+		errors.Is(err, services.ErrInvalidRegistrationNumber):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		handleDBError(c, err, "Table record not found")
+	}
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hostnote/server/internal/config"
+	"github.com/hostnote/server/internal/models"
+	"github.com/hostnote/server/internal/services"
+	"gorm.io/gorm"
+)
+
+// exportStaleAfter 作成中のまま残ったジョブ（サーバー再起動等）を失敗とみなすまでの時間
+const exportStaleAfter = time.Hour
+
+type ExportHandler struct {
+	db       *gorm.DB
+	exporter *services.Exporter
+}
+
+func NewExportHandler(db *gorm.DB) *ExportHandler {
+	return &ExportHandler{db: db, exporter: services.NewExporter(db, config.AppConfig)}
+}
+
+// ExportJobResponse エクスポートジョブ（作成済みの場合はダウンロードURL付き）
+type ExportJobResponse struct {
+	models.ExportJob
+	DownloadURL string `json:"downloadUrl,omitempty"`
+}
+
+// Create 個人データのエクスポートを開始（ZIPはバックグラウンドで作成）
+// 作成状況は Get で確認し、完了後に downloadUrl からダウンロードする
+func (h *ExportHandler) Create(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+
+	// 作成中のジョブがあれば重複して開始しない
+	var active models.ExportJob
+	err := h.db.Where("user_id = ? AND status IN ?", userID, []string{models.ExportStatusPending, models.ExportStatusRunning}).
+		Order("id DESC").First(&active).Error
+	if err == nil {
+		if time.Since(active.UpdatedAt) < exportStaleAfter {
+			c.JSON(http.StatusConflict, gin.H{"error": "エクスポートを作成中です。完了までお待ちください", "job": h.response(&active)})
+			return
+		}
+		h.db.Model(&active).Updates(map[string]interface{}{"status": models.ExportStatusFailed, "error": "timed out"})
+	}
+
+	job := models.ExportJob{UserID: userID, Status: models.ExportStatusPending}
+	if err := h.db.Create(&job).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.exporter.Start(job.ID)
+
+	c.JSON(http.StatusAccepted, h.response(&job))
+}
+
+// List エクスポートジョブ一覧を取得（新しい順）
+func (h *ExportHandler) List(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+
+	var jobs []models.ExportJob
+	if err := h.db.Where("user_id = ?", userID).Order("id DESC").Limit(20).Find(&jobs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make([]ExportJobResponse, len(jobs))
+	for i := range jobs {
+		response[i] = h.response(&jobs[i])
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Get エクスポートジョブの状態を取得
+func (h *ExportHandler) Get(c *gin.Context) {
+	job, ok := h.findJob(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, h.response(job))
+}
+
+// Download 作成済みのZIPをダウンロード
+func (h *ExportHandler) Download(c *gin.Context) {
+	job, ok := h.findJob(c)
+	if !ok {
+		return
+	}
+	if !job.IsDownloadable(time.Now()) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "ダウンロードできるファイルがありません"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.FileAttachment(h.exporter.Path(job), job.FileName)
+}
+
+// findJob 自分のエクスポートジョブを取得
+func (h *ExportHandler) findJob(c *gin.Context) (*models.ExportJob, bool) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return nil, false
+	}
+
+	id, err := parseID(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return nil, false
+	}
+
+	var job models.ExportJob
+	if err := h.db.Where("id = ? AND user_id = ?", id, userID).First(&job).Error; err != nil {
+		handleDBError(c, err, "Export not found")
+		return nil, false
+	}
+	return &job, true
+}
+
+// response ダウンロード可能な場合はURLを付ける
+func (h *ExportHandler) response(job *models.ExportJob) ExportJobResponse {
+	resp := ExportJobResponse{ExportJob: *job}
+	if job.IsDownloadable(time.Now()) {
+		resp.DownloadURL = fmt.Sprintf("/api/v1/account/export/%d/download", job.ID)
+	}
+	return resp
+}
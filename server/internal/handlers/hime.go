@@ -49,9 +49,12 @@ type HimeListItem struct {
 
 // List 姫一覧を取得（最適化版、ページネーション対応、photosとmemosを除外して軽量化）
 func (h *HimeHandler) List(c *gin.Context) {
-	userID, ok := getUserID(c)
+	userID, ok := getReadUserID(c, h.db)
+	if !ok {
+		return
+	}
+	storeID, ok := getStoreID(c, h.db)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
 		return
 	}
 
@@ -70,13 +73,18 @@ func (h *HimeHandler) List(c *gin.Context) {
 	}
 
 	var himes []models.Hime
-	query := h.db.Select("id, user_id, name, photo_url, sn_s_info, birthday, age, is_first_visit, tanto_cast_id, drink_preference, favorite_drink_id, ice, carbonation, mixer_preference, favorite_mixer_id, smokes, tobacco_type, created_at, updated_at").
+	query := h.db.WithContext(c).Select("id, user_id, name, photo_url, sn_s_info, birthday, age, is_first_visit, tanto_cast_id, drink_preference, favorite_drink_id, ice, carbonation, mixer_preference, favorite_mixer_id, smokes, tobacco_type, created_at, updated_at").
 		Where("user_id = ?", userID).
 		Preload("TantoCast", func(db *gorm.DB) *gorm.DB {
-			return db.Select("id, name, photo_url").Where("user_id = ?", userID)
+			return db.Select("id, name, photo_url").Where("store_id = ?", storeID)
 		}).
 		Order("created_at DESC")
 
+	// 名前で検索（暗号化しているためブラインドインデックスによる完全一致、空白と大文字小文字は区別しない）
+	if name := c.Query("name"); name != "" {
+		query = query.Where("name_index = ?", models.HimeNameIndex(name))
+	}
+
 	// 件数制限を適用
 	if err := query.Limit(limit).Offset(offset).Find(&himes).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -89,10 +97,10 @@ func (h *HimeHandler) List(c *gin.Context) {
 		item := HimeListItem{
 			ID:              hime.ID,
 			UserID:          hime.UserID,
-			Name:            hime.Name,
+			Name:            string(hime.Name),
 			PhotoURL:        hime.PhotoURL,
-			SnsInfo:         hime.SnsInfo,
-			Birthday:        hime.Birthday,
+			SnsInfo:         (*models.SnsInfo)(hime.SnsInfo),
+			Birthday:        hime.Birthday.StringPtr(),
 			Age:             hime.Age,
 			IsFirstVisit:    hime.IsFirstVisit,
 			TantoCastID:     hime.TantoCastID,
@@ -125,9 +133,12 @@ func (h *HimeHandler) List(c *gin.Context) {
 
 // Get 姫を取得
 func (h *HimeHandler) Get(c *gin.Context) {
-	userID, ok := getUserID(c)
+	userID, ok := getReadUserID(c, h.db)
+	if !ok {
+		return
+	}
+	storeID, ok := getStoreID(c, h.db)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
 		return
 	}
 
@@ -138,10 +149,10 @@ func (h *HimeHandler) Get(c *gin.Context) {
 	}
 
 	var hime models.Hime
-	if err := h.db.
+	if err := h.db.WithContext(c).
 		Where("user_id = ? AND id = ?", userID, id).
 		Preload("TantoCast", func(db *gorm.DB) *gorm.DB {
-			return db.Select("id, name, photo_url").Where("user_id = ?", userID)
+			return db.Select("id, name, photo_url").Where("store_id = ?", storeID)
 		}).
 		First(&hime).Error; err != nil {
 		if handleDBError(c, err, "Hime not found") {
@@ -170,7 +181,7 @@ func (h *HimeHandler) Create(c *gin.Context) {
 	// ユーザーIDを設定
 	hime.UserID = userID
 
-	if err := h.db.Create(&hime).Error; err != nil {
+	if err := h.db.WithContext(c).Create(&hime).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -184,6 +195,10 @@ func (h *HimeHandler) Update(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
 		return
 	}
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
 
 	id, err := parseID(c, "id")
 	if err != nil {
@@ -192,7 +207,7 @@ func (h *HimeHandler) Update(c *gin.Context) {
 	}
 
 	var hime models.Hime
-	if err := h.db.Where("user_id = ? AND id = ?", userID, id).First(&hime).Error; err != nil {
+	if err := h.db.WithContext(c).Where("user_id = ? AND id = ?", userID, id).First(&hime).Error; err != nil {
 		if handleDBError(c, err, "Hime not found") {
 			return
 		}
@@ -245,11 +260,23 @@ func (h *HimeHandler) Update(c *gin.Context) {
 			jsonBytes, err := json.Marshal(snsInfoMap)
 			if err == nil {
 				if err := json.Unmarshal(jsonBytes, &snsInfo); err == nil {
-					convertedData["SnsInfo"] = &snsInfo
+					convertedData["SnsInfo"] = (*models.EncryptedSnsInfo)(&snsInfo)
 				}
 			}
 		} else if snsInfoData == nil {
-			convertedData["SnsInfo"] = (*models.SnsInfo)(nil)
+			convertedData["SnsInfo"] = (*models.EncryptedSnsInfo)(nil)
+		}
+	}
+
+	// 暗号化して保存するフィールドを変換（名前のブラインドインデックスはモデルのフックで更新）
+	if name, ok := convertedData["Name"].(string); ok {
+		convertedData["Name"] = models.EncryptedString(name)
+	}
+	if birthdayData, ok := convertedData["Birthday"]; ok {
+		if birthday, ok := birthdayData.(string); ok {
+			convertedData["Birthday"] = models.NewEncryptedString(&birthday)
+		} else if birthdayData == nil {
+			convertedData["Birthday"] = (*models.EncryptedString)(nil)
 		}
 	}
 
@@ -269,7 +296,7 @@ func (h *HimeHandler) Update(c *gin.Context) {
 	// memosフィールドがある場合、Memos型に変換
 	if memosData, ok := convertedData["Memos"]; ok {
 		if memosData == nil {
-			convertedData["Memos"] = models.Memos{}
+			convertedData["Memos"] = models.EncryptedMemos{}
 		} else {
 			// まずJSONにマーシャルしてからMemos型にアンマーシャル
 			jsonBytes, err := json.Marshal(memosData)
@@ -282,23 +309,23 @@ func (h *HimeHandler) Update(c *gin.Context) {
 					log.Printf("Error unmarshaling memos: %v, jsonBytes: %s", err, string(jsonBytes))
 					delete(convertedData, "Memos")
 				} else {
-					convertedData["Memos"] = memos
+					convertedData["Memos"] = models.EncryptedMemos(memos)
 				}
 			}
 		}
 	}
 
 	// 更新を実行
-	if err := h.db.Model(&hime).Updates(convertedData).Error; err != nil {
+	if err := h.db.WithContext(c).Model(&hime).Updates(convertedData).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	// 更新後のデータを取得（Preload付き）
-	if err := h.db.
+	if err := h.db.WithContext(c).
 		Where("user_id = ? AND id = ?", userID, id).
 		Preload("TantoCast", func(db *gorm.DB) *gorm.DB {
-			return db.Select("id, name, photo_url").Where("user_id = ?", userID)
+			return db.Select("id, name, photo_url").Where("store_id = ?", storeID)
 		}).
 		First(&hime).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve updated record"})
@@ -322,7 +349,7 @@ func (h *HimeHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.Where("user_id = ? AND id = ?", userID, id).Delete(&models.Hime{}).Error; err != nil {
+	if err := h.db.WithContext(c).Where("user_id = ? AND id = ?", userID, id).Delete(&models.Hime{}).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -350,7 +377,7 @@ func (h *HimeHandler) BulkCreate(c *gin.Context) {
 	}
 
 	// トランザクション内で一括作成（一貫性のため）
-	if err := h.db.Transaction(func(tx *gorm.DB) error {
+	if err := h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
 		return tx.Create(&himes).Error
 	}); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
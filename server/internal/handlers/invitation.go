@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hostnote/server/internal/middleware"
+	"github.com/hostnote/server/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 招待の有効期限（時間）の既定値と上限
+const (
+	defaultInvitationHours = 72
+	maxInvitationHours     = 24 * 30
+)
+
+// errInvalidInvitation 招待コードが存在しない・期限切れ・取り消し済み
+var errInvalidInvitation = errors.New("招待コードが無効か、有効期限が切れています")
+
+type InvitationHandler struct {
+	db *gorm.DB
+}
+
+func NewInvitationHandler(db *gorm.DB) *InvitationHandler {
+	return &InvitationHandler{db: db}
+}
+
+type CreateInvitationRequest struct {
+	Role           string `json:"role" binding:"required"`
+	ExpiresInHours int    `json:"expiresInHours" binding:"omitempty,min=1"`
+	MaxUses        int    `json:"maxUses" binding:"omitempty,min=0"`
+}
+
+type AcceptInvitationRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// InvitationResponse 招待情報（招待リンク付き）
+type InvitationResponse struct {
+	models.StoreInvitation
+	URL string `json:"url"`
+}
+
+// InvitationPreview 招待コードから確認できる公開情報
+type InvitationPreview struct {
+	StoreName string    `json:"storeName"`
+	Role      string    `json:"role"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// List 所属店舗の招待一覧を取得
+func (h *InvitationHandler) List(c *gin.Context) {
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
+
+	var invitations []models.StoreInvitation
+	if err := h.db.Where("store_id = ?", storeID).Order("created_at DESC").Find(&invitations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make([]InvitationResponse, len(invitations))
+	for i, inv := range invitations {
+		response[i] = newInvitationResponse(inv)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Create 所属店舗への招待を作成
+func (h *InvitationHandler) Create(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
+
+	var req CreateInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !models.IsValidRole(req.Role) || req.Role == models.RoleSuperAdmin {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無効なロールです"})
+		return
+	}
+	// 自分より強いロールでの招待はできない
+	if models.RoleLevel(req.Role) > models.RoleLevel(middleware.GetRole(c)) {
+		middleware.Forbidden(c)
+		return
+	}
+
+	hours := req.ExpiresInHours
+	if hours == 0 {
+		hours = defaultInvitationHours
+	}
+	if hours > maxInvitationHours {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("有効期限は%d時間以内で指定してください", maxInvitationHours)})
+		return
+	}
+
+	code, err := generateInvitationCode()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "招待コードの生成に失敗しました"})
+		return
+	}
+
+	invitation := models.StoreInvitation{
+		StoreID:   storeID,
+		Code:      code,
+		Role:      req.Role,
+		ExpiresAt: time.Now().Add(time.Duration(hours) * time.Hour),
+		MaxUses:   req.MaxUses,
+		CreatedBy: userID,
+	}
+	if err := h.db.Create(&invitation).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, newInvitationResponse(invitation))
+}
+
+// Revoke 招待を取り消す
+func (h *InvitationHandler) Revoke(c *gin.Context) {
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
+
+	id, err := parseID(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var invitation models.StoreInvitation
+	if err := h.db.Where("id = ? AND store_id = ?", id, storeID).First(&invitation).Error; err != nil {
+		handleDBError(c, err, "Invitation not found")
+		return
+	}
+
+	if invitation.RevokedAt == nil {
+		now := time.Now()
+		if err := h.db.Model(&invitation).Update("revoked_at", now).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Preview 招待コードの店舗名・ロールを確認（認証不要、登録画面用）
+func (h *InvitationHandler) Preview(c *gin.Context) {
+	var invitation models.StoreInvitation
+	if err := h.db.Preload("Store").Where("code = ?", normalizeInvitationCode(c.Param("code"))).First(&invitation).Error; err != nil ||
+		!invitation.IsUsable(time.Now()) || invitation.Store == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": errInvalidInvitation.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, InvitationPreview{
+		StoreName: invitation.Store.Name,
+		Role:      invitation.Role,
+		ExpiresAt: invitation.ExpiresAt,
+	})
+}
+
+// Accept ログイン中のユーザーが招待を受けて店舗に参加する
+// ロールが変わるため新しいトークンを返す
+func (h *InvitationHandler) Accept(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+
+	var req AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&user, userID).Error; err != nil {
+			return err
+		}
+		return acceptInvitation(tx, req.Code, &user)
+	})
+	if err != nil {
+		if errors.Is(err, errInvalidInvitation) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		handleDBError(c, err, "ユーザーが見つかりません")
+		return
+	}
+
+	// リフレッシュトークンは現在のセッションのものをそのまま使う
+	sessionID, _ := getSessionID(c)
+	resp, err := newAuthResponse(&user, sessionID, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "トークン生成に失敗しました"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// acceptInvitation 招待コードを検証し、ユーザーを招待先の店舗に所属させてロールを付与する
+// トランザクション内で呼び出すこと（招待行をロックして使用回数を更新する）
+func acceptInvitation(tx *gorm.DB, code string, user *models.User) error {
+	var invitation models.StoreInvitation
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("code = ?", normalizeInvitationCode(code)).
+		First(&invitation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errInvalidInvitation
+	}
+	if err != nil {
+		return err
+	}
+	if !invitation.IsUsable(time.Now()) {
+		return errInvalidInvitation
+	}
+
+	if err := tx.Model(&invitation).Update("used_count", gorm.Expr("used_count + 1")).Error; err != nil {
+		return err
+	}
+	if err := joinStore(tx, user.ID, invitation.StoreID); err != nil {
+		return err
+	}
+
+	// スーパー管理者は店舗をまたいで管理するためロールを変更しない
+	if user.Role == models.RoleSuperAdmin {
+		return nil
+	}
+	// 別店舗のリーダー配下からは外れる
+	if err := tx.Model(user).Updates(map[string]interface{}{
+		"role":      invitation.Role,
+		"leader_id": nil,
+	}).Error; err != nil {
+		return err
+	}
+	user.Role = invitation.Role
+	user.LeaderID = nil
+	return nil
+}
+
+// generateInvitationCode 招待コードを生成（入力しやすいよう英大文字と数字のみ）
+func generateInvitationCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b), nil
+}
+
+// normalizeInvitationCode 手入力された招待コードを正規化
+func normalizeInvitationCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// newInvitationResponse 招待リンクを付与したレスポンスを作成
+func newInvitationResponse(invitation models.StoreInvitation) InvitationResponse {
+	frontendURL := os.Getenv("FRONTEND_URL")
+	if frontendURL == "" {
+		frontendURL = "http://localhost:5173"
+	}
+	return InvitationResponse{
+		StoreInvitation: invitation,
+		URL:             fmt.Sprintf("%s/invite/%s", frontendURL, invitation.Code),
+	}
+}
@@ -17,18 +17,28 @@ func NewMenuHandler(db *gorm.DB) *MenuHandler {
 	return &MenuHandler{db: db}
 }
 
-// List メニュー一覧を取得（共通データ）
+// List メニュー一覧を取得（店舗共通データ）
 func (h *MenuHandler) List(c *gin.Context) {
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
+
 	var menus []models.Menu
-	if err := h.db.Order("category, `order`, id").Find(&menus).Error; err != nil {
+	if err := h.db.WithContext(c).Where("store_id = ?", storeID).Order("category, `order`, id").Find(&menus).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, menus)
 }
 
-// Get メニューを取得（共通データ）
+// Get メニューを取得（店舗共通データ）
 func (h *MenuHandler) Get(c *gin.Context) {
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
+
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
@@ -36,7 +46,7 @@ func (h *MenuHandler) Get(c *gin.Context) {
 	}
 
 	var menu models.Menu
-	if err := h.db.First(&menu, id).Error; err != nil {
+	if err := h.db.WithContext(c).Where("store_id = ?", storeID).First(&menu, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Menu not found"})
 			return
@@ -47,26 +57,37 @@ func (h *MenuHandler) Get(c *gin.Context) {
 	c.JSON(http.StatusOK, menu)
 }
 
-// Create メニューを作成（共通データ）
+// Create メニューを作成（店舗共通データ）
 func (h *MenuHandler) Create(c *gin.Context) {
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
+
 	var menu models.Menu
 	if err := c.ShouldBindJSON(&menu); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// IDを無視（自動生成）
+	// IDを無視（自動生成）、店舗IDを設定
 	menu.ID = 0
+	menu.StoreID = storeID
 
-	if err := h.db.Create(&menu).Error; err != nil {
+	if err := h.db.WithContext(c).Create(&menu).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusCreated, menu)
 }
 
-// Update メニューを更新（共通データ）
+// Update メニューを更新（店舗共通データ）
 func (h *MenuHandler) Update(c *gin.Context) {
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
+
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
@@ -74,7 +95,7 @@ func (h *MenuHandler) Update(c *gin.Context) {
 	}
 
 	var menu models.Menu
-	if err := h.db.First(&menu, id).Error; err != nil {
+	if err := h.db.WithContext(c).Where("store_id = ?", storeID).First(&menu, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Menu not found"})
 			return
@@ -88,22 +109,31 @@ func (h *MenuHandler) Update(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.Save(&menu).Error; err != nil {
+	// ID・店舗IDは変更しない
+	menu.ID = uint(id)
+	menu.StoreID = storeID
+
+	if err := h.db.WithContext(c).Save(&menu).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, menu)
 }
 
-// Delete メニューを削除（共通データ）
+// Delete メニューを削除（店舗共通データ）
 func (h *MenuHandler) Delete(c *gin.Context) {
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
+
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
 		return
 	}
 
-	result := h.db.Delete(&models.Menu{}, id)
+	result := h.db.WithContext(c).Where("store_id = ?", storeID).Delete(&models.Menu{}, id)
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
 		return
@@ -117,20 +147,26 @@ func (h *MenuHandler) Delete(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Menu deleted successfully"})
 }
 
-// BulkCreate メニューを一括作成（共通データ）
+// BulkCreate メニューを一括作成（店舗共通データ）
 func (h *MenuHandler) BulkCreate(c *gin.Context) {
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
+
 	var menus []models.Menu
 	if err := c.ShouldBindJSON(&menus); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// IDを無視（自動生成）
+	// IDを無視（自動生成）、店舗IDを設定
 	for i := range menus {
 		menus[i].ID = 0
+		menus[i].StoreID = storeID
 	}
 
-	if err := h.db.Create(&menus).Error; err != nil {
+	if err := h.db.WithContext(c).Create(&menus).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -1,8 +1,6 @@
 package handlers
 
 import (
-	"encoding/json"
-	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -27,7 +25,7 @@ func (h *MyCastHandler) Get(c *gin.Context) {
 	}
 
 	var cast models.Cast
-	if err := h.db.Where("user_id = ?", userID).First(&cast).Error; err != nil {
+	if err := h.db.WithContext(c).Where("user_id = ?", userID).First(&cast).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "キャスト情報が見つかりません"})
 			return
@@ -49,7 +47,7 @@ func (h *MyCastHandler) Create(c *gin.Context) {
 
 	// 既にキャスト情報が存在するかチェック
 	var existingCast models.Cast
-	if err := h.db.Where("user_id = ?", userID).First(&existingCast).Error; err == nil {
+	if err := h.db.WithContext(c).Where("user_id = ?", userID).First(&existingCast).Error; err == nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "既にキャスト情報が登録されています"})
 		return
 	}
@@ -60,11 +58,17 @@ func (h *MyCastHandler) Create(c *gin.Context) {
 		return
 	}
 
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
+
 	uid := userID.(uint)
 	cast.UserID = &uid
-	cast.ID = 0 // IDを無視（自動生成）
+	cast.StoreID = &storeID // 店舗のキャスト一覧にも表示される
+	cast.ID = 0             // IDを無視（自動生成）
 
-	if err := h.db.Create(&cast).Error; err != nil {
+	if err := h.db.WithContext(c).Create(&cast).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "キャスト情報の作成に失敗しました"})
 		return
 	}
@@ -81,7 +85,7 @@ func (h *MyCastHandler) Update(c *gin.Context) {
 	}
 
 	var cast models.Cast
-	if err := h.db.Where("user_id = ?", userID).First(&cast).Error; err != nil {
+	if err := h.db.WithContext(c).Where("user_id = ?", userID).First(&cast).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "キャスト情報が見つかりません"})
 			return
@@ -96,97 +100,16 @@ func (h *MyCastHandler) Update(c *gin.Context) {
 		return
 	}
 
-	// JSONのキー名（camelCase）をモデルのフィールド名（PascalCase）に変換
-	fieldNameMap := map[string]string{
-		"photoUrl":          "PhotoURL",
-		"snsInfo":           "SnsInfo",
-		"name":              "Name",
-		"photos":            "Photos",
-		"birthday":          "Birthday",
-		"age":               "Age",
-		"champagneCallSong": "ChampagneCallSong",
-		"drinkPreference":   "DrinkPreference",
-		"favoriteDrinkId":   "FavoriteDrinkID",
-		"ice":               "Ice",
-		"carbonation":       "Carbonation",
-		"favoriteMixerId":   "FavoriteMixerID",
-		"smokes":            "Smokes",
-		"tobaccoType":       "TobaccoType",
-		"memos":             "Memos",
-	}
-
-	// キー名を変換
-	convertedData := make(map[string]interface{})
-	for key, value := range updateData {
-		if fieldName, ok := fieldNameMap[key]; ok {
-			convertedData[fieldName] = value
-		} else {
-			convertedData[key] = value
-		}
-	}
-
-	// snsInfoフィールドがある場合、SnsInfo型に変換
-	if snsInfoData, ok := convertedData["SnsInfo"]; ok {
-		if snsInfoMap, ok := snsInfoData.(map[string]interface{}); ok {
-			var snsInfo models.SnsInfo
-			jsonBytes, err := json.Marshal(snsInfoMap)
-			if err == nil {
-				if err := json.Unmarshal(jsonBytes, &snsInfo); err == nil {
-					convertedData["SnsInfo"] = &snsInfo
-				}
-			}
-		} else if snsInfoData == nil {
-			convertedData["SnsInfo"] = (*models.SnsInfo)(nil)
-		}
-	}
-
-	// photosフィールドがある場合、Photos型に変換
-	if photosData, ok := convertedData["Photos"]; ok {
-		if photosArray, ok := photosData.([]interface{}); ok {
-			photos := make(models.Photos, len(photosArray))
-			for i, v := range photosArray {
-				if str, ok := v.(string); ok {
-					photos[i] = str
-				}
-			}
-			convertedData["Photos"] = photos
-		}
-	}
-
-	// memosフィールドがある場合、Memos型に変換
-	if memosData, ok := convertedData["Memos"]; ok {
-		if memosData == nil {
-			convertedData["Memos"] = models.Memos{}
-		} else {
-			// まずJSONにマーシャルしてからMemos型にアンマーシャル
-			jsonBytes, err := json.Marshal(memosData)
-			if err != nil {
-				log.Printf("Error marshaling memos: %v", err)
-				delete(convertedData, "Memos")
-			} else {
-				var memos models.Memos
-				if err := json.Unmarshal(jsonBytes, &memos); err != nil {
-					log.Printf("Error unmarshaling memos: %v, jsonBytes: %s", err, string(jsonBytes))
-					delete(convertedData, "Memos")
-				} else {
-					convertedData["Memos"] = memos
-				}
-			}
-		}
-	}
-
-	// UserIDは変更しない
-	uid := userID.(uint)
-	convertedData["UserID"] = &uid
-	convertedData["ID"] = cast.ID
+	// 更新できる項目のみを反映（店舗・ユーザーなどは変更させない）
+	convertedData := castUpdates(updateData)
 
-	if err := h.db.Model(&cast).Updates(convertedData).Error; err != nil {
+	if err := h.db.WithContext(c).Model(&cast).Updates(convertedData).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "キャスト情報の更新に失敗しました"})
 		return
 	}
 
 	// 更新後のデータを取得
-	if err := h.db.Where("user_id = ?", userID).First(&cast).Error; err != nil {
+	if err := h.db.WithContext(c).Where("user_id = ?", userID).First(&cast).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新後のデータの取得に失敗しました"})
 		return
 	}
@@ -203,7 +126,7 @@ func (h *MyCastHandler) Check(c *gin.Context) {
 	}
 
 	var count int64
-	h.db.Model(&models.Cast{}).Where("user_id = ?", userID).Count(&count)
+	h.db.WithContext(c).Model(&models.Cast{}).Where("user_id = ?", userID).Count(&count)
 
 	c.JSON(http.StatusOK, gin.H{
 		"exists": count > 0,
@@ -0,0 +1,509 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hostnote/server/internal/models"
+	"github.com/hostnote/server/internal/services"
+	"gorm.io/gorm"
+)
+
+// errTableOrderNotFound 取り消す注文が卓にない
+var errTableOrderNotFound = errors.New("Order not found")
+
+// errTableHasPayments 支払いを登録済みの卓の削除
+var errTableHasPayments = errors.New("支払いを登録済みの卓は削除できません。先に支払いを削除してください")
+
+// errTableHasDocuments 領収書・請求書を発行済みの卓の削除
+var errTableHasDocuments = errors.New("領収書・請求書を発行済みの卓は削除できません")
+
+// TableOrderRequest 接客中の卓への注文の追加リクエスト
+type TableOrderRequest struct {
+	MenuID   uint `json:"menuId" binding:"required"`
+	Quantity int  `json:"quantity" binding:"omitempty,min=1"` // 省略時は1
+	HimeID   uint `json:"himeId"`                             // 注文した姫（省略時は卓全体）
+}
+
+// CloseTableRequest 卓の会計リクエスト
+type CloseTableRequest struct {
+	ClosedAt             *RequestTime              `json:"closedAt"`             // 会計日時（省略時は現在）
+	StayHours            *float64                  `json:"stayHours"`            // 滞在時間（省略時は入店から会計日時まで、再オープンした卓は元の滞在時間）
+	UseLatestPricingRule bool                      `json:"useLatestPricingRule"` // 再オープンした卓を最新の料金ルールで会計し直す（省略時は元の料金ルール）
+	VisitType            *string                   `json:"visitType"`
+	SplitMode            *string                   `json:"splitMode"`
+	Allocations          []services.HimeAllocation `json:"allocations"` // splitMode が custom の場合の負担額
+}
+
+// Open 接客中の卓を開く（入店日時は省略時は現在）
+// 姫・キャストは作成と同じ形式で指定し、注文は注文エンドポイントで追加していく
+func (h *TableHandler) Open(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
+
+	var req TableRecordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	datetime := time.Now()
+	if req.Datetime.isSet() {
+		datetime = req.Datetime.Time
+	}
+
+	record := models.TableRecord{
+		UserID:      userID,
+		Datetime:    datetime,
+		TableNumber: nonEmpty(req.TableNumber),
+		Memo:        nonEmpty(req.Memo),
+		SalesInfo:   &models.SalesInfo{VisitType: req.VisitType, SplitMode: req.SplitMode},
+		Status:      models.TableStatusOpen,
+	}
+
+	err := h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&record).Error; err != nil {
+			return err
+		}
+		// 注文のない状態の会計で来店区分と割り方を確認する
+		bill, err := services.RunningBill(c, tx, storeID, &record, record.Datetime)
+		if err != nil {
+			return err
+		}
+		record.SalesInfo = bill
+		if err := tx.Save(&record).Error; err != nil {
+			return err
+		}
+		return createTableRelations(tx, userID, storeID, &record, &req)
+	})
+	if err != nil {
+		respondTableError(c, err)
+		return
+	}
+
+	h.respondTableRecord(c, http.StatusCreated, record, userID, storeID)
+}
+
+// ListOrders 卓の注文を注文順に取得
+func (h *TableHandler) ListOrders(c *gin.Context) {
+	userID, ok := getReadUserID(c, h.db)
+	if !ok {
+		return
+	}
+
+	id, err := parseID(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var record models.TableRecord
+	if err := h.db.WithContext(c).Select("id").Where("user_id = ? AND id = ?", userID, id).First(&record).Error; err != nil {
+		if handleDBError(c, err, "Table record not found") {
+			return
+		}
+	}
+
+	var orders []models.TableOrder
+	if err := h.db.WithContext(c).Where("table_id = ?", record.ID).Order("ordered_at, id").Find(&orders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, orders)
+}
+
+// AddOrder 接客中の卓に注文を追加し、現在の会計を返す
+func (h *TableHandler) AddOrder(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
+
+	id, err := parseID(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req TableOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Quantity == 0 {
+		req.Quantity = 1
+	}
+
+	now := time.Now()
+	order := models.TableOrder{
+		TableID:   id,
+		MenuID:    req.MenuID,
+		HimeID:    req.HimeID,
+		Quantity:  req.Quantity,
+		OrderedBy: userID,
+		OrderedAt: now,
+	}
+	var record *models.TableRecord
+	err = h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if record, err = services.LockOpenTable(tx, userID, id); err != nil {
+			return err
+		}
+		if order.HimeID != 0 {
+			var count int64
+			if err := tx.Model(&models.TableHime{}).Where("table_id = ? AND hime_id = ?", id, order.HimeID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				return services.ErrAllocationHime
+			}
+		}
+		// 注文時のメニューの単価を記録し、後でメニューの価格を変更しても注文した時の単価で会計する
+		var menu models.Menu
+		if err := tx.Select("id", "price").Where("store_id = ? AND id = ?", storeID, order.MenuID).First(&menu).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: menuId %d", services.ErrUnknownMenuItem, order.MenuID)
+			}
+			return err
+		}
+		order.UnitPrice = &menu.Price
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+		// 追加した注文を含めて計算し、数量の誤りなどはロールバックする
+		return h.saveRunningBill(c, tx, storeID, record, now)
+	})
+	if err != nil {
+		respondTableError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"order": order, "salesInfo": record.SalesInfo})
+}
+
+// DeleteOrder 接客中の卓の注文を取り消し、現在の会計を返す
+func (h *TableHandler) DeleteOrder(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
+
+	id, err := parseID(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	orderID, err := parseID(c, "orderId")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var record *models.TableRecord
+	err = h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if record, err = services.LockOpenTable(tx, userID, id); err != nil {
+			return err
+		}
+		result := tx.Where("table_id = ? AND id = ?", id, orderID).Delete(&models.TableOrder{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errTableOrderNotFound
+		}
+		return h.saveRunningBill(c, tx, storeID, record, time.Now())
+	})
+	if err != nil {
+		respondTableError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Deleted", "salesInfo": record.SalesInfo})
+}
+
+// Bill 卓の会計を取得（接客中の卓は現在時刻までの滞在時間で計算し、保存しない）
+func (h *TableHandler) Bill(c *gin.Context) {
+	userID, ok := getReadUserID(c, h.db)
+	if !ok {
+		return
+	}
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
+
+	id, err := parseID(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var record models.TableRecord
+	if err := h.db.WithContext(c).Where("user_id = ? AND id = ?", userID, id).First(&record).Error; err != nil {
+		if handleDBError(c, err, "Table record not found") {
+			return
+		}
+	}
+	if record.Status != models.TableStatusOpen {
+		c.JSON(http.StatusOK, record.SalesInfo)
+		return
+	}
+
+	bill, err := services.RunningBill(c, h.db, storeID, &record, time.Now())
+	if err != nil {
+		respondBillingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, bill)
+}
+
+// Close 接客中の卓を会計する
+// 注文と滞在時間から売上情報を確定し、割り方に従って姫ごとの負担額を記録する
+func (h *TableHandler) Close(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
+
+	id, err := parseID(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	// 本文は省略できる（現在時刻で会計する）
+	var req CloseTableRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	closedAt := time.Now()
+	if req.ClosedAt.isSet() {
+		closedAt = req.ClosedAt.Time
+	}
+
+	var record *models.TableRecord
+	err = h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if record, err = services.LockOpenTable(tx, userID, id); err != nil {
+			return err
+		}
+		if record.SalesInfo == nil {
+			record.SalesInfo = &models.SalesInfo{}
+		}
+		if req.VisitType != nil {
+			record.SalesInfo.VisitType = *req.VisitType
+		}
+		if req.SplitMode != nil {
+			record.SalesInfo.SplitMode = *req.SplitMode
+		}
+
+		// 滞在時間を指定した場合は入店日時からその時間までで延長を数える
+		// （再オープンした卓も、指定した滞在時間・最新の料金ルールを優先する）
+		until := closedAt
+		if req.StayHours != nil {
+			if *req.StayHours < 0 {
+				return services.ErrInvalidStayHours
+			}
+			until = record.Datetime.Add(time.Duration(*req.StayHours * float64(time.Hour)))
+			record.ReopenedStayHours = nil
+		}
+		if req.UseLatestPricingRule {
+			record.ReopenedPricingRuleVersion = nil
+		}
+		bill, err := services.RunningBill(c, tx, storeID, record, until)
+		if err != nil {
+			return err
+		}
+		record.SalesInfo = bill
+		record.Status = models.TableStatusClosed
+		record.ClosedAt = &closedAt
+		record.ReopenedStayHours = nil
+		record.ReopenedPricingRuleVersion = nil
+		if err := tx.Save(record).Error; err != nil {
+			return err
+		}
+
+		// 姫ごとの負担額を確定する
+		var tableHimes []models.TableHime
+		if err := tx.Where("table_id = ?", record.ID).Order("id").Find(&tableHimes).Error; err != nil {
+			return err
+		}
+		himeIDs := make([]uint, len(tableHimes))
+		for i, th := range tableHimes {
+			himeIDs[i] = th.HimeID
+		}
+		allocations, err := services.SplitBill(bill, himeIDs, req.Allocations)
+		if err != nil {
+			return err
+		}
+		for i := range allocations {
+			if err := tx.Model(&tableHimes[i]).Update("allocation", allocations[i].Amount).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		respondTableError(c, err)
+		return
+	}
+
+	h.respondTableRecord(c, http.StatusOK, *record, userID, storeID)
+}
+
+// Void 卓を取り消す（売上や分析の対象から外す。支払い・領収書・請求書のある卓は取り消せない）
+func (h *TableHandler) Void(c *gin.Context) {
+	h.changeTableStatus(c, func(tx *gorm.DB, record *models.TableRecord) error {
+		if record.Status == models.TableStatusVoided {
+			return services.ErrTableClosed
+		}
+		if err := checkNoPayments(tx, record.ID); err != nil {
+			return err
+		}
+		if err := checkNoDocuments(tx, record.ID); err != nil {
+			return err
+		}
+		now := time.Now()
+		record.Status = models.TableStatusVoided
+		record.ClosedAt = &now
+		return nil
+	})
+}
+
+// Reopen 会計済みの卓を再オープンして注文や姫を変更できるようにする（支払い・領収書・請求書のある卓は再オープンできない）
+// 注文の記録がない卓は売上情報の注文を卓の注文に戻す
+// 元の会計の滞在時間・料金ルールを残し、会計し直す際は指定がなければそれを使う（再オープンしていた時間は延長に数えない）
+func (h *TableHandler) Reopen(c *gin.Context) {
+	h.changeTableStatus(c, func(tx *gorm.DB, record *models.TableRecord) error {
+		if record.Status != models.TableStatusClosed {
+			return services.ErrTableNotClosed
+		}
+		if err := checkNoPayments(tx, record.ID); err != nil {
+			return err
+		}
+		if err := checkNoDocuments(tx, record.ID); err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.TableOrder{}).Where("table_id = ?", record.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 && record.SalesInfo != nil && len(record.SalesInfo.OrderItems) > 0 {
+			lines, err := services.LinesFromOrderItems(record.ID, record.UserID, record.SalesInfo.OrderItems, time.Now())
+			if err != nil {
+				return err
+			}
+			if err := tx.Create(&lines).Error; err != nil {
+				return err
+			}
+		}
+
+		if record.SalesInfo != nil {
+			stayHours, version := record.SalesInfo.StayHours, record.SalesInfo.PricingRuleVersion
+			record.ReopenedStayHours = &stayHours
+			record.ReopenedPricingRuleVersion = &version
+		}
+		record.Status = models.TableStatusOpen
+		record.ClosedAt = nil
+		return nil
+	})
+}
+
+// changeTableStatus 卓記録を行ロックして状態を変更し、変更後の卓記録を返す
+func (h *TableHandler) changeTableStatus(c *gin.Context, change func(tx *gorm.DB, record *models.TableRecord) error) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
+
+	id, err := parseID(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var record *models.TableRecord
+	err = h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if record, err = services.LockTable(tx, userID, id); err != nil {
+			return err
+		}
+		if err := change(tx, record); err != nil {
+			return err
+		}
+		return tx.Save(record).Error
+	})
+	if err != nil {
+		respondTableError(c, err)
+		return
+	}
+
+	h.respondTableRecord(c, http.StatusOK, *record, userID, storeID)
+}
+
+// saveRunningBill 接客中の卓の会計を計算し直して売上情報に保存する
+func (h *TableHandler) saveRunningBill(c *gin.Context, tx *gorm.DB, storeID uint, record *models.TableRecord, until time.Time) error {
+	bill, err := services.RunningBill(c, tx, storeID, record, until)
+	if err != nil {
+		return err
+	}
+	record.SalesInfo = bill
+	return tx.Model(record).Update("sales_info", bill).Error
+}
+
+// checkNoPayments 卓に支払いが登録されていないことを確認する
+func checkNoPayments(tx *gorm.DB, tableID uint) error {
+	var count int64
+	if err := tx.Model(&models.Payment{}).Where("table_id = ?", tableID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return services.ErrTableHasPayments
+	}
+	return nil
+}
+
+// checkNoDocuments 卓に領収書・請求書が発行されていないことを確認する（発行済みの金額を変えさせない）
+func checkNoDocuments(tx *gorm.DB, tableID uint) error {
+	var count int64
+	if err := tx.Model(&models.IssuedDocument{}).Where("table_id = ?", tableID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return services.ErrTableHasDocuments
+	}
+	return nil
+}
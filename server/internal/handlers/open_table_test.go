@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hostnote/server/internal/models"
+	"github.com/hostnote/server/internal/services"
+	"gorm.io/gorm"
+)
+
+// newOpenTableTestRouter 接客中の卓のエンドポイントのみのルーター（ユーザー1、店舗1）
+func newOpenTableTestRouter(t *testing.T) (*gin.Engine, *gorm.DB) {
+	gin.SetMode(gin.TestMode)
+	db := newTestDB(t, &models.TableRecord{}, &models.TableHime{}, &models.TableCast{}, &models.TableOrder{},
+		&models.Hime{}, &models.Cast{}, &models.Menu{}, &models.PricingRule{}, &models.Payment{}, &models.IssuedDocument{})
+	h := NewTableHandler(db)
+	r := gin.New()
+	r.Use(withUser(1), func(c *gin.Context) { c.Set("storeID", uint(1)) })
+	r.POST("/table/:id/orders", h.AddOrder)
+	r.POST("/table/:id/close", h.Close)
+	r.POST("/table/:id/void", h.Void)
+	r.POST("/table/:id/reopen", h.Reopen)
+	return r, db
+}
+
+// postTable 卓のエンドポイントにJSONをPOSTし、応答の売上情報を返す
+func postTable(t *testing.T, r http.Handler, path, body string, want int) *models.SalesInfo {
+	t.Helper()
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	if w.Code != want {
+		t.Fatalf("%s: status = %d, want %d (%s)", path, w.Code, want, w.Body.String())
+	}
+	var resp struct {
+		SalesInfo *models.SalesInfo `json:"salesInfo"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%s: decode response: %v", path, err)
+	}
+	return resp.SalesInfo
+}
+
+// TestReopenKeepsOriginalPricing 再オープンした卓は、指定がなければ元の滞在時間・料金ルール・注文時の単価で会計し直すことをテスト
+func TestReopenKeepsOriginalPricing(t *testing.T) {
+	r, db := newOpenTableTestRouter(t)
+	for _, v := range []interface{}{
+		&models.Menu{ID: 1, StoreID: 1, Name: "ボトル", Price: 5000, Category: "ボトル"},
+		&models.TableRecord{ID: 1, UserID: 1, Datetime: time.Now().Add(-10 * time.Hour), Status: models.TableStatusOpen,
+			SalesInfo: &models.SalesInfo{VisitType: services.VisitTypeNormal}},
+	} {
+		if err := db.Create(v).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	postTable(t, r, "/table/1/orders", `{"menuId":1}`, http.StatusCreated)
+	// セット1000円＋ボトル5000円、税10%（既定の料金ルール）
+	closed := postTable(t, r, "/table/1/close", `{"stayHours":1}`, http.StatusOK)
+	if closed.Total != 6600 {
+		t.Fatalf("first close total = %v, want 6600", closed.Total)
+	}
+
+	// 会計後にメニューの価格と料金ルールを変更する
+	if err := db.Model(&models.Menu{}).Where("id = ?", 1).Update("price", 8000).Error; err != nil {
+		t.Fatal(err)
+	}
+	rule := services.DefaultPricingRule(1)
+	rule.Version = 1
+	rule.SetPriceNormal = 3000
+	if err := db.Create(rule).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	postTable(t, r, "/table/1/reopen", ``, http.StatusOK)
+	reclosed := postTable(t, r, "/table/1/close", ``, http.StatusOK)
+	if reclosed.Total != 6600 || reclosed.StayHours != 1 || reclosed.PricingRuleVersion != 0 {
+		t.Errorf("re-close = total %v, stay %v, rule v%d, want 6600, 1, v0", reclosed.Total, reclosed.StayHours, reclosed.PricingRuleVersion)
+	}
+
+	// 滞在時間と最新の料金ルールを指定した場合はそれで計算する（注文の単価は注文時のまま）
+	postTable(t, r, "/table/1/reopen", ``, http.StatusOK)
+	repriced := postTable(t, r, "/table/1/close", `{"stayHours":2,"useLatestPricingRule":true}`, http.StatusOK)
+	// セット3000円＋延長1000円＋ボトル5000円、税10%
+	if repriced.Total != 9900 || repriced.PricingRuleVersion != 1 {
+		t.Errorf("repriced = total %v, rule v%d, want 9900, v1", repriced.Total, repriced.PricingRuleVersion)
+	}
+
+	var record models.TableRecord
+	db.First(&record, 1)
+	if record.ReopenedStayHours != nil || record.ReopenedPricingRuleVersion != nil {
+		t.Errorf("reopened pricing kept after close: stay %v, rule %v", record.ReopenedStayHours, record.ReopenedPricingRuleVersion)
+	}
+}
+
+// TestVoidReopenWithDocuments 領収書・請求書を発行済みの卓は取り消し・再オープンせず409を返すことをテスト
+func TestVoidReopenWithDocuments(t *testing.T) {
+	r, db := newOpenTableTestRouter(t)
+	now := time.Now()
+	for _, v := range []interface{}{
+		&models.TableRecord{ID: 1, UserID: 1, Datetime: now, Status: models.TableStatusClosed, ClosedAt: &now, SalesInfo: &models.SalesInfo{Total: 10000}},
+		&models.TableRecord{ID: 2, UserID: 1, Datetime: now, Status: models.TableStatusClosed, ClosedAt: &now, SalesInfo: &models.SalesInfo{Total: 10000}},
+		&models.IssuedDocument{UserID: 1, TableID: 1, Type: models.DocumentTypeReceipt, Number: "R-000001", Amount: 10000, IssuedAt: now},
+	} {
+		if err := db.Create(v).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	postTable(t, r, "/table/1/void", ``, http.StatusConflict)
+	postTable(t, r, "/table/1/reopen", ``, http.StatusConflict)
+	var record models.TableRecord
+	db.First(&record, 1)
+	if record.Status != models.TableStatusClosed {
+		t.Errorf("status = %q, want closed (unchanged)", record.Status)
+	}
+
+	// 書類のない卓は再オープン・取り消しできる
+	postTable(t, r, "/table/2/reopen", ``, http.StatusOK)
+	postTable(t, r, "/table/2/void", ``, http.StatusOK)
+}
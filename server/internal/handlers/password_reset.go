@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hostnote/server/internal/models"
+	"github.com/hostnote/server/internal/services"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// passwordResetTTL パスワード再設定リンクの有効期間
+const passwordResetTTL = time.Hour
+
+// errInvalidResetToken 再設定トークンが存在しない・期限切れ・使用済み
+var errInvalidResetToken = errors.New("再設定リンクが無効か、有効期限が切れています")
+
+type PasswordResetRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type PasswordResetConfirmRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"newPassword" binding:"required,min=6"`
+}
+
+// RequestPasswordReset パスワード再設定メールを送信
+// メールアドレスの登録有無が分からないよう、結果に関わらず同じレスポンスを返す
+func (h *AuthHandler) RequestPasswordReset(c *gin.Context) {
+	var req PasswordResetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{"message": "登録済みのメールアドレスの場合、パスワード再設定の案内を送信しました"}
+
+	var user models.User
+	if err := h.db.Where("email = ?", req.Email).First(&user).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Error fetching user for password reset: %v", err)
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	token, err := generateRandomToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "トークン生成に失敗しました"})
+		return
+	}
+
+	// 未使用の古いトークンは無効化し、最新のリンクのみ有効にする
+	if err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.PasswordResetToken{
+			UserID:    user.ID,
+			TokenHash: hashToken(token),
+			ExpiresAt: time.Now().Add(passwordResetTTL),
+			IPAddress: c.ClientIP(),
+		}).Error
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "パスワード再設定の受付に失敗しました"})
+		return
+	}
+
+	if err := services.SendMail(c.Request.Context(), passwordResetMail(&user, token)); err != nil {
+		log.Printf("Error sending password reset mail to user %d: %v", user.ID, err)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ConfirmPasswordReset 再設定トークンを検証して新しいパスワードを設定
+// 成功時はトークンを使用済みにし、すべてのセッションを取り消す
+func (h *AuthHandler) ConfirmPasswordReset(c *gin.Context) {
+	var req PasswordResetConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "パスワードの更新に失敗しました"})
+		return
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var resetToken models.PasswordResetToken
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ? AND used_at IS NULL", hashToken(req.Token)).
+			First(&resetToken).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errInvalidResetToken
+		}
+		if err != nil {
+			return err
+		}
+		if !time.Now().Before(resetToken.ExpiresAt) {
+			return errInvalidResetToken
+		}
+
+		if err := tx.Model(&resetToken).Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+		result := tx.Model(&models.User{}).Where("id = ?", resetToken.UserID).Update("password", string(hashedPassword))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errInvalidResetToken
+		}
+		return revokeAllSessions(tx, resetToken.UserID)
+	})
+	if err != nil {
+		if errors.Is(err, errInvalidResetToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "パスワードの更新に失敗しました"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "パスワードを再設定しました。新しいパスワードでログインしてください"})
+}
+
+// passwordResetMail パスワード再設定メールを作成
+func passwordResetMail(user *models.User, token string) services.MailMessage {
+	frontendURL := os.Getenv("FRONTEND_URL")
+	if frontendURL == "" {
+		frontendURL = "http://localhost:5173"
+	}
+	link := fmt.Sprintf("%s/reset-password?token=%s", frontendURL, url.QueryEscape(token))
+
+	return services.MailMessage{
+		To:      *user.Email,
+		Subject: "【HostNote】パスワード再設定のご案内",
+		Body: fmt.Sprintf(`%s 様
+
+パスワード再設定のリクエストを受け付けました。
+以下のリンクから%d分以内に新しいパスワードを設定してください。
+
+%s
+
+このメールに心当たりがない場合は、このまま破棄してください。
+パスワードは変更されません。
+`, user.Username, int(passwordResetTTL.Minutes()), link),
+	}
+}
@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hostnote/server/internal/models"
+	"github.com/hostnote/server/internal/services"
+	"gorm.io/gorm"
+)
+
+type PaymentHandler struct {
+	db *gorm.DB
+}
+
+func NewPaymentHandler(db *gorm.DB) *PaymentHandler {
+	return &PaymentHandler{db: db}
+}
+
+// PaymentRequest 支払いの登録リクエスト
+type PaymentRequest struct {
+	HimeID  uint    `json:"himeId"` // 卓の姫が1人の場合は省略可
+	Method  string  `json:"method" binding:"required"`
+	Amount  float64 `json:"amount" binding:"required"`
+	DueDate string  `json:"dueDate"` // 売掛の回収期限（YYYY-MM-DD）
+	PaidAt  string  `json:"paidAt"`  // 省略時は現在時刻
+	Memo    *string `json:"memo"`
+}
+
+// payment リクエストから支払いを作成
+func (r *PaymentRequest) payment() (*models.Payment, error) {
+	p := &models.Payment{HimeID: r.HimeID, Method: r.Method, Amount: r.Amount, Memo: r.Memo, PaidAt: time.Now()}
+	if r.PaidAt != "" {
+		paidAt, err := parseTime(r.PaidAt)
+		if err != nil {
+			return nil, errors.New("paidAt の形式が正しくありません")
+		}
+		p.PaidAt = paidAt
+	}
+	if r.DueDate != "" {
+		due, err := time.ParseInLocation("2006-01-02", r.DueDate, time.Local)
+		if err != nil {
+			return nil, errors.New("dueDate は YYYY-MM-DD 形式で指定してください")
+		}
+		p.DueDate = &due
+	}
+	return p, services.ValidatePayment(p)
+}
+
+// ListTablePayments 卓記録の支払い一覧を取得
+func (h *PaymentHandler) ListTablePayments(c *gin.Context) {
+	userID, ok := getReadUserID(c, h.db)
+	if !ok {
+		return
+	}
+
+	id, err := parseID(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var payments []models.Payment
+	if err := h.db.WithContext(c).
+		Where("user_id = ? AND table_id = ?", userID, id).
+		Order("paid_at, id").
+		Find(&payments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, payments)
+}
+
+// CreateTablePayment 卓記録の支払い（現金・カード・売掛）を登録
+func (h *PaymentHandler) CreateTablePayment(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+
+	id, err := parseID(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req PaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	payment, err := req.payment()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var record models.TableRecord
+	if err := h.db.WithContext(c).Where("user_id = ? AND id = ?", userID, id).First(&record).Error; err != nil {
+		if handleDBError(c, err, "Table record not found") {
+			return
+		}
+	}
+
+	// 支払った姫は卓の姫から選ぶ
+	var himeIDs []uint
+	if err := h.db.WithContext(c).Model(&models.TableHime{}).Where("table_id = ?", record.ID).Pluck("hime_id", &himeIDs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if payment.HimeID == 0 && len(himeIDs) == 1 {
+		payment.HimeID = himeIDs[0]
+	}
+	if !containsUint(himeIDs, payment.HimeID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "himeId には卓の姫を指定してください"})
+		return
+	}
+
+	payment.UserID = userID
+
+	if err := services.RecordTablePayment(c, h.db, &record, payment); err != nil {
+		respondPaymentError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, payment)
+}
+
+// DeletePayment 支払いを削除（回収の記録がある売掛は削除できない）
+func (h *PaymentHandler) DeletePayment(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+
+	id, err := parseID(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var payment models.Payment
+	if err := h.db.WithContext(c).Where("user_id = ? AND id = ?", userID, id).First(&payment).Error; err != nil {
+		if handleDBError(c, err, "Payment not found") {
+			return
+		}
+	}
+
+	var repayments int64
+	if err := h.db.WithContext(c).Model(&models.Payment{}).Where("receivable_id = ?", payment.ID).Count(&repayments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if repayments > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "回収の記録がある売掛は削除できません。先に回収の記録を削除してください"})
+		return
+	}
+
+	if err := h.db.WithContext(c).Delete(&payment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Deleted"})
+}
+
+// Receivables 売掛台帳を姫ごとに取得
+// デフォルトは未回収のみ。all=true で回収済みを含める、himeId で姫を絞り込む
+func (h *PaymentHandler) Receivables(c *gin.Context) {
+	userID, ok := getReadUserID(c, h.db)
+	if !ok {
+		return
+	}
+
+	scope := h.db.Where("user_id = ?", userID)
+	if himeID := c.Query("himeId"); himeID != "" {
+		scope = scope.Where("hime_id = ?", parseInt(himeID))
+	}
+
+	receivables, err := services.LoadReceivables(c, scope, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if c.Query("all") != "true" {
+		receivables = services.OutstandingReceivables(receivables)
+	}
+	c.JSON(http.StatusOK, services.GroupReceivablesByHime(receivables))
+}
+
+// Overdue 回収期限を過ぎた未回収の売掛を取得（期限の古い順）
+func (h *PaymentHandler) Overdue(c *gin.Context) {
+	userID, ok := getReadUserID(c, h.db)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	scope := h.db.Where("user_id = ? AND due_date < ?", userID, today)
+
+	receivables, err := services.LoadReceivables(c, scope, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, services.OverdueReceivables(receivables))
+}
+
+// RecordReceivablePayment 売掛の回収（一部回収を含む）を登録
+func (h *PaymentHandler) RecordReceivablePayment(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+
+	id, err := parseID(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req PaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.DueDate = ""
+	payment, err := req.payment()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.RecordReceivablePayment(c, h.db, userID, id, payment); err != nil {
+		respondPaymentError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, payment)
+}
+
+// respondPaymentError 支払い登録のエラーを返す（残高超過・会計前の卓は409）
+func respondPaymentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrPaymentExceedsBalance),
+		errors.Is(err, services.ErrTableNotClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrReceivableRepayment),
+		errors.Is(err, services.ErrInvalidPaymentMethod),
+		errors.Is(err, services.ErrInvalidPaymentAmount):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		handleDBError(c, err, "Receivable not found")
+	}
+}
+
+// containsUint スライスに値が含まれるか
+func containsUint(values []uint, v uint) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hostnote/server/internal/models"
+	"github.com/hostnote/server/internal/services"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PricingRuleHandler struct {
+	db *gorm.DB
+}
+
+func NewPricingRuleHandler(db *gorm.DB) *PricingRuleHandler {
+	return &PricingRuleHandler{db: db}
+}
+
+// Get 所属店舗の現在の料金ルールを取得（未登録の場合はバージョン0の既定値）
+func (h *PricingRuleHandler) Get(c *gin.Context) {
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
+
+	rule, err := services.LoadPricingRule(c.Request.Context(), h.db, storeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+// ListVersions 所属店舗の料金ルールの履歴を取得（新しい順）
+func (h *PricingRuleHandler) ListVersions(c *gin.Context) {
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
+
+	var rules []models.PricingRule
+	if err := h.db.WithContext(c).Where("store_id = ?", storeID).Order("version DESC").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+// Update 料金ルールを変更する
+// 既存のルールは書き換えず、新しいバージョンとして作成する（過去の売上情報が参照するため）
+func (h *PricingRuleHandler) Update(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
+
+	var rule models.PricingRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if rule.RoundingMode == "" {
+		rule.RoundingMode = models.RoundingRound
+	}
+	if rule.RoundingUnit == 0 {
+		rule.RoundingUnit = 1
+	}
+	if rule.Surcharges == nil {
+		rule.Surcharges = models.PricingSurcharges{}
+	}
+	if err := services.ValidatePricingRule(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// IDとバージョンを無視（自動採番）、店舗IDと作成者を設定
+	rule.ID = 0
+	rule.StoreID = storeID
+	rule.CreatedBy = userID
+
+	if err := h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		// 同時に保存された場合に同じバージョンを採番しないよう、店舗の行をロックして順に処理する
+		// （ルールが未登録の店舗でもロックできるよう、最新のルールではなく店舗をロックする）
+		var store models.Store
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&store, storeID).Error; err != nil {
+			return err
+		}
+
+		var latest models.PricingRule
+		err := tx.Select("version").Where("store_id = ?", storeID).Order("version DESC").First(&latest).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		rule.Version = latest.Version + 1
+		return tx.Create(&rule).Error
+	}); err != nil {
+		// 店舗・バージョンの一意制約の重複（1062）は、他の保存と競合したものとして409を返す
+		if strings.Contains(err.Error(), "Duplicate entry") || strings.Contains(err.Error(), "1062") {
+			c.JSON(http.StatusConflict, gin.H{"error": "料金ルールが同時に変更されました。最新の内容を確認してから保存してください"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, rule)
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hostnote/server/internal/models"
+)
+
+// TestUpdatePricingRuleVersions 保存のたびに店舗ごとのバージョンを採番することをテスト
+func TestUpdatePricingRuleVersions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := newTestDB(t, &models.Store{}, &models.PricingRule{})
+	for _, store := range []models.Store{{ID: 1, Name: "本店"}, {ID: 2, Name: "2号店"}} {
+		if err := db.Create(&store).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := NewPricingRuleHandler(db)
+
+	body := `{"setMinutes":60,"setPriceNormal":5000,"taxRate":10,"version":9}`
+	for i, tt := range []struct {
+		storeID uint
+		want    int
+	}{{1, 1}, {1, 2}, {2, 1}} {
+		r := gin.New()
+		r.PUT("/pricing-rule", withUser(1), func(c *gin.Context) { c.Set("storeID", tt.storeID) }, h.Update)
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, "/pricing-rule", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("#%d: status = %d (%s)", i, w.Code, w.Body.String())
+		}
+		var rule models.PricingRule
+		if err := json.Unmarshal(w.Body.Bytes(), &rule); err != nil {
+			t.Fatal(err)
+		}
+		if rule.StoreID != tt.storeID || rule.Version != tt.want {
+			t.Errorf("#%d: store %d version %d, want store %d version %d", i, rule.StoreID, rule.Version, tt.storeID, tt.want)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"github.com/hostnote/server/internal/config"
 	"github.com/hostnote/server/internal/middleware"
 	"gorm.io/gorm"
 
@@ -9,23 +10,60 @@ import (
 
 // RegisterRoutes APIルートを登録
 func RegisterRoutes(r *gin.RouterGroup, db *gorm.DB) {
+	// レート制限（認証系は接続元IPごと、認証済みAPIとAI分析はユーザーごと）
+	authLimit, apiLimit, aiLimit := rateLimits()
+	authRateLimit := middleware.RateLimit(middleware.NewRateLimiter(authLimit), middleware.ByIP)
+	apiRateLimit := middleware.RateLimit(middleware.NewRateLimiter(apiLimit), middleware.ByUser)
+	aiRateLimit := middleware.RateLimit(middleware.NewRateLimiter(aiLimit), middleware.ByUser)
+
 	// 認証エンドポイント（認証不要）
 	authHandler := NewAuthHandler(db)
-	r.POST("/auth/register", authHandler.Register)
-	r.POST("/auth/login", authHandler.Login)
-	r.GET("/auth/me", middleware.AuthMiddleware(), authHandler.Me)
+	auth := middleware.AuthMiddleware(sessionChecker(db))
+	r.POST("/auth/register", authRateLimit, authHandler.Register)
+	r.POST("/auth/login", authRateLimit, authHandler.Login)
+	r.POST("/auth/refresh", authRateLimit, authHandler.Refresh)
+	r.POST("/auth/password-reset", authRateLimit, authHandler.RequestPasswordReset)
+	r.POST("/auth/password-reset/confirm", authRateLimit, authHandler.ConfirmPasswordReset)
+	r.POST("/auth/2fa/challenge/setup", authRateLimit, authHandler.ChallengeSetupTwoFactor) // ログイン途中の登録（店舗で必須の場合）
+	r.POST("/auth/2fa/verify", authRateLimit, authHandler.VerifyTwoFactor)                  // ログイン時のコード検証
+	r.GET("/auth/me", auth, authHandler.Me)
 	r.GET("/auth/google", authHandler.GoogleLogin)
-	r.GET("/auth/google/callback", authHandler.GoogleCallback)              // サーバーサイドフロー用
-	r.POST("/auth/google/callback", authHandler.GoogleCallbackFromFrontend) // フロントエンド用
+	r.GET("/auth/google/callback", authHandler.GoogleCallback)                             // サーバーサイドフロー用
+	r.POST("/auth/google/callback", authRateLimit, authHandler.GoogleCallbackFromFrontend) // フロントエンド用
+	r.POST("/auth/login-code", authRateLimit, authHandler.ExchangeLoginCode)               // サーバーサイドフローのリダイレクト後に交換
+
+	// 招待コードの確認（登録画面で招待先を表示するため認証不要）
+	invitationHandler := NewInvitationHandler(db)
+	r.GET("/invitations/:code", authRateLimit, invitationHandler.Preview)
 
 	// 認証が必要なエンドポイント
 	authenticated := r.Group("")
-	authenticated.Use(middleware.AuthMiddleware())
+	authenticated.Use(auth, apiRateLimit)
 	{
 		// 認証関連（パスワード更新やメール更新）
 		authenticated.POST("/auth/change-password", authHandler.ChangePassword)
 		authenticated.PUT("/auth/email", authHandler.UpdateEmail)
 		authenticated.DELETE("/auth/account", authHandler.DeleteAccount)
+		authenticated.POST("/auth/logout", authHandler.Logout)
+
+		// セッション（ログイン中の端末）管理
+		authenticated.GET("/auth/sessions", authHandler.ListSessions)
+		authenticated.DELETE("/auth/sessions", authHandler.RevokeAllSessions)
+		authenticated.DELETE("/auth/sessions/:id", authHandler.RevokeSession)
+
+		// 個人データのエクスポート（データポータビリティ）
+		exportHandler := NewExportHandler(db)
+		authenticated.POST("/account/export", exportHandler.Create)
+		authenticated.GET("/account/export", exportHandler.List)
+		authenticated.GET("/account/export/:id", exportHandler.Get)
+		authenticated.GET("/account/export/:id/download", exportHandler.Download)
+
+		// 2段階認証（TOTP）
+		authenticated.GET("/auth/2fa", authHandler.TwoFactorStatus)
+		authenticated.POST("/auth/2fa/setup", authHandler.SetupTwoFactor)
+		authenticated.POST("/auth/2fa/enable", authHandler.EnableTwoFactor)
+		authenticated.POST("/auth/2fa/disable", authHandler.DisableTwoFactor)
+		authenticated.POST("/auth/2fa/recovery-codes", authHandler.RegenerateRecoveryCodes)
 
 		// 姫エンドポイント
 		himeHandler := NewHimeHandler(db)
@@ -50,14 +88,37 @@ func RegisterRoutes(r *gin.RouterGroup, db *gorm.DB) {
 		authenticated.GET("/table", tableHandler.List)
 		authenticated.POST("/table", tableHandler.Create)
 		authenticated.POST("/table/bulk", tableHandler.BulkCreate)
+		authenticated.POST("/table/bill", tableHandler.CalculateBill)
 		authenticated.GET("/table/:id", tableHandler.Get)
 		authenticated.PUT("/table/:id", tableHandler.Update)
 		authenticated.DELETE("/table/:id", tableHandler.Delete)
+		authenticated.POST("/table/open", tableHandler.Open)
+		authenticated.GET("/table/:id/orders", tableHandler.ListOrders)
+		authenticated.POST("/table/:id/orders", tableHandler.AddOrder)
+		authenticated.DELETE("/table/:id/orders/:orderId", tableHandler.DeleteOrder)
+		authenticated.GET("/table/:id/bill", tableHandler.Bill)
+		authenticated.POST("/table/:id/close", tableHandler.Close)
+		authenticated.POST("/table/:id/void", tableHandler.Void)
+		authenticated.POST("/table/:id/reopen", tableHandler.Reopen)
 		authenticated.POST("/table-hime", tableHandler.CreateTableHime)
 		authenticated.POST("/table-hime/bulk", tableHandler.BulkCreateTableHime)
 		authenticated.POST("/table-cast", tableHandler.CreateTableCast)
 		authenticated.POST("/table-cast/bulk", tableHandler.BulkCreateTableCast)
 
+		// 支払い・売掛エンドポイント
+		paymentHandler := NewPaymentHandler(db)
+		authenticated.GET("/table/:id/payments", paymentHandler.ListTablePayments)
+		authenticated.POST("/table/:id/payments", paymentHandler.CreateTablePayment)
+		authenticated.DELETE("/payments/:id", paymentHandler.DeletePayment)
+		authenticated.GET("/receivables", paymentHandler.Receivables)
+		authenticated.GET("/receivables/overdue", paymentHandler.Overdue)
+		authenticated.POST("/receivables/:id/payments", paymentHandler.RecordReceivablePayment)
+
+		// 領収書・請求書エンドポイント
+		documentHandler := NewDocumentHandler(db)
+		authenticated.GET("/table/:id/documents", documentHandler.ListTableDocuments)
+		authenticated.POST("/table/:id/documents", documentHandler.IssueTableDocument)
+
 		// スケジュールエンドポイント
 		scheduleHandler := NewScheduleHandler(db)
 		authenticated.GET("/schedule", scheduleHandler.List)
@@ -79,16 +140,24 @@ func RegisterRoutes(r *gin.RouterGroup, db *gorm.DB) {
 		// 設定エンドポイント
 		settingHandler := NewSettingHandler(db)
 		authenticated.GET("/setting", settingHandler.List)
-		authenticated.POST("/setting", settingHandler.Create)
-		authenticated.POST("/setting/bulk", settingHandler.BulkCreate)
+		authenticated.POST("/setting", middleware.RequirePermission(middleware.PermSettingWrite), settingHandler.Create)
+		authenticated.POST("/setting/bulk", middleware.RequirePermission(middleware.PermSettingWrite), settingHandler.BulkCreate)
 		authenticated.GET("/setting/:key", settingHandler.Get)
-		authenticated.PUT("/setting/:key", settingHandler.Update)
-		authenticated.DELETE("/setting/:key", settingHandler.Delete)
+		authenticated.PUT("/setting/:key", middleware.RequirePermission(middleware.PermSettingWrite), settingHandler.Update)
+		authenticated.DELETE("/setting/:key", middleware.RequirePermission(middleware.PermSettingWrite), settingHandler.Delete)
 
 		// AI分析エンドポイント
 		aiHandler := NewAIHandler(db)
-		authenticated.POST("/ai/analyze", aiHandler.Analyze)
-		authenticated.POST("/ai/conversation", aiHandler.AnalyzeConversation)
+		authenticated.POST("/ai/analyze", aiRateLimit, aiHandler.Analyze)
+		authenticated.POST("/ai/conversation", aiRateLimit, aiHandler.AnalyzeConversation)
+		authenticated.POST("/ai/conversation/stream", aiRateLimit, aiHandler.AnalyzeConversationStream)
+		authenticated.POST("/ai/drafts", aiRateLimit, aiHandler.Drafts)
+		authenticated.GET("/ai/analyses", aiHandler.ListAnalyses)
+		authenticated.GET("/ai/analyses/:id", aiHandler.GetAnalysis)
+		authenticated.POST("/ai/analyses/:id/messages", aiRateLimit, aiHandler.FollowUp)
+		authenticated.DELETE("/ai/analyses/:id", aiHandler.DeleteAnalysis)
+		authenticated.GET("/ai/usage", aiHandler.Usage)
+		authenticated.GET("/ai/usage/users", middleware.RequirePermission(middleware.PermAIUsageRead), aiHandler.StoreUsage)
 
 		// 自分のキャスト情報エンドポイント
 		myCastHandler := NewMyCastHandler(db)
@@ -105,10 +174,46 @@ func RegisterRoutes(r *gin.RouterGroup, db *gorm.DB) {
 		// メニューエンドポイント
 		menuHandler := NewMenuHandler(db)
 		authenticated.GET("/menu", menuHandler.List)
-		authenticated.POST("/menu", menuHandler.Create)
-		authenticated.POST("/menu/bulk", menuHandler.BulkCreate)
+		authenticated.POST("/menu", middleware.RequirePermission(middleware.PermMenuWrite), menuHandler.Create)
+		authenticated.POST("/menu/bulk", middleware.RequirePermission(middleware.PermMenuWrite), menuHandler.BulkCreate)
 		authenticated.GET("/menu/:id", menuHandler.Get)
-		authenticated.PUT("/menu/:id", menuHandler.Update)
-		authenticated.DELETE("/menu/:id", menuHandler.Delete)
+		authenticated.PUT("/menu/:id", middleware.RequirePermission(middleware.PermMenuWrite), menuHandler.Update)
+		authenticated.DELETE("/menu/:id", middleware.RequirePermission(middleware.PermMenuWrite), menuHandler.Delete)
+
+		// 料金ルールエンドポイント（変更は新しいバージョンとして保存）
+		pricingRuleHandler := NewPricingRuleHandler(db)
+		authenticated.GET("/pricing-rule", pricingRuleHandler.Get)
+		authenticated.GET("/pricing-rule/versions", pricingRuleHandler.ListVersions)
+		authenticated.PUT("/pricing-rule", middleware.RequirePermission(middleware.PermPricingWrite), pricingRuleHandler.Update)
+
+		// ユーザー管理エンドポイント
+		userHandler := NewUserHandler(db)
+		authenticated.GET("/users", middleware.RequirePermission(middleware.PermMemberRead), userHandler.List)
+		authenticated.PUT("/users/:id/role", middleware.RequirePermission(middleware.PermUserManage), userHandler.UpdateRole)
+
+		// 店舗エンドポイント
+		storeHandler := NewStoreHandler(db)
+		authenticated.GET("/store", storeHandler.Get)
+		authenticated.PUT("/store", middleware.RequirePermission(middleware.PermStoreManage), storeHandler.Update)
+		authenticated.GET("/stores", middleware.RequirePermission(middleware.PermStoreCreate), storeHandler.List)
+		authenticated.POST("/stores", middleware.RequirePermission(middleware.PermStoreCreate), storeHandler.Create)
+
+		// 店舗招待エンドポイント
+		authenticated.GET("/store/invitations", middleware.RequirePermission(middleware.PermStoreManage), invitationHandler.List)
+		authenticated.POST("/store/invitations", middleware.RequirePermission(middleware.PermStoreManage), invitationHandler.Create)
+		authenticated.DELETE("/store/invitations/:id", middleware.RequirePermission(middleware.PermStoreManage), invitationHandler.Revoke)
+		authenticated.POST("/invitations/accept", invitationHandler.Accept)
+
+		// 監査ログエンドポイント
+		auditHandler := NewAuditHandler(db)
+		authenticated.GET("/audit", middleware.RequirePermission(middleware.PermAuditRead), auditHandler.List)
+	}
+}
+
+// rateLimits 設定からレート制限を取得（設定未読み込み時は既定値）
+func rateLimits() (auth, api, ai config.RateLimit) {
+	if config.AppConfig == nil {
+		return config.DefaultAuthRateLimit, config.DefaultAPIRateLimit, config.DefaultAIRateLimit
 	}
+	return config.AppConfig.AuthRateLimit, config.AppConfig.APIRateLimit, config.AppConfig.AIRateLimit
 }
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hostnote/server/internal/models"
+	"gorm.io/gorm"
+)
+
+// newTestRouter 認証と権限チェックに必要なテーブルのみのDBでルーターを構築する
+// ユーザー1（店舗1）と、その配下のユーザー2を作成する
+// 権限チェックを通過したハンドラーはDBアクセスで失敗するため、Recoveryで500に変換する
+func newTestRouter(t *testing.T) (*gin.Engine, *gorm.DB) {
+	gin.SetMode(gin.TestMode)
+	db := newTestDB(t, &models.User{}, &models.Session{}, &models.StoreMember{})
+	leaderID := uint(1)
+	for _, u := range []models.User{{ID: 1, Username: "tester"}, {ID: 2, Username: "member", LeaderID: &leaderID}} {
+		if err := db.Create(&u).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		if err := db.Create(&models.StoreMember{StoreID: 1, UserID: u.ID}).Error; err != nil {
+			t.Fatalf("create store member: %v", err)
+		}
+	}
+	r := gin.New()
+	r.Use(gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, _ any) {
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}))
+	RegisterRoutes(r.Group("/api/v1"), db)
+	return r, db
+}
+
+// newTestAccessToken 有効なセッションに紐付いたアクセストークンを発行
+func newTestAccessToken(t *testing.T, db *gorm.DB, userID uint, role string) string {
+	t.Helper()
+	session := models.Session{UserID: userID, RefreshTokenHash: hashToken(fmt.Sprintf("%d:%s:%d", userID, role, time.Now().UnixNano())),
+		ExpiresAt: time.Now().Add(time.Hour), LastUsedAt: time.Now()}
+	if err := db.Create(&session).Error; err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	token, err := generateToken(userID, "tester", role, session.ID)
+	if err != nil {
+		t.Fatalf("generateToken: %v", err)
+	}
+	return token
+}
+
+// TestRoutePermissions ルートグループごとのロール権限をテスト
+func TestRoutePermissions(t *testing.T) {
+	r, db := newTestRouter(t)
+
+	allRoles := []string{models.RoleSuperAdmin, models.RoleAdmin, models.RoleLeader, models.RoleMember}
+	adminRoles := []string{models.RoleSuperAdmin, models.RoleAdmin}
+	leaderRoles := []string{models.RoleSuperAdmin, models.RoleAdmin, models.RoleLeader}
+	superAdminRoles := []string{models.RoleSuperAdmin}
+
+	tests := []struct {
+		group   string
+		method  string
+		path    string
+		allowed []string
+	}{
+		{"menu", http.MethodGet, "/api/v1/menu", allRoles},
+		{"menu", http.MethodGet, "/api/v1/menu/1", allRoles},
+		{"menu", http.MethodPost, "/api/v1/menu", adminRoles},
+		{"menu", http.MethodPost, "/api/v1/menu/bulk", adminRoles},
+		{"menu", http.MethodPut, "/api/v1/menu/1", adminRoles},
+		{"menu", http.MethodDelete, "/api/v1/menu/1", adminRoles},
+		{"setting", http.MethodGet, "/api/v1/setting", allRoles},
+		{"setting", http.MethodGet, "/api/v1/setting/key", allRoles},
+		{"setting", http.MethodPost, "/api/v1/setting", adminRoles},
+		{"setting", http.MethodPost, "/api/v1/setting/bulk", adminRoles},
+		{"setting", http.MethodPut, "/api/v1/setting/key", adminRoles},
+		{"setting", http.MethodDelete, "/api/v1/setting/key", adminRoles},
+		{"pricing-rule", http.MethodGet, "/api/v1/pricing-rule", allRoles},
+		{"pricing-rule", http.MethodGet, "/api/v1/pricing-rule/versions", allRoles},
+		{"pricing-rule", http.MethodPut, "/api/v1/pricing-rule", adminRoles},
+		{"payment", http.MethodGet, "/api/v1/table/1/payments", allRoles},
+		{"payment", http.MethodPost, "/api/v1/table/1/payments", allRoles},
+		{"payment", http.MethodDelete, "/api/v1/payments/1", allRoles},
+		{"payment", http.MethodGet, "/api/v1/receivables", allRoles},
+		{"payment", http.MethodGet, "/api/v1/receivables/overdue", allRoles},
+		{"payment", http.MethodPost, "/api/v1/receivables/1/payments", allRoles},
+		{"document", http.MethodGet, "/api/v1/table/1/documents", allRoles},
+		{"document", http.MethodPost, "/api/v1/table/1/documents", allRoles},
+		{"users", http.MethodGet, "/api/v1/users", leaderRoles},
+		{"users", http.MethodPut, "/api/v1/users/1/role", adminRoles},
+		{"store", http.MethodGet, "/api/v1/store", allRoles},
+		{"store", http.MethodPut, "/api/v1/store", adminRoles},
+		{"store", http.MethodGet, "/api/v1/stores", superAdminRoles},
+		{"store", http.MethodPost, "/api/v1/stores", superAdminRoles},
+		{"invitation", http.MethodGet, "/api/v1/store/invitations", adminRoles},
+		{"invitation", http.MethodPost, "/api/v1/store/invitations", adminRoles},
+		{"invitation", http.MethodDelete, "/api/v1/store/invitations/1", adminRoles},
+		{"invitation", http.MethodPost, "/api/v1/invitations/accept", allRoles},
+		{"audit", http.MethodGet, "/api/v1/audit", adminRoles},
+		{"export", http.MethodPost, "/api/v1/account/export", allRoles},
+		{"export", http.MethodGet, "/api/v1/account/export", allRoles},
+		{"export", http.MethodGet, "/api/v1/account/export/1", allRoles},
+		{"export", http.MethodGet, "/api/v1/account/export/1/download", allRoles},
+		{"ai", http.MethodPost, "/api/v1/ai/drafts", allRoles},
+		{"ai", http.MethodGet, "/api/v1/ai/usage", allRoles},
+		{"ai", http.MethodGet, "/api/v1/ai/usage/users", adminRoles},
+		{"ai", http.MethodGet, "/api/v1/ai/analyses?himeId=1", allRoles},
+		{"ai", http.MethodGet, "/api/v1/ai/analyses/1", allRoles},
+		{"ai", http.MethodPost, "/api/v1/ai/analyses/1/messages", allRoles},
+		{"ai", http.MethodDelete, "/api/v1/ai/analyses/1", allRoles},
+		{"session", http.MethodGet, "/api/v1/auth/sessions", allRoles},
+		{"session", http.MethodDelete, "/api/v1/auth/sessions", allRoles},
+		{"session", http.MethodDelete, "/api/v1/auth/sessions/1", allRoles},
+		{"2fa", http.MethodGet, "/api/v1/auth/2fa", allRoles},
+		{"2fa", http.MethodPost, "/api/v1/auth/2fa/setup", allRoles},
+		{"hime", http.MethodGet, "/api/v1/hime", allRoles},
+		{"hime", http.MethodGet, "/api/v1/hime?userId=2", leaderRoles},
+		{"hime", http.MethodPost, "/api/v1/hime", allRoles},
+		{"cast", http.MethodGet, "/api/v1/cast", allRoles},
+		{"cast", http.MethodPost, "/api/v1/cast", allRoles},
+		{"table", http.MethodGet, "/api/v1/table/1?userId=2", leaderRoles},
+		{"table", http.MethodPost, "/api/v1/table", allRoles},
+		{"table", http.MethodPost, "/api/v1/table/bill", allRoles},
+		{"table", http.MethodPost, "/api/v1/table/open", allRoles},
+		{"table", http.MethodGet, "/api/v1/table/1/orders", allRoles},
+		{"table", http.MethodPost, "/api/v1/table/1/orders", allRoles},
+		{"table", http.MethodDelete, "/api/v1/table/1/orders/2", allRoles},
+		{"table", http.MethodGet, "/api/v1/table/1/bill", allRoles},
+		{"table", http.MethodPost, "/api/v1/table/1/close", allRoles},
+		{"table", http.MethodPost, "/api/v1/table/1/void", allRoles},
+		{"table", http.MethodPost, "/api/v1/table/1/reopen", allRoles},
+		{"schedule", http.MethodGet, "/api/v1/schedule?userId=2", leaderRoles},
+		{"schedule", http.MethodPost, "/api/v1/schedule", allRoles},
+		{"visit", http.MethodGet, "/api/v1/visit?userId=2", leaderRoles},
+		{"visit", http.MethodPost, "/api/v1/visit", allRoles},
+	}
+
+	for _, tt := range tests {
+		for _, role := range allRoles {
+			token := newTestAccessToken(t, db, 1, role)
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader("{}"))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			wantForbidden := !containsRole(tt.allowed, role)
+			gotForbidden := w.Code == http.StatusForbidden
+			if gotForbidden != wantForbidden {
+				t.Errorf("[%s] %s %s as %s: status = %d, want forbidden = %v", tt.group, tt.method, tt.path, role, w.Code, wantForbidden)
+			}
+			if gotForbidden && !strings.Contains(w.Body.String(), "権限がありません") {
+				t.Errorf("[%s] %s %s as %s: unexpected 403 body %s", tt.group, tt.method, tt.path, role, w.Body.String())
+			}
+		}
+	}
+}
+
+// TestRoutePermissionsWithoutRoleClaim ロールを含まない旧トークンはmemberとして扱う
+func TestRoutePermissionsWithoutRoleClaim(t *testing.T) {
+	r, db := newTestRouter(t)
+
+	token := newTestAccessToken(t, db, 1, "")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/menu", strings.NewReader("{}"))
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+// TestTokenWithoutSessionRejected セッションに紐付かない（取り消せない）トークンは受け付けない
+func TestTokenWithoutSessionRejected(t *testing.T) {
+	r, _ := newTestRouter(t)
+
+	for name, sessionID := range map[string]uint{"sidなし": 0, "存在しないセッション": 999} {
+		token, err := generateToken(1, "tester", models.RoleMember, sessionID)
+		if err != nil {
+			t.Fatalf("generateToken: %v", err)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/hime", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: status = %d, want %d", name, w.Code, http.StatusUnauthorized)
+		}
+	}
+}
+
+// TestChallengeTokenRejected 2段階認証のチャレンジトークンはアクセストークンとして使えない
+func TestChallengeTokenRejected(t *testing.T) {
+	r, _ := newTestRouter(t)
+
+	token, err := generateChallengeToken(1)
+	if err != nil {
+		t.Fatalf("generateChallengeToken: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/hime", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+
+	if _, err := parseChallengeToken(token); err != nil {
+		t.Errorf("parseChallengeToken: %v", err)
+	}
+	access, err := generateToken(1, "tester", models.RoleMember, 0)
+	if err != nil {
+		t.Fatalf("generateToken: %v", err)
+	}
+	if _, err := parseChallengeToken(access); err == nil {
+		t.Errorf("parseChallengeToken accepted an access token")
+	}
+}
+
+func containsRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -18,14 +18,13 @@ func NewScheduleHandler(db *gorm.DB) *ScheduleHandler {
 
 // List スケジュール一覧を取得（最適化版）
 func (h *ScheduleHandler) List(c *gin.Context) {
-	userID, ok := getUserID(c)
+	userID, ok := getReadUserID(c, h.db)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
 		return
 	}
 
 	var schedules []models.Schedule
-	if err := h.db.
+	if err := h.db.WithContext(c).
 		Where("user_id = ?", userID).
 		Preload("Hime", func(db *gorm.DB) *gorm.DB {
 			return db.Select("id, name, photo_url").Where("user_id = ?", userID)
@@ -40,9 +39,8 @@ func (h *ScheduleHandler) List(c *gin.Context) {
 
 // Get スケジュールを取得
 func (h *ScheduleHandler) Get(c *gin.Context) {
-	userID, ok := getUserID(c)
+	userID, ok := getReadUserID(c, h.db)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
 		return
 	}
 
@@ -53,7 +51,7 @@ func (h *ScheduleHandler) Get(c *gin.Context) {
 	}
 
 	var schedule models.Schedule
-	if err := h.db.
+	if err := h.db.WithContext(c).
 		Where("user_id = ? AND id = ?", userID, id).
 		Preload("Hime", func(db *gorm.DB) *gorm.DB {
 			return db.Select("id, name, photo_url").Where("user_id = ?", userID)
@@ -85,7 +83,7 @@ func (h *ScheduleHandler) Create(c *gin.Context) {
 	// ユーザーIDを設定
 	schedule.UserID = userID
 
-	if err := h.db.Create(&schedule).Error; err != nil {
+	if err := h.db.WithContext(c).Create(&schedule).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -107,7 +105,7 @@ func (h *ScheduleHandler) Update(c *gin.Context) {
 	}
 
 	var schedule models.Schedule
-	if err := h.db.Where("user_id = ? AND id = ?", userID, id).First(&schedule).Error; err != nil {
+	if err := h.db.WithContext(c).Where("user_id = ? AND id = ?", userID, id).First(&schedule).Error; err != nil {
 		if handleDBError(c, err, "Schedule not found") {
 			return
 		}
@@ -137,13 +135,13 @@ func (h *ScheduleHandler) Update(c *gin.Context) {
 		}
 	}
 
-	if err := h.db.Model(&schedule).Updates(convertedData).Error; err != nil {
+	if err := h.db.WithContext(c).Model(&schedule).Updates(convertedData).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	// 更新後のデータを取得
-	if err := h.db.Where("user_id = ? AND id = ?", userID, id).First(&schedule).Error; err != nil {
+	if err := h.db.WithContext(c).Where("user_id = ? AND id = ?", userID, id).First(&schedule).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新後のデータの取得に失敗しました"})
 		return
 	}
@@ -164,7 +162,7 @@ func (h *ScheduleHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.Where("user_id = ? AND id = ?", userID, id).Delete(&models.Schedule{}).Error; err != nil {
+	if err := h.db.WithContext(c).Where("user_id = ? AND id = ?", userID, id).Delete(&models.Schedule{}).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -192,7 +190,7 @@ func (h *ScheduleHandler) BulkCreate(c *gin.Context) {
 	}
 
 	// トランザクション内で一括作成（一貫性のため）
-	if err := h.db.Transaction(func(tx *gorm.DB) error {
+	if err := h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
 		return tx.Create(&schedules).Error
 	}); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -0,0 +1,390 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hostnote/server/internal/config"
+	"github.com/hostnote/server/internal/middleware"
+	"github.com/hostnote/server/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// トークン有効期間の既定値（設定が読み込まれていない場合に使用）
+const (
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// loginCodeTTL ログインコードの有効期間（リダイレクト直後に交換する）
+const loginCodeTTL = time.Minute
+
+var (
+	// errInvalidRefreshToken リフレッシュトークンが存在しない・期限切れ・取り消し済み
+	errInvalidRefreshToken = errors.New("リフレッシュトークンが無効です。再度ログインしてください")
+	// errInvalidLoginCode ログインコードが存在しない・期限切れ・使用済み
+	errInvalidLoginCode = errors.New("ログインコードが無効か、有効期限が切れています。再度ログインしてください")
+)
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+type LoginCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// SessionInfo セッション一覧用の情報
+type SessionInfo struct {
+	models.Session
+	Current bool `json:"current"`
+}
+
+// Refresh リフレッシュトークンを検証し、新しいアクセストークンとリフレッシュトークンを発行
+// 使用済み（ローテーション前）のトークンが再利用された場合は盗用とみなしセッションを取り消す
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hash := hashToken(req.RefreshToken)
+
+	// 再利用検知
+	var reused models.Session
+	if err := h.db.Where("previous_token_hash = ?", hash).First(&reused).Error; err == nil {
+		if err := revokeSession(h.db, reused.UserID, reused.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errInvalidRefreshToken.Error()})
+		return
+	}
+
+	var user models.User
+	var session models.Session
+	refreshToken, err := generateRandomToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "トークン生成に失敗しました"})
+		return
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("refresh_token_hash = ?", hash).
+			First(&session).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errInvalidRefreshToken
+		}
+		if err != nil {
+			return err
+		}
+		if !session.IsActive(time.Now()) {
+			return errInvalidRefreshToken
+		}
+
+		// ロール変更を反映するためユーザーを取得し直す
+		if err := tx.First(&user, session.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errInvalidRefreshToken
+			}
+			return err
+		}
+		// 店舗で2段階認証が必須になった場合は、再ログインして登録させる
+		if !user.TotpEnabled && storeRequiresTwoFactor(tx, user.ID) {
+			return errTwoFactorSetupRequired
+		}
+
+		return tx.Model(&session).Updates(map[string]interface{}{
+			"refresh_token_hash":  hashToken(refreshToken),
+			"previous_token_hash": hash,
+			"last_used_at":        time.Now(),
+			"user_agent":          truncateString(c.Request.UserAgent(), 255),
+			"ip_address":          c.ClientIP(),
+		}).Error
+	})
+	if err != nil {
+		if errors.Is(err, errInvalidRefreshToken) || errors.Is(err, errTwoFactorSetupRequired) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := newAuthResponse(&user, session.ID, refreshToken)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "トークン生成に失敗しました"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// ExchangeLoginCode 外部認証のリダイレクトで渡したログインコードをセッションと交換（1回限り）
+func (h *AuthHandler) ExchangeLoginCode(c *gin.Context) {
+	var req LoginCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var code models.LoginCode
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("code_hash = ?", hashToken(req.Code)).
+			First(&code).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errInvalidLoginCode
+		}
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if code.UsedAt != nil || !now.Before(code.ExpiresAt) {
+			return errInvalidLoginCode
+		}
+		if err := tx.Model(&code).Update("used_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&user, code.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errInvalidLoginCode
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, errInvalidLoginCode) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.respondLogin(c, &user, http.StatusOK)
+}
+
+// Logout 現在のセッションを取り消す
+func (h *AuthHandler) Logout(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+
+	if sessionID, ok := getSessionID(c); ok {
+		if err := revokeSession(h.db, userID, sessionID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ログアウトしました"})
+}
+
+// ListSessions 有効なセッション（ログイン中の端末）一覧を取得
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+
+	var sessions []models.Session
+	if err := h.db.
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	currentID, _ := getSessionID(c)
+	response := make([]SessionInfo, len(sessions))
+	for i, s := range sessions {
+		response[i] = SessionInfo{Session: s, Current: s.ID == currentID}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// RevokeSession 指定したセッションを取り消す
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+
+	id, err := parseID(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var session models.Session
+	if err := h.db.Where("id = ? AND user_id = ?", id, userID).First(&session).Error; err != nil {
+		handleDBError(c, err, "Session not found")
+		return
+	}
+
+	if err := revokeSession(h.db, userID, session.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// RevokeAllSessions すべてのセッションを取り消す（現在の端末も含む）
+func (h *AuthHandler) RevokeAllSessions(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+
+	if err := revokeAllSessions(h.db, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// issueSession 新しいセッションを作成し、アクセストークンとリフレッシュトークンを発行
+func issueSession(db *gorm.DB, c *gin.Context, user *models.User) (*AuthResponse, error) {
+	refreshToken, err := generateRandomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	_, refreshTTL := tokenTTLs()
+	session := models.Session{
+		UserID:           user.ID,
+		RefreshTokenHash: hashToken(refreshToken),
+		UserAgent:        truncateString(c.Request.UserAgent(), 255),
+		IPAddress:        c.ClientIP(),
+		ExpiresAt:        now.Add(refreshTTL),
+		LastUsedAt:       now,
+	}
+	if err := db.Create(&session).Error; err != nil {
+		return nil, err
+	}
+
+	return newAuthResponse(user, session.ID, refreshToken)
+}
+
+// newAuthResponse アクセストークンを生成して認証レスポンスを作成
+func newAuthResponse(user *models.User, sessionID uint, refreshToken string) (*AuthResponse, error) {
+	token, err := generateToken(user.ID, user.Username, user.Role, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	accessTTL, _ := tokenTTLs()
+	return &AuthResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(accessTTL.Seconds()),
+		User: &UserInfo{
+			ID:       user.ID,
+			Username: user.Username,
+			Email:    user.Email,
+			Role:     user.Role,
+		},
+	}, nil
+}
+
+// revokeSession セッションを取り消す
+// 発行済みのアクセストークンも認証ミドルウェアで拒否される
+func revokeSession(db *gorm.DB, userID, sessionID uint) error {
+	return db.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// revokeAllSessions ユーザーのすべてのセッションを取り消す
+func revokeAllSessions(db *gorm.DB, userID uint) error {
+	return db.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// sessionChecker 認証ミドルウェアでアクセストークンのセッションが有効か確認する
+func sessionChecker(db *gorm.DB) middleware.SessionChecker {
+	return func(ctx context.Context, userID, sessionID uint) (bool, error) {
+		var count int64
+		err := db.WithContext(ctx).Model(&models.Session{}).
+			Where("id = ? AND user_id = ? AND revoked_at IS NULL AND expires_at > ?", sessionID, userID, time.Now()).
+			Count(&count).Error
+		return count > 0, err
+	}
+}
+
+// createLoginCode ログインコードを発行（平文はリダイレクトでのみ渡す）
+func createLoginCode(db *gorm.DB, userID uint) (string, error) {
+	code, err := generateRandomToken()
+	if err != nil {
+		return "", err
+	}
+	if err := db.Create(&models.LoginCode{
+		UserID:    userID,
+		CodeHash:  hashToken(code),
+		ExpiresAt: time.Now().Add(loginCodeTTL),
+	}).Error; err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// getSessionID コンテキストからセッションIDを取得
+func getSessionID(c *gin.Context) (uint, bool) {
+	sessionID, exists := c.Get("sessionID")
+	if !exists {
+		return 0, false
+	}
+	id, ok := sessionID.(uint)
+	return id, ok
+}
+
+// tokenTTLs アクセストークンとリフレッシュトークンの有効期間を取得
+func tokenTTLs() (time.Duration, time.Duration) {
+	if config.AppConfig == nil {
+		return defaultAccessTokenTTL, defaultRefreshTokenTTL
+	}
+	return config.AppConfig.AccessTokenTTL, config.AppConfig.RefreshTokenTTL
+}
+
+// generateRandomToken 推測不可能なランダムトークンを生成
+func generateRandomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken トークンをSHA-256でハッシュ化（DBには平文を保存しない）
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// truncateString 文字列を指定したバイト数以内に切り詰める
+func truncateString(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max]
+}
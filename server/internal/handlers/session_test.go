@@ -0,0 +1,296 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hostnote/server/internal/middleware"
+	"github.com/hostnote/server/internal/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB テストごとのインメモリSQLiteを作成し、指定したモデルのテーブルを作成する
+func newTestDB(t *testing.T, tables ...interface{}) *gorm.DB {
+	t.Helper()
+	return openTestDB(t, "", tables...)
+}
+
+// newForeignKeyTestDB 外部キー制約を有効にしたテスト用DB（削除順序の確認に使う）
+func newForeignKeyTestDB(t *testing.T, tables ...interface{}) *gorm.DB {
+	t.Helper()
+	return openTestDB(t, "&_foreign_keys=1", tables...)
+}
+
+// openTestDB DSNに追加のパラメータを付けてインメモリSQLiteを開く
+func openTestDB(t *testing.T, params string, tables ...interface{}) *gorm.DB {
+	t.Helper()
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared%s", strings.ReplaceAll(t.Name(), "/", "_"), params)
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("gorm.Open: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("db.DB: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.AutoMigrate(tables...); err != nil {
+		t.Fatalf("AutoMigrate: %v", err)
+	}
+	return db
+}
+
+// newAuthTestDB 認証・セッションのテスト用DB
+func newAuthTestDB(t *testing.T) *gorm.DB {
+	return newTestDB(t, &models.User{}, &models.Session{}, &models.LoginCode{}, &models.Store{}, &models.StoreMember{}, &models.StoreInvitation{})
+}
+
+// createTestUser パスワード "password" のユーザーを作成
+func createTestUser(t *testing.T, db *gorm.DB, username string) *models.User {
+	t.Helper()
+	password := "password"
+	user := &models.User{Username: username, Password: &password, Role: models.RoleMember}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+// createTestSession セッションを発行
+func createTestSession(t *testing.T, db *gorm.DB, user *models.User) *AuthResponse {
+	t.Helper()
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+	resp, err := issueSession(db, c, user)
+	if err != nil {
+		t.Fatalf("issueSession: %v", err)
+	}
+	return resp
+}
+
+// postJSON JSONをPOSTしてレスポンスを返す
+func postJSON(r http.Handler, path string, body interface{}, token string) *httptest.ResponseRecorder {
+	b, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(string(b)))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// newAuthTestRouter 認証系のエンドポイントのみのルーター
+func newAuthTestRouter(db *gorm.DB) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	h := NewAuthHandler(db)
+	auth := middleware.AuthMiddleware(sessionChecker(db))
+	r := gin.New()
+	r.POST("/auth/register", h.Register)
+	r.POST("/auth/refresh", h.Refresh)
+	r.POST("/auth/login-code", h.ExchangeLoginCode)
+	r.GET("/auth/me", auth, h.Me)
+	r.POST("/auth/change-password", auth, h.ChangePassword)
+	r.POST("/auth/logout", auth, h.Logout)
+	return r
+}
+
+func decodeAuthResponse(t *testing.T, w *httptest.ResponseRecorder) AuthResponse {
+	t.Helper()
+	var resp AuthResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v (%s)", err, w.Body.String())
+	}
+	return resp
+}
+
+// TestRefreshRotation 更新のたびにリフレッシュトークンがローテーションされることをテスト
+func TestRefreshRotation(t *testing.T) {
+	db := newAuthTestDB(t)
+	r := newAuthTestRouter(db)
+	user := createTestUser(t, db, "rotation")
+	first := createTestSession(t, db, user)
+
+	w := postJSON(r, "/auth/refresh", RefreshRequest{RefreshToken: first.RefreshToken}, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("refresh: status = %d (%s)", w.Code, w.Body.String())
+	}
+	second := decodeAuthResponse(t, w)
+	if second.RefreshToken == "" || second.RefreshToken == first.RefreshToken {
+		t.Fatalf("refresh token was not rotated")
+	}
+
+	// 新しいトークンで続けて更新できる
+	w = postJSON(r, "/auth/refresh", RefreshRequest{RefreshToken: second.RefreshToken}, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("second refresh: status = %d (%s)", w.Code, w.Body.String())
+	}
+	third := decodeAuthResponse(t, w)
+
+	var count int64
+	db.Model(&models.Session{}).Count(&count)
+	if count != 1 {
+		t.Errorf("sessions = %d, want 1 (rotation reuses the session)", count)
+	}
+
+	// 新しいアクセストークンで認証できる
+	req := httptest.NewRequest(http.MethodGet, "/auth/me", nil)
+	req.Header.Set("Authorization", "Bearer "+third.Token)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("me: status = %d (%s)", w.Code, w.Body.String())
+	}
+}
+
+// TestRefreshReuseRevokesSession ローテーション前のトークンが再利用された場合にセッションを取り消すことをテスト
+func TestRefreshReuseRevokesSession(t *testing.T) {
+	db := newAuthTestDB(t)
+	r := newAuthTestRouter(db)
+	user := createTestUser(t, db, "reuse")
+	first := createTestSession(t, db, user)
+
+	w := postJSON(r, "/auth/refresh", RefreshRequest{RefreshToken: first.RefreshToken}, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("refresh: status = %d (%s)", w.Code, w.Body.String())
+	}
+	second := decodeAuthResponse(t, w)
+
+	// 盗まれた古いトークンの再利用
+	w = postJSON(r, "/auth/refresh", RefreshRequest{RefreshToken: first.RefreshToken}, "")
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("replay: status = %d, want 401", w.Code)
+	}
+
+	var session models.Session
+	if err := db.First(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+	if session.RevokedAt == nil {
+		t.Fatalf("session was not revoked after reuse")
+	}
+
+	// 正規の利用者のトークンも無効になる
+	w = postJSON(r, "/auth/refresh", RefreshRequest{RefreshToken: second.RefreshToken}, "")
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("refresh after reuse: status = %d, want 401", w.Code)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/auth/me", nil)
+	req.Header.Set("Authorization", "Bearer "+second.Token)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("access token after reuse: status = %d, want 401", w.Code)
+	}
+}
+
+// TestRefreshRejectsInactiveSession 取り消し済み・期限切れ・不明なリフレッシュトークンを拒否することをテスト
+func TestRefreshRejectsInactiveSession(t *testing.T) {
+	db := newAuthTestDB(t)
+	r := newAuthTestRouter(db)
+	user := createTestUser(t, db, "inactive")
+
+	revoked := createTestSession(t, db, user)
+	if err := db.Model(&models.Session{}).Where("refresh_token_hash = ?", hashToken(revoked.RefreshToken)).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		t.Fatal(err)
+	}
+	expired := createTestSession(t, db, user)
+	if err := db.Model(&models.Session{}).Where("refresh_token_hash = ?", hashToken(expired.RefreshToken)).
+		Update("expires_at", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for name, token := range map[string]string{
+		"revoked": revoked.RefreshToken,
+		"expired": expired.RefreshToken,
+		"unknown": "not-a-refresh-token",
+	} {
+		w := postJSON(r, "/auth/refresh", RefreshRequest{RefreshToken: token}, "")
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: status = %d, want 401", name, w.Code)
+		}
+	}
+
+	// 取り消したセッションのアクセストークンも拒否する
+	req := httptest.NewRequest(http.MethodGet, "/auth/me", nil)
+	req.Header.Set("Authorization", "Bearer "+revoked.Token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("revoked access token: status = %d, want 401", w.Code)
+	}
+}
+
+// TestChangePasswordRevokesOtherSessions パスワード変更で他の端末のセッションが取り消されることをテスト
+func TestChangePasswordRevokesOtherSessions(t *testing.T) {
+	db := newAuthTestDB(t)
+	r := newAuthTestRouter(db)
+	user := createTestUser(t, db, "changer")
+	current := createTestSession(t, db, user)
+	other := createTestSession(t, db, user)
+
+	w := postJSON(r, "/auth/change-password", ChangePasswordRequest{CurrentPassword: "password", NewPassword: "new-password"}, current.Token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("change password: status = %d (%s)", w.Code, w.Body.String())
+	}
+	issued := decodeAuthResponse(t, w)
+
+	for name, token := range map[string]string{"current": current.RefreshToken, "other": other.RefreshToken} {
+		if w := postJSON(r, "/auth/refresh", RefreshRequest{RefreshToken: token}, ""); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s refresh token: status = %d, want 401", name, w.Code)
+		}
+	}
+	req := httptest.NewRequest(http.MethodGet, "/auth/me", nil)
+	req.Header.Set("Authorization", "Bearer "+other.Token)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("other access token: status = %d, want 401", w.Code)
+	}
+
+	// 操作した端末には新しいセッションが発行される
+	if w := postJSON(r, "/auth/refresh", RefreshRequest{RefreshToken: issued.RefreshToken}, ""); w.Code != http.StatusOK {
+		t.Errorf("new refresh token: status = %d (%s)", w.Code, w.Body.String())
+	}
+}
+
+// TestExchangeLoginCode ログインコードが1回限り・有効期限内のみ交換できることをテスト
+func TestExchangeLoginCode(t *testing.T) {
+	db := newAuthTestDB(t)
+	r := newAuthTestRouter(db)
+	user := createTestUser(t, db, "google")
+
+	code, err := createLoginCode(db, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := postJSON(r, "/auth/login-code", LoginCodeRequest{Code: code}, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("exchange: status = %d (%s)", w.Code, w.Body.String())
+	}
+	if resp := decodeAuthResponse(t, w); resp.Token == "" || resp.RefreshToken == "" {
+		t.Errorf("exchange returned no tokens: %+v", resp)
+	}
+	if w := postJSON(r, "/auth/login-code", LoginCodeRequest{Code: code}, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("second exchange: status = %d, want 401", w.Code)
+	}
+
+	expired, err := createLoginCode(db, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Model(&models.LoginCode{}).Where("code_hash = ?", hashToken(expired)).Update("expires_at", time.Now().Add(-time.Second))
+	if w := postJSON(r, "/auth/login-code", LoginCodeRequest{Code: expired}, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expired code: status = %d, want 401", w.Code)
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/hostnote/server/internal/models"
+	"github.com/hostnote/server/internal/services"
 	"gorm.io/gorm"
 )
 
@@ -17,10 +18,15 @@ func NewSettingHandler(db *gorm.DB) *SettingHandler {
 	return &SettingHandler{db: db}
 }
 
-// List 設定一覧を取得
+// List 設定一覧を取得（所属店舗の設定）
 func (h *SettingHandler) List(c *gin.Context) {
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
+
 	var settings []models.Setting
-	if err := h.db.Find(&settings).Error; err != nil {
+	if err := h.db.WithContext(c).Where("store_id = ?", storeID).Find(&settings).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -29,10 +35,15 @@ func (h *SettingHandler) List(c *gin.Context) {
 
 // Get 設定を取得
 func (h *SettingHandler) Get(c *gin.Context) {
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
+
 	key := c.Param("key")
 
 	var setting models.Setting
-	if err := h.db.Where("`key` = ?", key).First(&setting).Error; err != nil {
+	if err := h.db.WithContext(c).Where("store_id = ? AND `key` = ?", storeID, key).First(&setting).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Setting not found"})
 			return
@@ -45,26 +56,36 @@ func (h *SettingHandler) Get(c *gin.Context) {
 
 // Create 設定を作成
 func (h *SettingHandler) Create(c *gin.Context) {
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
+
 	var setting models.Setting
 	if err := c.ShouldBindJSON(&setting); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	setting.StoreID = storeID
+	if err := normalizeSetting(&setting); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// 既に存在するかチェック
 	var existing models.Setting
-	if err := h.db.Where("`key` = ?", setting.Key).First(&existing).Error; err == nil {
+	if err := h.db.WithContext(c).Where("store_id = ? AND `key` = ?", storeID, setting.Key).First(&existing).Error; err == nil {
 		// 既に存在する場合は既存の設定を返す（409 Conflict）
 		c.JSON(http.StatusConflict, existing)
 		return
 	}
 
-	if err := h.db.Create(&setting).Error; err != nil {
+	if err := h.db.WithContext(c).Create(&setting).Error; err != nil {
 		// MySQLの重複エラー（1062）を検出
 		errMsg := err.Error()
 		if strings.Contains(errMsg, "Duplicate entry") || strings.Contains(errMsg, "1062") {
 			// 既存の設定を取得して返す
-			if err := h.db.Where("`key` = ?", setting.Key).First(&existing).Error; err == nil {
+			if err := h.db.WithContext(c).Where("store_id = ? AND `key` = ?", storeID, setting.Key).First(&existing).Error; err == nil {
 				c.JSON(http.StatusConflict, existing)
 				return
 			}
@@ -77,10 +98,15 @@ func (h *SettingHandler) Create(c *gin.Context) {
 
 // Update 設定を更新
 func (h *SettingHandler) Update(c *gin.Context) {
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
+
 	key := c.Param("key")
 
 	var setting models.Setting
-	if err := h.db.Where("`key` = ?", key).First(&setting).Error; err != nil {
+	if err := h.db.WithContext(c).Where("store_id = ? AND `key` = ?", storeID, key).First(&setting).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Setting not found"})
 			return
@@ -94,7 +120,15 @@ func (h *SettingHandler) Update(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.Save(&setting).Error; err != nil {
+	// 主キーは変更しない
+	setting.StoreID = storeID
+	setting.Key = key
+	if err := normalizeSetting(&setting); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.db.WithContext(c).Save(&setting).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -103,9 +137,14 @@ func (h *SettingHandler) Update(c *gin.Context) {
 
 // Delete 設定を削除
 func (h *SettingHandler) Delete(c *gin.Context) {
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
+
 	key := c.Param("key")
 
-	if err := h.db.Delete(&models.Setting{}, "`key` = ?", key).Error; err != nil {
+	if err := h.db.WithContext(c).Delete(&models.Setting{}, "store_id = ? AND `key` = ?", storeID, key).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -114,15 +153,45 @@ func (h *SettingHandler) Delete(c *gin.Context) {
 
 // BulkCreate 複数の設定を一括作成
 func (h *SettingHandler) BulkCreate(c *gin.Context) {
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
+
 	var settings []models.Setting
 	if err := c.ShouldBindJSON(&settings); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := h.db.Create(&settings).Error; err != nil {
+	for i := range settings {
+		settings[i].StoreID = storeID
+		if err := normalizeSetting(&settings[i]); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := h.db.WithContext(c).Create(&settings).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusCreated, settings)
 }
+
+// normalizeSetting 形式の決まっている設定値を検証してそろえる（空の値は未設定として扱う）
+func normalizeSetting(s *models.Setting) error {
+	switch s.Key {
+	case services.SettingInvoiceRegistrationNumber:
+		if strings.TrimSpace(s.Value) == "" {
+			s.Value = ""
+			return nil
+		}
+		number, err := services.NormalizeRegistrationNumber(s.Value)
+		if err != nil {
+			return err
+		}
+		s.Value = number
+	}
+	return nil
+}
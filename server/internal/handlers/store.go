@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hostnote/server/internal/models"
+	"gorm.io/gorm"
+)
+
+type StoreHandler struct {
+	db *gorm.DB
+}
+
+func NewStoreHandler(db *gorm.DB) *StoreHandler {
+	return &StoreHandler{db: db}
+}
+
+type StoreRequest struct {
+	Name             string `json:"name" binding:"required,max=255"`
+	RequireTwoFactor *bool  `json:"requireTwoFactor"` // 省略時は変更しない
+}
+
+// StoreResponse 店舗情報
+type StoreResponse struct {
+	models.Store
+	MemberCount int64 `json:"memberCount"`
+}
+
+// Get 所属店舗の情報を取得
+func (h *StoreHandler) Get(c *gin.Context) {
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
+
+	var store models.Store
+	if err := h.db.First(&store, storeID).Error; err != nil {
+		handleDBError(c, err, "Store not found")
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.StoreMember{}).Where("store_id = ?", storeID).Count(&count)
+
+	c.JSON(http.StatusOK, StoreResponse{Store: store, MemberCount: count})
+}
+
+// Update 所属店舗の情報を更新
+func (h *StoreHandler) Update(c *gin.Context) {
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
+
+	var req StoreRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var store models.Store
+	if err := h.db.First(&store, storeID).Error; err != nil {
+		handleDBError(c, err, "Store not found")
+		return
+	}
+
+	updates := map[string]interface{}{"name": req.Name}
+	if req.RequireTwoFactor != nil {
+		updates["require_two_factor"] = *req.RequireTwoFactor
+	}
+	if err := h.db.Model(&store).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, store)
+}
+
+// List 全店舗一覧を取得
+func (h *StoreHandler) List(c *gin.Context) {
+	var stores []models.Store
+	if err := h.db.Order("id").Find(&stores).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, stores)
+}
+
+// Create 店舗を作成
+func (h *StoreHandler) Create(c *gin.Context) {
+	var req StoreRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	store := models.Store{Name: req.Name}
+	if req.RequireTwoFactor != nil {
+		store.RequireTwoFactor = *req.RequireTwoFactor
+	}
+	if err := h.db.Create(&store).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, store)
+}
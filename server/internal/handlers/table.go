@@ -2,67 +2,69 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"sort"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/hostnote/server/internal/models"
+	"github.com/hostnote/server/internal/services"
 	"gorm.io/gorm"
 )
 
-// ヘルパー関数
-func parseTime(timeStr string) time.Time {
-	if timeStr == "" {
-		return time.Time{}
-	}
+// requestTimeLayouts リクエストで受け付ける日時の形式
+var requestTimeLayouts = []string{
+	time.RFC3339,          // RFC3339形式
+	"2006-01-02T15:04:05", // ISO8601形式（タイムゾーンなし）
+	"2006-01-02T15:04",    // datetime-local形式（YYYY-MM-DDTHH:mm）
+}
 
-	// RFC3339形式を試す
-	t, err := time.Parse(time.RFC3339, timeStr)
-	if err == nil {
-		return t
-	}
-	// ISO8601形式（タイムゾーンなし）を試す
-	t, err = time.Parse("2006-01-02T15:04:05", timeStr)
-	if err == nil {
-		return t
-	}
-	// datetime-local形式（YYYY-MM-DDTHH:mm）を試す
-	t, err = time.Parse("2006-01-02T15:04", timeStr)
-	if err == nil {
-		return t
-	}
-	// タイムゾーン付き形式を試す
-	t, err = time.Parse("2006-01-02T15:04:05Z07:00", timeStr)
-	if err == nil {
-		return t
+// parseTime リクエストの日時を解釈する（解釈できない場合はエラー）
+func parseTime(timeStr string) (time.Time, error) {
+	for _, layout := range requestTimeLayouts {
+		if t, err := time.Parse(layout, timeStr); err == nil {
+			return t, nil
+		}
 	}
-	// すべて失敗した場合はゼロ値を返す
-	return time.Time{}
+	return time.Time{}, fmt.Errorf("Invalid datetime format: %q", timeStr)
 }
 
-func parseStringPtr(v interface{}) *string {
-	if v == nil {
-		return nil
-	}
-	if str, ok := v.(string); ok && str != "" {
-		return &str
-	}
-	return nil
+// RequestTime リクエストの日時（null・空文字は未指定、解釈できない値はバインドエラーにする）
+type RequestTime struct {
+	time.Time
 }
 
-func parseSalesInfo(v interface{}) *models.SalesInfo {
-	if v == nil {
+// UnmarshalJSON parseTime と同じ形式で日時を読み取る
+func (t *RequestTime) UnmarshalJSON(data []byte) error {
+	var s *string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("Invalid datetime format: %s", data)
+	}
+	if s == nil || *s == "" {
+		t.Time = time.Time{}
 		return nil
 	}
-	bytes, err := json.Marshal(v)
+	parsed, err := parseTime(*s)
 	if err != nil {
-		return nil
+		return err
 	}
-	var salesInfo models.SalesInfo
-	if err := json.Unmarshal(bytes, &salesInfo); err != nil {
+	t.Time = parsed
+	return nil
+}
+
+// isSet 日時が指定されているか
+func (t *RequestTime) isSet() bool {
+	return t != nil && !t.IsZero()
+}
+
+// nonEmpty 空文字を nil にする
+func nonEmpty(s *string) *string {
+	if s == nil || *s == "" {
 		return nil
 	}
-	return &salesInfo
+	return s
 }
 
 type TableHandler struct {
@@ -73,296 +75,251 @@ func NewTableHandler(db *gorm.DB) *TableHandler {
 	return &TableHandler{db: db}
 }
 
-// List 卓記録一覧を取得（ページネーション対応）
-func (h *TableHandler) List(c *gin.Context) {
-	userID, ok := getUserID(c)
-	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
-		return
-	}
+// TableRecordRequest 卓記録の作成・更新・オープンのリクエスト
+// 更新では指定した項目だけを変更する（himeIds・mainCastId・helpCastIds を指定した場合は関連を置き換える）
+type TableRecordRequest struct {
+	Datetime    *RequestTime              `json:"datetime"` // 入店日時（作成では必須、オープンでは省略時は現在）
+	TableNumber *string                   `json:"tableNumber" binding:"omitempty,max=50"`
+	Memo        *string                   `json:"memo"`
+	SalesInfo   *models.SalesInfo         `json:"salesInfo"`
+	HimeIDs     *[]uint                   `json:"himeIds"`
+	MainCastID  *uint                     `json:"mainCastId"` // 0 はメインキャストなし
+	HelpCastIDs *[]uint                   `json:"helpCastIds"`
+	Allocations []services.HimeAllocation `json:"allocations" binding:"omitempty,dive"` // splitMode が custom の場合の負担額
+	VisitType   string                    `json:"visitType"`                            // オープン時の来店区分
+	SplitMode   string                    `json:"splitMode"`                            // オープン時の割り方
+}
 
-	// クエリパラメータからページネーション情報を取得
-	limit := 50 // デフォルトは50件
-	if limitStr := c.Query("limit"); limitStr != "" {
-		if parsedLimit := parseInt(limitStr); parsedLimit > 0 && parsedLimit <= 200 {
-			limit = parsedLimit
-		}
-	}
-	offset := 0
-	if offsetStr := c.Query("offset"); offsetStr != "" {
-		if parsedOffset := parseInt(offsetStr); parsedOffset >= 0 {
-			offset = parsedOffset
+// fields 指定された項目（JSONのキー名）
+func (r *TableRecordRequest) fields() []string {
+	var fields []string
+	for _, f := range []struct {
+		name string
+		set  bool
+	}{
+		{"datetime", r.Datetime != nil},
+		{"tableNumber", r.TableNumber != nil},
+		{"memo", r.Memo != nil},
+		{"salesInfo", r.SalesInfo != nil},
+		{"himeIds", r.HimeIDs != nil},
+		{"mainCastId", r.MainCastID != nil},
+		{"helpCastIds", r.HelpCastIDs != nil},
+		{"allocations", r.Allocations != nil},
+		{"visitType", r.VisitType != ""},
+		{"splitMode", r.SplitMode != ""},
+	} {
+		if f.set {
+			fields = append(fields, f.name)
 		}
 	}
+	return fields
+}
 
-	var records []models.TableRecord
-	query := h.db.Where("user_id = ?", userID).Order("datetime DESC")
+// himeIDs 指定された姫のID（未指定は nil）
+func (r *TableRecordRequest) himeIDs() []uint {
+	if r.HimeIDs == nil {
+		return nil
+	}
+	return *r.HimeIDs
+}
 
-	// 件数制限を適用
-	if err := query.Limit(limit).Offset(offset).Find(&records).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+// castIDs 指定されたメインキャストとヘルプキャストのID
+func (r *TableRecordRequest) castIDs() (uint, []uint) {
+	var mainCastID uint
+	var helpCastIDs []uint
+	if r.MainCastID != nil {
+		mainCastID = *r.MainCastID
+	}
+	if r.HelpCastIDs != nil {
+		helpCastIDs = *r.HelpCastIDs
 	}
+	return mainCastID, helpCastIDs
+}
+
+// TableRecordResponse 卓記録と同席した姫・キャスト
+type TableRecordResponse struct {
+	ID          uint                `json:"id"`
+	Datetime    time.Time           `json:"datetime"`
+	TableNumber *string             `json:"tableNumber"`
+	Memo        *string             `json:"memo"`
+	SalesInfo   *models.SalesInfo   `json:"salesInfo"`
+	Status      string              `json:"status"`
+	ClosedAt    *time.Time          `json:"closedAt"`
+	CreatedAt   time.Time           `json:"createdAt"`
+	UpdatedAt   time.Time           `json:"updatedAt"`
+	HimeList    []TableHimeResponse `json:"himeList"`
+	MainCast    *TableCastResponse  `json:"mainCast"`
+	HelpCasts   []TableCastResponse `json:"helpCasts"`
+}
 
+// TableHimeResponse 卓の姫
+type TableHimeResponse struct {
+	ID         uint                   `json:"id"`
+	Name       models.EncryptedString `json:"name"`
+	PhotoURL   *string                `json:"photoUrl"`
+	Allocation *float64               `json:"allocation"` // 卓の合計のうちこの姫の負担額
+}
+
+// TableCastResponse 卓のキャスト
+type TableCastResponse struct {
+	ID       uint    `json:"id"`
+	Name     string  `json:"name"`
+	PhotoURL *string `json:"photoUrl"`
+}
+
+// buildTableRecordResponses 卓記録に姫・キャストを付ける（関連はまとめて取得する）
+// 姫はユーザー、キャストは店舗のものだけを含める
+func buildTableRecordResponses(db *gorm.DB, records []models.TableRecord, userID, storeID uint) ([]TableRecordResponse, error) {
+	result := make([]TableRecordResponse, len(records))
 	if len(records) == 0 {
-		c.JSON(http.StatusOK, []interface{}{})
-		return
+		return result, nil
 	}
 
-	// 全てのtable_idを収集
 	tableIDs := make([]uint, len(records))
 	for i, record := range records {
 		tableIDs[i] = record.ID
 	}
 
-	// 一括で関連データを取得（Select最適化）
-	// 姫リストを一括取得
 	var tableHimes []models.TableHime
-	h.db.Select("table_id, hime_id").Where("table_id IN ?", tableIDs).Find(&tableHimes)
-
-	// キャストリストを一括取得
+	if err := db.Select("table_id, hime_id, allocation").Where("table_id IN ?", tableIDs).Order("id").Find(&tableHimes).Error; err != nil {
+		return nil, err
+	}
 	var tableCasts []models.TableCast
-	h.db.Select("table_id, cast_id, role").Where("table_id IN ?", tableIDs).Find(&tableCasts)
+	if err := db.Select("table_id, cast_id, role").Where("table_id IN ?", tableIDs).Order("id").Find(&tableCasts).Error; err != nil {
+		return nil, err
+	}
 
-	// HimeIDとCastIDを収集
-	himeIDs := make(map[uint]bool)
-	castIDs := make(map[uint]bool)
+	himeIDs := make([]uint, 0, len(tableHimes))
 	for _, th := range tableHimes {
-		himeIDs[th.HimeID] = true
+		himeIDs = append(himeIDs, th.HimeID)
 	}
+	castIDs := make([]uint, 0, len(tableCasts))
 	for _, tc := range tableCasts {
-		castIDs[tc.CastID] = true
+		castIDs = append(castIDs, tc.CastID)
 	}
 
-	// Himeを一括取得（必要なフィールドのみ、ユーザーIDでフィルタリング）
-	himeIDList := make([]uint, 0, len(himeIDs))
-	for id := range himeIDs {
-		himeIDList = append(himeIDList, id)
-	}
-	var himes []models.Hime
-	if len(himeIDList) > 0 {
-		h.db.Select("id, name, photo_url").Where("user_id = ? AND id IN ?", userID, himeIDList).Find(&himes)
+	// 必要なフィールドのみ取得
+	himeMap := make(map[uint]models.Hime)
+	if len(himeIDs) > 0 {
+		var himes []models.Hime
+		if err := db.Select("id, name, photo_url").Where("user_id = ? AND id IN ?", userID, himeIDs).Find(&himes).Error; err != nil {
+			return nil, err
+		}
+		for _, hime := range himes {
+			himeMap[hime.ID] = hime
+		}
 	}
-	himeMap := make(map[uint]models.Hime, len(himes))
-	for _, hime := range himes {
-		himeMap[hime.ID] = hime
+	castMap := make(map[uint]models.Cast)
+	if len(castIDs) > 0 {
+		var casts []models.Cast
+		if err := db.Select("id, name, photo_url").Where("store_id = ? AND id IN ?", storeID, castIDs).Find(&casts).Error; err != nil {
+			return nil, err
+		}
+		for _, cast := range casts {
+			castMap[cast.ID] = cast
+		}
 	}
 
-	// Castを一括取得（必要なフィールドのみ、ユーザーIDでフィルタリング）
-	castIDList := make([]uint, 0, len(castIDs))
-	for id := range castIDs {
-		castIDList = append(castIDList, id)
-	}
-	var casts []models.Cast
-	if len(castIDList) > 0 {
-		h.db.Select("id, name, photo_url").Where("user_id = ? AND id IN ?", userID, castIDList).Find(&casts)
-	}
-	castMap := make(map[uint]models.Cast, len(casts))
-	for _, cast := range casts {
-		castMap[cast.ID] = cast
+	index := make(map[uint]int, len(records))
+	for i, record := range records {
+		index[record.ID] = i
+		result[i] = TableRecordResponse{
+			ID:          record.ID,
+			Datetime:    record.Datetime,
+			TableNumber: record.TableNumber,
+			Memo:        record.Memo,
+			SalesInfo:   record.SalesInfo,
+			Status:      record.Status,
+			ClosedAt:    record.ClosedAt,
+			CreatedAt:   record.CreatedAt,
+			UpdatedAt:   record.UpdatedAt,
+			HimeList:    []TableHimeResponse{},
+			HelpCasts:   []TableCastResponse{},
+		}
 	}
-
-	// table_idごとにグループ化
-	tableHimeMap := make(map[uint][]models.TableHime)
 	for _, th := range tableHimes {
-		tableHimeMap[th.TableID] = append(tableHimeMap[th.TableID], th)
+		if hime, ok := himeMap[th.HimeID]; ok {
+			r := &result[index[th.TableID]]
+			r.HimeList = append(r.HimeList, TableHimeResponse{ID: hime.ID, Name: hime.Name, PhotoURL: hime.PhotoURL, Allocation: th.Allocation})
+		}
 	}
-
-	tableCastMap := make(map[uint][]models.TableCast)
 	for _, tc := range tableCasts {
-		tableCastMap[tc.TableID] = append(tableCastMap[tc.TableID], tc)
-	}
-
-	// 結果を構築
-	result := make([]map[string]interface{}, len(records))
-	for i, record := range records {
-		var salesInfo interface{} = nil
-		if record.SalesInfo != nil {
-			salesInfo = record.SalesInfo
-		}
-
-		recordMap := map[string]interface{}{
-			"id":          record.ID,
-			"datetime":    record.Datetime,
-			"tableNumber": record.TableNumber,
-			"memo":        record.Memo,
-			"salesInfo":   salesInfo,
-			"createdAt":   record.CreatedAt,
-			"updatedAt":   record.UpdatedAt,
-		}
-
-		// 姫リストを構築
-		himeList := make([]map[string]interface{}, 0)
-		if tableHimes, ok := tableHimeMap[record.ID]; ok {
-			for _, th := range tableHimes {
-				if hime, ok := himeMap[th.HimeID]; ok {
-					himeList = append(himeList, map[string]interface{}{
-						"id":       hime.ID,
-						"name":     hime.Name,
-						"photoUrl": hime.PhotoURL,
-					})
-				}
-			}
-		}
-		recordMap["himeList"] = himeList
-
-		// メインキャストを取得
-		var mainCast *map[string]interface{} = nil
-		if tableCasts, ok := tableCastMap[record.ID]; ok {
-			for _, tc := range tableCasts {
-				if tc.Role == "main" {
-					if cast, ok := castMap[tc.CastID]; ok {
-						mainCast = &map[string]interface{}{
-							"id":       cast.ID,
-							"name":     cast.Name,
-							"photoUrl": cast.PhotoURL,
-						}
-						break
-					}
-				}
-			}
+		cast, ok := castMap[tc.CastID]
+		if !ok {
+			continue
 		}
-		if mainCast != nil {
-			recordMap["mainCast"] = *mainCast
-		} else {
-			recordMap["mainCast"] = nil
-		}
-
-		// ヘルプキャストを構築
-		helpCastList := make([]map[string]interface{}, 0)
-		if tableCasts, ok := tableCastMap[record.ID]; ok {
-			for _, tc := range tableCasts {
-				if tc.Role == "help" {
-					if cast, ok := castMap[tc.CastID]; ok {
-						helpCastList = append(helpCastList, map[string]interface{}{
-							"id":       cast.ID,
-							"name":     cast.Name,
-							"photoUrl": cast.PhotoURL,
-						})
-					}
-				}
+		r := &result[index[tc.TableID]]
+		castResponse := TableCastResponse{ID: cast.ID, Name: cast.Name, PhotoURL: cast.PhotoURL}
+		switch tc.Role {
+		case "main":
+			if r.MainCast == nil {
+				r.MainCast = &castResponse
 			}
+		case "help":
+			r.HelpCasts = append(r.HelpCasts, castResponse)
 		}
-		recordMap["helpCasts"] = helpCastList
-
-		result[i] = recordMap
 	}
-
-	c.JSON(http.StatusOK, result)
+	return result, nil
 }
 
-// buildTableRecordMap 卓記録のマップを構築（共通処理、最適化版）
-func (h *TableHandler) buildTableRecordMap(record models.TableRecord, userID uint) map[string]interface{} {
-	recordMap := map[string]interface{}{
-		"id":          record.ID,
-		"datetime":    record.Datetime,
-		"tableNumber": record.TableNumber,
-		"memo":        record.Memo,
-		"salesInfo":   record.SalesInfo,
-		"createdAt":   record.CreatedAt,
-		"updatedAt":   record.UpdatedAt,
-	}
-
-	// 関連データを一括取得（Select最適化）
-	var tableHimes []models.TableHime
-	h.db.Select("table_id, hime_id").Where("table_id = ?", record.ID).Find(&tableHimes)
-
-	var tableCasts []models.TableCast
-	h.db.Select("table_id, cast_id, role").Where("table_id = ?", record.ID).Find(&tableCasts)
-
-	if len(tableHimes) == 0 && len(tableCasts) == 0 {
-		recordMap["himeList"] = []interface{}{}
-		recordMap["mainCast"] = nil
-		recordMap["helpCasts"] = []interface{}{}
-		return recordMap
-	}
-
-	// HimeIDとCastIDを収集
-	himeIDs := make([]uint, 0, len(tableHimes))
-	castIDs := make([]uint, 0, len(tableCasts))
-	for _, th := range tableHimes {
-		himeIDs = append(himeIDs, th.HimeID)
-	}
-	for _, tc := range tableCasts {
-		castIDs = append(castIDs, tc.CastID)
-	}
-
-	// Himeを一括取得（必要なフィールドのみ、ユーザーIDでフィルタリング）
-	var himes []models.Hime
-	if len(himeIDs) > 0 {
-		h.db.Select("id, name, photo_url").Where("user_id = ? AND id IN ?", userID, himeIDs).Find(&himes)
-	}
-	himeMap := make(map[uint]models.Hime, len(himes))
-	for _, hime := range himes {
-		himeMap[hime.ID] = hime
+// respondTableRecord 卓記録に姫・キャストを付けて返す
+func (h *TableHandler) respondTableRecord(c *gin.Context, status int, record models.TableRecord, userID, storeID uint) {
+	responses, err := buildTableRecordResponses(h.db.WithContext(c), []models.TableRecord{record}, userID, storeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
+	c.JSON(status, responses[0])
+}
 
-	// Castを一括取得（必要なフィールドのみ、ユーザーIDでフィルタリング）
-	var casts []models.Cast
-	if len(castIDs) > 0 {
-		h.db.Select("id, name, photo_url").Where("user_id = ? AND id IN ?", userID, castIDs).Find(&casts)
+// List 卓記録一覧を取得（ページネーション対応）
+func (h *TableHandler) List(c *gin.Context) {
+	userID, ok := getReadUserID(c, h.db)
+	if !ok {
+		return
 	}
-	castMap := make(map[uint]models.Cast, len(casts))
-	for _, cast := range casts {
-		castMap[cast.ID] = cast
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
 	}
 
-	// 姫リストを構築
-	himeList := make([]map[string]interface{}, 0, len(tableHimes))
-	for _, th := range tableHimes {
-		if hime, ok := himeMap[th.HimeID]; ok {
-			himeList = append(himeList, map[string]interface{}{
-				"id":       hime.ID,
-				"name":     hime.Name,
-				"photoUrl": hime.PhotoURL,
-			})
+	// クエリパラメータからページネーション情報を取得
+	limit := 50 // デフォルトは50件
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if parsedLimit := parseInt(limitStr); parsedLimit > 0 && parsedLimit <= 200 {
+			limit = parsedLimit
 		}
 	}
-	recordMap["himeList"] = himeList
-
-	// メインキャストを取得
-	var mainCast *map[string]interface{} = nil
-	for _, tc := range tableCasts {
-		if tc.Role == "main" {
-			if cast, ok := castMap[tc.CastID]; ok {
-				mainCast = &map[string]interface{}{
-					"id":       cast.ID,
-					"name":     cast.Name,
-					"photoUrl": cast.PhotoURL,
-				}
-				break
-			}
+	offset := 0
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if parsedOffset := parseInt(offsetStr); parsedOffset >= 0 {
+			offset = parsedOffset
 		}
 	}
-	if mainCast != nil {
-		recordMap["mainCast"] = *mainCast
-	} else {
-		recordMap["mainCast"] = nil
-	}
 
-	// ヘルプキャストを構築
-	helpCastList := make([]map[string]interface{}, 0, len(tableCasts))
-	for _, tc := range tableCasts {
-		if tc.Role == "help" {
-			if cast, ok := castMap[tc.CastID]; ok {
-				helpCastList = append(helpCastList, map[string]interface{}{
-					"id":       cast.ID,
-					"name":     cast.Name,
-					"photoUrl": cast.PhotoURL,
-				})
-			}
-		}
+	var records []models.TableRecord
+	if err := h.db.WithContext(c).Where("user_id = ?", userID).Order("datetime DESC").
+		Limit(limit).Offset(offset).Find(&records).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
-	recordMap["helpCasts"] = helpCastList
 
-	return recordMap
+	result, err := buildTableRecordResponses(h.db.WithContext(c), records, userID, storeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
 }
 
 // Get 卓記録を取得
 func (h *TableHandler) Get(c *gin.Context) {
-	userID, ok := getUserID(c)
+	userID, ok := getReadUserID(c, h.db)
+	if !ok {
+		return
+	}
+	storeID, ok := getStoreID(c, h.db)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
 		return
 	}
 
@@ -373,14 +330,13 @@ func (h *TableHandler) Get(c *gin.Context) {
 	}
 
 	var record models.TableRecord
-	if err := h.db.Where("user_id = ? AND id = ?", userID, id).First(&record).Error; err != nil {
+	if err := h.db.WithContext(c).Where("user_id = ? AND id = ?", userID, id).First(&record).Error; err != nil {
 		if handleDBError(c, err, "Table record not found") {
 			return
 		}
 	}
 
-	recordMap := h.buildTableRecordMap(record, userID)
-	c.JSON(http.StatusOK, recordMap)
+	h.respondTableRecord(c, http.StatusOK, record, userID, storeID)
 }
 
 // Create 卓記録を作成
@@ -390,344 +346,385 @@ func (h *TableHandler) Create(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
 		return
 	}
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
 
-	var requestData map[string]interface{}
-	if err := c.ShouldBindJSON(&requestData); err != nil {
+	var req TableRecordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !req.Datetime.isSet() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "datetime is required"})
+		return
+	}
+	datetime := req.Datetime.Time
 
-	// トランザクション開始
-	tx := h.db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
-
-	// テーブル記録を作成
-	datetimeStr, ok := requestData["datetime"].(string)
+	// 売上情報はサーバー側で計算する（時間帯による加算は入店日時で判定）
+	salesInfo, ok := h.calculateSalesInfo(c, storeID, req.SalesInfo, datetime)
 	if !ok {
-		tx.Rollback()
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid datetime format"})
 		return
 	}
 
+	// テーブル記録を作成（会計済みの記録として登録）
+	now := time.Now()
 	record := models.TableRecord{
 		UserID:      userID,
-		Datetime:    parseTime(datetimeStr),
-		TableNumber: parseStringPtr(requestData["tableNumber"]),
-		Memo:        parseStringPtr(requestData["memo"]),
-		SalesInfo:   parseSalesInfo(requestData["salesInfo"]),
+		Datetime:    datetime,
+		TableNumber: nonEmpty(req.TableNumber),
+		Memo:        nonEmpty(req.Memo),
+		SalesInfo:   salesInfo,
+		Status:      models.TableStatusClosed,
+		ClosedAt:    &now,
+	}
+	if err := h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&record).Error; err != nil {
+			return err
+		}
+		return createTableRelations(tx, userID, storeID, &record, &req)
+	}); err != nil {
+		respondTableError(c, err)
+		return
 	}
 
-	if err := tx.Create(&record).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	h.respondTableRecord(c, http.StatusCreated, record, userID, storeID)
+}
+
+// Update 卓記録を更新（指定した項目のみ）
+func (h *TableHandler) Update(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
 		return
 	}
-
-	// 姫の関連を作成（ユーザーIDでフィルタリング）
-	if himeIds, ok := requestData["himeIds"].([]interface{}); ok {
-		for _, himeId := range himeIds {
-			var himeIdUint uint
-			switch v := himeId.(type) {
-			case float64:
-				himeIdUint = uint(v)
-			case int:
-				himeIdUint = uint(v)
-			default:
-				continue
-			}
-			// 指定された姫が現在のユーザーのものか確認
-			var hime models.Hime
-			if err := tx.Where("user_id = ? AND id = ?", userID, himeIdUint).First(&hime).Error; err != nil {
-				// ユーザーの姫でない場合はスキップ
-				continue
-			}
-			tableHime := models.TableHime{
-				TableID: record.ID,
-				HimeID:  himeIdUint,
-			}
-			if err := tx.Create(&tableHime).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-				return
-			}
-		}
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
 	}
 
-	// メインキャストの関連を作成（ユーザーIDでフィルタリング）
-	if mainCastId, ok := requestData["mainCastId"].(float64); ok && mainCastId > 0 {
-		// 指定されたキャストが現在のユーザーのものか確認
-		var cast models.Cast
-		if err := tx.Where("user_id = ? AND id = ?", userID, uint(mainCastId)).First(&cast).Error; err == nil {
-			tableCast := models.TableCast{
-				TableID: record.ID,
-				CastID:  uint(mainCastId),
-				Role:    "main",
-			}
-			if err := tx.Create(&tableCast).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-				return
-			}
-		}
+	id, err := parseID(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
 	}
 
-	// ヘルプキャストの関連を作成（ユーザーIDでフィルタリング）
-	if helpCastIds, ok := requestData["helpCastIds"].([]interface{}); ok {
-		for _, castId := range helpCastIds {
-			var castIdUint uint
-			switch v := castId.(type) {
-			case float64:
-				castIdUint = uint(v)
-			case int:
-				castIdUint = uint(v)
-			default:
-				continue
-			}
-			// 指定されたキャストが現在のユーザーのものか確認
-			var cast models.Cast
-			if err := tx.Where("user_id = ? AND id = ?", userID, castIdUint).First(&cast).Error; err != nil {
-				// ユーザーのキャストでない場合はスキップ
-				continue
-			}
-			tableCast := models.TableCast{
-				TableID: record.ID,
-				CastID:  castIdUint,
-				Role:    "help",
-			}
-			if err := tx.Create(&tableCast).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-				return
-			}
-		}
+	var req TableRecordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	// 来店履歴を自動追加（卓記録に参加している各姫について）
-	if himeIds, ok := requestData["himeIds"].([]interface{}); ok {
-		// 卓記録の日付を取得（日付のみ、時刻は無視）
-		visitDate := time.Date(
-			record.Datetime.Year(),
-			record.Datetime.Month(),
-			record.Datetime.Day(),
-			0, 0, 0, 0,
-			record.Datetime.Location(),
-		)
-		// 翌日の0時（同日の終わり）
-		nextDay := visitDate.AddDate(0, 0, 1)
-
-		// 各姫について来店履歴を作成（同日の来店履歴が既に存在する場合はスキップ）
-		for _, himeId := range himeIds {
-			var himeIdUint uint
-			switch v := himeId.(type) {
-			case float64:
-				himeIdUint = uint(v)
-			case int:
-				himeIdUint = uint(v)
-			default:
-				continue
-			}
-
-			// 同日の来店履歴が既に存在するかチェック（日付範囲でチェック、ユーザーIDでフィルタリング）
-			var existingVisit models.VisitRecord
-			err := tx.Where("user_id = ? AND hime_id = ? AND visit_date >= ? AND visit_date < ?", userID, himeIdUint, visitDate, nextDay).
-				First(&existingVisit).Error
-			if err == nil {
-				// 既に存在する場合はスキップ
-				continue
-			}
-			if err != gorm.ErrRecordNotFound {
-				// その他のエラーの場合はロールバック
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-				return
-			}
+	var record *models.TableRecord
+	var lockedField string
+	err = h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		// 会計・取り消しと同時に更新しないよう行ロックして取得
+		var err error
+		if record, err = services.LockTable(tx, userID, id); err != nil {
+			return err
+		}
 
-			// 来店履歴を作成
-			visitRecord := models.VisitRecord{
-				UserID:    userID,
-				HimeID:    himeIdUint,
-				VisitDate: visitDate,
-				Memo:      nil,
-			}
-			if err := tx.Create(&visitRecord).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-				return
-			}
+		// 会計済み・取り消し済みの卓は卓番号とメモのみ変更できる（金額・姫・日時を変えるには再オープンする）
+		// 接客中の卓の注文は注文エンドポイントで追加し、会計で売上情報を確定する
+		if field, ok := lockedTableField(record, &req); !ok {
+			lockedField = field
+			return services.ErrTableClosed
 		}
-	}
 
-	tx.Commit()
+		if req.Datetime.isSet() {
+			record.Datetime = req.Datetime.Time
+		}
+		if req.TableNumber != nil {
+			record.TableNumber = nonEmpty(req.TableNumber)
+		}
+		if req.Memo != nil {
+			record.Memo = nonEmpty(req.Memo)
+		}
+		if err := tx.Save(record).Error; err != nil {
+			return err
+		}
 
-	// 作成したレコードを取得して返す
-	var createdRecord models.TableRecord
-	if err := h.db.Where("user_id = ? AND id = ?", userID, record.ID).First(&createdRecord).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve created record"})
+		// 指定された関連のみ置き換える（卓番号やメモだけの更新で姫・キャストを消さない）
+		return replaceTableRelations(tx, userID, storeID, record, &req)
+	})
+	if lockedField != "" {
+		c.JSON(http.StatusConflict, gin.H{"error": services.ErrTableClosed.Error(), "field": lockedField})
+		return
+	}
+	if err != nil {
+		respondTableError(c, err)
 		return
 	}
 
-	recordMap := h.buildTableRecordMap(createdRecord, userID)
-	c.JSON(http.StatusCreated, recordMap)
+	h.respondTableRecord(c, http.StatusOK, *record, userID, storeID)
 }
 
-// Update 卓記録を更新
-func (h *TableHandler) Update(c *gin.Context) {
-	userID, ok := getUserID(c)
-	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
-		return
+// lockedTableField 卓の状態で変更できない項目が含まれていれば、その項目名と false を返す
+func lockedTableField(record *models.TableRecord, req *TableRecordRequest) (string, bool) {
+	var editable map[string]bool
+	switch record.Status {
+	case models.TableStatusOpen:
+		editable = map[string]bool{"datetime": true, "tableNumber": true, "memo": true, "himeIds": true, "mainCastId": true, "helpCastIds": true}
+	default:
+		editable = map[string]bool{"tableNumber": true, "memo": true}
+	}
+	for _, field := range req.fields() {
+		if !editable[field] {
+			return field, false
+		}
 	}
+	return "", true
+}
 
-	id, err := parseID(c, "id")
+// createTableRelations 作成した卓記録に姫・キャストの関連と来店履歴を作成する
+func createTableRelations(tx *gorm.DB, userID, storeID uint, record *models.TableRecord, req *TableRecordRequest) error {
+	// 姫の関連と負担額を作成（ユーザーIDでフィルタリング）
+	himeIDs, err := createTableHimes(tx, userID, record, req.himeIDs(), req.Allocations)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
-		return
+		return err
+	}
+	// キャストの関連を作成（店舗IDでフィルタリング）
+	mainCastID, helpCastIDs := req.castIDs()
+	if err := createTableCasts(tx, storeID, record, mainCastID, helpCastIDs); err != nil {
+		return err
 	}
+	// 来店履歴を自動追加（卓記録に参加している各姫について）
+	return createVisitRecords(tx, userID, record, himeIDs)
+}
 
-	var record models.TableRecord
-	if err := h.db.Where("user_id = ? AND id = ?", userID, id).First(&record).Error; err != nil {
-		if handleDBError(c, err, "Table record not found") {
-			return
+// replaceTableRelations 指定された姫・キャストの関連を置き換える
+func replaceTableRelations(tx *gorm.DB, userID, storeID uint, record *models.TableRecord, req *TableRecordRequest) error {
+	if req.HimeIDs != nil {
+		if err := tx.Where("table_id = ?", record.ID).Delete(&models.TableHime{}).Error; err != nil {
+			return err
+		}
+		if _, err := createTableHimes(tx, userID, record, req.himeIDs(), req.Allocations); err != nil {
+			return err
+		}
+	}
+	if req.MainCastID != nil || req.HelpCastIDs != nil {
+		if err := tx.Where("table_id = ?", record.ID).Delete(&models.TableCast{}).Error; err != nil {
+			return err
+		}
+		mainCastID, helpCastIDs := req.castIDs()
+		if err := createTableCasts(tx, storeID, record, mainCastID, helpCastIDs); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	var requestData map[string]interface{}
-	if err := c.ShouldBindJSON(&requestData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+// createTableHimes 卓の姫の関連を作成し、売上情報の割り方で姫ごとの負担額を記録する
+// 金額を指定する割り方（custom）の場合は custom に姫ごとの負担額を指定する
+// 関連を作成した姫（ユーザーの姫）のIDを返す
+func createTableHimes(tx *gorm.DB, userID uint, record *models.TableRecord, requested []uint, custom []services.HimeAllocation) ([]uint, error) {
+	if len(requested) == 0 {
+		return nil, nil
 	}
 
-	// トランザクション開始
-	tx := h.db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
+	// 指定された姫が現在のユーザーのものか確認（ユーザーの姫でない場合はスキップ）
+	var owned []uint
+	if err := tx.Model(&models.Hime{}).Where("user_id = ? AND id IN ?", userID, requested).Pluck("id", &owned).Error; err != nil {
+		return nil, err
+	}
+	himeIDs := make([]uint, 0, len(requested))
+	for _, id := range requested {
+		if containsUint(owned, id) && !containsUint(himeIDs, id) {
+			himeIDs = append(himeIDs, id)
 		}
-	}()
+	}
 
-	// テーブル記録を更新（部分更新対応）
-	// datetimeフィールドがある場合のみ更新
-	if datetimeStr, ok := requestData["datetime"].(string); ok && datetimeStr != "" {
-		parsedTime := parseTime(datetimeStr)
-		// パースに失敗した場合（ゼロ値）はエラーを返す
-		if parsedTime.IsZero() {
-			tx.Rollback()
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid datetime format"})
-			return
+	// 接客中の卓の負担額は会計で確定する
+	var allocations []services.HimeAllocation
+	if record.Status != models.TableStatusOpen {
+		var err error
+		if allocations, err = services.SplitBill(record.SalesInfo, himeIDs, custom); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, himeID := range himeIDs {
+		tableHime := models.TableHime{
+			TableID: record.ID,
+			HimeID:  himeID,
+		}
+		if allocations != nil {
+			tableHime.Allocation = &allocations[i].Amount
+		}
+		if err := tx.Create(&tableHime).Error; err != nil {
+			return nil, err
 		}
-		record.Datetime = parsedTime
 	}
-	// tableNumberフィールドがある場合のみ更新
-	if tableNumber, ok := requestData["tableNumber"]; ok {
-		record.TableNumber = parseStringPtr(tableNumber)
+	return himeIDs, nil
+}
+
+// createTableCasts 卓のメインキャストとヘルプキャストの関連を作成（店舗のキャストでない場合はスキップ）
+func createTableCasts(tx *gorm.DB, storeID uint, record *models.TableRecord, mainCastID uint, helpCastIDs []uint) error {
+	if mainCastID == 0 && len(helpCastIDs) == 0 {
+		return nil
 	}
-	// memoフィールドがある場合のみ更新
-	if memo, ok := requestData["memo"]; ok {
-		record.Memo = parseStringPtr(memo)
+	requested := append([]uint{mainCastID}, helpCastIDs...)
+	var owned []uint
+	if err := tx.Model(&models.Cast{}).Where("store_id = ? AND id IN ?", storeID, requested).Pluck("id", &owned).Error; err != nil {
+		return err
+	}
+
+	if mainCastID > 0 && containsUint(owned, mainCastID) {
+		if err := tx.Create(&models.TableCast{TableID: record.ID, CastID: mainCastID, Role: "main"}).Error; err != nil {
+			return err
+		}
 	}
-	// salesInfoフィールドがある場合のみ更新
-	if salesInfo, ok := requestData["salesInfo"]; ok {
-		record.SalesInfo = parseSalesInfo(salesInfo)
+	for _, castID := range helpCastIDs {
+		if !containsUint(owned, castID) {
+			continue
+		}
+		if err := tx.Create(&models.TableCast{TableID: record.ID, CastID: castID, Role: "help"}).Error; err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	if err := tx.Save(&record).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+// createVisitRecords 卓記録に参加している各姫の来店履歴を作成（同日の来店履歴がある場合は作成しない）
+func createVisitRecords(tx *gorm.DB, userID uint, record *models.TableRecord, himeIDs []uint) error {
+	// 卓記録の日付を取得（日付のみ、時刻は無視）
+	visitDate := time.Date(
+		record.Datetime.Year(),
+		record.Datetime.Month(),
+		record.Datetime.Day(),
+		0, 0, 0, 0,
+		record.Datetime.Location(),
+	)
+	// 翌日の0時（同日の終わり）
+	nextDay := visitDate.AddDate(0, 0, 1)
+
+	// 各姫について来店履歴を作成（同日の来店履歴が既に存在する場合はスキップ）
+	for _, himeID := range himeIDs {
+		// 同日の来店履歴が既に存在するかチェック（日付範囲でチェック、ユーザーIDでフィルタリング）
+		var existingVisit models.VisitRecord
+		err := tx.Where("user_id = ? AND hime_id = ? AND visit_date >= ? AND visit_date < ?", userID, himeID, visitDate, nextDay).
+			First(&existingVisit).Error
+		if err == nil {
+			// 既に存在する場合はスキップ
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		// 来店履歴を作成
+		visitRecord := models.VisitRecord{
+			UserID:    userID,
+			HimeID:    himeID,
+			VisitDate: visitDate,
+		}
+		if err := tx.Create(&visitRecord).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// respondTableError 卓記録の操作のエラーを返す（卓の状態による拒否は409、指定の誤りは400）
+func respondTableError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrTableNotOpen),
+		errors.Is(err, services.ErrTableNotClosed),
+		errors.Is(err, services.ErrTableClosed),
+		errors.Is(err, services.ErrTableHasPayments),
+		errors.Is(err, services.ErrTableHasDocuments),
+		errors.Is(err, errTableHasPayments),
+		errors.Is(err, errTableHasDocuments):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, errTableOrderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Table record not found"})
+	case errors.Is(err, services.ErrInvalidSplitMode),
+		errors.Is(err, services.ErrAllocationHime),
+		errors.Is(err, services.ErrAllocationMismatch):
+		respondSplitError(c, err)
+	default:
+		respondBillingError(c, err)
+	}
+}
+
+// respondSplitError 負担額の割り当てのエラーを返す（指定の誤りは400）
+func respondSplitError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrInvalidSplitMode) ||
+		errors.Is(err, services.ErrAllocationHime) ||
+		errors.Is(err, services.ErrAllocationMismatch) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
 
-	// 既存の関連を削除
-	tx.Where("table_id = ?", record.ID).Delete(&models.TableHime{})
-	tx.Where("table_id = ?", record.ID).Delete(&models.TableCast{})
+// BillRequest 会計の計算（保存しない）のリクエスト
+type BillRequest struct {
+	models.SalesInfo
+	Datetime *RequestTime `json:"datetime"` // 入店日時（省略時は現在）
+}
 
-	// 姫の関連を作成（ユーザーIDでフィルタリング）
-	if himeIds, ok := requestData["himeIds"].([]interface{}); ok {
-		for _, himeId := range himeIds {
-			var himeIdUint uint
-			switch v := himeId.(type) {
-			case float64:
-				himeIdUint = uint(v)
-			case int:
-				himeIdUint = uint(v)
-			default:
-				continue
-			}
-			// 指定された姫が現在のユーザーのものか確認
-			var hime models.Hime
-			if err := tx.Where("user_id = ? AND id = ?", userID, himeIdUint).First(&hime).Error; err != nil {
-				// ユーザーの姫でない場合はスキップ
-				continue
-			}
-			tableHime := models.TableHime{
-				TableID: record.ID,
-				HimeID:  himeIdUint,
-			}
-			if err := tx.Create(&tableHime).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-				return
-			}
-		}
+// CalculateBill 売上情報の会計をサーバー側で計算して返す（保存はしない）
+// 注文（メニューIDと数量）・来店区分・滞在時間・入店日時から、店舗のメニューと料金ルールで金額を決める
+func (h *TableHandler) CalculateBill(c *gin.Context) {
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
 	}
 
-	// メインキャストの関連を作成（ユーザーIDでフィルタリング）
-	if mainCastId, ok := requestData["mainCastId"].(float64); ok && mainCastId > 0 {
-		// 指定されたキャストが現在のユーザーのものか確認
-		var cast models.Cast
-		if err := tx.Where("user_id = ? AND id = ?", userID, uint(mainCastId)).First(&cast).Error; err == nil {
-			tableCast := models.TableCast{
-				TableID: record.ID,
-				CastID:  uint(mainCastId),
-				Role:    "main",
-			}
-			if err := tx.Create(&tableCast).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-				return
-			}
-		}
+	var req BillRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	at := time.Now()
+	if req.Datetime.isSet() {
+		at = req.Datetime.Time
 	}
 
-	// ヘルプキャストの関連を作成（ユーザーIDでフィルタリング）
-	if helpCastIds, ok := requestData["helpCastIds"].([]interface{}); ok {
-		for _, castId := range helpCastIds {
-			var castIdUint uint
-			switch v := castId.(type) {
-			case float64:
-				castIdUint = uint(v)
-			case int:
-				castIdUint = uint(v)
-			default:
-				continue
-			}
-			// 指定されたキャストが現在のユーザーのものか確認
-			var cast models.Cast
-			if err := tx.Where("user_id = ? AND id = ?", userID, castIdUint).First(&cast).Error; err != nil {
-				// ユーザーのキャストでない場合はスキップ
-				continue
-			}
-			tableCast := models.TableCast{
-				TableID: record.ID,
-				CastID:  castIdUint,
-				Role:    "help",
-			}
-			if err := tx.Create(&tableCast).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-				return
-			}
-		}
+	bill, err := services.CalculateBill(c.Request.Context(), h.db, storeID, &req.SalesInfo, at)
+	if err != nil {
+		respondBillingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, bill)
+}
+
+// calculateSalesInfo 送られた売上情報をもとにサーバー側で会計を計算する
+// クライアントが計算した金額が一致しない場合は、正しい金額を付けて422を返す
+func (h *TableHandler) calculateSalesInfo(c *gin.Context, storeID uint, order *models.SalesInfo, at time.Time) (*models.SalesInfo, bool) {
+	if order == nil {
+		return nil, true
 	}
 
-	tx.Commit()
+	bill, err := services.CalculateBill(c.Request.Context(), h.db, storeID, order, at)
+	if err != nil {
+		respondBillingError(c, err)
+		return nil, false
+	}
+	if err := services.CheckClientBill(order, bill); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":     "売上金額がメニューと料金ルールから計算した金額と一致しません: " + err.Error(),
+			"salesInfo": bill,
+		})
+		return nil, false
+	}
+	return bill, true
+}
 
-	// 更新したレコードを取得して返す（トランザクション内で取得）
-	recordMap := h.buildTableRecordMap(record, userID)
-	c.JSON(http.StatusOK, recordMap)
+// respondBillingError 会計計算のエラーを返す（注文内容の誤りは400）
+func respondBillingError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrUnknownMenuItem) ||
+		errors.Is(err, services.ErrInvalidOrderQuantity) ||
+		errors.Is(err, services.ErrInvalidVisitType) ||
+		errors.Is(err, services.ErrInvalidStayHours) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 }
 
 // Delete 卓記録を削除
@@ -744,39 +741,45 @@ func (h *TableHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	// トランザクション開始
-	tx := h.db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
+	err = h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		// 自分の卓記録であることを確認してから関連データを削除する（注文の追加・会計と直列化）
+		record, err := services.LockTable(tx, userID, id)
+		if err != nil {
+			return err
 		}
-	}()
 
-	// 関連データを削除（外部キー制約を考慮）
-	// 1. TableHimeを削除
-	if err := tx.Where("table_id = ?", id).Delete(&models.TableHime{}).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	// 2. TableCastを削除
-	if err := tx.Where("table_id = ?", id).Delete(&models.TableCast{}).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
+		// 支払い（売掛）の記録は残す必要があるため、支払いのある卓は削除しない
+		var payments int64
+		if err := tx.Model(&models.Payment{}).Where("user_id = ? AND table_id = ?", userID, record.ID).Count(&payments).Error; err != nil {
+			return err
+		}
+		if payments > 0 {
+			return errTableHasPayments
+		}
 
-	// 3. TableRecordを削除
-	if err := tx.Where("user_id = ? AND id = ?", userID, id).Delete(&models.TableRecord{}).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
+		// 発行した領収書・請求書は保存義務があるため、書類のある卓も削除しない
+		var documents int64
+		if err := tx.Model(&models.IssuedDocument{}).Where("user_id = ? AND table_id = ?", userID, record.ID).Count(&documents).Error; err != nil {
+			return err
+		}
+		if documents > 0 {
+			return errTableHasDocuments
+		}
 
-	// コミット
-	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		// 関連データを削除（外部キー制約を考慮）
+		if err := tx.Where("table_id = ?", record.ID).Delete(&models.TableHime{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("table_id = ?", record.ID).Delete(&models.TableCast{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("table_id = ?", record.ID).Delete(&models.TableOrder{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(record).Error
+	})
+	if err != nil {
+		respondTableError(c, err)
 		return
 	}
 
@@ -784,75 +787,143 @@ func (h *TableHandler) Delete(c *gin.Context) {
 }
 
 // BulkCreate 複数の卓記録を一括作成
+// 1件ずつ Create と同じく売上情報をサーバー側で計算し、会計済みの記録として登録する（1件でも不正なら登録しない）
 func (h *TableHandler) BulkCreate(c *gin.Context) {
 	userID, ok := getUserID(c)
 	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
 		return
 	}
+	storeID, ok := getStoreID(c, h.db)
+	if !ok {
+		return
+	}
 
-	var records []models.TableRecord
-	if err := c.ShouldBindJSON(&records); err != nil {
+	var reqs []TableRecordRequest
+	if err := c.ShouldBindJSON(&reqs); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// IDを無視（自動生成）、ユーザーIDを設定
-	for i := range records {
-		records[i].ID = 0
-		records[i].UserID = userID
+	now := time.Now()
+	records := make([]models.TableRecord, len(reqs))
+	for i := range reqs {
+		req := &reqs[i]
+		if !req.Datetime.isSet() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("records[%d]: datetime is required", i)})
+			return
+		}
+		salesInfo, ok := h.calculateSalesInfo(c, storeID, req.SalesInfo, req.Datetime.Time)
+		if !ok {
+			return
+		}
+		records[i] = models.TableRecord{
+			UserID:      userID,
+			Datetime:    req.Datetime.Time,
+			TableNumber: nonEmpty(req.TableNumber),
+			Memo:        nonEmpty(req.Memo),
+			SalesInfo:   salesInfo,
+			Status:      models.TableStatusClosed,
+			ClosedAt:    &now,
+		}
 	}
 
 	// トランザクション内で一括作成（一貫性のため）
-	if err := h.db.Transaction(func(tx *gorm.DB) error {
-		return tx.Create(&records).Error
+	if err := h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		for i := range records {
+			if err := tx.Create(&records[i]).Error; err != nil {
+				return err
+			}
+			if err := createTableRelations(tx, userID, storeID, &records[i], &reqs[i]); err != nil {
+				return err
+			}
+		}
+		return nil
 	}); err != nil {
+		respondTableError(c, err)
+		return
+	}
+
+	responses, err := buildTableRecordResponses(h.db.WithContext(c), records, userID, storeID)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusCreated, records)
+	c.JSON(http.StatusCreated, responses)
 }
 
-// CreateTableHime 卓と姫の関連を作成
+// CreateTableHime 卓と姫の関連を作成（接客中の卓のみ）
 func (h *TableHandler) CreateTableHime(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+
 	var tableHime models.TableHime
 	if err := c.ShouldBindJSON(&tableHime); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// IDを無視（自動生成）
+	// IDを無視（自動生成）、負担額は会計の割り当て（SplitBill）でのみ設定する
 	tableHime.ID = 0
+	tableHime.Allocation = nil
 
-	if err := h.db.Create(&tableHime).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := lockOpenTables(tx, userID, []uint{tableHime.TableID}); err != nil {
+			return err
+		}
+		return tx.Create(&tableHime).Error
+	}); err != nil {
+		respondTableError(c, err)
 		return
 	}
 	c.JSON(http.StatusCreated, tableHime)
 }
 
-// BulkCreateTableHime 複数の卓と姫の関連を一括作成
+// BulkCreateTableHime 複数の卓と姫の関連を一括作成（接客中の卓のみ）
 func (h *TableHandler) BulkCreateTableHime(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+
 	var tableHimes []models.TableHime
 	if err := c.ShouldBindJSON(&tableHimes); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// IDを無視（自動生成）
+	// IDを無視（自動生成）、負担額は会計の割り当て（SplitBill）でのみ設定する
+	tableIDs := make([]uint, len(tableHimes))
 	for i := range tableHimes {
 		tableHimes[i].ID = 0
+		tableHimes[i].Allocation = nil
+		tableIDs[i] = tableHimes[i].TableID
 	}
 
-	if err := h.db.Create(&tableHimes).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := lockOpenTables(tx, userID, tableIDs); err != nil {
+			return err
+		}
+		return tx.Create(&tableHimes).Error
+	}); err != nil {
+		respondTableError(c, err)
 		return
 	}
 	c.JSON(http.StatusCreated, tableHimes)
 }
 
-// CreateTableCast 卓とキャストの関連を作成
+// CreateTableCast 卓とキャストの関連を作成（接客中の卓のみ）
 func (h *TableHandler) CreateTableCast(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+
 	var tableCast models.TableCast
 	if err := c.ShouldBindJSON(&tableCast); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -862,15 +933,26 @@ func (h *TableHandler) CreateTableCast(c *gin.Context) {
 	// IDを無視（自動生成）
 	tableCast.ID = 0
 
-	if err := h.db.Create(&tableCast).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := lockOpenTables(tx, userID, []uint{tableCast.TableID}); err != nil {
+			return err
+		}
+		return tx.Create(&tableCast).Error
+	}); err != nil {
+		respondTableError(c, err)
 		return
 	}
 	c.JSON(http.StatusCreated, tableCast)
 }
 
-// BulkCreateTableCast 複数の卓とキャストの関連を一括作成
+// BulkCreateTableCast 複数の卓とキャストの関連を一括作成（接客中の卓のみ）
 func (h *TableHandler) BulkCreateTableCast(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+
 	var tableCasts []models.TableCast
 	if err := c.ShouldBindJSON(&tableCasts); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -878,13 +960,42 @@ func (h *TableHandler) BulkCreateTableCast(c *gin.Context) {
 	}
 
 	// IDを無視（自動生成）
+	tableIDs := make([]uint, len(tableCasts))
 	for i := range tableCasts {
 		tableCasts[i].ID = 0
+		tableIDs[i] = tableCasts[i].TableID
 	}
 
-	if err := h.db.Create(&tableCasts).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := lockOpenTables(tx, userID, tableIDs); err != nil {
+			return err
+		}
+		return tx.Create(&tableCasts).Error
+	}); err != nil {
+		respondTableError(c, err)
 		return
 	}
 	c.JSON(http.StatusCreated, tableCasts)
 }
+
+// lockOpenTables 関連を追加する卓（ユーザーの卓）を行ロックし、接客中であることを確認する
+// 会計済み・取り消し済みの卓は売上と姫ごとの負担額が確定しているため、姫・キャストを追加させない
+// 同時に一括作成した場合にデッドロックしないよう、卓ID順にロックする
+func lockOpenTables(tx *gorm.DB, userID uint, tableIDs []uint) error {
+	ids := make([]uint, 0, len(tableIDs))
+	seen := make(map[uint]bool, len(tableIDs))
+	for _, id := range tableIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		if _, err := services.LockOpenTable(tx, userID, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
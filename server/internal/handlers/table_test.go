@@ -0,0 +1,369 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hostnote/server/internal/models"
+)
+
+// TestRequestTimeUnmarshal 受け付ける日時の形式と、解釈できない値がエラーになることをテスト
+func TestRequestTimeUnmarshal(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		want    time.Time
+		wantErr bool
+	}{
+		{"RFC3339", `"2026-03-01T20:30:00+09:00"`, time.Date(2026, 3, 1, 20, 30, 0, 0, time.FixedZone("", 9*60*60)), false},
+		{"タイムゾーンなし", `"2026-03-01T20:30:15"`, time.Date(2026, 3, 1, 20, 30, 15, 0, time.UTC), false},
+		{"datetime-local", `"2026-03-01T20:30"`, time.Date(2026, 3, 1, 20, 30, 0, 0, time.UTC), false},
+		{"空文字は未指定", `""`, time.Time{}, false},
+		{"nullは未指定", `null`, time.Time{}, false},
+		{"日付のみ", `"2026-03-01"`, time.Time{}, true},
+		{"不正な文字列", `"昨日の夜"`, time.Time{}, true},
+		{"数値", `1700000000`, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got RequestTime
+			err := json.Unmarshal([]byte(tt.json), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !got.Time.Equal(tt.want) {
+				t.Errorf("time = %v, want %v", got.Time, tt.want)
+			}
+		})
+	}
+}
+
+// TestCreateTableInvalidDatetime 解釈できない入店日時を400で拒否することをテスト
+func TestCreateTableInvalidDatetime(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/table", withUser(1), func(c *gin.Context) { c.Set("storeID", uint(1)) }, NewTableHandler(newDryRunDB(t)).Create)
+
+	for _, body := range []string{
+		`{"datetime":"2026/03/01 20:30","himeIds":[1]}`,
+		`{"himeIds":[1]}`,
+		`{"datetime":"2026-03-01T20:30","himeIds":["あいり"]}`,
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/table", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400 (%s)", body, w.Code, w.Body.String())
+		}
+	}
+}
+
+// TestLockedTableField 卓の状態ごとに変更できる項目をテスト
+func TestLockedTableField(t *testing.T) {
+	var req TableRecordRequest
+	if err := json.Unmarshal([]byte(`{"memo":"","himeIds":[1,2]}`), &req); err != nil {
+		t.Fatal(err)
+	}
+
+	open := &models.TableRecord{Status: models.TableStatusOpen}
+	if field, ok := lockedTableField(open, &req); !ok {
+		t.Errorf("open: locked field %q, want editable", field)
+	}
+
+	closed := &models.TableRecord{Status: models.TableStatusClosed}
+	if field, ok := lockedTableField(closed, &req); ok || field != "himeIds" {
+		t.Errorf("closed: got (%q, %v), want (\"himeIds\", false)", field, ok)
+	}
+
+	var memoOnly TableRecordRequest
+	if err := json.Unmarshal([]byte(`{"tableNumber":"A1","memo":"誕生日"}`), &memoOnly); err != nil {
+		t.Fatal(err)
+	}
+	voided := &models.TableRecord{Status: models.TableStatusVoided}
+	if field, ok := lockedTableField(voided, &memoOnly); !ok {
+		t.Errorf("voided: locked field %q, want editable", field)
+	}
+
+	// 売上情報は接客中でも注文エンドポイントで変更する
+	var sales TableRecordRequest
+	if err := json.Unmarshal([]byte(`{"salesInfo":{"orderItems":[]}}`), &sales); err != nil {
+		t.Fatal(err)
+	}
+	if field, ok := lockedTableField(open, &sales); ok || field != "salesInfo" {
+		t.Errorf("open salesInfo: got (%q, %v), want (\"salesInfo\", false)", field, ok)
+	}
+}
+
+// TestBulkCreateTable 一括作成でも売上情報をサーバー側で計算し、会計済みとして登録することをテスト
+func TestBulkCreateTable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := newTestDB(t, &models.TableRecord{}, &models.TableHime{}, &models.TableCast{}, &models.Hime{}, &models.Cast{},
+		&models.VisitRecord{}, &models.PricingRule{}, &models.Menu{})
+	r := gin.New()
+	r.POST("/table/bulk", withUser(1), func(c *gin.Context) { c.Set("storeID", uint(1)) }, NewTableHandler(db).BulkCreate)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/table/bulk", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// 1件でも金額が一致しなければ何も登録しない
+	w := post(`[{"datetime":"2026-03-01T20:30","salesInfo":{"visitType":"normal","stayHours":1}},
+		{"datetime":"2026-03-02T20:30","salesInfo":{"visitType":"normal","stayHours":1,"total":1}}]`)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("mismatch: status = %d, want 422 (%s)", w.Code, w.Body.String())
+	}
+	var count int64
+	db.Model(&models.TableRecord{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("records = %d, want 0", count)
+	}
+
+	// クライアントが送った状態・金額は使わない
+	w = post(`[{"datetime":"2026-03-01T20:30","salesInfo":{"visitType":"normal","stayHours":1,"tableCharge":1},"status":"open","closedAt":null}]`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d (%s)", w.Code, w.Body.String())
+	}
+	var record models.TableRecord
+	if err := db.First(&record).Error; err != nil {
+		t.Fatal(err)
+	}
+	if record.Status != models.TableStatusClosed || record.ClosedAt == nil {
+		t.Errorf("status = %q, closedAt = %v, want closed", record.Status, record.ClosedAt)
+	}
+	if record.SalesInfo == nil || record.SalesInfo.Total == 0 || record.SalesInfo.TableCharge == 1 {
+		t.Errorf("salesInfo = %+v, want the server-side bill", record.SalesInfo)
+	}
+}
+
+// TestDeleteTableWithPayments 支払いを登録済みの卓は削除せず409を返すことをテスト
+func TestDeleteTableWithPayments(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := newTestDB(t, &models.TableRecord{}, &models.TableHime{}, &models.TableCast{}, &models.TableOrder{},
+		&models.Payment{}, &models.IssuedDocument{})
+	r := gin.New()
+	r.DELETE("/table/:id", withUser(1), NewTableHandler(db).Delete)
+
+	now := time.Now()
+	for _, v := range []interface{}{
+		&models.TableRecord{ID: 1, UserID: 1, Datetime: now, Status: models.TableStatusClosed},
+		&models.TableRecord{ID: 2, UserID: 1, Datetime: now, Status: models.TableStatusClosed},
+		&models.TableHime{TableID: 1, HimeID: 1},
+		&models.Payment{UserID: 1, TableID: 1, HimeID: 1, Method: models.PaymentMethodTab, Amount: 10000, PaidAt: now},
+	} {
+		if err := db.Create(v).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	del := func(id string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/table/"+id, nil))
+		return w
+	}
+
+	if w := del("1"); w.Code != http.StatusConflict {
+		t.Fatalf("with payments: status = %d, want 409 (%s)", w.Code, w.Body.String())
+	}
+	var payments, himes int64
+	db.Model(&models.Payment{}).Count(&payments)
+	db.Model(&models.TableHime{}).Count(&himes)
+	if payments != 1 || himes != 1 {
+		t.Errorf("payments = %d, table himes = %d, want both kept", payments, himes)
+	}
+
+	if w := del("2"); w.Code != http.StatusOK {
+		t.Fatalf("without payments: status = %d (%s)", w.Code, w.Body.String())
+	}
+	var records int64
+	db.Model(&models.TableRecord{}).Count(&records)
+	if records != 1 {
+		t.Errorf("records = %d, want 1", records)
+	}
+}
+
+// TestCreateTableHimeIgnoresAllocation 卓と姫の関連の作成では負担額を受け付けないことをテスト
+func TestCreateTableHimeIgnoresAllocation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := newTestDB(t, &models.TableRecord{}, &models.TableHime{})
+	h := NewTableHandler(db)
+	r := gin.New()
+	r.POST("/table-hime", withUser(1), h.CreateTableHime)
+	r.POST("/table-hime/bulk", withUser(1), h.BulkCreateTableHime)
+	if err := db.Create(&models.TableRecord{ID: 1, UserID: 1, Datetime: time.Now(), Status: models.TableStatusOpen}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for path, body := range map[string]string{
+		"/table-hime":      `{"tableId":1,"himeId":1,"allocation":99999}`,
+		"/table-hime/bulk": `[{"tableId":1,"himeId":2,"allocation":99999}]`,
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("%s: status = %d (%s)", path, w.Code, w.Body.String())
+		}
+	}
+
+	var tableHimes []models.TableHime
+	db.Find(&tableHimes)
+	if len(tableHimes) != 2 {
+		t.Fatalf("table himes = %d, want 2", len(tableHimes))
+	}
+	for _, th := range tableHimes {
+		if th.Allocation != nil {
+			t.Errorf("hime %d allocation = %v, want nil", th.HimeID, *th.Allocation)
+		}
+	}
+}
+
+// TestCreateTableLinksRequireOpenTable 姫・キャストの関連は自分の接客中の卓にのみ追加でき、会計済みの卓は409、他のユーザーの卓は404を返すことをテスト
+func TestCreateTableLinksRequireOpenTable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := newTestDB(t, &models.TableRecord{}, &models.TableHime{}, &models.TableCast{})
+	h := NewTableHandler(db)
+	r := gin.New()
+	r.POST("/table-hime", withUser(1), h.CreateTableHime)
+	r.POST("/table-hime/bulk", withUser(1), h.BulkCreateTableHime)
+	r.POST("/table-cast", withUser(1), h.CreateTableCast)
+	r.POST("/table-cast/bulk", withUser(1), h.BulkCreateTableCast)
+
+	now := time.Now()
+	for _, v := range []interface{}{
+		&models.TableRecord{ID: 1, UserID: 1, Datetime: now, Status: models.TableStatusOpen},
+		&models.TableRecord{ID: 2, UserID: 1, Datetime: now, Status: models.TableStatusClosed, ClosedAt: &now},
+		&models.TableRecord{ID: 3, UserID: 2, Datetime: now, Status: models.TableStatusOpen},
+	} {
+		if err := db.Create(v).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		path, body string
+		want       int
+	}{
+		{"/table-hime", `{"tableId":1,"himeId":1}`, http.StatusCreated},
+		{"/table-hime/bulk", `[{"tableId":1,"himeId":2}]`, http.StatusCreated},
+		{"/table-cast", `{"tableId":1,"castId":1,"role":"main"}`, http.StatusCreated},
+		{"/table-cast/bulk", `[{"tableId":1,"castId":2,"role":"help"}]`, http.StatusCreated},
+		{"/table-hime", `{"tableId":2,"himeId":1}`, http.StatusConflict},
+		{"/table-hime/bulk", `[{"tableId":1,"himeId":3},{"tableId":2,"himeId":3}]`, http.StatusConflict},
+		{"/table-cast", `{"tableId":2,"castId":1,"role":"main"}`, http.StatusConflict},
+		{"/table-cast/bulk", `[{"tableId":2,"castId":2,"role":"help"}]`, http.StatusConflict},
+		{"/table-hime", `{"tableId":3,"himeId":1}`, http.StatusNotFound},
+		{"/table-cast/bulk", `[{"tableId":3,"castId":1,"role":"main"}]`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s %s: status = %d, want %d (%s)", tt.path, tt.body, w.Code, tt.want, w.Body.String())
+		}
+	}
+
+	// 拒否した一括作成では、接客中の卓の分も作成しない
+	var himes, casts int64
+	db.Model(&models.TableHime{}).Count(&himes)
+	db.Model(&models.TableCast{}).Count(&casts)
+	if himes != 2 || casts != 2 {
+		t.Errorf("table himes = %d, table casts = %d, want 2 each", himes, casts)
+	}
+}
+
+// TestDeleteOtherUsersTable 他のユーザーの卓は関連データも含めて削除せず404を返すことをテスト
+func TestDeleteOtherUsersTable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := newTestDB(t, &models.TableRecord{}, &models.TableHime{}, &models.TableCast{}, &models.TableOrder{},
+		&models.Payment{}, &models.IssuedDocument{})
+	r := gin.New()
+	r.DELETE("/table/:id", withUser(1), NewTableHandler(db).Delete)
+
+	now := time.Now()
+	for _, v := range []interface{}{
+		&models.TableRecord{ID: 1, UserID: 2, Datetime: now, Status: models.TableStatusOpen},
+		&models.TableHime{TableID: 1, HimeID: 1},
+		&models.TableCast{TableID: 1, CastID: 1, Role: "main"},
+		&models.TableOrder{TableID: 1, MenuID: 1, Quantity: 1, OrderedBy: 2, OrderedAt: now},
+	} {
+		if err := db.Create(v).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/table/1", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404 (%s)", w.Code, w.Body.String())
+	}
+	for _, m := range []interface{}{&models.TableHime{}, &models.TableCast{}, &models.TableOrder{}} {
+		var count int64
+		db.Model(m).Count(&count)
+		if count != 1 {
+			t.Errorf("%T = %d, want 1 (kept)", m, count)
+		}
+	}
+}
+
+// TestTableOrderRequestQuantity 注文の数量は省略（1）か1以上のみ受け付けることをテスト
+func TestTableOrderRequestQuantity(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for body, wantOK := range map[string]bool{
+		`{"menuId":1}`:               true,
+		`{"menuId":1,"quantity":3}`:  true,
+		`{"menuId":1,"quantity":-1}`: false,
+	} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		var req TableOrderRequest
+		if err := c.ShouldBindJSON(&req); (err == nil) != wantOK {
+			t.Errorf("%s: err = %v, want ok = %v", body, err, wantOK)
+		}
+	}
+}
+
+// TestDeleteTableWithDocuments 領収書・請求書を発行済みの卓は削除せず409を返すことをテスト
+func TestDeleteTableWithDocuments(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := newTestDB(t, &models.TableRecord{}, &models.TableHime{}, &models.TableCast{}, &models.TableOrder{},
+		&models.Payment{}, &models.IssuedDocument{})
+	r := gin.New()
+	r.DELETE("/table/:id", withUser(1), NewTableHandler(db).Delete)
+
+	now := time.Now()
+	for _, v := range []interface{}{
+		&models.TableRecord{ID: 1, UserID: 1, Datetime: now, Status: models.TableStatusClosed},
+		&models.IssuedDocument{UserID: 1, TableID: 1, Type: models.DocumentTypeReceipt, Number: "R-000001", Amount: 10000, IssuedAt: now},
+	} {
+		if err := db.Create(v).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/table/1", nil))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want 409 (%s)", w.Code, w.Body.String())
+	}
+	var records, documents int64
+	db.Model(&models.TableRecord{}).Count(&records)
+	db.Model(&models.IssuedDocument{}).Count(&documents)
+	if records != 1 || documents != 1 {
+		t.Errorf("records = %d, documents = %d, want both kept", records, documents)
+	}
+}
@@ -0,0 +1,551 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/hostnote/server/internal/middleware"
+	"github.com/hostnote/server/internal/models"
+	"github.com/hostnote/server/internal/services"
+	"gorm.io/gorm"
+)
+
+const (
+	twoFactorIssuer    = "HostNote"      // 認証アプリに表示される発行者名
+	challengeTokenType = "2fa_challenge" // チャレンジトークンのtypクレーム
+	challengeTokenTTL  = 5 * time.Minute // チャレンジトークンの有効期間
+	recoveryCodeCount  = 10              // 発行するリカバリーコードの数
+	recoveryCodeBytes  = 5               // リカバリーコードのランダムバイト数（16進10桁）
+	totpCodeLength     = 6               // TOTPコードの桁数
+)
+
+var (
+	errInvalidTwoFactorCode   = errors.New("認証コードが正しくありません")
+	errInvalidChallengeToken  = errors.New("認証の有効期限が切れました。再度ログインしてください")
+	errTwoFactorSetupRequired = errors.New("店舗の設定により2段階認証の登録が必要です。再度ログインしてください")
+)
+
+// TwoFactorChallengeResponse パスワード認証後、2段階認証が必要な場合のレスポンス
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"twoFactorRequired"`
+	SetupRequired     bool   `json:"setupRequired"` // 店舗の設定により未登録のユーザーも登録が必要
+	ChallengeToken    string `json:"challengeToken"`
+}
+
+// TwoFactorSetupResponse 認証アプリ登録用の情報
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauthUri"`
+}
+
+// TwoFactorStatusResponse 2段階認証の状態
+type TwoFactorStatusResponse struct {
+	Enabled                bool  `json:"enabled"`
+	Required               bool  `json:"required"` // 所属店舗で必須か
+	RecoveryCodesRemaining int64 `json:"recoveryCodesRemaining"`
+}
+
+// TwoFactorVerifyResponse チャレンジ検証後の認証レスポンス（ログイン時に登録した場合はリカバリーコードを含む）
+type TwoFactorVerifyResponse struct {
+	*AuthResponse
+	RecoveryCodes []string `json:"recoveryCodes,omitempty"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type TwoFactorChallengeRequest struct {
+	ChallengeToken string `json:"challengeToken" binding:"required"`
+}
+
+type TwoFactorVerifyRequest struct {
+	ChallengeToken string `json:"challengeToken" binding:"required"`
+	Code           string `json:"code" binding:"required"` // TOTPコードまたはリカバリーコード
+}
+
+type DisableTwoFactorRequest struct {
+	Password string `json:"password"` // パスワードを設定していない（Googleのみの）アカウントは不要
+	Code     string `json:"code" binding:"required"`
+}
+
+// TwoFactorStatus 2段階認証の状態を取得
+func (h *AuthHandler) TwoFactorStatus(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	var remaining int64
+	if err := h.db.Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", user.ID).Count(&remaining).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, TwoFactorStatusResponse{
+		Enabled:                user.TotpEnabled,
+		Required:               storeRequiresTwoFactor(h.db, user.ID),
+		RecoveryCodesRemaining: remaining,
+	})
+}
+
+// SetupTwoFactor 2段階認証の登録を開始（シークレットを発行し、有効化はEnableTwoFactorで行う）
+func (h *AuthHandler) SetupTwoFactor(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	if user.TotpEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "2段階認証は既に有効です"})
+		return
+	}
+
+	setup, err := startTwoFactorSetup(h.db, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "シークレットの生成に失敗しました"})
+		return
+	}
+	c.JSON(http.StatusOK, setup)
+}
+
+// EnableTwoFactor 認証アプリのコードを確認して2段階認証を有効化し、リカバリーコードを返す
+func (h *AuthHandler) EnableTwoFactor(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if user.TotpEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "2段階認証は既に有効です"})
+		return
+	}
+
+	codes, err := enableTwoFactor(h.db, user, req.Code)
+	if err != nil {
+		if errors.Is(err, errInvalidTwoFactorCode) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+}
+
+// DisableTwoFactor パスワード（設定済みの場合）と認証コードを確認して2段階認証を無効化
+func (h *AuthHandler) DisableTwoFactor(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !user.TotpEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "2段階認証は有効になっていません"})
+		return
+	}
+	if storeRequiresTwoFactor(h.db, user.ID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "店舗の設定により2段階認証を無効にできません"})
+		return
+	}
+	if user.Password != nil && !user.CheckPassword(req.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "パスワードが正しくありません"})
+		return
+	}
+	if err := verifySecondFactor(h.db, user, req.Code); err != nil {
+		if errors.Is(err, errInvalidTwoFactorCode) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"totp_secret":    nil,
+			"totp_enabled":   false,
+			"totp_last_step": 0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "2段階認証を無効にしました"})
+}
+
+// RegenerateRecoveryCodes 認証コードを確認してリカバリーコードを再発行（以前のコードは無効）
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !user.TotpEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "2段階認証は有効になっていません"})
+		return
+	}
+	if err := verifySecondFactor(h.db, user, req.Code); err != nil {
+		if errors.Is(err, errInvalidTwoFactorCode) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var codes []string
+	if err := h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+}
+
+// ChallengeSetupTwoFactor ログイン途中（店舗で必須・未登録）のユーザーが認証アプリを登録する
+func (h *AuthHandler) ChallengeSetupTwoFactor(c *gin.Context) {
+	var req TwoFactorChallengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := h.challengeUser(c, req.ChallengeToken)
+	if !ok {
+		return
+	}
+	if user.TotpEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "2段階認証は既に有効です"})
+		return
+	}
+
+	setup, err := startTwoFactorSetup(h.db, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "シークレットの生成に失敗しました"})
+		return
+	}
+	c.JSON(http.StatusOK, setup)
+}
+
+// VerifyTwoFactor チャレンジトークンと認証コードを検証してログインを完了する
+// 未登録のユーザーは登録途中のシークレットで検証し、同時に2段階認証を有効化する
+func (h *AuthHandler) VerifyTwoFactor(c *gin.Context) {
+	var req TwoFactorVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := h.challengeUser(c, req.ChallengeToken)
+	if !ok {
+		return
+	}
+
+	// 認証コードの総当たりを防ぐため、ユーザーごとにロックアウトする
+	throttleKey := fmt.Sprintf("2fa:%d", user.ID)
+	if retryAfter, locked := h.userThrottle.Locked(throttleKey); locked {
+		middleware.TooManyRequests(c, retryAfter)
+		return
+	}
+
+	var recoveryCodes []string
+	var err error
+	if user.TotpEnabled {
+		err = verifySecondFactor(h.db, user, req.Code)
+	} else {
+		recoveryCodes, err = enableTwoFactor(h.db, user, req.Code)
+	}
+	if err != nil {
+		if errors.Is(err, errInvalidTwoFactorCode) {
+			h.userThrottle.Fail(throttleKey)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.userThrottle.Reset(throttleKey)
+
+	resp, err := issueSession(h.db, c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "トークン生成に失敗しました"})
+		return
+	}
+
+	c.JSON(http.StatusOK, TwoFactorVerifyResponse{
+		AuthResponse:  resp,
+		RecoveryCodes: recoveryCodes,
+	})
+}
+
+// currentUser 認証中のユーザーを取得（失敗時はレスポンスを返す）
+func (h *AuthHandler) currentUser(c *gin.Context) (*models.User, bool) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return nil, false
+	}
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		handleDBError(c, err, "ユーザーが見つかりません")
+		return nil, false
+	}
+	return &user, true
+}
+
+// challengeUser チャレンジトークンからユーザーを取得（失敗時はレスポンスを返す）
+func (h *AuthHandler) challengeUser(c *gin.Context, challengeToken string) (*models.User, bool) {
+	userID, err := parseChallengeToken(challengeToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errInvalidChallengeToken.Error()})
+		return nil, false
+	}
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": errInvalidChallengeToken.Error()})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return &user, true
+}
+
+// respondLogin 認証済みのユーザーに2段階認証が必要ならチャレンジを、不要ならセッションを返す
+// パスワード・Google・招待による登録のいずれでも、店舗の2段階認証の設定を適用する
+func (h *AuthHandler) respondLogin(c *gin.Context, user *models.User, status int) {
+	challenge, err := newTwoFactorChallenge(h.db, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ログインに失敗しました"})
+		return
+	}
+	if challenge != nil {
+		c.JSON(status, challenge)
+		return
+	}
+
+	// セッションを作成してトークンを発行
+	resp, err := issueSession(h.db, c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "トークン生成に失敗しました"})
+		return
+	}
+	c.JSON(status, resp)
+}
+
+// newTwoFactorChallenge 認証済みのユーザーに2段階認証が必要ならチャレンジを返す（不要ならnil）
+func newTwoFactorChallenge(db *gorm.DB, user *models.User) (*TwoFactorChallengeResponse, error) {
+	setupRequired := !user.TotpEnabled && storeRequiresTwoFactor(db, user.ID)
+	if !user.TotpEnabled && !setupRequired {
+		return nil, nil
+	}
+
+	token, err := generateChallengeToken(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &TwoFactorChallengeResponse{
+		TwoFactorRequired: true,
+		SetupRequired:     setupRequired,
+		ChallengeToken:    token,
+	}, nil
+}
+
+// startTwoFactorSetup 新しいシークレットを登録途中の状態で保存
+func startTwoFactorSetup(db *gorm.DB, user *models.User) (*TwoFactorSetupResponse, error) {
+	secret, err := services.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Model(user).Update("totp_secret", secret).Error; err != nil {
+		return nil, err
+	}
+
+	account := user.Username
+	if user.Email != nil && *user.Email != "" {
+		account = *user.Email
+	}
+	return &TwoFactorSetupResponse{
+		Secret:     secret,
+		OtpauthURI: services.TOTPURI(twoFactorIssuer, account, secret),
+	}, nil
+}
+
+// enableTwoFactor 登録途中のシークレットでコードを検証して有効化し、リカバリーコードを発行
+func enableTwoFactor(db *gorm.DB, user *models.User, code string) ([]string, error) {
+	if user.TotpSecret == nil {
+		return nil, errInvalidTwoFactorCode
+	}
+	step, ok := services.ValidateTOTP(*user.TotpSecret, code, time.Now(), user.TotpLastStep)
+	if !ok {
+		return nil, errInvalidTwoFactorCode
+	}
+
+	var codes []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"totp_enabled":   true,
+			"totp_last_step": step,
+		}).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	user.TotpEnabled = true
+	user.TotpLastStep = step
+	return codes, nil
+}
+
+// verifySecondFactor TOTPコードまたはリカバリーコードを検証（どちらも1回限り有効）
+func verifySecondFactor(db *gorm.DB, user *models.User, code string) error {
+	code = strings.TrimSpace(code)
+	if user.TotpSecret == nil {
+		return errInvalidTwoFactorCode
+	}
+
+	if len(code) == totpCodeLength {
+		step, ok := services.ValidateTOTP(*user.TotpSecret, code, time.Now(), user.TotpLastStep)
+		if !ok {
+			return errInvalidTwoFactorCode
+		}
+		// 同時リクエストで同じコードが使われないよう、条件付きで更新する
+		result := db.Model(&models.User{}).
+			Where("id = ? AND totp_last_step < ?", user.ID, step).
+			Update("totp_last_step", step)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errInvalidTwoFactorCode
+		}
+		user.TotpLastStep = step
+		return nil
+	}
+
+	result := db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashToken(normalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// replaceRecoveryCodes 既存のリカバリーコードを削除し、新しいコードを発行（平文を返すのはこの時のみ）
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	records := make([]models.RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		raw := hex.EncodeToString(b)
+		codes[i] = fmt.Sprintf("%s-%s", raw[:len(raw)/2], raw[len(raw)/2:])
+		records[i] = models.RecoveryCode{UserID: userID, CodeHash: hashToken(raw)}
+	}
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode 入力されたリカバリーコードから区切り文字を除去して小文字にする
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// storeRequiresTwoFactor 所属店舗で2段階認証が必須か判定
+func storeRequiresTwoFactor(db *gorm.DB, userID uint) bool {
+	var count int64
+	db.Model(&models.Store{}).
+		Joins("JOIN store_member ON store_member.store_id = store.id").
+		Where("store_member.user_id = ? AND store.require_two_factor = ?", userID, true).
+		Count(&count)
+	return count > 0
+}
+
+// generateChallengeToken 2段階認証用のチャレンジトークンを生成（アクセストークンとしては使えない）
+func generateChallengeToken(userID uint) (string, error) {
+	claims := jwt.MapClaims{
+		"userID": userID,
+		"typ":    challengeTokenType,
+		"exp":    time.Now().Add(challengeTokenTTL).Unix(),
+		"iat":    time.Now().Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(jwtSecret()))
+}
+
+// parseChallengeToken チャレンジトークンを検証してユーザーIDを取得
+func parseChallengeToken(tokenString string) (uint, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return []byte(jwtSecret()), nil
+	})
+	if err != nil || !token.Valid {
+		return 0, errInvalidChallengeToken
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return 0, errInvalidChallengeToken
+	}
+	if typ, _ := claims["typ"].(string); typ != challengeTokenType {
+		return 0, errInvalidChallengeToken
+	}
+	userID, ok := claims["userID"].(float64)
+	if !ok {
+		return 0, errInvalidChallengeToken
+	}
+	return uint(userID), nil
+}
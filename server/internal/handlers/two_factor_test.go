@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hostnote/server/internal/models"
+	"gorm.io/gorm"
+)
+
+// createTwoFactorStore 2段階認証が必須の店舗を作成
+func createTwoFactorStore(t *testing.T, db *gorm.DB) *models.Store {
+	t.Helper()
+	store := &models.Store{Name: "本店", RequireTwoFactor: true}
+	if err := db.Create(store).Error; err != nil {
+		t.Fatalf("create store: %v", err)
+	}
+	return store
+}
+
+// assertTwoFactorChallenge セッションを発行せずにチャレンジを返したことを確認
+func assertTwoFactorChallenge(t *testing.T, db *gorm.DB, name string, body []byte) {
+	t.Helper()
+	var resp struct {
+		TwoFactorChallengeResponse
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("%s: decode response: %v (%s)", name, err, body)
+	}
+	if !resp.TwoFactorRequired || !resp.SetupRequired || resp.ChallengeToken == "" || resp.Token != "" {
+		t.Errorf("%s: response = %s, want a setup challenge without tokens", name, body)
+	}
+	var count int64
+	db.Model(&models.Session{}).Count(&count)
+	if count != 0 {
+		t.Errorf("%s: sessions = %d, want 0", name, count)
+	}
+}
+
+// TestTwoFactorRequiredForGoogleLogin パスワードのない（Googleの）ユーザーにも店舗の2段階認証を適用することをテスト
+func TestTwoFactorRequiredForGoogleLogin(t *testing.T) {
+	db := newAuthTestDB(t)
+	r := newAuthTestRouter(db)
+	store := createTwoFactorStore(t, db)
+	user := &models.User{Username: "google", Role: models.RoleMember}
+	if err := createUserWithStore(db, user, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := joinStore(db, user.ID, store.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := createLoginCode(db, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := postJSON(r, "/auth/login-code", LoginCodeRequest{Code: code}, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("exchange: status = %d (%s)", w.Code, w.Body.String())
+	}
+	assertTwoFactorChallenge(t, db, "login code", w.Body.Bytes())
+
+	// 必須になる前に発行されたセッションも更新できない
+	session := createTestSession(t, db, user)
+	if w := postJSON(r, "/auth/refresh", RefreshRequest{RefreshToken: session.RefreshToken}, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("refresh: status = %d, want 401", w.Code)
+	}
+}
+
+// TestTwoFactorRequiredForInvitedRegister 2段階認証が必須の店舗への招待で登録した場合にセッションを発行しないことをテスト
+func TestTwoFactorRequiredForInvitedRegister(t *testing.T) {
+	db := newAuthTestDB(t)
+	r := newAuthTestRouter(db)
+	store := createTwoFactorStore(t, db)
+	invitation := models.StoreInvitation{StoreID: store.ID, Code: "INVITE2FA", Role: models.RoleMember,
+		ExpiresAt: time.Now().Add(time.Hour), CreatedBy: 1}
+	if err := db.Create(&invitation).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	w := postJSON(r, "/auth/register", RegisterRequest{Username: "invited", Email: "invited@example.com",
+		Password: "password", InviteCode: invitation.Code}, "")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("register: status = %d (%s)", w.Code, w.Body.String())
+	}
+	assertTwoFactorChallenge(t, db, "register", w.Body.Bytes())
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hostnote/server/internal/middleware"
+	"github.com/hostnote/server/internal/models"
+	"gorm.io/gorm"
+)
+
+type UserHandler struct {
+	db *gorm.DB
+}
+
+func NewUserHandler(db *gorm.DB) *UserHandler {
+	return &UserHandler{db: db}
+}
+
+// MemberInfo メンバー一覧用のユーザー情報
+type MemberInfo struct {
+	ID       uint    `json:"id"`
+	Username string  `json:"username"`
+	Email    *string `json:"email"`
+	Role     string  `json:"role"`
+	LeaderID *uint   `json:"leaderId"`
+}
+
+type UpdateRoleRequest struct {
+	Role     string `json:"role" binding:"required"`
+	LeaderID *uint  `json:"leaderId"`
+}
+
+// List 閲覧可能なメンバー一覧を取得（管理者は所属店舗のユーザー、リーダーは配下メンバー）
+func (h *UserHandler) List(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+
+	query := h.db.Model(&models.User{}).Order("id")
+	switch middleware.GetRole(c) {
+	case models.RoleLeader:
+		query = query.Where("leader_id = ?", userID)
+	case models.RoleAdmin:
+		storeID, ok := getStoreID(c, h.db)
+		if !ok {
+			return
+		}
+		query = query.Where("id IN (?)", h.db.Model(&models.StoreMember{}).Select("user_id").Where("store_id = ?", storeID))
+	}
+
+	var users []models.User
+	if err := query.Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	members := make([]MemberInfo, len(users))
+	for i, u := range users {
+		members[i] = MemberInfo{
+			ID:       u.ID,
+			Username: u.Username,
+			Email:    u.Email,
+			Role:     u.Role,
+			LeaderID: u.LeaderID,
+		}
+	}
+	c.JSON(http.StatusOK, members)
+}
+
+// UpdateRole ユーザーのロールと所属リーダーを更新
+func (h *UserHandler) UpdateRole(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return
+	}
+
+	id, err := parseID(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !models.IsValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無効なロールです"})
+		return
+	}
+
+	// 自分と同じか強いロールは付与できない
+	role := middleware.GetRole(c)
+	if models.RoleLevel(req.Role) >= models.RoleLevel(role) {
+		middleware.Forbidden(c)
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, id).Error; err != nil {
+		handleDBError(c, err, "ユーザーが見つかりません")
+		return
+	}
+	// 自分と同じか強いロールのユーザーは変更できない
+	if models.RoleLevel(user.Role) >= models.RoleLevel(role) {
+		middleware.Forbidden(c)
+		return
+	}
+	// スーパー管理者以外は所属店舗のユーザーのみ変更可能
+	if role != models.RoleSuperAdmin && !sameStore(h.db, userID, user.ID) {
+		middleware.Forbidden(c)
+		return
+	}
+
+	// リーダーは対象ユーザーと同じ店舗のリーダーから選ぶ
+	if req.LeaderID != nil {
+		var leader models.User
+		if err := h.db.First(&leader, *req.LeaderID).Error; err != nil || leader.Role != models.RoleLeader ||
+			!sameStore(h.db, user.ID, leader.ID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "リーダーが見つかりません"})
+			return
+		}
+	}
+
+	// ロールを変更した場合は、古いロールのアクセストークンを使えないようにセッションを取り消す
+	roleChanged := user.Role != req.Role
+	err = h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"role":      req.Role,
+			"leader_id": req.LeaderID,
+		}).Error; err != nil {
+			return err
+		}
+		if !roleChanged {
+			return nil
+		}
+		return revokeAllSessions(tx, user.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, MemberInfo{
+		ID:       user.ID,
+		Username: user.Username,
+		Email:    user.Email,
+		Role:     req.Role,
+		LeaderID: req.LeaderID,
+	})
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hostnote/server/internal/models"
+)
+
+// TestUpdateRole 自分と同じか強いロールの付与・変更を拒否し、ロールを変更したユーザーのセッションを取り消すことをテスト
+func TestUpdateRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := newTestDB(t, &models.User{}, &models.Session{}, &models.StoreMember{})
+	for _, u := range []models.User{
+		{ID: 1, Username: "admin", Role: models.RoleAdmin},
+		{ID: 2, Username: "peer", Role: models.RoleAdmin},
+		{ID: 3, Username: "member", Role: models.RoleMember},
+	} {
+		if err := db.Create(&u).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Create(&models.StoreMember{StoreID: 1, UserID: u.ID}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Create(&models.Session{UserID: 3, RefreshTokenHash: "member", ExpiresAt: time.Now().Add(time.Hour), LastUsedAt: time.Now()}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.PUT("/users/:id/role", withUser(1), func(c *gin.Context) { c.Set("role", models.RoleAdmin) }, NewUserHandler(db).UpdateRole)
+	put := func(path, body string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := put("/users/3/role", `{"role":"admin"}`); code != http.StatusForbidden {
+		t.Errorf("promote to admin: status = %d, want 403", code)
+	}
+	if code := put("/users/2/role", `{"role":"member"}`); code != http.StatusForbidden {
+		t.Errorf("demote peer admin: status = %d, want 403", code)
+	}
+	if code := put("/users/3/role", `{"role":"leader"}`); code != http.StatusOK {
+		t.Fatalf("promote to leader: status = %d, want 200", code)
+	}
+
+	var member models.User
+	db.First(&member, 3)
+	if member.Role != models.RoleLeader {
+		t.Errorf("role = %q, want leader", member.Role)
+	}
+	var active int64
+	db.Model(&models.Session{}).Where("user_id = ? AND revoked_at IS NULL", 3).Count(&active)
+	if active != 0 {
+		t.Errorf("active sessions = %d, want 0 after the role change", active)
+	}
+}
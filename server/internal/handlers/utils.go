@@ -5,6 +5,8 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hostnote/server/internal/middleware"
+	"github.com/hostnote/server/internal/models"
 	"gorm.io/gorm"
 )
 
@@ -18,6 +20,111 @@ func getUserID(c *gin.Context) (uint, bool) {
 	return uid, ok
 }
 
+// getReadUserID 閲覧対象のユーザーIDを取得
+// userIdクエリが指定された場合、リーダーは配下メンバー、管理者は全ユーザーのデータを閲覧できる
+// 権限がない場合はエラーレスポンスを返してfalseを返す
+func getReadUserID(c *gin.Context, db *gorm.DB) (uint, bool) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return 0, false
+	}
+
+	targetStr := c.Query("userId")
+	if targetStr == "" {
+		return userID, true
+	}
+	target, err := strconv.ParseUint(targetStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid userId"})
+		return 0, false
+	}
+	targetID := uint(target)
+	if targetID == userID {
+		return userID, true
+	}
+
+	if !canReadMember(db, middleware.GetRole(c), userID, targetID) {
+		middleware.Forbidden(c)
+		return 0, false
+	}
+	return targetID, true
+}
+
+// canReadMember 他ユーザーのデータを閲覧できるか判定
+func canReadMember(db *gorm.DB, role string, userID, targetID uint) bool {
+	if !middleware.HasPermission(role, middleware.PermMemberRead) {
+		return false
+	}
+
+	var target models.User
+	if err := db.Select("id, leader_id").First(&target, targetID).Error; err != nil {
+		return false
+	}
+
+	switch role {
+	case models.RoleSuperAdmin:
+		return true
+	case models.RoleAdmin:
+		return sameStore(db, userID, targetID)
+	case models.RoleLeader:
+		return target.LeaderID != nil && *target.LeaderID == userID
+	}
+	return false
+}
+
+// getStoreID ログインユーザーの所属店舗IDを取得
+// 所属店舗がない場合はエラーレスポンスを返してfalseを返す
+func getStoreID(c *gin.Context, db *gorm.DB) (uint, bool) {
+	if storeID, exists := c.Get("storeID"); exists {
+		if id, ok := storeID.(uint); ok {
+			return id, true
+		}
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
+		return 0, false
+	}
+
+	var member models.StoreMember
+	if err := db.Where("user_id = ?", userID).First(&member).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusForbidden, gin.H{"error": "店舗に所属していません"})
+			return 0, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return 0, false
+	}
+
+	c.Set("storeID", member.StoreID)
+	return member.StoreID, true
+}
+
+// sameStore 2人のユーザーが同じ店舗に所属しているか判定
+func sameStore(db *gorm.DB, userID, otherID uint) bool {
+	var count int64
+	db.Model(&models.StoreMember{}).
+		Where("user_id = ? AND store_id IN (?)", otherID,
+			db.Model(&models.StoreMember{}).Select("store_id").Where("user_id = ?", userID)).
+		Count(&count)
+	return count > 0
+}
+
+// joinStore ユーザーを店舗に所属させる（既に所属している場合は所属先を変更）
+func joinStore(tx *gorm.DB, userID, storeID uint) error {
+	var member models.StoreMember
+	err := tx.Where("user_id = ?", userID).First(&member).Error
+	if err == gorm.ErrRecordNotFound {
+		return tx.Create(&models.StoreMember{StoreID: storeID, UserID: userID}).Error
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Model(&member).Update("store_id", storeID).Error
+}
+
 // requireAuth 認証が必要な場合にエラーレスポンスを返す
 func requireAuth(c *gin.Context) bool {
 	_, exists := getUserID(c)
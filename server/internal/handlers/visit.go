@@ -18,14 +18,13 @@ func NewVisitHandler(db *gorm.DB) *VisitHandler {
 
 // List 来店記録一覧を取得（最適化版）
 func (h *VisitHandler) List(c *gin.Context) {
-	userID, ok := getUserID(c)
+	userID, ok := getReadUserID(c, h.db)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
 		return
 	}
 
 	var visits []models.VisitRecord
-	if err := h.db.
+	if err := h.db.WithContext(c).
 		Where("user_id = ?", userID).
 		Preload("Hime", func(db *gorm.DB) *gorm.DB {
 			return db.Select("id, name, photo_url").Where("user_id = ?", userID)
@@ -40,9 +39,8 @@ func (h *VisitHandler) List(c *gin.Context) {
 
 // Get 来店記録を取得
 func (h *VisitHandler) Get(c *gin.Context) {
-	userID, ok := getUserID(c)
+	userID, ok := getReadUserID(c, h.db)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証が必要です"})
 		return
 	}
 
@@ -53,7 +51,7 @@ func (h *VisitHandler) Get(c *gin.Context) {
 	}
 
 	var visit models.VisitRecord
-	if err := h.db.
+	if err := h.db.WithContext(c).
 		Where("user_id = ? AND id = ?", userID, id).
 		Preload("Hime", func(db *gorm.DB) *gorm.DB {
 			return db.Select("id, name, photo_url").Where("user_id = ?", userID)
@@ -85,7 +83,7 @@ func (h *VisitHandler) Create(c *gin.Context) {
 	// ユーザーIDを設定
 	visit.UserID = userID
 
-	if err := h.db.Create(&visit).Error; err != nil {
+	if err := h.db.WithContext(c).Create(&visit).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -107,7 +105,7 @@ func (h *VisitHandler) Update(c *gin.Context) {
 	}
 
 	var visit models.VisitRecord
-	if err := h.db.Where("user_id = ? AND id = ?", userID, id).First(&visit).Error; err != nil {
+	if err := h.db.WithContext(c).Where("user_id = ? AND id = ?", userID, id).First(&visit).Error; err != nil {
 		if handleDBError(c, err, "Visit record not found") {
 			return
 		}
@@ -136,13 +134,13 @@ func (h *VisitHandler) Update(c *gin.Context) {
 		}
 	}
 
-	if err := h.db.Model(&visit).Updates(convertedData).Error; err != nil {
+	if err := h.db.WithContext(c).Model(&visit).Updates(convertedData).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	// 更新後のデータを取得
-	if err := h.db.Where("user_id = ? AND id = ?", userID, id).First(&visit).Error; err != nil {
+	if err := h.db.WithContext(c).Where("user_id = ? AND id = ?", userID, id).First(&visit).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新後のデータの取得に失敗しました"})
 		return
 	}
@@ -163,7 +161,7 @@ func (h *VisitHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.Where("user_id = ? AND id = ?", userID, id).Delete(&models.VisitRecord{}).Error; err != nil {
+	if err := h.db.WithContext(c).Where("user_id = ? AND id = ?", userID, id).Delete(&models.VisitRecord{}).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -191,7 +189,7 @@ func (h *VisitHandler) BulkCreate(c *gin.Context) {
 	}
 
 	// トランザクション内で一括作成（一貫性のため）
-	if err := h.db.Transaction(func(tx *gorm.DB) error {
+	if err := h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
 		return tx.Create(&visits).Error
 	}); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"os"
 	"strings"
@@ -9,8 +10,12 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// SessionChecker アクセストークンのセッションが有効か確認する（取り消し・削除・期限切れの場合はfalse）
+type SessionChecker func(ctx context.Context, userID, sessionID uint) (bool, error)
+
 // AuthMiddleware JWT認証ミドルウェア
-func AuthMiddleware() gin.HandlerFunc {
+// セッションを取り消したアクセストークンは有効期限内でも受け付けない
+func AuthMiddleware(checkSession SessionChecker) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Authorizationヘッダーからトークンを取得
 		authHeader := c.GetHeader("Authorization")
@@ -46,12 +51,46 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		// クレームからユーザー情報を取得
 		if claims, ok := token.Claims.(jwt.MapClaims); ok {
+			// 2段階認証のチャレンジトークンなど、アクセストークン以外は受け付けない
+			if typ, ok := claims["typ"].(string); ok && typ != "" {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "無効な認証トークンです"})
+				c.Abort()
+				return
+			}
 			if userID, ok := claims["userID"].(float64); ok {
 				c.Set("userID", uint(userID))
 			}
 			if username, ok := claims["username"].(string); ok {
 				c.Set("username", username)
 			}
+			if role, ok := claims["role"].(string); ok {
+				c.Set("role", role)
+			}
+			if sessionID, ok := claims["sid"].(float64); ok {
+				c.Set("sessionID", uint(sessionID))
+			}
+		}
+
+		// セッションに紐付かないトークンは取り消せないため受け付けない
+		userID, _ := c.Get("userID")
+		sessionID, _ := c.Get("sessionID")
+		uid, _ := userID.(uint)
+		sid, _ := sessionID.(uint)
+		if uid == 0 || sid == 0 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "無効な認証トークンです"})
+			c.Abort()
+			return
+		}
+		active, err := checkSession(c, uid, sid)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "認証の確認に失敗しました"})
+			c.Abort()
+			return
+		}
+		if !active {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "セッションが無効です。再度ログインしてください"})
+			c.Abort()
+			return
 		}
 
 		c.Next()
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hostnote/server/internal/models"
+)
+
+// Permission 操作権限
+type Permission string
+
+const (
+	PermMenuWrite    Permission = "menu:write"    // メニューの作成・更新・削除
+	PermSettingWrite Permission = "setting:write" // 設定の作成・更新・削除
+	PermMemberRead   Permission = "member:read"   // 他ユーザー（配下メンバー）のデータ閲覧
+	PermUserManage   Permission = "user:manage"   // ユーザーのロール・リーダー設定
+	PermStoreManage  Permission = "store:manage"  // 所属店舗の情報更新
+	PermStoreCreate  Permission = "store:create"  // 店舗の作成・一覧
+	PermAuditRead    Permission = "audit:read"    // 監査ログの閲覧
+	PermAIUsageRead  Permission = "ai_usage:read" // 店舗メンバーのAI利用量の閲覧
+	PermPricingWrite Permission = "pricing:write" // 料金ルールの変更
+	PermCastManage   Permission = "cast:manage"   // 他のメンバーが登録したキャストの更新・削除
+)
+
+// rolePolicies 権限ごとに許可されるロール
+var rolePolicies = map[Permission][]string{
+	PermMenuWrite:    {models.RoleSuperAdmin, models.RoleAdmin},
+	PermSettingWrite: {models.RoleSuperAdmin, models.RoleAdmin},
+	PermMemberRead:   {models.RoleSuperAdmin, models.RoleAdmin, models.RoleLeader},
+	PermUserManage:   {models.RoleSuperAdmin, models.RoleAdmin},
+	PermStoreManage:  {models.RoleSuperAdmin, models.RoleAdmin},
+	PermStoreCreate:  {models.RoleSuperAdmin},
+	PermAuditRead:    {models.RoleSuperAdmin, models.RoleAdmin},
+	PermAIUsageRead:  {models.RoleSuperAdmin, models.RoleAdmin},
+	PermPricingWrite: {models.RoleSuperAdmin, models.RoleAdmin},
+	PermCastManage:   {models.RoleSuperAdmin, models.RoleAdmin},
+}
+
+// HasPermission ロールが権限を持つか判定
+func HasPermission(role string, perm Permission) bool {
+	for _, r := range rolePolicies[perm] {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// GetRole コンテキストからロールを取得（未設定の場合はmember扱い）
+func GetRole(c *gin.Context) string {
+	if role, ok := c.Get("role"); ok {
+		if r, ok := role.(string); ok && r != "" {
+			return r
+		}
+	}
+	return models.RoleMember
+}
+
+// RequirePermission 指定した権限を持たないユーザーを403で拒否するミドルウェア
+func RequirePermission(perm Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasPermission(GetRole(c), perm) {
+			Forbidden(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// Forbidden 権限エラー（403）を返す
+func Forbidden(c *gin.Context) {
+	c.JSON(http.StatusForbidden, gin.H{"error": "この操作を行う権限がありません"})
+	c.Abort()
+}
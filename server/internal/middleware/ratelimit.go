@@ -0,0 +1,210 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hostnote/server/internal/config"
+)
+
+// sweepThreshold この件数を超えたら期限切れのエントリを掃除する
+const sweepThreshold = 10000
+
+// RateLimiter 固定ウィンドウ方式のリクエスト数カウンター（キーごと）
+// カウンターはプロセス内のメモリに保持するため、複数インスタンス構成では各インスタンスごとの上限となる
+type RateLimiter struct {
+	mu      sync.Mutex
+	limit   int
+	window  time.Duration
+	entries map[string]*rateWindow
+	now     func() time.Time
+}
+
+type rateWindow struct {
+	count int
+	reset time.Time
+}
+
+// RateLimitResult レート制限の判定結果
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// NewRateLimiter レート制限を作成（Limitが0の場合は無制限）
+func NewRateLimiter(cfg config.RateLimit) *RateLimiter {
+	return &RateLimiter{
+		limit:   cfg.Limit,
+		window:  cfg.Window,
+		entries: make(map[string]*rateWindow),
+		now:     time.Now,
+	}
+}
+
+// Allow キーのリクエストを1回カウントし、上限内か判定
+func (l *RateLimiter) Allow(key string) RateLimitResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if len(l.entries) > sweepThreshold {
+		for k, w := range l.entries {
+			if !now.Before(w.reset) {
+				delete(l.entries, k)
+			}
+		}
+	}
+
+	w, ok := l.entries[key]
+	if !ok || !now.Before(w.reset) {
+		w = &rateWindow{reset: now.Add(l.window)}
+		l.entries[key] = w
+	}
+
+	if w.count >= l.limit {
+		return RateLimitResult{Allowed: false, Limit: l.limit, Remaining: 0, Reset: w.reset}
+	}
+	w.count++
+	return RateLimitResult{Allowed: true, Limit: l.limit, Remaining: l.limit - w.count, Reset: w.reset}
+}
+
+// RateLimit リクエスト数を制限するミドルウェア
+// X-RateLimit-* ヘッダーを付与し、上限を超えた場合はRetry-After付きの429を返す
+func RateLimit(limiter *RateLimiter, keyFunc func(*gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limiter.limit <= 0 {
+			c.Next()
+			return
+		}
+
+		result := limiter.Allow(keyFunc(c))
+		c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(result.Reset.Unix(), 10))
+
+		if !result.Allowed {
+			TooManyRequests(c, result.Reset.Sub(limiter.now()))
+			return
+		}
+		c.Next()
+	}
+}
+
+// TooManyRequests リクエスト過多エラー（429）をRetry-After付きで返す
+func TooManyRequests(c *gin.Context, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":      "リクエストが多すぎます。しばらくしてから再度お試しください",
+		"retryAfter": seconds,
+	})
+	c.Abort()
+}
+
+// ByIP 接続元IPをキーにする
+func ByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// ByUser 認証済みユーザーIDをキーにする（未認証の場合は接続元IP）
+func ByUser(c *gin.Context) string {
+	if userID, ok := c.Get("userID"); ok {
+		return fmt.Sprintf("user:%v", userID)
+	}
+	return ByIP(c)
+}
+
+// LoginThrottle ログイン失敗回数に応じた指数的なロックアウト
+// 閾値に達した後は失敗するたびにロック時間が倍になる（上限あり）
+type LoginThrottle struct {
+	mu        sync.Mutex
+	threshold int
+	base      time.Duration
+	max       time.Duration
+	entries   map[string]*loginFailures
+	now       func() time.Time
+}
+
+type loginFailures struct {
+	count       int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// NewLoginThrottle ロックアウトを作成
+// threshold回連続で失敗するとbaseの間ロックし、以降は失敗ごとに倍（最大max）
+func NewLoginThrottle(threshold int, base, max time.Duration) *LoginThrottle {
+	return &LoginThrottle{
+		threshold: threshold,
+		base:      base,
+		max:       max,
+		entries:   make(map[string]*loginFailures),
+		now:       time.Now,
+	}
+}
+
+// Locked キーがロック中なら残り時間を返す
+func (t *LoginThrottle) Locked(key string) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	f, ok := t.entries[key]
+	if !ok {
+		return 0, false
+	}
+	if remaining := f.lockedUntil.Sub(t.now()); remaining > 0 {
+		return remaining, true
+	}
+	return 0, false
+}
+
+// Fail 失敗を記録し、ロックされた場合はロック時間を返す
+func (t *LoginThrottle) Fail(key string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	if len(t.entries) > sweepThreshold {
+		for k, f := range t.entries {
+			if now.Sub(f.lastFailure) > t.max && !now.Before(f.lockedUntil) {
+				delete(t.entries, k)
+			}
+		}
+	}
+
+	f, ok := t.entries[key]
+	// 最後の失敗から十分時間が経っていればカウントをリセット
+	if !ok || (now.Sub(f.lastFailure) > t.max && !now.Before(f.lockedUntil)) {
+		f = &loginFailures{}
+		t.entries[key] = f
+	}
+	f.count++
+	f.lastFailure = now
+
+	if f.count < t.threshold {
+		return 0
+	}
+	lock := t.base << uint(f.count-t.threshold)
+	if lock > t.max || lock <= 0 {
+		lock = t.max
+	}
+	f.lockedUntil = now.Add(lock)
+	return lock
+}
+
+// Reset 成功時に失敗回数をリセット
+func (t *LoginThrottle) Reset(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.entries, key)
+}
@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hostnote/server/internal/config"
+)
+
+// fakeClock テスト用の時計
+type fakeClock struct {
+	t time.Time
+}
+
+func (f *fakeClock) now() time.Time { return f.t }
+
+// TestRateLimiter ウィンドウ内の上限とウィンドウ経過後のリセットをテスト
+func TestRateLimiter(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	l := NewRateLimiter(config.RateLimit{Limit: 2, Window: time.Minute})
+	l.now = clock.now
+
+	tests := []struct {
+		advance       time.Duration
+		wantAllowed   bool
+		wantRemaining int
+	}{
+		{0, true, 1},
+		{10 * time.Second, true, 0},
+		{10 * time.Second, false, 0},
+		{time.Minute, true, 1}, // ウィンドウ経過後はリセット
+	}
+
+	for i, tt := range tests {
+		clock.t = clock.t.Add(tt.advance)
+		got := l.Allow("ip:127.0.0.1")
+		if got.Allowed != tt.wantAllowed || got.Remaining != tt.wantRemaining {
+			t.Errorf("#%d: Allow = {allowed %v, remaining %d}, want {%v, %d}", i, got.Allowed, got.Remaining, tt.wantAllowed, tt.wantRemaining)
+		}
+	}
+
+	// 別のキーは独立してカウントされる
+	if got := l.Allow("ip:192.0.2.1"); !got.Allowed {
+		t.Errorf("Allow for another key = %v, want allowed", got)
+	}
+}
+
+// TestRateLimitHeaders X-RateLimit-* と Retry-After ヘッダーをテスト
+func TestRateLimitHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/", RateLimit(NewRateLimiter(config.RateLimit{Limit: 1, Window: time.Minute}), ByIP), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("first request status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if got := w.Header().Get("X-RateLimit-Limit"); got != "1" {
+		t.Errorf("X-RateLimit-Limit = %q, want 1", got)
+	}
+	if got := w.Header().Get("X-RateLimit-Remaining"); got != "0" {
+		t.Errorf("X-RateLimit-Remaining = %q, want 0", got)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if got := w.Header().Get("Retry-After"); got == "" || got == "0" {
+		t.Errorf("Retry-After = %q, want positive seconds", got)
+	}
+}
+
+// TestLoginThrottle 閾値到達後にロック時間が倍々に伸びることをテスト
+func TestLoginThrottle(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	th := NewLoginThrottle(3, 30*time.Second, 5*time.Minute)
+	th.now = clock.now
+
+	tests := []struct {
+		wantLock time.Duration
+	}{
+		{0},
+		{0},
+		{30 * time.Second},
+		{time.Minute},
+		{2 * time.Minute},
+		{4 * time.Minute},
+		{5 * time.Minute}, // 上限
+	}
+
+	for i, tt := range tests {
+		if got := th.Fail("login:demo"); got != tt.wantLock {
+			t.Errorf("#%d: Fail = %s, want %s", i+1, got, tt.wantLock)
+		}
+	}
+
+	if _, locked := th.Locked("login:demo"); !locked {
+		t.Errorf("Locked = false, want true")
+	}
+	clock.t = clock.t.Add(5 * time.Minute)
+	if _, locked := th.Locked("login:demo"); locked {
+		t.Errorf("Locked after lock expired = true, want false")
+	}
+
+	th.Reset("login:demo")
+	if got := th.Fail("login:demo"); got != 0 {
+		t.Errorf("Fail after Reset = %s, want 0", got)
+	}
+}
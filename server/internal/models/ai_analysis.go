@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+)
+
+// AI分析の種類
+const (
+	AIAnalysisKindCustomer     = "customer"     // 姫・期間の来店・売上データの分析（/ai/analyze）
+	AIAnalysisKindConversation = "conversation" // 会話ログの分析（/ai/conversation）
+	AIAnalysisKindDraft        = "draft"        // 姫に送るメッセージの下書き（/ai/drafts）
+)
+
+// AIMessage AI分析の会話の1メッセージ
+type AIMessage struct {
+	Role      string    `json:"role"` // system, user, assistant
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// AIAnalysis AI分析の結果と、続けて行った質問・回答の履歴
+// 入力・結果・履歴には姫の個人情報や会話ログが含まれるため暗号化して保存する
+type AIAnalysis struct {
+	ID               uint                `gorm:"primaryKey" json:"id"`
+	UserID           uint                `gorm:"not null;index" json:"userId"`
+	HimeID           *uint               `gorm:"index" json:"himeId"`
+	Kind             string              `gorm:"type:varchar(20);not null" json:"kind"` // customer, conversation, draft
+	Input            EncryptedMap        `gorm:"type:mediumtext" json:"input"`          // リクエストの内容
+	Result           EncryptedString     `gorm:"type:mediumtext" json:"result"`         // 最初の分析結果
+	Messages         EncryptedAIMessages `gorm:"type:mediumtext" json:"-"`              // AIに送ったプロンプトを含む会話履歴
+	Model            string              `gorm:"type:varchar(100)" json:"model"`        // 最後に応答したモデル
+	PromptTokens     int                 `json:"promptTokens"`                          // 追加の質問を含む合計
+	CompletionTokens int                 `json:"completionTokens"`
+	TotalTokens      int                 `json:"totalTokens"`
+	CreatedAt        time.Time           `json:"createdAt"`
+	UpdatedAt        time.Time           `json:"updatedAt"`
+	DeletedAt        *time.Time          `gorm:"index" json:"-"`
+
+	// リレーション
+	User *User `gorm:"foreignKey:UserID" json:"-"`
+	Hime *Hime `gorm:"foreignKey:HimeID" json:"-"`
+}
+
+// TableName テーブル名を指定
+func (AIAnalysis) TableName() string {
+	return "ai_analysis"
+}
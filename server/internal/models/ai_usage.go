@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// AIUsage ユーザーごと・月ごとのAI利用量（AIの応答ごとに加算する）
+type AIUsage struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	UserID           uint      `gorm:"not null;uniqueIndex:idx_ai_usage_user_month" json:"userId"`
+	Month            string    `gorm:"type:char(7);not null;uniqueIndex:idx_ai_usage_user_month" json:"month"` // 2006-01
+	Requests         int       `gorm:"not null;default:0" json:"requests"`                                     // AIを呼び出した回数
+	PromptTokens     int       `gorm:"not null;default:0" json:"promptTokens"`
+	CompletionTokens int       `gorm:"not null;default:0" json:"completionTokens"`
+	TotalTokens      int       `gorm:"not null;default:0" json:"totalTokens"`
+	CreatedAt        time.Time `json:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+
+	// リレーション
+	User *User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// TableName テーブル名を指定
+func (AIUsage) TableName() string {
+	return "ai_usage"
+}
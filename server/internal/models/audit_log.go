@@ -0,0 +1,61 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+)
+
+// 監査ログの操作種別
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+)
+
+// AuditChange 1カラム分の変更（作成時はOld、削除時はNewがnull）
+type AuditChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// AuditChanges カラム名ごとの変更内容
+type AuditChanges map[string]AuditChange
+
+// Value JSONに変換
+func (a AuditChanges) Value() (driver.Value, error) {
+	return json.Marshal(a)
+}
+
+// Scan JSONから復元
+func (a *AuditChanges) Scan(value interface{}) error {
+	if value == nil {
+		*a = AuditChanges{}
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, a)
+}
+
+// AuditLog 顧客データの作成・更新・削除の履歴
+type AuditLog struct {
+	ID        uint         `gorm:"primaryKey" json:"id"`
+	StoreID   *uint        `gorm:"index" json:"storeId"`                                              // 操作したユーザーの所属店舗
+	ActorID   *uint        `gorm:"index" json:"actorId"`                                              // 操作したユーザー（アカウント削除後はnull）
+	Entity    string       `gorm:"type:varchar(50);not null;index:idx_audit_entity" json:"entity"`    // テーブル名
+	EntityID  string       `gorm:"type:varchar(100);not null;index:idx_audit_entity" json:"entityId"` // 主キー（複合キーは":"区切り）
+	Action    string       `gorm:"type:varchar(10);not null" json:"action"`                           // create, update, delete
+	Changes   AuditChanges `gorm:"type:json" json:"changes"`
+	CreatedAt time.Time    `gorm:"index" json:"createdAt"`
+
+	// リレーション
+	Actor *User `gorm:"foreignKey:ActorID;constraint:OnDelete:SET NULL" json:"-"`
+}
+
+// TableName テーブル名を指定
+func (AuditLog) TableName() string {
+	return "audit_log"
+}
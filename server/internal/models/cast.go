@@ -7,7 +7,8 @@ import (
 // Cast キャスト情報
 type Cast struct {
 	ID                uint       `gorm:"primaryKey" json:"id"`
-	UserID            *uint      `gorm:"index" json:"userId"` // ログインユーザー自身のキャスト情報の場合に設定
+	UserID            *uint      `gorm:"index" json:"userId"`  // ログインユーザー自身のキャスト情報の場合に設定
+	StoreID           *uint      `gorm:"index" json:"storeId"` // 所属店舗（店舗内で共有）
 	Name              string     `gorm:"not null" json:"name"`
 	PhotoURL          *string    `json:"photoUrl"`
 	Photos            Photos     `gorm:"type:json" json:"photos"`
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+)
+
+// 発行する書類の種類
+const (
+	DocumentTypeReceipt = "receipt" // 領収書
+	DocumentTypeInvoice = "invoice" // 請求書（売掛の請求、適格請求書）
+)
+
+// IssuedDocument 発行した領収書・請求書
+// 書類番号の採番と、同じ卓に同じ種類の書類を発行した場合の再発行の表示に使う
+type IssuedDocument struct {
+	ID        uint            `gorm:"primaryKey" json:"id"`
+	UserID    uint            `gorm:"not null;index" json:"userId"`
+	TableID   uint            `gorm:"not null;index" json:"tableId"`
+	Type      string          `gorm:"type:varchar(10);not null" json:"type"`
+	Number    string          `gorm:"type:varchar(20);not null" json:"number"` // R-000001（領収書）、I-000001（請求書）
+	Addressee EncryptedString `gorm:"type:text" json:"addressee"`              // 宛名（暗号化して保存）
+	Amount    float64         `gorm:"not null" json:"amount"`                  // 領収金額・請求金額
+	Reissue   bool            `gorm:"not null;default:false" json:"reissue"`
+	IssuedAt  time.Time       `gorm:"not null" json:"issuedAt"`
+}
+
+// TableName テーブル名を指定
+func (IssuedDocument) TableName() string {
+	return "issued_document"
+}
@@ -0,0 +1,144 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hostnote/server/internal/fieldcrypt"
+)
+
+// EncryptedString 暗号化して保存する文字列
+type EncryptedString string
+
+// Value 暗号化
+func (s EncryptedString) Value() (driver.Value, error) {
+	return fieldcrypt.Encrypt([]byte(s))
+}
+
+// Scan 復号（暗号化前の平文はそのまま読み込む）
+func (s *EncryptedString) Scan(value interface{}) error {
+	plaintext, err := decryptColumn(value)
+	if err != nil || plaintext == nil {
+		return err
+	}
+	*s = EncryptedString(plaintext)
+	return nil
+}
+
+// StringPtr *stringに変換
+func (s *EncryptedString) StringPtr() *string {
+	if s == nil {
+		return nil
+	}
+	v := string(*s)
+	return &v
+}
+
+// NewEncryptedString *stringから変換
+func NewEncryptedString(s *string) *EncryptedString {
+	if s == nil {
+		return nil
+	}
+	v := EncryptedString(*s)
+	return &v
+}
+
+// EncryptedSnsInfo 暗号化して保存するSNS情報
+type EncryptedSnsInfo SnsInfo
+
+// Value JSONに変換して暗号化
+func (s EncryptedSnsInfo) Value() (driver.Value, error) {
+	return encryptJSON(SnsInfo(s))
+}
+
+// Scan 復号してJSONから復元
+func (s *EncryptedSnsInfo) Scan(value interface{}) error {
+	plaintext, err := decryptColumn(value)
+	if err != nil || plaintext == nil {
+		return err
+	}
+	return json.Unmarshal(plaintext, (*SnsInfo)(s))
+}
+
+// EncryptedMemos 暗号化して保存するメモの配列
+type EncryptedMemos Memos
+
+// Value JSONに変換して暗号化
+func (m EncryptedMemos) Value() (driver.Value, error) {
+	return encryptJSON(Memos(m))
+}
+
+// Scan 復号してJSONから復元
+func (m *EncryptedMemos) Scan(value interface{}) error {
+	plaintext, err := decryptColumn(value)
+	if err != nil {
+		return err
+	}
+	if plaintext == nil {
+		*m = EncryptedMemos{}
+		return nil
+	}
+	return json.Unmarshal(plaintext, (*Memos)(m))
+}
+
+// encryptJSON JSONに変換して暗号化
+func encryptJSON(v interface{}) (driver.Value, error) {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return fieldcrypt.Encrypt(bytes)
+}
+
+// decryptColumn DBから読み込んだ値を復号（NULLの場合はnil）
+func decryptColumn(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return fieldcrypt.Decrypt(string(v))
+	case string:
+		return fieldcrypt.Decrypt(v)
+	default:
+		return nil, fmt.Errorf("unsupported type for encrypted column: %T", value)
+	}
+}
+
+// EncryptedMap 暗号化して保存するJSONオブジェクト
+type EncryptedMap map[string]interface{}
+
+// Value JSONに変換して暗号化
+func (m EncryptedMap) Value() (driver.Value, error) {
+	return encryptJSON(map[string]interface{}(m))
+}
+
+// Scan 復号してJSONから復元
+func (m *EncryptedMap) Scan(value interface{}) error {
+	plaintext, err := decryptColumn(value)
+	if err != nil || plaintext == nil {
+		return err
+	}
+	return json.Unmarshal(plaintext, (*map[string]interface{})(m))
+}
+
+// EncryptedAIMessages 暗号化して保存するAI分析の会話履歴
+type EncryptedAIMessages []AIMessage
+
+// Value JSONに変換して暗号化
+func (m EncryptedAIMessages) Value() (driver.Value, error) {
+	return encryptJSON([]AIMessage(m))
+}
+
+// Scan 復号してJSONから復元
+func (m *EncryptedAIMessages) Scan(value interface{}) error {
+	plaintext, err := decryptColumn(value)
+	if err != nil {
+		return err
+	}
+	if plaintext == nil {
+		*m = EncryptedAIMessages{}
+		return nil
+	}
+	return json.Unmarshal(plaintext, (*[]AIMessage)(m))
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+)
+
+// データエクスポートの状態
+const (
+	ExportStatusPending   = "pending"
+	ExportStatusRunning   = "running"
+	ExportStatusCompleted = "completed"
+	ExportStatusFailed    = "failed"
+)
+
+// ExportJob 個人データのエクスポート（ZIP作成）ジョブ
+type ExportJob struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"not null;index" json:"userId"`
+	Status      string     `gorm:"type:varchar(20);not null;default:pending" json:"status"` // pending, running, completed, failed
+	FileName    string     `gorm:"type:varchar(255)" json:"fileName"`                       // 作成したZIPのファイル名（エクスポート用ディレクトリ内）
+	FileSize    int64      `json:"fileSize"`
+	Error       string     `gorm:"type:varchar(500)" json:"error,omitempty"`
+	StartedAt   *time.Time `json:"startedAt"`
+	CompletedAt *time.Time `json:"completedAt"`
+	ExpiresAt   *time.Time `json:"expiresAt"` // ダウンロード期限（期限後にファイルを削除）
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+
+	// リレーション
+	User *User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// TableName テーブル名を指定
+func (ExportJob) TableName() string {
+	return "export_job"
+}
+
+// IsActive 作成待ち・作成中か
+func (j *ExportJob) IsActive() bool {
+	return j.Status == ExportStatusPending || j.Status == ExportStatusRunning
+}
+
+// IsDownloadable ダウンロード可能か
+func (j *ExportJob) IsDownloadable(now time.Time) bool {
+	return j.Status == ExportStatusCompleted && j.FileName != "" && (j.ExpiresAt == nil || now.Before(*j.ExpiresAt))
+}
@@ -3,7 +3,11 @@ package models
 import (
 	"database/sql/driver"
 	"encoding/json"
+	"fmt"
 	"time"
+
+	"github.com/hostnote/server/internal/fieldcrypt"
+	"gorm.io/gorm"
 )
 
 // SnsAccount SNSアカウント情報
@@ -87,28 +91,29 @@ func (p *Photos) Scan(value interface{}) error {
 
 // Hime 姫情報
 type Hime struct {
-	ID              uint       `gorm:"primaryKey" json:"id"`
-	UserID          uint       `gorm:"not null;index" json:"userId"`
-	Name            string     `gorm:"not null" json:"name"`
-	PhotoURL        *string    `json:"photoUrl"`
-	Photos          Photos     `gorm:"type:json" json:"photos"`
-	SnsInfo         *SnsInfo   `gorm:"column:sn_s_info;type:json" json:"snsInfo"`
-	Birthday        *string    `json:"birthday"`
-	Age             *int       `json:"age"` // 年齢
-	IsFirstVisit    bool       `gorm:"default:false" json:"isFirstVisit"`
-	TantoCastID     *uint      `gorm:"index" json:"tantoCastId"`
-	DrinkPreference *string    `json:"drinkPreference"` // お酒の濃さ: 超薄め、薄め、普通、濃いめ、超濃いめ
-	FavoriteDrinkID *uint      `json:"favoriteDrinkId"` // 好きなお酒（商品メニューID）
-	Ice             *string    `json:"ice"`             // 氷: 1個、2~3個、満タン
-	Carbonation     *string    `json:"carbonation"`     // 炭酸: OK、NG
-	MixerPreference *string    `json:"mixerPreference"` // 割物の好み（テキスト）
-	FavoriteMixerID *uint      `json:"favoriteMixerId"` // 好きな割物（商品メニューID）
-	Smokes          *bool      `json:"smokes"`          // タバコを吸うか
-	TobaccoType     *string    `json:"tobaccoType"`     // タバコの種類: 紙タバコ、アイコス、両方
-	Memos           Memos      `gorm:"type:json" json:"memos"`
-	CreatedAt       time.Time  `json:"createdAt"`
-	UpdatedAt       time.Time  `json:"updatedAt"`
-	DeletedAt       *time.Time `gorm:"index" json:"-"`
+	ID              uint              `gorm:"primaryKey" json:"id"`
+	UserID          uint              `gorm:"not null;index" json:"userId"`
+	Name            EncryptedString   `gorm:"type:text;not null" json:"name"` // 暗号化して保存
+	NameIndex       string            `gorm:"type:char(64);index" json:"-"`   // 名前検索用のブラインドインデックス
+	PhotoURL        *string           `json:"photoUrl"`
+	Photos          Photos            `gorm:"type:json" json:"photos"`
+	SnsInfo         *EncryptedSnsInfo `gorm:"column:sn_s_info;type:mediumtext" json:"snsInfo"` // 暗号化して保存
+	Birthday        *EncryptedString  `gorm:"type:text" json:"birthday"`                       // 暗号化して保存
+	Age             *int              `json:"age"`                                             // 年齢
+	IsFirstVisit    bool              `gorm:"default:false" json:"isFirstVisit"`
+	TantoCastID     *uint             `gorm:"index" json:"tantoCastId"`
+	DrinkPreference *string           `json:"drinkPreference"`              // お酒の濃さ: 超薄め、薄め、普通、濃いめ、超濃いめ
+	FavoriteDrinkID *uint             `json:"favoriteDrinkId"`              // 好きなお酒（商品メニューID）
+	Ice             *string           `json:"ice"`                          // 氷: 1個、2~3個、満タン
+	Carbonation     *string           `json:"carbonation"`                  // 炭酸: OK、NG
+	MixerPreference *string           `json:"mixerPreference"`              // 割物の好み（テキスト）
+	FavoriteMixerID *uint             `json:"favoriteMixerId"`              // 好きな割物（商品メニューID）
+	Smokes          *bool             `json:"smokes"`                       // タバコを吸うか
+	TobaccoType     *string           `json:"tobaccoType"`                  // タバコの種類: 紙タバコ、アイコス、両方
+	Memos           EncryptedMemos    `gorm:"type:mediumtext" json:"memos"` // 暗号化して保存
+	CreatedAt       time.Time         `json:"createdAt"`
+	UpdatedAt       time.Time         `json:"updatedAt"`
+	DeletedAt       *time.Time        `gorm:"index" json:"-"`
 
 	// リレーション
 	User      *User `gorm:"foreignKey:UserID" json:"-"`
@@ -119,3 +124,21 @@ type Hime struct {
 func (Hime) TableName() string {
 	return "hime"
 }
+
+// BeforeSave 名前のブラインドインデックスを更新
+// Updates(map) の場合は更新対象に名前が含まれるときのみ設定する
+func (h *Hime) BeforeSave(tx *gorm.DB) error {
+	if dest, ok := tx.Statement.Dest.(map[string]interface{}); ok {
+		if name, ok := dest["Name"]; ok {
+			tx.Statement.SetColumn("NameIndex", HimeNameIndex(fmt.Sprint(name)))
+		}
+		return nil
+	}
+	h.NameIndex = HimeNameIndex(string(h.Name))
+	return nil
+}
+
+// HimeNameIndex 姫の名前から検索用のブラインドインデックスを計算
+func HimeNameIndex(name string) string {
+	return fieldcrypt.BlindIndex(name)
+}
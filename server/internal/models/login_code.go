@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+)
+
+// LoginCode 外部認証（サーバーサイドフロー）のリダイレクトで渡す1回限りのログインコード
+// トークンをURLに載せないよう、フロントエンドはこのコードをPOSTでセッションと交換する（SHA-256ハッシュのみ保存）
+type LoginCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"userId"`
+	CodeHash  string     `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expiresAt"`
+	UsedAt    *time.Time `json:"usedAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+
+	// リレーション
+	User *User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// TableName テーブル名を指定
+func (LoginCode) TableName() string {
+	return "login_code"
+}
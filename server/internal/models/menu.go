@@ -4,9 +4,10 @@ import (
 	"time"
 )
 
-// Menu メニューアイテム（共通データ）
+// Menu メニューアイテム（店舗共通データ）
 type Menu struct {
 	ID        uint       `gorm:"primaryKey" json:"id"`
+	StoreID   uint       `gorm:"not null;index" json:"storeId"`
 	Name      string     `gorm:"type:varchar(255);not null" json:"name"`
 	Price     float64    `gorm:"not null" json:"price"`
 	Category  string     `gorm:"type:varchar(100);not null" json:"category"`
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+)
+
+// PasswordResetToken パスワード再設定トークン（SHA-256ハッシュのみ保存、1回限り使用可能）
+type PasswordResetToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"userId"`
+	TokenHash string     `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expiresAt"`
+	UsedAt    *time.Time `json:"usedAt"`
+	IPAddress string     `gorm:"type:varchar(45)" json:"ipAddress"` // 再設定を要求した接続元
+	CreatedAt time.Time  `json:"createdAt"`
+
+	// リレーション
+	User *User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// TableName テーブル名を指定
+func (PasswordResetToken) TableName() string {
+	return "password_reset_token"
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+// 支払い方法
+const (
+	PaymentMethodCash = "cash" // 現金
+	PaymentMethodCard = "card" // カード
+	PaymentMethodTab  = "tab"  // 売掛（後日回収）
+)
+
+// Payment 卓記録の支払い
+// 売掛（method = tab）は回収すべき金額を表し、その回収は ReceivableID に売掛の支払いIDを持つ現金・カードの支払いとして記録する
+type Payment struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	UserID         uint       `gorm:"not null;index" json:"userId"`
+	TableID        uint       `gorm:"not null;index" json:"tableId"`
+	HimeID         uint       `gorm:"not null;index" json:"himeId"`
+	ReceivableID   *uint      `gorm:"index" json:"receivableId"` // 回収した売掛
+	Method         string     `gorm:"type:varchar(10);not null" json:"method"`
+	Amount         float64    `gorm:"not null" json:"amount"`
+	DueDate        *time.Time `gorm:"index" json:"dueDate"` // 売掛の回収期限
+	PaidAt         time.Time  `gorm:"not null" json:"paidAt"`
+	Memo           *string    `json:"memo"`
+	ReminderSentAt *time.Time `json:"-"` // 期限超過の通知を最後に送った日時
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+
+	// リレーション
+	User *User `gorm:"foreignKey:UserID" json:"-"`
+	Hime *Hime `gorm:"foreignKey:HimeID" json:"-"`
+}
+
+// TableName テーブル名を指定
+func (Payment) TableName() string {
+	return "payment"
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+)
+
+// 端数処理の方法
+const (
+	RoundingRound = "round" // 四捨五入
+	RoundingFloor = "floor" // 切り捨て
+	RoundingCeil  = "ceil"  // 切り上げ
+)
+
+// PricingSurcharge 時間帯による加算（深夜料金など）
+// 入店時刻が Start〜End に含まれる場合、セット・延長料金に Rate（%）と Amount を加算する
+type PricingSurcharge struct {
+	Name   string  `json:"name"`
+	Start  string  `json:"start"` // HH:MM
+	End    string  `json:"end"`   // HH:MM（Startより前の場合は日をまたぐ）
+	Rate   float64 `json:"rate"`  // セット・延長料金に対する%
+	Amount float64 `json:"amount"`
+}
+
+// PricingSurcharges 時間帯による加算の一覧
+type PricingSurcharges []PricingSurcharge
+
+// Value JSONに変換
+func (s PricingSurcharges) Value() (driver.Value, error) {
+	if s == nil {
+		s = PricingSurcharges{}
+	}
+	return json.Marshal(s)
+}
+
+// Scan JSONから復元
+func (s *PricingSurcharges) Scan(value interface{}) error {
+	if value == nil {
+		*s = nil
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, s)
+}
+
+// PricingRule 店舗の料金ルール
+// 変更するたびに新しいバージョンを作成し、卓記録の売上情報にはどのバージョンで計算したかを残す
+type PricingRule struct {
+	ID                uint              `gorm:"primaryKey" json:"id"`
+	StoreID           uint              `gorm:"not null;uniqueIndex:idx_pricing_rule_store_version" json:"storeId"`
+	Version           int               `gorm:"not null;uniqueIndex:idx_pricing_rule_store_version" json:"version"`
+	SetMinutes        int               `gorm:"not null" json:"setMinutes"`                    // 1セットの時間（分）
+	SetPriceNormal    float64           `gorm:"not null" json:"setPriceNormal"`                // 通常のセット料金
+	SetPriceFirst     float64           `gorm:"not null" json:"setPriceFirst"`                 // 初回のセット料金
+	SetPriceShimei    float64           `gorm:"not null" json:"setPriceShimei"`                // 指名ありのセット料金
+	FirstVisitFlat    bool              `gorm:"not null" json:"firstVisitFlat"`                // 初回のセット料金をサービス料・税込みの定額にする
+	ExtensionMinutes  int               `gorm:"not null" json:"extensionMinutes"`              // 延長の単位（分）
+	ExtensionPrice    float64           `gorm:"not null" json:"extensionPrice"`                // 延長1単位の料金
+	ShimeiFee         float64           `gorm:"not null" json:"shimeiFee"`                     // 指名料
+	ServiceChargeRate float64           `gorm:"not null" json:"serviceChargeRate"`             // TAX・サービス料率（%）
+	TaxRate           float64           `gorm:"not null" json:"taxRate"`                       // 消費税率（%）
+	RoundingMode      string            `gorm:"type:varchar(10);not null" json:"roundingMode"` // round, floor, ceil
+	RoundingUnit      int               `gorm:"not null" json:"roundingUnit"`                  // 端数処理の単位（円）
+	Surcharges        PricingSurcharges `gorm:"type:json" json:"surcharges"`
+	CreatedBy         uint              `json:"createdBy"`
+	CreatedAt         time.Time         `json:"createdAt"`
+
+	// リレーション
+	Store *Store `gorm:"foreignKey:StoreID" json:"-"`
+}
+
+// TableName テーブル名を指定
+func (PricingRule) TableName() string {
+	return "pricing_rule"
+}
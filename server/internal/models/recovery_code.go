@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// RecoveryCode 2段階認証のリカバリーコード（SHA-256ハッシュのみ保存、1回限り使用可能）
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"userId"`
+	CodeHash  string     `gorm:"type:varchar(64);not null" json:"-"`
+	UsedAt    *time.Time `json:"usedAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+
+	// リレーション
+	User *User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// TableName テーブル名を指定
+func (RecoveryCode) TableName() string {
+	return "recovery_code"
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+// Session ログインセッション（端末ごとのリフレッシュトークン）
+// リフレッシュトークンはSHA-256ハッシュのみ保存し、更新のたびにローテーションする
+type Session struct {
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	UserID            uint       `gorm:"not null;index" json:"userId"`
+	RefreshTokenHash  string     `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"`
+	PreviousTokenHash *string    `gorm:"type:varchar(64);index" json:"-"` // 再利用検知用（ローテーション前のトークン）
+	UserAgent         string     `gorm:"type:varchar(255)" json:"userAgent"`
+	IPAddress         string     `gorm:"type:varchar(45)" json:"ipAddress"`
+	ExpiresAt         time.Time  `gorm:"not null" json:"expiresAt"`
+	LastUsedAt        time.Time  `json:"lastUsedAt"`
+	RevokedAt         *time.Time `gorm:"index" json:"-"`
+	CreatedAt         time.Time  `json:"createdAt"`
+	UpdatedAt         time.Time  `json:"updatedAt"`
+
+	// リレーション
+	User *User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// TableName テーブル名を指定
+func (Session) TableName() string {
+	return "session"
+}
+
+// IsActive セッションが有効か判定（取り消し・期限切れでない）
+func (s *Session) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
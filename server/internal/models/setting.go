@@ -4,8 +4,9 @@ import (
 	"time"
 )
 
-// Setting 設定
+// Setting 設定（店舗ごと）
 type Setting struct {
+	StoreID   uint      `gorm:"primaryKey;autoIncrement:false" json:"storeId"`
 	Key       string    `gorm:"primaryKey;column:key" json:"key"`
 	Value     string    `gorm:"not null" json:"value"`
 	CreatedAt time.Time `json:"createdAt"`
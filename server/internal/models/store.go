@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+)
+
+// Store 店舗（メニュー・キャスト・設定を共有する単位）
+type Store struct {
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	Name             string     `gorm:"type:varchar(255);not null" json:"name"`
+	RequireTwoFactor bool       `gorm:"not null;default:false" json:"requireTwoFactor"` // 所属メンバー全員に2段階認証を必須にする
+	CreatedAt        time.Time  `json:"createdAt"`
+	UpdatedAt        time.Time  `json:"updatedAt"`
+	DeletedAt        *time.Time `gorm:"index" json:"-"`
+}
+
+// TableName テーブル名を指定
+func (Store) TableName() string {
+	return "store"
+}
+
+// StoreMember 店舗とユーザーの所属関係（ユーザーは1店舗に所属）
+type StoreMember struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	StoreID   uint      `gorm:"not null;index" json:"storeId"`
+	UserID    uint      `gorm:"not null;uniqueIndex" json:"userId"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	// リレーション
+	Store *Store `gorm:"foreignKey:StoreID" json:"-"`
+	User  *User  `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// TableName テーブル名を指定
+func (StoreMember) TableName() string {
+	return "store_member"
+}
+
+// StoreInvitation 店舗への招待（招待コード・リンク）
+type StoreInvitation struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	StoreID   uint       `gorm:"not null;index" json:"storeId"`
+	Code      string     `gorm:"type:varchar(64);not null;uniqueIndex" json:"code"`
+	Role      string     `gorm:"type:varchar(20);not null" json:"role"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expiresAt"`
+	MaxUses   int        `gorm:"not null;default:0" json:"maxUses"` // 0は無制限
+	UsedCount int        `gorm:"not null;default:0" json:"usedCount"`
+	CreatedBy uint       `gorm:"not null" json:"createdBy"`
+	RevokedAt *time.Time `json:"revokedAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+
+	// リレーション
+	Store *Store `gorm:"foreignKey:StoreID" json:"-"`
+}
+
+// TableName テーブル名を指定
+func (StoreInvitation) TableName() string {
+	return "store_invitation"
+}
+
+// IsUsable 招待が有効か判定（取り消し・期限切れ・使用回数超過でない）
+func (i *StoreInvitation) IsUsable(now time.Time) bool {
+	if i.RevokedAt != nil || !now.Before(i.ExpiresAt) {
+		return false
+	}
+	return i.MaxUses == 0 || i.UsedCount < i.MaxUses
+}
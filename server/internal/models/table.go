@@ -8,23 +8,38 @@ import (
 
 // OrderItem 注文アイテム
 type OrderItem struct {
+	MenuID    uint    `json:"menuId,omitempty"` // 注文したメニュー（サーバー側で単価を決める）
+	HimeID    uint    `json:"himeId,omitempty"` // 注文した姫（品目ごとの割り勘用、0は卓全体）
 	Name      string  `json:"name"`
 	Quantity  int     `json:"quantity"`
 	UnitPrice float64 `json:"unitPrice"`
 	Amount    float64 `json:"amount"`
+
+	FixedUnitPrice *float64 `json:"-"` // 注文時に確定した単価（接客中の卓の注文から計算する場合のみ、クライアントからは指定できない）
 }
 
 // SalesInfo 売上情報
+// 金額はサーバー側でメニューと店舗の料金ルールから計算する（services.CalculateBill）
 type SalesInfo struct {
-	TableCharge float64     `json:"tableCharge"`
-	OrderItems  []OrderItem `json:"orderItems"`
-	VisitType   string      `json:"visitType"` // normal, first, shimei
-	StayHours   float64     `json:"stayHours"`
-	ShimeiFee   float64     `json:"shimeiFee"`
-	Subtotal    float64     `json:"subtotal"`
-	TaxRate     float64     `json:"taxRate"`
-	Tax         float64     `json:"tax"`
-	Total       float64     `json:"total"`
+	TableCharge        float64     `json:"tableCharge"` // セット料金＋延長料金＋時間帯による加算
+	OrderItems         []OrderItem `json:"orderItems"`
+	VisitType          string      `json:"visitType"` // normal, first, shimei
+	StayHours          float64     `json:"stayHours"`
+	SetPrice           float64     `json:"setPrice"`
+	ExtensionCount     int         `json:"extensionCount"` // 延長の回数
+	ExtensionFee       float64     `json:"extensionFee"`
+	Surcharge          float64     `json:"surcharge"` // 深夜料金など時間帯による加算
+	ShimeiFee          float64     `json:"shimeiFee"`
+	Subtotal           float64     `json:"subtotal"`          // テーブルチャージ＋注文
+	ServiceChargeRate  float64     `json:"serviceChargeRate"` // サービス料率（%）
+	ServiceCharge      float64     `json:"serviceCharge"`     // （小計＋指名料）に対するサービス料
+	TaxRate            float64     `json:"taxRate"`
+	Tax                float64     `json:"tax"`
+	TaxIncluded        float64     `json:"taxIncluded,omitempty"` // 税込みの金額（初回の定額料金、Tax には含まない）
+	Total              float64     `json:"total"`
+	PricingRuleID      uint        `json:"pricingRuleId,omitempty"` // 計算に使った料金ルール（既定のルールの場合は0）
+	PricingRuleVersion int         `json:"pricingRuleVersion"`
+	SplitMode          string      `json:"splitMode,omitempty"` // 姫ごとの負担額の決め方（equal, item, custom）
 }
 
 // Value JSONに変換
@@ -44,7 +59,15 @@ func (s *SalesInfo) Scan(value interface{}) error {
 	return json.Unmarshal(bytes, s)
 }
 
+// 卓記録の状態
+const (
+	TableStatusOpen   = "open"   // 接客中（注文を追加できる）
+	TableStatusClosed = "closed" // 会計済み
+	TableStatusVoided = "voided" // 取り消し
+)
+
 // TableRecord 卓記録
+// 接客中の卓は Datetime が入店日時で、注文は TableOrder に記録し、会計で SalesInfo を確定する
 type TableRecord struct {
 	ID          uint       `gorm:"primaryKey" json:"id"`
 	UserID      uint       `gorm:"not null;index" json:"userId"`
@@ -52,10 +75,16 @@ type TableRecord struct {
 	TableNumber *string    `json:"tableNumber"`
 	Memo        *string    `json:"memo"`
 	SalesInfo   *SalesInfo `gorm:"type:json" json:"salesInfo"`
+	Status      string     `gorm:"type:varchar(10);not null;default:closed;index" json:"status"`
+	ClosedAt    *time.Time `json:"closedAt"` // 会計（取り消し）した日時
 	CreatedAt   time.Time  `json:"createdAt"`
 	UpdatedAt   time.Time  `json:"updatedAt"`
 	DeletedAt   *time.Time `gorm:"index" json:"-"`
 
+	// 再オープンした卓の元の会計の滞在時間と料金ルール（会計し直す際に使い、会計後にnullに戻す）
+	ReopenedStayHours          *float64 `json:"-"`
+	ReopenedPricingRuleVersion *int     `json:"-"`
+
 	// リレーション
 	User *User `gorm:"foreignKey:UserID" json:"-"`
 }
@@ -65,11 +94,30 @@ func (TableRecord) TableName() string {
 	return "table_record"
 }
 
+// TableOrder 接客中の卓の注文
+type TableOrder struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	TableID   uint      `gorm:"not null;index" json:"tableId"`
+	MenuID    uint      `gorm:"not null" json:"menuId"`
+	HimeID    uint      `json:"himeId,omitempty"` // 注文した姫（0は卓全体）
+	Quantity  int       `gorm:"not null" json:"quantity"`
+	UnitPrice *float64  `json:"unitPrice"` // 注文時の単価（会計ではこの単価を使う。記録のない注文は現在のメニューの単価）
+	OrderedBy uint      `gorm:"not null" json:"orderedBy"`
+	OrderedAt time.Time `gorm:"not null" json:"orderedAt"`
+}
+
+// TableName テーブル名を指定
+func (TableOrder) TableName() string {
+	return "table_order"
+}
+
 // TableHime 卓と姫の関連
+// Allocation は卓の合計のうちこの姫の負担額（売上情報の SplitMode で計算、未設定の卓は均等に按分する）
 type TableHime struct {
-	ID      uint `gorm:"primaryKey" json:"id"`
-	TableID uint `gorm:"not null;index:idx_table_hime_table_id;index:idx_table_hime_composite" json:"tableId"`
-	HimeID  uint `gorm:"not null;index:idx_table_hime_hime_id;index:idx_table_hime_composite" json:"himeId"`
+	ID         uint     `gorm:"primaryKey" json:"id"`
+	TableID    uint     `gorm:"not null;index:idx_table_hime_table_id;index:idx_table_hime_composite" json:"tableId"`
+	HimeID     uint     `gorm:"not null;index:idx_table_hime_hime_id;index:idx_table_hime_composite" json:"himeId"`
+	Allocation *float64 `json:"allocation"`
 }
 
 // TableName テーブル名を指定
@@ -7,6 +7,14 @@ import (
 	"gorm.io/gorm"
 )
 
+// ロール
+const (
+	RoleSuperAdmin = "superadmin"
+	RoleAdmin      = "admin"
+	RoleLeader     = "leader"
+	RoleMember     = "member"
+)
+
 // User ユーザー情報
 type User struct {
 	ID            uint           `gorm:"primaryKey" json:"id"`
@@ -14,6 +22,10 @@ type User struct {
 	Email         *string        `gorm:"type:varchar(255);uniqueIndex" json:"email"`
 	Password      *string        `gorm:"type:varchar(255)" json:"-"`                             // OAuthユーザーはNULL可
 	Role          string         `gorm:"type:varchar(20);not null;default:'member'" json:"role"` // superadmin, admin, leader, member
+	LeaderID      *uint          `gorm:"index" json:"leaderId"`                                  // 所属リーダーのユーザーID
+	TotpSecret    *string        `gorm:"type:varchar(64)" json:"-"`                              // TOTP共有シークレット（有効化前は登録途中の値）
+	TotpEnabled   bool           `gorm:"not null;default:false" json:"totpEnabled"`              // 2段階認証が有効か
+	TotpLastStep  int64          `gorm:"not null;default:0" json:"-"`                            // 最後に使用したTOTPのタイムステップ（再利用防止）
 	CreatedAt     time.Time      `json:"createdAt"`
 	UpdatedAt     time.Time      `json:"updatedAt"`
 	DeletedAt     *time.Time     `gorm:"index" json:"-"`
@@ -46,3 +58,26 @@ func (u *User) CheckPassword(password string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(*u.Password), []byte(password))
 	return err == nil
 }
+
+// RoleLevel ロールの権限レベルを返す（大きいほど強い、不明なロールはmember扱い）
+func RoleLevel(role string) int {
+	switch role {
+	case RoleSuperAdmin:
+		return 4
+	case RoleAdmin:
+		return 3
+	case RoleLeader:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// IsValidRole 有効なロールか判定
+func IsValidRole(role string) bool {
+	switch role {
+	case RoleSuperAdmin, RoleAdmin, RoleLeader, RoleMember:
+		return true
+	}
+	return false
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/hostnote/server/internal/database"
 	"github.com/hostnote/server/internal/models"
 	"gorm.io/gorm"
 )
@@ -57,7 +58,16 @@ func RunDemoData(db *gorm.DB, opts Options) error {
 			return err
 		}
 
-		casts, err := ensureCasts(tx, user)
+		store, err := database.DefaultStore(tx)
+		if err != nil {
+			return err
+		}
+
+		if err := ensureDemoMembership(tx, user, store.ID); err != nil {
+			return err
+		}
+
+		casts, err := ensureCasts(tx, user, store.ID)
 		if err != nil {
 			return err
 		}
@@ -99,7 +109,14 @@ func truncateDemoTables(db *gorm.DB) error {
 		"visit_record",
 		"schedule",
 		"push_tokens",
+		"session",
+		"recovery_code",
+		"password_reset_token",
+		"audit_log",
+		"export_job",
+		"ai_analysis",
 		"oauth_account",
+		"store_member",
 		"hime",
 		"cast",
 		"user",
@@ -144,9 +161,21 @@ func ensureDemoUser(db *gorm.DB) (*models.User, error) {
 	return &user, nil
 }
 
-func ensureCasts(db *gorm.DB, user *models.User) ([]models.Cast, error) {
+// ensureDemoMembership デモユーザーを店舗に所属させる
+func ensureDemoMembership(db *gorm.DB, user *models.User, storeID uint) error {
+	var count int64
+	if err := db.Model(&models.StoreMember{}).Where("user_id = ?", user.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	return db.Create(&models.StoreMember{StoreID: storeID, UserID: user.ID}).Error
+}
+
+func ensureCasts(db *gorm.DB, user *models.User, storeID uint) ([]models.Cast, error) {
 	var casts []models.Cast
-	if err := db.Find(&casts).Error; err != nil {
+	if err := db.Where("store_id = ?", storeID).Find(&casts).Error; err != nil {
 		return nil, err
 	}
 
@@ -161,6 +190,7 @@ func ensureCasts(db *gorm.DB, user *models.User) ([]models.Cast, error) {
 	if !myCastExists {
 		cast := randomCast(randomCastName())
 		cast.UserID = ptr(user.ID)
+		cast.StoreID = ptr(storeID)
 		if err := db.Create(&cast).Error; err != nil {
 			return nil, err
 		}
@@ -169,6 +199,7 @@ func ensureCasts(db *gorm.DB, user *models.User) ([]models.Cast, error) {
 
 	for len(casts) < targetCastCount {
 		cast := randomCast(randomCastName())
+		cast.StoreID = ptr(storeID)
 		if err := db.Create(&cast).Error; err != nil {
 			return nil, err
 		}
@@ -217,7 +248,7 @@ func ensureSchedules(db *gorm.DB, himes []models.Hime, user *models.User) error
 		daysAhead := randRange(-7, 30)
 		startHour := randRange(18, 24)
 		datetime := time.Now().AddDate(0, 0, daysAhead).Truncate(24 * time.Hour).Add(time.Duration(startHour) * time.Hour)
-		memo := randomScheduleMemo(hime.Name)
+		memo := randomScheduleMemo(string(hime.Name))
 		schedule := models.Schedule{
 			UserID:            user.ID,
 			HimeID:            hime.ID,
@@ -339,16 +370,16 @@ func randomCast(name string) models.Cast {
 func randomHime(tantoCastID uint) models.Hime {
 	photo := randomPhotoURL("hime")
 	return models.Hime{
-		Name:             randomHimeName(),
+		Name:             models.EncryptedString(randomHimeName()),
 		PhotoURL:         ptr(photo),
 		Photos:           models.Photos{photo},
-		SnsInfo:          randomSnsInfo(),
-		Birthday:         randomBirthday(),
+		SnsInfo:          (*models.EncryptedSnsInfo)(randomSnsInfo()),
+		Birthday:         models.NewEncryptedString(randomBirthday()),
 		IsFirstVisit:     rand.Intn(2) == 0,
 		TantoCastID:      ptr(tantoCastID),
 		DrinkPreference:  ptr(randomChoice(drinks)),
 		MixerPreference:  ptr(randomChoice(mixers)),
-		Memos:            randomMemos(),
+		Memos:            models.EncryptedMemos(randomMemos()),
 	}
 }
 
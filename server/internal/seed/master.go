@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/hostnote/server/internal/database"
 	"github.com/hostnote/server/internal/models"
 	"gorm.io/gorm"
 )
@@ -29,13 +30,19 @@ type MasterData struct {
 // RunMasterData マスターデータをシードする
 func RunMasterData(db *gorm.DB, opts Options) error {
 	return db.Transaction(func(tx *gorm.DB) error {
+		// マスタデータはデフォルト店舗に登録する
+		store, err := database.DefaultStore(tx)
+		if err != nil {
+			return err
+		}
+
 		// Settingsをシード
-		if err := ensureSettings(tx); err != nil {
+		if err := ensureSettings(tx, store.ID); err != nil {
 			return err
 		}
 
 		// Menusをシード
-		if err := ensureMenus(tx); err != nil {
+		if err := ensureMenus(tx, store.ID); err != nil {
 			return err
 		}
 
@@ -45,9 +52,9 @@ func RunMasterData(db *gorm.DB, opts Options) error {
 }
 
 // ensureSettings マスタデータからSettingsを読み込んでシードする
-func ensureSettings(db *gorm.DB) error {
+func ensureSettings(db *gorm.DB, storeID uint) error {
 	var count int64
-	if err := db.Model(&models.Setting{}).Count(&count).Error; err != nil {
+	if err := db.Model(&models.Setting{}).Where("store_id = ?", storeID).Count(&count).Error; err != nil {
 		return err
 	}
 
@@ -63,8 +70,8 @@ func ensureSettings(db *gorm.DB) error {
 		log.Printf("⚠️  Failed to load master data: %v, using default settings", err)
 		// フォールバック: デフォルトのSettingsを追加
 		defaultSettings := []models.Setting{
-			{Key: "iceOptions", Value: `["少なめ","普通","多め"]`},
-			{Key: "tobaccoOptions", Value: `["吸わない","紙巻き","加熱式","電子タバコ"]`},
+			{StoreID: storeID, Key: "iceOptions", Value: `["少なめ","普通","多め"]`},
+			{StoreID: storeID, Key: "tobaccoOptions", Value: `["吸わない","紙巻き","加熱式","電子タバコ"]`},
 		}
 		if err := db.Create(&defaultSettings).Error; err != nil {
 			return err
@@ -86,8 +93,9 @@ func ensureSettings(db *gorm.DB) error {
 			value, _ := settingMap["value"].(string)
 			if key != "" {
 				settings = append(settings, models.Setting{
-					Key:   key,
-					Value: value,
+					StoreID: storeID,
+					Key:     key,
+					Value:   value,
 				})
 			}
 		}
@@ -103,8 +111,9 @@ func ensureSettings(db *gorm.DB) error {
 				continue
 			}
 			settings = append(settings, models.Setting{
-				Key:   key,
-				Value: string(valueJSON),
+				StoreID: storeID,
+				Key:     key,
+				Value:   string(valueJSON),
 			})
 		}
 	}
@@ -121,9 +130,9 @@ func ensureSettings(db *gorm.DB) error {
 }
 
 // ensureMenus マスタデータからMenusを読み込んでシードする
-func ensureMenus(db *gorm.DB) error {
+func ensureMenus(db *gorm.DB, storeID uint) error {
 	var count int64
-	if err := db.Model(&models.Menu{}).Count(&count).Error; err != nil {
+	if err := db.Model(&models.Menu{}).Where("store_id = ?", storeID).Count(&count).Error; err != nil {
 		return err
 	}
 
@@ -209,6 +218,10 @@ func ensureMenus(db *gorm.DB) error {
 		}
 	}
 
+	for i := range menus {
+		menus[i].StoreID = storeID
+	}
+
 	if err := db.Create(&menus).Error; err != nil {
 		return err
 	}
@@ -52,7 +52,16 @@ func truncateAllTables(db *gorm.DB) error {
 		"visit_record",
 		"schedule",
 		"push_tokens",
+		"session",
+		"recovery_code",
+		"password_reset_token",
+		"audit_log",
+		"export_job",
+		"ai_analysis",
 		"oauth_account",
+		"store_invitation",
+		"store_member",
+		"store",
 		"setting",
 		"menu",
 		"hime",
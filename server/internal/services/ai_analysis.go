@@ -0,0 +1,90 @@
+package services
+
+import (
+	"time"
+
+	"github.com/hostnote/server/internal/models"
+)
+
+// aiFollowUpMaxMessages 追加の質問でAIに送る直近のやり取りの数（最初のプロンプトと分析結果は常に送る）
+const aiFollowUpMaxMessages = 10
+
+// AIFollowUpMaxLength 追加の質問の最大文字数
+const AIFollowUpMaxLength = 2000
+
+// NewAIAnalysis 分析のリクエストと結果から保存用のレコードを作成
+func NewAIAnalysis(userID uint, himeID *uint, kind string, input map[string]interface{}, req LLMRequest, resp *LLMResponse, now time.Time) *models.AIAnalysis {
+	a := &models.AIAnalysis{
+		UserID: userID,
+		HimeID: himeID,
+		Kind:   kind,
+		Input:  models.EncryptedMap(input),
+		Result: models.EncryptedString(resp.Content),
+	}
+	for _, m := range req.Messages {
+		a.Messages = append(a.Messages, models.AIMessage{Role: m.Role, Content: m.Content, CreatedAt: now})
+	}
+	a.Messages = append(a.Messages, models.AIMessage{Role: LLMRoleAssistant, Content: resp.Content, CreatedAt: now})
+	addAIUsage(a, resp)
+	return a
+}
+
+// FollowUpRequest 過去の会話に追加の質問を続けたリクエストを作成
+// 会話が長くなった場合は、最初のプロンプトと分析結果のあとに直近のやり取りだけを続ける
+func FollowUpRequest(a *models.AIAnalysis, message string) LLMRequest {
+	history := a.Messages
+
+	// 最初の分析結果までは常に含める
+	head := 0
+	for head < len(history) && history[head].Role != LLMRoleAssistant {
+		head++
+	}
+	if head < len(history) {
+		head++
+	}
+	rest := history[head:]
+	if len(rest) > aiFollowUpMaxMessages {
+		rest = rest[len(rest)-aiFollowUpMaxMessages:]
+	}
+
+	messages := make([]LLMMessage, 0, head+len(rest)+1)
+	for _, m := range history[:head] {
+		messages = append(messages, LLMMessage{Role: m.Role, Content: m.Content})
+	}
+	for _, m := range rest {
+		messages = append(messages, LLMMessage{Role: m.Role, Content: m.Content})
+	}
+	messages = append(messages, LLMMessage{Role: LLMRoleUser, Content: message})
+
+	return LLMRequest{Messages: messages, Temperature: 0.7, MaxTokens: 1000}
+}
+
+// AppendAIFollowUp 追加の質問と回答を履歴に加え、トークン数を合算する
+func AppendAIFollowUp(a *models.AIAnalysis, message string, resp *LLMResponse, now time.Time) {
+	a.Messages = append(a.Messages,
+		models.AIMessage{Role: LLMRoleUser, Content: message, CreatedAt: now},
+		models.AIMessage{Role: LLMRoleAssistant, Content: resp.Content, CreatedAt: now},
+	)
+	addAIUsage(a, resp)
+}
+
+// VisibleAIMessages 画面に表示する会話履歴（AIへの指示は除く）
+func VisibleAIMessages(a *models.AIAnalysis) []models.AIMessage {
+	messages := make([]models.AIMessage, 0, len(a.Messages))
+	for _, m := range a.Messages {
+		if m.Role != LLMRoleSystem {
+			messages = append(messages, m)
+		}
+	}
+	return messages
+}
+
+// addAIUsage 応答したモデルとトークン数を反映する
+func addAIUsage(a *models.AIAnalysis, resp *LLMResponse) {
+	if resp.Model != "" {
+		a.Model = resp.Model
+	}
+	a.PromptTokens += resp.Usage.PromptTokens
+	a.CompletionTokens += resp.Usage.CompletionTokens
+	a.TotalTokens += resp.Usage.TotalTokens
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hostnote/server/internal/models"
+)
+
+// TestNewAIAnalysis プロンプト・結果・モデル名・トークン数が保存用のレコードに入ることをテスト
+func TestNewAIAnalysis(t *testing.T) {
+	now := time.Now()
+	himeID := uint(3)
+	req := LLMRequest{Messages: []LLMMessage{{Role: LLMRoleSystem, Content: "指示"}, {Role: LLMRoleUser, Content: "会話ログ"}}}
+	resp := &LLMResponse{Content: "分析結果", Model: "gpt-4o-mini", Usage: LLMUsage{PromptTokens: 100, CompletionTokens: 50, TotalTokens: 150}}
+
+	a := NewAIAnalysis(1, &himeID, models.AIAnalysisKindConversation, map[string]interface{}{"chatLog": "会話ログ"}, req, resp, now)
+
+	if a.UserID != 1 || *a.HimeID != 3 || a.Kind != models.AIAnalysisKindConversation || string(a.Result) != "分析結果" || a.Input["chatLog"] != "会話ログ" {
+		t.Errorf("analysis = %+v", a)
+	}
+	if a.Model != "gpt-4o-mini" || a.PromptTokens != 100 || a.CompletionTokens != 50 || a.TotalTokens != 150 {
+		t.Errorf("model/usage = %s %d/%d/%d", a.Model, a.PromptTokens, a.CompletionTokens, a.TotalTokens)
+	}
+	if len(a.Messages) != 3 || a.Messages[2].Role != LLMRoleAssistant || a.Messages[2].Content != "分析結果" {
+		t.Errorf("messages = %+v", a.Messages)
+	}
+	if visible := VisibleAIMessages(a); len(visible) != 2 || visible[0].Role != LLMRoleUser {
+		t.Errorf("visible messages = %+v", visible)
+	}
+}
+
+// TestFollowUpRequest 最初のプロンプトと分析結果は常に含め、それ以降は直近のやり取りだけを送ることをテスト
+func TestFollowUpRequest(t *testing.T) {
+	now := time.Now()
+	a := NewAIAnalysis(1, nil, models.AIAnalysisKindCustomer, nil,
+		LLMRequest{Messages: []LLMMessage{{Role: LLMRoleSystem, Content: "指示"}, {Role: LLMRoleUser, Content: "データ"}}},
+		&LLMResponse{Content: "分析結果", Model: "m1", Usage: LLMUsage{TotalTokens: 10}}, now)
+
+	req := FollowUpRequest(a, "次は何を送ればいい？")
+	if len(req.Messages) != 4 || req.Messages[2].Content != "分析結果" || req.Messages[3].Role != LLMRoleUser || req.Messages[3].Content != "次は何を送ればいい？" {
+		t.Fatalf("messages = %+v", req.Messages)
+	}
+
+	for i := 0; i < 8; i++ {
+		AppendAIFollowUp(a, fmt.Sprintf("質問%d", i), &LLMResponse{Content: fmt.Sprintf("回答%d", i), Model: "m2", Usage: LLMUsage{TotalTokens: 5}}, now)
+	}
+	if a.Model != "m2" || a.TotalTokens != 50 || len(a.Messages) != 19 {
+		t.Errorf("model = %s, total = %d, messages = %d", a.Model, a.TotalTokens, len(a.Messages))
+	}
+
+	req = FollowUpRequest(a, "最後の質問")
+	want := []string{"指示", "データ", "分析結果", "質問3", "回答3", "質問4", "回答4", "質問5", "回答5", "質問6", "回答6", "質問7", "回答7", "最後の質問"}
+	if len(req.Messages) != len(want) {
+		t.Fatalf("got %d messages, want %d: %+v", len(req.Messages), len(want), req.Messages)
+	}
+	for i, w := range want {
+		if req.Messages[i].Content != w {
+			t.Errorf("messages[%d] = %q, want %q", i, req.Messages[i].Content, w)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hostnote/server/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AI利用量の上限の設定キー（店舗の設定、未設定または0以下の場合は無制限）
+const (
+	SettingAIMonthlyTokenQuota   = "ai_monthly_token_quota"   // 1ユーザーあたりの月間トークン数
+	SettingAIMonthlyRequestQuota = "ai_monthly_request_quota" // 1ユーザーあたりの月間のAI呼び出し回数
+)
+
+// AIUsageMonthLayout 利用量を集計する月の形式
+const AIUsageMonthLayout = "2006-01"
+
+// ErrAIQuotaExceeded 今月のAI利用量が上限に達している
+var ErrAIQuotaExceeded = errors.New("monthly AI quota exceeded")
+
+// AIQuota 1ユーザーあたりの月間の上限（0は無制限）
+type AIQuota struct {
+	MonthlyTokens   int `json:"monthlyTokens"`
+	MonthlyRequests int `json:"monthlyRequests"`
+}
+
+// AIUsageStatus 今月の利用量と上限
+type AIUsageStatus struct {
+	Month            string    `json:"month"`
+	Requests         int       `json:"requests"`
+	PromptTokens     int       `json:"promptTokens"`
+	CompletionTokens int       `json:"completionTokens"`
+	TotalTokens      int       `json:"totalTokens"`
+	Quota            AIQuota   `json:"quota"`
+	ResetAt          time.Time `json:"resetAt"` // 利用量がリセットされる日時（翌月1日）
+}
+
+// TokensExceeded トークン数が上限に達しているか
+func (s *AIUsageStatus) TokensExceeded() bool {
+	return s.Quota.MonthlyTokens > 0 && s.TotalTokens >= s.Quota.MonthlyTokens
+}
+
+// RequestsExceeded 呼び出し回数が上限に達しているか
+func (s *AIUsageStatus) RequestsExceeded() bool {
+	return s.Quota.MonthlyRequests > 0 && s.Requests >= s.Quota.MonthlyRequests
+}
+
+// AIUsageMonth 日時が属する集計月
+func AIUsageMonth(t time.Time) string {
+	return t.Format(AIUsageMonthLayout)
+}
+
+// nextAIUsageMonth 翌月1日の0時
+func nextAIUsageMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+}
+
+// LoadAIQuota ユーザーが所属する店舗の設定からAI利用量の上限を取得
+// 店舗に所属していない場合は無制限
+func LoadAIQuota(ctx context.Context, db *gorm.DB, userID uint) (AIQuota, error) {
+	db = db.WithContext(ctx)
+	return loadAIQuota(db.Where("store_id IN (?)", db.Model(&models.StoreMember{}).Select("store_id").Where("user_id = ?", userID)))
+}
+
+// LoadStoreAIQuota 店舗の設定からAI利用量の上限を取得
+func LoadStoreAIQuota(ctx context.Context, db *gorm.DB, storeID uint) (AIQuota, error) {
+	return loadAIQuota(db.WithContext(ctx).Where("store_id = ?", storeID))
+}
+
+func loadAIQuota(query *gorm.DB) (AIQuota, error) {
+	var settings []models.Setting
+	if err := query.Where("`key` IN ?", []string{SettingAIMonthlyTokenQuota, SettingAIMonthlyRequestQuota}).Find(&settings).Error; err != nil {
+		return AIQuota{}, err
+	}
+
+	var quota AIQuota
+	for _, s := range settings {
+		v, err := strconv.Atoi(strings.TrimSpace(s.Value))
+		if err != nil || v < 0 {
+			log.Printf("Invalid AI quota setting %s=%q for store %d, ignoring", s.Key, s.Value, s.StoreID)
+			continue
+		}
+		switch s.Key {
+		case SettingAIMonthlyTokenQuota:
+			quota.MonthlyTokens = v
+		case SettingAIMonthlyRequestQuota:
+			quota.MonthlyRequests = v
+		}
+	}
+	return quota, nil
+}
+
+// GetAIUsageStatus 今月の利用量と上限を取得
+func GetAIUsageStatus(ctx context.Context, db *gorm.DB, userID uint, now time.Time) (*AIUsageStatus, error) {
+	quota, err := LoadAIQuota(ctx, db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	month := AIUsageMonth(now)
+	var usage models.AIUsage
+	if err := db.WithContext(ctx).Where("user_id = ? AND month = ?", userID, month).Limit(1).Find(&usage).Error; err != nil {
+		return nil, err
+	}
+
+	return &AIUsageStatus{
+		Month:            month,
+		Requests:         usage.Requests,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+		Quota:            quota,
+		ResetAt:          nextAIUsageMonth(now),
+	}, nil
+}
+
+// CheckAIQuota AIを呼び出す前に今月の利用量が上限に達していないか確認する
+// 上限に達している場合は利用状況とともに ErrAIQuotaExceeded を返す
+// 応答のトークン数は呼び出すまでわからないため、上限を超えるのは最後の1回分まで許容する
+func CheckAIQuota(ctx context.Context, db *gorm.DB, userID uint, now time.Time) (*AIUsageStatus, error) {
+	status, err := GetAIUsageStatus(ctx, db, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	if status.TokensExceeded() || status.RequestsExceeded() {
+		return status, ErrAIQuotaExceeded
+	}
+	return status, nil
+}
+
+// RecordAIUsage AIの応答で消費したトークン数を今月の利用量に加算する
+func RecordAIUsage(ctx context.Context, db *gorm.DB, userID uint, resp *LLMResponse, now time.Time) error {
+	usage := models.AIUsage{
+		UserID:           userID,
+		Month:            AIUsageMonth(now),
+		Requests:         1,
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+		TotalTokens:      resp.Usage.TotalTokens,
+	}
+	return db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "month"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"requests":          gorm.Expr("requests + ?", usage.Requests),
+			"prompt_tokens":     gorm.Expr("prompt_tokens + ?", usage.PromptTokens),
+			"completion_tokens": gorm.Expr("completion_tokens + ?", usage.CompletionTokens),
+			"total_tokens":      gorm.Expr("total_tokens + ?", usage.TotalTokens),
+			"updated_at":        now,
+		}),
+	}).Create(&usage).Error
+}
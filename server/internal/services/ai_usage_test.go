@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// TestAIUsageStatusExceeded 上限（0は無制限）に達したかの判定をテスト
+func TestAIUsageStatusExceeded(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       AIUsageStatus
+		wantTokens   bool
+		wantRequests bool
+	}{
+		{"unlimited", AIUsageStatus{Requests: 1000, TotalTokens: 1000000}, false, false},
+		{"under", AIUsageStatus{Requests: 9, TotalTokens: 999, Quota: AIQuota{MonthlyTokens: 1000, MonthlyRequests: 10}}, false, false},
+		{"tokens", AIUsageStatus{Requests: 9, TotalTokens: 1200, Quota: AIQuota{MonthlyTokens: 1000, MonthlyRequests: 10}}, true, false},
+		{"requests", AIUsageStatus{Requests: 10, TotalTokens: 0, Quota: AIQuota{MonthlyRequests: 10}}, false, true},
+	}
+	for _, tt := range tests {
+		if got := tt.status.TokensExceeded(); got != tt.wantTokens {
+			t.Errorf("%s: TokensExceeded() = %v, want %v", tt.name, got, tt.wantTokens)
+		}
+		if got := tt.status.RequestsExceeded(); got != tt.wantRequests {
+			t.Errorf("%s: RequestsExceeded() = %v, want %v", tt.name, got, tt.wantRequests)
+		}
+	}
+}
+
+// TestAIUsageMonth 集計月とリセット日時をテスト
+func TestAIUsageMonth(t *testing.T) {
+	now := time.Date(2024, 12, 31, 23, 59, 0, 0, time.UTC)
+	if got := AIUsageMonth(now); got != "2024-12" {
+		t.Errorf("AIUsageMonth() = %q, want 2024-12", got)
+	}
+	if got, want := nextAIUsageMonth(now), time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("nextAIUsageMonth() = %v, want %v", got, want)
+	}
+}
+
+// TestRecordAIUsage 同じ月の利用量に加算するSQLになることをテスト
+func TestRecordAIUsage(t *testing.T) {
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "test:test@tcp(127.0.0.1:0)/test", SkipInitializeWithVersion: true}), &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+		Logger:                 logger.Discard,
+	})
+	if err != nil {
+		t.Fatalf("gorm.Open: %v", err)
+	}
+
+	var sql string
+	if err := db.Callback().Create().After("gorm:create").Register("test:capture", func(tx *gorm.DB) {
+		sql = tx.Statement.SQL.String()
+	}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	resp := &LLMResponse{Usage: LLMUsage{PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120}}
+	if err := RecordAIUsage(context.Background(), db, 1, resp, time.Date(2024, 5, 31, 12, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("RecordAIUsage: %v", err)
+	}
+	for _, want := range []string{"INSERT INTO `ai_usage`", "ON DUPLICATE KEY UPDATE", "`requests`=requests + ?", "`total_tokens`=total_tokens + ?"} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("SQL does not contain %q:\n%s", want, sql)
+		}
+	}
+}
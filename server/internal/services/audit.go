@@ -0,0 +1,335 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/hostnote/server/internal/fieldcrypt"
+	"github.com/hostnote/server/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 監査ログに記録するテーブル（顧客データと店舗のマスタ）
+var auditedTables = map[string]bool{
+	"hime":            true,
+	"cast":            true,
+	"table_record":    true,
+	"table_order":     true,
+	"schedule":        true,
+	"visit_record":    true,
+	"menu":            true,
+	"setting":         true,
+	"pricing_rule":    true,
+	"payment":         true,
+	"issued_document": true,
+}
+
+// 差分に含めないカラム
+var auditIgnoredColumns = map[string]bool{
+	"created_at": true,
+	"updated_at": true,
+}
+
+// 操作ユーザーを取得するコンテキストのキー
+// ハンドラーでは db.WithContext(c) とすることで、gin.Context に保存された値が参照される
+const (
+	auditActorKey  = "userID"
+	auditStoreKey  = "storeID"
+	auditBeforeKey = "audit:before"
+)
+
+// RegisterAuditCallbacks 作成・更新・削除時に監査ログを書き込むGORMコールバックを登録
+// 操作ユーザーがコンテキストにない処理（シード、スケジューラー等）は記録しない
+func RegisterAuditCallbacks(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().After("gorm:create").Register("audit:after_create", auditAfterCreate); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("audit:before_update", auditBeforeChange); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("audit:after_update", auditAfterUpdate); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("audit:before_delete", auditBeforeChange); err != nil {
+		return err
+	}
+	return cb.Delete().After("gorm:delete").Register("audit:after_delete", auditAfterDelete)
+}
+
+// auditAfterCreate 作成されたレコードを記録
+func auditAfterCreate(db *gorm.DB) {
+	actorID, ok := auditTarget(db)
+	if !ok || db.Error != nil || db.RowsAffected == 0 {
+		return
+	}
+
+	for _, pk := range createdPrimaryKeys(db.Statement) {
+		rows := auditFetch(db, pk)
+		if len(rows) == 0 {
+			continue
+		}
+		writeAuditLog(db, actorID, models.AuditActionCreate, pk, diffRows(nil, rows[0]))
+	}
+}
+
+// auditBeforeChange 更新・削除の対象レコードを変更前の状態で取得しておく
+func auditBeforeChange(db *gorm.DB) {
+	if _, ok := auditTarget(db); !ok || db.Error != nil {
+		return
+	}
+
+	query, ok := auditConditions(db)
+	if !ok {
+		return
+	}
+	var rows []map[string]interface{}
+	if err := query.Find(&rows).Error; err != nil {
+		log.Printf("audit: failed to load rows before change on %s: %v", db.Statement.Table, err)
+		return
+	}
+	db.InstanceSet(auditBeforeKey, rows)
+}
+
+// auditAfterUpdate 変更前後の差分を記録
+func auditAfterUpdate(db *gorm.DB) {
+	actorID, ok := auditTarget(db)
+	if !ok || db.Error != nil || db.RowsAffected == 0 {
+		return
+	}
+
+	for _, before := range auditBeforeRows(db) {
+		pk := rowPrimaryKey(db.Statement, before)
+		after := auditFetch(db, pk)
+		if len(after) == 0 {
+			continue
+		}
+		if changes := diffRows(before, after[0]); len(changes) > 0 {
+			writeAuditLog(db, actorID, models.AuditActionUpdate, pk, changes)
+		}
+	}
+}
+
+// auditAfterDelete 削除されたレコードを記録
+func auditAfterDelete(db *gorm.DB) {
+	actorID, ok := auditTarget(db)
+	if !ok || db.Error != nil || db.RowsAffected == 0 {
+		return
+	}
+
+	for _, before := range auditBeforeRows(db) {
+		writeAuditLog(db, actorID, models.AuditActionDelete, rowPrimaryKey(db.Statement, before), diffRows(before, nil))
+	}
+}
+
+// auditTarget 監査対象のテーブルかつ操作ユーザーが分かる場合にユーザーIDを返す
+func auditTarget(db *gorm.DB) (uint, bool) {
+	stmt := db.Statement
+	if stmt.Schema == nil || !auditedTables[stmt.Table] {
+		return 0, false
+	}
+	return contextUint(stmt.Context, auditActorKey)
+}
+
+// auditConditions 更新・削除対象を取得するクエリを組み立てる（WHERE句とモデルの主キー）
+func auditConditions(db *gorm.DB) (*gorm.DB, bool) {
+	stmt := db.Statement
+	query := db.Session(&gorm.Session{NewDB: true}).Table(stmt.Table)
+	conditioned := false
+
+	if c, ok := stmt.Clauses["WHERE"]; ok {
+		if where, ok := c.Expression.(clause.Where); ok && len(where.Exprs) > 0 {
+			query = query.Clauses(where)
+			conditioned = true
+		}
+	}
+	if stmt.ReflectValue.Kind() == reflect.Struct {
+		if pk := structPrimaryKey(stmt, stmt.ReflectValue); pk != nil {
+			query = query.Where(pk)
+			conditioned = true
+		}
+	}
+	return query, conditioned
+}
+
+// auditFetch 主キーでレコードを取得（呼び出し元と同じトランザクションで実行）
+func auditFetch(db *gorm.DB, pk map[string]interface{}) []map[string]interface{} {
+	if len(pk) == 0 {
+		return nil
+	}
+	var rows []map[string]interface{}
+	if err := db.Session(&gorm.Session{NewDB: true}).Table(db.Statement.Table).Where(pk).Find(&rows).Error; err != nil {
+		log.Printf("audit: failed to load row from %s: %v", db.Statement.Table, err)
+		return nil
+	}
+	return rows
+}
+
+// auditBeforeRows 更新・削除前に取得したレコード
+func auditBeforeRows(db *gorm.DB) []map[string]interface{} {
+	v, ok := db.InstanceGet(auditBeforeKey)
+	if !ok {
+		return nil
+	}
+	rows, _ := v.([]map[string]interface{})
+	return rows
+}
+
+// createdPrimaryKeys 作成したレコード（一括作成を含む）の主キー
+func createdPrimaryKeys(stmt *gorm.Statement) []map[string]interface{} {
+	rv := reflect.Indirect(stmt.ReflectValue)
+	switch rv.Kind() {
+	case reflect.Struct:
+		if pk := structPrimaryKey(stmt, rv); pk != nil {
+			return []map[string]interface{}{pk}
+		}
+	case reflect.Slice, reflect.Array:
+		pks := make([]map[string]interface{}, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			if pk := structPrimaryKey(stmt, reflect.Indirect(rv.Index(i))); pk != nil {
+				pks = append(pks, pk)
+			}
+		}
+		return pks
+	}
+	return nil
+}
+
+// structPrimaryKey 構造体の主キー（ゼロ値を含む場合はnil）
+func structPrimaryKey(stmt *gorm.Statement, rv reflect.Value) map[string]interface{} {
+	if len(stmt.Schema.PrimaryFields) == 0 || rv.Kind() != reflect.Struct || rv.Type() != stmt.Schema.ModelType {
+		return nil
+	}
+	pk := make(map[string]interface{}, len(stmt.Schema.PrimaryFields))
+	for _, field := range stmt.Schema.PrimaryFields {
+		value, zero := field.ValueOf(stmt.Context, rv)
+		if zero {
+			return nil
+		}
+		pk[field.DBName] = value
+	}
+	return pk
+}
+
+// rowPrimaryKey 取得したレコードの主キー
+func rowPrimaryKey(stmt *gorm.Statement, row map[string]interface{}) map[string]interface{} {
+	pk := make(map[string]interface{}, len(stmt.Schema.PrimaryFields))
+	for _, field := range stmt.Schema.PrimaryFields {
+		pk[field.DBName] = row[field.DBName]
+	}
+	return pk
+}
+
+// writeAuditLog 監査ログを書き込む（失敗しても元の操作は妨げない）
+func writeAuditLog(db *gorm.DB, actorID uint, action string, pk map[string]interface{}, changes models.AuditChanges) {
+	entry := models.AuditLog{
+		StoreID:  auditStoreID(db, actorID),
+		ActorID:  &actorID,
+		Entity:   db.Statement.Table,
+		EntityID: formatPrimaryKey(db.Statement, pk),
+		Action:   action,
+		Changes:  changes,
+	}
+	if err := db.Session(&gorm.Session{NewDB: true}).Create(&entry).Error; err != nil {
+		log.Printf("audit: failed to write audit log for %s %s: %v", entry.Entity, entry.EntityID, err)
+	}
+}
+
+// auditStoreID 操作ユーザーの所属店舗（コンテキストになければDBから取得）
+func auditStoreID(db *gorm.DB, actorID uint) *uint {
+	if storeID, ok := contextUint(db.Statement.Context, auditStoreKey); ok {
+		return &storeID
+	}
+	var member models.StoreMember
+	if err := db.Session(&gorm.Session{NewDB: true}).Where("user_id = ?", actorID).Take(&member).Error; err != nil {
+		return nil
+	}
+	return &member.StoreID
+}
+
+// formatPrimaryKey 主キーを文字列にする（複合キーはスキーマ定義順に":"で連結）
+func formatPrimaryKey(stmt *gorm.Statement, pk map[string]interface{}) string {
+	parts := make([]string, 0, len(stmt.Schema.PrimaryFields))
+	for _, field := range stmt.Schema.PrimaryFields {
+		parts = append(parts, fmt.Sprint(normalizeAuditValue(pk[field.DBName])))
+	}
+	return strings.Join(parts, ":")
+}
+
+// diffRows 変更前後のレコードから変更のあったカラムを抽出（beforeまたはafterがnilなら全カラム）
+func diffRows(before, after map[string]interface{}) models.AuditChanges {
+	changes := models.AuditChanges{}
+	columns := make(map[string]bool)
+	for k := range before {
+		columns[k] = true
+	}
+	for k := range after {
+		columns[k] = true
+	}
+
+	for column := range columns {
+		if auditIgnoredColumns[column] {
+			continue
+		}
+		oldValue := normalizeAuditValue(before[column])
+		newValue := normalizeAuditValue(after[column])
+		if before != nil && after != nil && auditValueEqual(oldValue, newValue) {
+			continue
+		}
+		if before == nil && newValue == nil {
+			continue
+		}
+		changes[column] = models.AuditChange{Old: oldValue, New: newValue}
+	}
+	return changes
+}
+
+// normalizeAuditValue DBから取得した値をJSONにできる形へ変換
+func normalizeAuditValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case []byte:
+		return string(value)
+	case *interface{}:
+		if value == nil {
+			return nil
+		}
+		return normalizeAuditValue(*value)
+	case time.Time:
+		return value.UTC().Format(time.RFC3339Nano)
+	}
+	return v
+}
+
+// auditValueEqual 正規化済みの値を比較
+// 暗号化カラムは暗号文が毎回変わるため復号して比較する（ログには暗号文のまま残す）
+func auditValueEqual(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	as, bs := fmt.Sprint(a), fmt.Sprint(b)
+	if fieldcrypt.IsEncrypted(as) || fieldcrypt.IsEncrypted(bs) {
+		ap, aerr := fieldcrypt.Decrypt(as)
+		bp, berr := fieldcrypt.Decrypt(bs)
+		return aerr == nil && berr == nil && string(ap) == string(bp)
+	}
+	return as == bs
+}
+
+// contextUint コンテキストから符号なし整数の値を取得
+func contextUint(ctx context.Context, key string) (uint, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	switch v := ctx.Value(key).(type) {
+	case uint:
+		return v, v != 0
+	case int:
+		return uint(v), v > 0
+	}
+	return 0, false
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/hostnote/server/internal/models"
+)
+
+// TestDiffRows 変更前後のレコードから差分が抽出されることをテスト
+func TestDiffRows(t *testing.T) {
+	tests := []struct {
+		name   string
+		before map[string]interface{}
+		after  map[string]interface{}
+		want   models.AuditChanges
+	}{
+		{
+			name:   "更新は変更されたカラムのみ",
+			before: map[string]interface{}{"id": int64(1), "name": []byte("あいり"), "rank": "A", "updated_at": "old"},
+			after:  map[string]interface{}{"id": int64(1), "name": "あいり", "rank": "S", "updated_at": "new"},
+			want:   models.AuditChanges{"rank": {Old: "A", New: "S"}},
+		},
+		{
+			name:   "作成はnull以外の全カラム",
+			before: nil,
+			after:  map[string]interface{}{"id": int64(1), "name": "あいり", "memo": nil, "created_at": "now"},
+			want:   models.AuditChanges{"id": {New: int64(1)}, "name": {New: "あいり"}},
+		},
+		{
+			name:   "削除は全カラムの変更前の値",
+			before: map[string]interface{}{"id": int64(1), "memo": nil},
+			after:  nil,
+			want:   models.AuditChanges{"id": {Old: int64(1)}, "memo": {}},
+		},
+		{
+			name:   "nullへの変更",
+			before: map[string]interface{}{"memo": "メモ"},
+			after:  map[string]interface{}{"memo": nil},
+			want:   models.AuditChanges{"memo": {Old: "メモ"}},
+		},
+		{
+			name:   "変更なし",
+			before: map[string]interface{}{"id": int64(1), "name": "あいり"},
+			after:  map[string]interface{}{"id": int64(1), "name": "あいり"},
+			want:   models.AuditChanges{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diffRows(tt.before, tt.after)
+			if len(got) != len(tt.want) {
+				t.Fatalf("diffRows() = %v, want %v", got, tt.want)
+			}
+			for column, want := range tt.want {
+				if got[column] != want {
+					t.Errorf("diffRows()[%q] = %v, want %v", column, got[column], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/hostnote/server/internal/models"
+)
+
+// 姫ごとの負担額の決め方（SalesInfo.SplitMode）
+const (
+	SplitModeEqual  = "equal"  // 均等に割る
+	SplitModeItem   = "item"   // 注文した品目はその姫、卓全体の料金は均等に割る
+	SplitModeCustom = "custom" // 金額を指定する
+)
+
+var (
+	// ErrInvalidSplitMode 未対応の割り方
+	ErrInvalidSplitMode = errors.New("splitMode must be one of equal, item, custom")
+	// ErrAllocationHime 卓にいない姫への割り当て
+	ErrAllocationHime = errors.New("卓の姫ではない姫が指定されています")
+	// ErrAllocationMismatch 指定した負担額の合計が卓の合計と一致しない
+	ErrAllocationMismatch = errors.New("負担額の合計が卓の合計と一致しません")
+)
+
+// HimeAllocation 姫の負担額
+type HimeAllocation struct {
+	HimeID uint    `json:"himeId"`
+	Amount float64 `json:"amount"`
+}
+
+// SplitBill 卓の合計を同席した姫に割り当てる（合計は円単位で卓の合計と一致させる）
+// custom の場合は custom の金額をそのまま使い、指定のない姫は0円とする
+// 売上情報や姫がない場合は nil を返す
+func SplitBill(sales *models.SalesInfo, himeIDs []uint, custom []HimeAllocation) ([]HimeAllocation, error) {
+	if sales == nil || len(himeIDs) == 0 {
+		return nil, nil
+	}
+	onTable := make(map[uint]bool, len(himeIDs))
+	for _, id := range himeIDs {
+		onTable[id] = true
+	}
+
+	weights := make([]float64, len(himeIDs))
+	switch sales.SplitMode {
+	case "", SplitModeEqual:
+		for i := range weights {
+			weights[i] = 1
+		}
+	case SplitModeItem:
+		// 注文した品目はその姫、それ以外（セット料金・指名料・共有の注文）は均等に割り、
+		// サービス料・税は割り当てた金額の比率で按分する
+		own := make(map[uint]float64, len(himeIDs))
+		assigned := 0.0
+		for _, item := range sales.OrderItems {
+			if item.HimeID == 0 {
+				continue
+			}
+			if !onTable[item.HimeID] {
+				return nil, fmt.Errorf("%w: himeId %d", ErrAllocationHime, item.HimeID)
+			}
+			own[item.HimeID] += item.Amount
+			assigned += item.Amount
+		}
+		shared := (sales.Subtotal + sales.ShimeiFee - assigned) / float64(len(himeIDs))
+		for i, id := range himeIDs {
+			weights[i] = own[id] + shared
+		}
+	case SplitModeCustom:
+		amounts := make(map[uint]float64, len(custom))
+		sum := 0.0
+		for _, a := range custom {
+			if !onTable[a.HimeID] {
+				return nil, fmt.Errorf("%w: himeId %d", ErrAllocationHime, a.HimeID)
+			}
+			if a.Amount < 0 || math.IsNaN(a.Amount) {
+				return nil, fmt.Errorf("%w: himeId %d", ErrAllocationMismatch, a.HimeID)
+			}
+			amounts[a.HimeID] += a.Amount
+			sum += a.Amount
+		}
+		if math.Abs(sum-sales.Total) >= 0.5 {
+			return nil, fmt.Errorf("%w（合計%.0f円、指定%.0f円）", ErrAllocationMismatch, sales.Total, sum)
+		}
+		allocations := make([]HimeAllocation, len(himeIDs))
+		for i, id := range himeIDs {
+			allocations[i] = HimeAllocation{HimeID: id, Amount: amounts[id]}
+		}
+		return allocations, nil
+	default:
+		return nil, ErrInvalidSplitMode
+	}
+
+	return distribute(sales.Total, himeIDs, weights), nil
+}
+
+// distribute 合計を重みの比率で円単位に割り、端数は最も重い姫に寄せる
+func distribute(total float64, himeIDs []uint, weights []float64) []HimeAllocation {
+	sum := 0.0
+	for _, w := range weights {
+		sum += w
+	}
+	if sum <= 0 {
+		for i := range weights {
+			weights[i] = 1
+		}
+		sum = float64(len(weights))
+	}
+
+	allocations := make([]HimeAllocation, len(himeIDs))
+	allocated, heaviest := 0.0, 0
+	for i, id := range himeIDs {
+		amount := math.Floor(total * weights[i] / sum)
+		allocations[i] = HimeAllocation{HimeID: id, Amount: amount}
+		allocated += amount
+		if weights[i] > weights[heaviest] {
+			heaviest = i
+		}
+	}
+	allocations[heaviest].Amount += total - allocated
+	return allocations
+}
+
+// HimeShares 卓の姫ごとの負担額（負担額のない卓は合計を均等に按分する）
+// 一部の姫にだけ負担額がある場合（割り勘の後に姫を追加した等）、負担額のない姫は0円とする
+func HimeShares(tableHimes []models.TableHime, total float64) map[uint]float64 {
+	allocated := false
+	for _, th := range tableHimes {
+		if th.Allocation != nil {
+			allocated = true
+			break
+		}
+	}
+
+	shares := make(map[uint]float64, len(tableHimes))
+	for _, th := range tableHimes {
+		switch {
+		case th.Allocation != nil:
+			shares[th.HimeID] = *th.Allocation
+		case allocated:
+			shares[th.HimeID] = 0
+		default:
+			shares[th.HimeID] = total / float64(len(tableHimes))
+		}
+	}
+	return shares
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hostnote/server/internal/models"
+)
+
+// splitTestSales 小計30000円（セット5000円、シャンパン20000円はあいり、ボトル5000円は共有）、合計36000円
+func splitTestSales(mode string) *models.SalesInfo {
+	return &models.SalesInfo{
+		SplitMode: mode,
+		OrderItems: []models.OrderItem{
+			{Name: "シャンパン", Quantity: 1, Amount: 20000, HimeID: 1},
+			{Name: "ボトル", Quantity: 1, Amount: 5000},
+		},
+		TableCharge: 5000,
+		Subtotal:    30000,
+		Total:       36000,
+	}
+}
+
+func allocationAmounts(allocations []HimeAllocation) map[uint]float64 {
+	amounts := make(map[uint]float64, len(allocations))
+	for _, a := range allocations {
+		amounts[a.HimeID] = a.Amount
+	}
+	return amounts
+}
+
+// TestSplitBill 割り方ごとの姫の負担額をテスト
+func TestSplitBill(t *testing.T) {
+	tests := []struct {
+		name   string
+		mode   string
+		himes  []uint
+		custom []HimeAllocation
+		want   map[uint]float64
+	}{
+		{"equal", SplitModeEqual, []uint{1, 2}, nil, map[uint]float64{1: 18000, 2: 18000}},
+		{"legacy", "", []uint{1, 2}, nil, map[uint]float64{1: 18000, 2: 18000}},
+		// 端数は最も負担の重い姫に寄せる
+		{"equal remainder", SplitModeEqual, []uint{1, 2, 3, 4, 5, 6, 7}, nil, map[uint]float64{1: 5148, 2: 5142, 3: 5142, 4: 5142, 5: 5142, 6: 5142, 7: 5142}},
+		// 共有分 10000円を半分ずつ、シャンパンはあいり → 25000:5000 で合計を按分
+		{"item", SplitModeItem, []uint{1, 2}, nil, map[uint]float64{1: 30000, 2: 6000}},
+		{"custom", SplitModeCustom, []uint{1, 2}, []HimeAllocation{{HimeID: 1, Amount: 36000}}, map[uint]float64{1: 36000, 2: 0}},
+	}
+	for _, tt := range tests {
+		allocations, err := SplitBill(splitTestSales(tt.mode), tt.himes, tt.custom)
+		if err != nil {
+			t.Errorf("%s: SplitBill: %v", tt.name, err)
+			continue
+		}
+		got := allocationAmounts(allocations)
+		for id, want := range tt.want {
+			if got[id] != want {
+				t.Errorf("%s: hime %d = %v, want %v", tt.name, id, got[id], want)
+			}
+		}
+	}
+}
+
+// TestSplitBillErrors 不正な割り当てを拒否することをテスト
+func TestSplitBillErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		mode   string
+		himes  []uint
+		custom []HimeAllocation
+		want   error
+	}{
+		{"mode", "dutch", []uint{1, 2}, nil, ErrInvalidSplitMode},
+		{"item hime", SplitModeItem, []uint{2, 3}, nil, ErrAllocationHime},
+		{"custom hime", SplitModeCustom, []uint{1, 2}, []HimeAllocation{{HimeID: 3, Amount: 36000}}, ErrAllocationHime},
+		{"custom total", SplitModeCustom, []uint{1, 2}, []HimeAllocation{{HimeID: 1, Amount: 10000}, {HimeID: 2, Amount: 10000}}, ErrAllocationMismatch},
+	}
+	for _, tt := range tests {
+		if _, err := SplitBill(splitTestSales(tt.mode), tt.himes, tt.custom); !errors.Is(err, tt.want) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	if allocations, err := SplitBill(nil, []uint{1}, nil); allocations != nil || err != nil {
+		t.Errorf("SplitBill(nil) = %v, %v, want nil", allocations, err)
+	}
+}
+
+// TestHimeShares 負担額のない卓は均等に按分し、負担額のない姫が混在する場合は0円とすることをテスト
+func TestHimeShares(t *testing.T) {
+	allocation := 30000.0
+	shares := HimeShares([]models.TableHime{{HimeID: 1, Allocation: &allocation}}, 36000)
+	if shares[1] != 30000 {
+		t.Errorf("shares[1] = %v, want 30000", shares[1])
+	}
+
+	shares = HimeShares([]models.TableHime{{HimeID: 1}, {HimeID: 2}}, 36000)
+	if shares[1] != 18000 || shares[2] != 18000 {
+		t.Errorf("shares = %v, want 18000 each", shares)
+	}
+
+	// 負担額のある姫とない姫が混在する場合、合計を超えて按分しない
+	shares = HimeShares([]models.TableHime{{HimeID: 1, Allocation: &allocation}, {HimeID: 2}}, 30000)
+	if shares[1] != 30000 || shares[2] != 0 {
+		t.Errorf("mixed shares = %v, want 30000 and 0", shares)
+	}
+}
@@ -0,0 +1,326 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/hostnote/server/internal/models"
+	"gorm.io/gorm"
+)
+
+// 来店区分
+const (
+	VisitTypeNormal = "normal"
+	VisitTypeFirst  = "first"
+	VisitTypeShimei = "shimei"
+)
+
+// billingTolerance クライアントの金額と一致とみなす差（端数処理の違いを許容する）
+const billingTolerance = 0.5
+
+var (
+	// ErrUnknownMenuItem 注文に店舗のメニューにない品目が含まれている
+	ErrUnknownMenuItem = errors.New("unknown menu item")
+	// ErrInvalidOrderQuantity 注文の数量が不正
+	ErrInvalidOrderQuantity = errors.New("order quantity must be positive")
+	// ErrInvalidVisitType 未対応の来店区分
+	ErrInvalidVisitType = errors.New("visitType must be one of normal, first, shimei")
+	// ErrInvalidStayHours 滞在時間が不正
+	ErrInvalidStayHours = errors.New("stayHours must not be negative")
+	// ErrInvalidPricingRule 料金ルールの内容が不正
+	ErrInvalidPricingRule = errors.New("invalid pricing rule")
+	// ErrBillMismatch クライアントが計算した金額がサーバーの計算と一致しない
+	ErrBillMismatch = errors.New("sales amounts do not match the server calculation")
+)
+
+// DefaultPricingRule 料金ルールを登録していない店舗の既定値
+// 従来クライアントで計算していた金額（1時間1000円、指名料2000円、税10%）と同じになる
+func DefaultPricingRule(storeID uint) *models.PricingRule {
+	return &models.PricingRule{
+		StoreID:          storeID,
+		SetMinutes:       60,
+		SetPriceNormal:   1000,
+		SetPriceFirst:    1000,
+		SetPriceShimei:   1000,
+		ExtensionMinutes: 60,
+		ExtensionPrice:   1000,
+		ShimeiFee:        2000,
+		TaxRate:          10,
+		RoundingMode:     models.RoundingRound,
+		RoundingUnit:     1,
+		Surcharges:       models.PricingSurcharges{},
+	}
+}
+
+// LoadPricingRule 店舗の最新の料金ルールを取得（未登録の場合は既定値）
+func LoadPricingRule(ctx context.Context, db *gorm.DB, storeID uint) (*models.PricingRule, error) {
+	var rules []models.PricingRule
+	if err := db.WithContext(ctx).Where("store_id = ?", storeID).Order("version DESC").Limit(1).Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return DefaultPricingRule(storeID), nil
+	}
+	return &rules[0], nil
+}
+
+// LoadPricingRuleVersion 店舗の指定したバージョンの料金ルールを取得（0は未登録の店舗の既定値）
+func LoadPricingRuleVersion(ctx context.Context, db *gorm.DB, storeID uint, version int) (*models.PricingRule, error) {
+	if version == 0 {
+		return DefaultPricingRule(storeID), nil
+	}
+	var rule models.PricingRule
+	if err := db.WithContext(ctx).Where("store_id = ? AND version = ?", storeID, version).First(&rule).Error; err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// ValidatePricingRule 料金ルールの内容を確認する
+func ValidatePricingRule(rule *models.PricingRule) error {
+	switch {
+	case rule.SetMinutes <= 0:
+		return fmt.Errorf("%w: setMinutes must be positive", ErrInvalidPricingRule)
+	case rule.ExtensionMinutes < 0:
+		return fmt.Errorf("%w: extensionMinutes must not be negative", ErrInvalidPricingRule)
+	case rule.SetPriceNormal < 0 || rule.SetPriceFirst < 0 || rule.SetPriceShimei < 0 || rule.ExtensionPrice < 0 || rule.ShimeiFee < 0:
+		return fmt.Errorf("%w: prices must not be negative", ErrInvalidPricingRule)
+	case rule.ServiceChargeRate < 0 || rule.ServiceChargeRate > 100:
+		return fmt.Errorf("%w: serviceChargeRate must be between 0 and 100", ErrInvalidPricingRule)
+	case rule.TaxRate < 0 || rule.TaxRate > 100:
+		return fmt.Errorf("%w: taxRate must be between 0 and 100", ErrInvalidPricingRule)
+	case rule.RoundingUnit < 1:
+		return fmt.Errorf("%w: roundingUnit must be at least 1", ErrInvalidPricingRule)
+	}
+	switch rule.RoundingMode {
+	case models.RoundingRound, models.RoundingFloor, models.RoundingCeil:
+	default:
+		return fmt.Errorf("%w: roundingMode must be one of round, floor, ceil", ErrInvalidPricingRule)
+	}
+	for _, s := range rule.Surcharges {
+		_, startErr := parseClock(s.Start)
+		_, endErr := parseClock(s.End)
+		if startErr != nil || endErr != nil {
+			return fmt.Errorf("%w: surcharge %q must have start and end in HH:MM", ErrInvalidPricingRule, s.Name)
+		}
+		if s.Rate < 0 || s.Amount < 0 {
+			return fmt.Errorf("%w: surcharge %q must not be negative", ErrInvalidPricingRule, s.Name)
+		}
+	}
+	return nil
+}
+
+// CalculatePrice 注文と来店区分・滞在時間・入店日時から、料金ルールで会計を計算する
+// 注文の単価はメニュー（menus）から決め、クライアントが送った単価・金額は使わない
+// （接客中の卓の注文で単価を確定済みのものは、その単価を使う）
+// メニューIDのない注文は品名でメニューを探す（メニューID導入前のクライアントとの互換性のため）
+// 滞在時間が0の場合はセット料金をかけない（注文だけの記録）
+func CalculatePrice(rule *models.PricingRule, order *models.SalesInfo, menus []models.Menu, at time.Time) (*models.SalesInfo, error) {
+	visitType := order.VisitType
+	if visitType == "" {
+		visitType = VisitTypeNormal
+	}
+	var setPrice float64
+	switch visitType {
+	case VisitTypeNormal:
+		setPrice = rule.SetPriceNormal
+	case VisitTypeFirst:
+		setPrice = rule.SetPriceFirst
+	case VisitTypeShimei:
+		setPrice = rule.SetPriceShimei
+	default:
+		return nil, ErrInvalidVisitType
+	}
+	if order.StayHours < 0 || math.IsNaN(order.StayHours) {
+		return nil, ErrInvalidStayHours
+	}
+
+	byID := make(map[uint]models.Menu, len(menus))
+	byName := make(map[string]models.Menu, len(menus))
+	for _, m := range menus {
+		byID[m.ID] = m
+		byName[m.Name] = m
+	}
+
+	bill := &models.SalesInfo{
+		VisitType:          visitType,
+		StayHours:          order.StayHours,
+		SplitMode:          order.SplitMode,
+		OrderItems:         make([]models.OrderItem, 0, len(order.OrderItems)),
+		ServiceChargeRate:  rule.ServiceChargeRate,
+		TaxRate:            rule.TaxRate,
+		PricingRuleID:      rule.ID,
+		PricingRuleVersion: rule.Version,
+	}
+	itemsTotal := 0.0
+	for _, item := range order.OrderItems {
+		if item.Quantity <= 0 {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidOrderQuantity, item.Name)
+		}
+		menu, ok := byID[item.MenuID]
+		if item.MenuID == 0 {
+			menu, ok = byName[item.Name]
+		}
+		if !ok {
+			if item.MenuID != 0 {
+				return nil, fmt.Errorf("%w: menuId %d", ErrUnknownMenuItem, item.MenuID)
+			}
+			return nil, fmt.Errorf("%w: %s", ErrUnknownMenuItem, item.Name)
+		}
+		unitPrice := menu.Price
+		if item.FixedUnitPrice != nil {
+			unitPrice = *item.FixedUnitPrice
+		}
+		amount := unitPrice * float64(item.Quantity)
+		bill.OrderItems = append(bill.OrderItems, models.OrderItem{
+			MenuID:    menu.ID,
+			HimeID:    item.HimeID,
+			Name:      menu.Name,
+			Quantity:  item.Quantity,
+			UnitPrice: unitPrice,
+			Amount:    amount,
+		})
+		itemsTotal += amount
+	}
+
+	// セット料金と延長（セット時間を超えた分を延長の単位で切り上げ）
+	if minutes := int(math.Round(order.StayHours * 60)); minutes > 0 {
+		bill.SetPrice = setPrice
+		if over := minutes - rule.SetMinutes; over > 0 && rule.ExtensionMinutes > 0 {
+			bill.ExtensionCount = (over + rule.ExtensionMinutes - 1) / rule.ExtensionMinutes
+			bill.ExtensionFee = float64(bill.ExtensionCount) * rule.ExtensionPrice
+		}
+		for _, s := range rule.Surcharges {
+			if surchargeApplies(s, at) {
+				bill.Surcharge += (bill.SetPrice+bill.ExtensionFee)*s.Rate/100 + s.Amount
+			}
+		}
+		bill.Surcharge = roundAmount(bill.Surcharge, rule)
+	}
+
+	bill.TableCharge = bill.SetPrice + bill.ExtensionFee + bill.Surcharge
+	bill.Subtotal = bill.TableCharge + itemsTotal
+	if visitType == VisitTypeShimei {
+		bill.ShimeiFee = rule.ShimeiFee
+	}
+
+	// 初回の定額料金はサービス料・税込みのため、サービス料と税の対象から除く
+	chargeable := bill.Subtotal + bill.ShimeiFee
+	if visitType == VisitTypeFirst && rule.FirstVisitFlat {
+		chargeable -= bill.SetPrice
+		bill.TaxIncluded = bill.SetPrice
+	}
+	bill.ServiceCharge = roundAmount(chargeable*rule.ServiceChargeRate/100, rule)
+	bill.Tax = roundAmount((chargeable+bill.ServiceCharge)*rule.TaxRate/100, rule)
+	bill.Total = bill.Subtotal + bill.ShimeiFee + bill.ServiceCharge + bill.Tax
+	return bill, nil
+}
+
+// CalculateBill 店舗のメニューと最新の料金ルールで会計を計算する
+func CalculateBill(ctx context.Context, db *gorm.DB, storeID uint, order *models.SalesInfo, at time.Time) (*models.SalesInfo, error) {
+	rule, err := LoadPricingRule(ctx, db, storeID)
+	if err != nil {
+		return nil, err
+	}
+	return calculateBillWithRule(ctx, db, storeID, rule, order, at)
+}
+
+// calculateBillWithRule 店舗のメニューと指定した料金ルールで会計を計算する
+func calculateBillWithRule(ctx context.Context, db *gorm.DB, storeID uint, rule *models.PricingRule, order *models.SalesInfo, at time.Time) (*models.SalesInfo, error) {
+	var menus []models.Menu
+	if len(order.OrderItems) > 0 {
+		ids := make([]uint, 0, len(order.OrderItems))
+		names := make([]string, 0, len(order.OrderItems))
+		for _, item := range order.OrderItems {
+			if item.MenuID != 0 {
+				ids = append(ids, item.MenuID)
+			} else {
+				names = append(names, item.Name)
+			}
+		}
+		query := db.WithContext(ctx).Where("store_id = ?", storeID)
+		switch {
+		case len(ids) > 0 && len(names) > 0:
+			query = query.Where(db.Where("id IN ?", ids).Or("name IN ?", names))
+		case len(ids) > 0:
+			query = query.Where("id IN ?", ids)
+		default:
+			query = query.Where("name IN ?", names)
+		}
+		// 同じ品名のメニューが複数ある場合は表示順の早いものを使う（後に読み込んだものが優先される）
+		if err := query.Order("`order` DESC, id DESC").Find(&menus).Error; err != nil {
+			return nil, err
+		}
+	}
+	return CalculatePrice(rule, order, menus, at)
+}
+
+// roundAmount 料金ルールの端数処理で金額を丸める
+func roundAmount(v float64, rule *models.PricingRule) float64 {
+	unit := float64(rule.RoundingUnit)
+	if unit < 1 {
+		unit = 1
+	}
+	switch rule.RoundingMode {
+	case models.RoundingFloor:
+		return math.Floor(v/unit) * unit
+	case models.RoundingCeil:
+		return math.Ceil(v/unit) * unit
+	default:
+		return math.Round(v/unit) * unit
+	}
+}
+
+// surchargeApplies 入店時刻が加算の時間帯に含まれるか（終了が開始より前の場合は日をまたぐ）
+func surchargeApplies(s models.PricingSurcharge, at time.Time) bool {
+	start, err := parseClock(s.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(s.End)
+	if err != nil {
+		return false
+	}
+	minute := at.Hour()*60 + at.Minute()
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// parseClock HH:MM を0時からの分に変換
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// CheckClientBill クライアントが計算して送った金額がサーバーの計算と一致するか確認する
+// 合計が0（未計算）の場合は確認しない
+func CheckClientBill(client, computed *models.SalesInfo) error {
+	if client.Total == 0 {
+		return nil
+	}
+	amounts := []struct {
+		name            string
+		client, correct float64
+	}{
+		{"tableCharge", client.TableCharge, computed.TableCharge},
+		{"shimeiFee", client.ShimeiFee, computed.ShimeiFee},
+		{"subtotal", client.Subtotal, computed.Subtotal},
+		{"tax", client.Tax, computed.Tax},
+		{"total", client.Total, computed.Total},
+	}
+	for _, a := range amounts {
+		if math.Abs(a.client-a.correct) > billingTolerance {
+			return fmt.Errorf("%w: %s is %.0f, expected %.0f", ErrBillMismatch, a.name, a.client, a.correct)
+		}
+	}
+	return nil
+}
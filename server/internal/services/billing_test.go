@@ -0,0 +1,158 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hostnote/server/internal/models"
+)
+
+var billingTestMenus = []models.Menu{
+	{ID: 1, Name: "シャンパン", Price: 30000},
+	{ID: 2, Name: "ハウスボトル", Price: 5000},
+}
+
+// billingTestRule 60分セット・30分延長・TAX20%・100円単位切り上げの料金ルール
+func billingTestRule() *models.PricingRule {
+	return &models.PricingRule{
+		ID: 3, StoreID: 1, Version: 2,
+		SetMinutes: 60, SetPriceNormal: 3000, SetPriceFirst: 1000, SetPriceShimei: 5000, FirstVisitFlat: true,
+		ExtensionMinutes: 30, ExtensionPrice: 1500,
+		ShimeiFee: 2000, ServiceChargeRate: 20, TaxRate: 10,
+		RoundingMode: models.RoundingCeil, RoundingUnit: 100,
+		Surcharges: models.PricingSurcharges{{Name: "深夜", Start: "23:00", End: "05:00", Rate: 10}},
+	}
+}
+
+// TestCalculatePrice セット・延長・時間帯の加算・サービス料・税を料金ルールで計算することをテスト
+func TestCalculatePrice(t *testing.T) {
+	order := &models.SalesInfo{
+		VisitType: VisitTypeShimei,
+		StayHours: 2,
+		OrderItems: []models.OrderItem{
+			{MenuID: 1, Name: "改ざんされた品名", Quantity: 1, UnitPrice: 1, Amount: 1},
+			{Name: "ハウスボトル", Quantity: 2}, // メニューIDのない注文は品名で探す
+		},
+	}
+	at := time.Date(2024, 5, 31, 23, 30, 0, 0, time.Local)
+
+	bill, err := CalculatePrice(billingTestRule(), order, billingTestMenus, at)
+	if err != nil {
+		t.Fatalf("CalculatePrice: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name      string
+		got, want float64
+	}{
+		{"setPrice", bill.SetPrice, 5000},
+		{"extensionCount", float64(bill.ExtensionCount), 2}, // 60分超過 ÷ 30分
+		{"extensionFee", bill.ExtensionFee, 3000},
+		{"surcharge", bill.Surcharge, 800}, // (5000 + 3000) × 10%
+		{"tableCharge", bill.TableCharge, 8800},
+		{"subtotal", bill.Subtotal, 48800}, // 8800 + 30000 + 5000×2
+		{"shimeiFee", bill.ShimeiFee, 2000},
+		{"serviceCharge", bill.ServiceCharge, 10200}, // (48800 + 2000) × 20% = 10160 → 100円単位で切り上げ
+		{"tax", bill.Tax, 6100},                      // 61000 × 10%
+		{"total", bill.Total, 67100},
+		{"pricingRuleVersion", float64(bill.PricingRuleVersion), 2},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	if got := bill.OrderItems[0]; got.Name != "シャンパン" || got.UnitPrice != 30000 || got.Amount != 30000 {
+		t.Errorf("order item = %+v, want price from menu", got)
+	}
+	if got := bill.OrderItems[1]; got.MenuID != 2 || got.Amount != 10000 {
+		t.Errorf("order item = %+v, want menuId 2 resolved by name", got)
+	}
+}
+
+// TestCalculatePriceFirstVisitFlat 初回の定額料金はサービス料・税の対象外、時間帯の外は加算しないことをテスト
+func TestCalculatePriceFirstVisitFlat(t *testing.T) {
+	order := &models.SalesInfo{VisitType: VisitTypeFirst, StayHours: 1, OrderItems: []models.OrderItem{{MenuID: 2, Quantity: 1}}}
+	bill, err := CalculatePrice(billingTestRule(), order, billingTestMenus, time.Date(2024, 5, 31, 21, 0, 0, 0, time.Local))
+	if err != nil {
+		t.Fatalf("CalculatePrice: %v", err)
+	}
+	// セット1000円（定額）＋ ボトル5000円、サービス料と税はボトルのみ
+	if bill.Surcharge != 0 || bill.ExtensionCount != 0 || bill.ServiceCharge != 1000 || bill.Tax != 600 || bill.Total != 7600 {
+		t.Errorf("unexpected bill: %+v", *bill)
+	}
+}
+
+// TestCalculatePriceDefaultRule 既定の料金ルールは従来のクライアントの計算と一致することをテスト
+func TestCalculatePriceDefaultRule(t *testing.T) {
+	order := &models.SalesInfo{VisitType: VisitTypeShimei, StayHours: 2, OrderItems: []models.OrderItem{{MenuID: 2, Quantity: 1}}}
+	computed, err := CalculatePrice(DefaultPricingRule(1), order, billingTestMenus, time.Now())
+	if err != nil {
+		t.Fatalf("CalculatePrice: %v", err)
+	}
+	client := &models.SalesInfo{TableCharge: 2000, ShimeiFee: 2000, Subtotal: 7000, Tax: 900, Total: 9900}
+	if err := CheckClientBill(client, computed); err != nil {
+		t.Errorf("CheckClientBill() = %v, want nil", err)
+	}
+}
+
+// TestCalculatePriceErrors 不正な注文を拒否することをテスト
+func TestCalculatePriceErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		order models.SalesInfo
+		want  error
+	}{
+		{"unknown menu id", models.SalesInfo{OrderItems: []models.OrderItem{{MenuID: 99, Quantity: 1}}}, ErrUnknownMenuItem},
+		{"unknown name", models.SalesInfo{OrderItems: []models.OrderItem{{Name: "水", Quantity: 1}}}, ErrUnknownMenuItem},
+		{"zero quantity", models.SalesInfo{OrderItems: []models.OrderItem{{MenuID: 1, Quantity: 0}}}, ErrInvalidOrderQuantity},
+		{"visit type", models.SalesInfo{VisitType: "vip"}, ErrInvalidVisitType},
+		{"stay hours", models.SalesInfo{StayHours: -1}, ErrInvalidStayHours},
+	}
+	for _, tt := range tests {
+		if _, err := CalculatePrice(DefaultPricingRule(1), &tt.order, billingTestMenus, time.Now()); !errors.Is(err, tt.want) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+// TestValidatePricingRule 料金ルールの検証をテスト
+func TestValidatePricingRule(t *testing.T) {
+	if err := ValidatePricingRule(billingTestRule()); err != nil {
+		t.Errorf("ValidatePricingRule() = %v, want nil", err)
+	}
+	for name, modify := range map[string]func(*models.PricingRule){
+		"set minutes":   func(r *models.PricingRule) { r.SetMinutes = 0 },
+		"negative fee":  func(r *models.PricingRule) { r.ShimeiFee = -1 },
+		"service rate":  func(r *models.PricingRule) { r.ServiceChargeRate = 120 },
+		"rounding mode": func(r *models.PricingRule) { r.RoundingMode = "bankers" },
+		"rounding unit": func(r *models.PricingRule) { r.RoundingUnit = 0 },
+		"surcharge":     func(r *models.PricingRule) { r.Surcharges[0].End = "25:00" },
+	} {
+		rule := billingTestRule()
+		modify(rule)
+		if err := ValidatePricingRule(rule); !errors.Is(err, ErrInvalidPricingRule) {
+			t.Errorf("%s: error = %v, want ErrInvalidPricingRule", name, err)
+		}
+	}
+}
+
+// TestCheckClientBill クライアントの金額がサーバーの計算と異なる場合に拒否することをテスト
+func TestCheckClientBill(t *testing.T) {
+	computed := &models.SalesInfo{TableCharge: 2000, Subtotal: 7000, Tax: 700, Total: 7700}
+
+	client := &models.SalesInfo{TableCharge: 2000, Subtotal: 7000, Tax: 700, Total: 7700}
+	if err := CheckClientBill(client, computed); err != nil {
+		t.Errorf("CheckClientBill() = %v, want nil", err)
+	}
+
+	client.Total = 100
+	if err := CheckClientBill(client, computed); !errors.Is(err, ErrBillMismatch) {
+		t.Errorf("CheckClientBill() = %v, want ErrBillMismatch", err)
+	}
+
+	// 合計を送らない場合は確認しない
+	if err := CheckClientBill(&models.SalesInfo{}, computed); err != nil {
+		t.Errorf("CheckClientBill() = %v, want nil", err)
+	}
+}
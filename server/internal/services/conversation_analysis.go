@@ -0,0 +1,94 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// conversationChatLogMaxRunes AIに渡すチャットログの最大文字数（超えた分は古い方から省略する）
+const conversationChatLogMaxRunes = 8000
+
+// ConversationAnalysisInput は会話分析に必要な入力情報
+type ConversationAnalysisInput struct {
+	SelfProfile    string
+	PartnerProfile string
+	Goal           string
+	ExtraInfo      string
+	ChatLog        string
+}
+
+// ConversationAnalysisRequest 会話分析のプロンプトを組み立てる
+// チャットログがLINEのトーク履歴として読み込めた場合は、整えた履歴と送信者ごとの集計を渡し、集計も返す
+func ConversationAnalysisRequest(input ConversationAnalysisInput) (LLMRequest, *ChatStats) {
+	systemPrompt := `あなたは日本のホストクラブで働くホスト向けの、トップクラスの会話コンサルタントです。
+与えられた「自分（ホスト）」と「相手（姫）」の情報、およびLINEなどのチャットログをもとに、
+・今どんな関係性か（現状）
+・相手のライフスタイルや性格傾向
+・こちらへの好意度・興味度
+・どの時間帯・タイミングが連絡しやすいか
+・相手がどんな言葉や接し方を求めていそうか
+・これからどう動くと距離を縮めやすいか（具体的なアクションプラン）
+を、「ホスト目線」でわかりやすく日本語で出力してください。
+
+出力は以下のセクション構成でお願いします：
+
+1. 現在の関係性の分析
+2. 相手のライフスタイル・性格の仮説
+3. 好意度・信頼度の評価（5段階などで）
+4. 連絡しやすい時間帯・頻度の提案
+5. 相手が言われたい言葉・喜びそうな話題
+6. 今後の具体的な立ち回り方（LINEの送り方・会話例・注意点）
+
+ホストが読みやすいように、箇条書きと短めの文章で、ポジティブかつ現実的なアドバイスをしてください。`
+
+	chatLog := truncateChatLog(input.ChatLog, conversationChatLogMaxRunes)
+	var stats *ChatStats
+	if chat, ok := ParseLINEChat(input.ChatLog); ok {
+		stats = chat.Stats()
+		chatLog = chat.Transcript(conversationChatLogMaxRunes)
+	}
+
+	userContent := fmt.Sprintf(
+		"【自分（ホスト）の情報】\n%s\n\n【相手（姫）の情報】\n%s\n\n【この状況からどうしたいか・目標】\n%s\n\n【その他の補足情報】\n%s\n\n",
+		input.SelfProfile,
+		input.PartnerProfile,
+		emptyIfTooShort(input.Goal),
+		input.ExtraInfo,
+	)
+	if stats != nil {
+		userContent += "【トーク履歴の集計（送信者ごと）】\n" + stats.Summary() + "\n\n"
+	}
+	userContent += "【チャットログ（古い順に上から）】\n" + chatLog + "\n"
+
+	return LLMRequest{
+		Messages: []LLMMessage{
+			{Role: LLMRoleSystem, Content: systemPrompt},
+			{Role: LLMRoleUser, Content: userContent},
+		},
+		Temperature: 0.8,
+		MaxTokens:   1200,
+	}, stats
+}
+
+// truncateChatLog 長いチャットログは直近の maxRunes 文字だけ残す（行の途中からにならないようにする）
+func truncateChatLog(s string, maxRunes int) string {
+	s = strings.TrimSpace(s)
+	if utf8.RuneCountInString(s) <= maxRunes {
+		return s
+	}
+	runes := []rune(s)
+	tail := string(runes[len(runes)-maxRunes:])
+	if i := strings.Index(tail, "\n"); i >= 0 && i < len(tail)-1 {
+		tail = tail[i+1:]
+	}
+	return "（前略）\n" + tail
+}
+
+// emptyIfTooShort は空に近い文字列を空文字として扱うヘルパー
+func emptyIfTooShort(s string) string {
+	if len([]rune(s)) < 3 {
+		return "（特になし）"
+	}
+	return s
+}
@@ -0,0 +1,772 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hostnote/server/internal/models"
+	"gorm.io/gorm"
+)
+
+// 分析の種類（フロントエンドの analysisTypeOptions と対応）
+const (
+	AnalysisTypeGeneral        = "general"        // 総合分析
+	AnalysisTypeSales          = "sales"          // 売上分析
+	AnalysisTypeVisit          = "visit"          // 来店分析
+	AnalysisTypeRecommendation = "recommendation" // AI推奨事項
+)
+
+// 分析期間（フロントエンドの periodOptions と対応）
+const (
+	AnalysisPeriodWeek  = "week"
+	AnalysisPeriodMonth = "month"
+	AnalysisPeriodYear  = "year"
+)
+
+var (
+	// ErrInvalidAnalysisType 未対応の分析の種類
+	ErrInvalidAnalysisType = errors.New("analysisType must be one of general, sales, visit, recommendation")
+	// ErrInvalidAnalysisPeriod 未対応の分析期間
+	ErrInvalidAnalysisPeriod = errors.New("period must be one of week, month, year")
+)
+
+// プロンプトに含める件数の上限（トークン数を抑えるため）
+const (
+	analysisMaxTables    = 30
+	analysisMaxVisits    = 30
+	analysisMaxMemos     = 10
+	analysisMaxRanking   = 10
+	analysisMemoMaxRunes = 200
+	analysisDormantDays  = 30 // この日数以上来店がない姫を離脱リスクとみなす
+)
+
+// CustomerAnalysisInput 顧客分析の対象
+type CustomerAnalysisInput struct {
+	UserID       uint
+	HimeID       *uint // nilの場合は担当する姫全体を分析
+	AnalysisType string
+	Period       string
+	Now          time.Time
+}
+
+// AnalysisSummary 分析対象期間の集計値
+type AnalysisSummary struct {
+	From               time.Time  `json:"from"`
+	To                 time.Time  `json:"to"`
+	TableCount         int        `json:"tableCount"`
+	VisitCount         int        `json:"visitCount"`
+	HimeCount          int        `json:"himeCount"`
+	TotalSales         float64    `json:"totalSales"`
+	AverageSpend       float64    `json:"averageSpend"` // 1卓あたり
+	PreviousTableCount int        `json:"previousTableCount"`
+	PreviousTotalSales float64    `json:"previousTotalSales"` // 直前の同じ長さの期間
+	LastVisitAt        *time.Time `json:"lastVisitAt,omitempty"`
+}
+
+// CustomerAnalysis 分析用に集めたデータ
+type CustomerAnalysis struct {
+	Input   CustomerAnalysisInput
+	Summary AnalysisSummary
+
+	hime          *models.Hime
+	tantoCastName string
+	tables        []analysisTable
+	visits        []models.VisitRecord
+	schedules     []models.Schedule
+	himeNames     map[uint]string
+	topHimes      []himeStat
+	dormantHimes  []himeStat
+	topItems      []itemStat
+	weekdayCounts [7]int
+	hourCounts    [24]int
+}
+
+// analysisTable 卓記録（同席した姫・キャスト名付き）
+type analysisTable struct {
+	record models.TableRecord
+	himes  []string
+	casts  []string
+	share  float64          // 分析対象の姫の負担額
+	shares map[uint]float64 // 同席した姫ごとの負担額（卓の姫の負担額、未設定の卓は均等に按分）
+}
+
+type himeStat struct {
+	name       string
+	sales      float64
+	tableCount int
+	lastVisit  time.Time
+}
+
+type itemStat struct {
+	name     string
+	quantity int
+	amount   float64
+}
+
+// AnalysisPeriodRange 分析期間の開始・終了日時（終了は含まない）
+func AnalysisPeriodRange(period string, now time.Time) (time.Time, time.Time, error) {
+	switch period {
+	case AnalysisPeriodWeek:
+		return now.AddDate(0, 0, -7), now, nil
+	case "", AnalysisPeriodMonth:
+		return now.AddDate(0, -1, 0), now, nil
+	case AnalysisPeriodYear:
+		return now.AddDate(-1, 0, 0), now, nil
+	}
+	return time.Time{}, time.Time{}, ErrInvalidAnalysisPeriod
+}
+
+// ValidAnalysisType 対応している分析の種類か
+func ValidAnalysisType(analysisType string) bool {
+	switch analysisType {
+	case AnalysisTypeGeneral, AnalysisTypeSales, AnalysisTypeVisit, AnalysisTypeRecommendation:
+		return true
+	}
+	return false
+}
+
+// CollectCustomerAnalysis 姫（または担当する姫全体）の来店・卓記録・売上・メモ・好みを集める
+// 指定した姫が存在しない場合は gorm.ErrRecordNotFound を返す
+func CollectCustomerAnalysis(ctx context.Context, db *gorm.DB, input CustomerAnalysisInput) (*CustomerAnalysis, error) {
+	if !ValidAnalysisType(input.AnalysisType) {
+		return nil, ErrInvalidAnalysisType
+	}
+	if input.Now.IsZero() {
+		input.Now = time.Now()
+	}
+	from, to, err := AnalysisPeriodRange(input.Period, input.Now)
+	if err != nil {
+		return nil, err
+	}
+	db = db.WithContext(ctx)
+
+	a := &CustomerAnalysis{Input: input, Summary: AnalysisSummary{From: from, To: to}, himeNames: map[uint]string{}}
+
+	if input.HimeID != nil {
+		var hime models.Hime
+		if err := db.Where("user_id = ? AND id = ?", input.UserID, *input.HimeID).First(&hime).Error; err != nil {
+			return nil, err
+		}
+		a.hime = &hime
+		if hime.TantoCastID != nil {
+			var cast models.Cast
+			if err := db.Select("id, name").First(&cast, *hime.TantoCastID).Error; err == nil {
+				a.tantoCastName = cast.Name
+			}
+		}
+	}
+
+	var himes []models.Hime
+	if err := db.Select("id, name").Where("user_id = ?", input.UserID).Find(&himes).Error; err != nil {
+		return nil, err
+	}
+	for _, h := range himes {
+		a.himeNames[h.ID] = string(h.Name)
+	}
+
+	// 期間内の卓記録と、直前の同じ長さの期間の売上
+	records, err := a.findTables(db, from, to)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := a.findTables(db, from.Add(-to.Sub(from)), from)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.loadTables(db, records); err != nil {
+		return nil, err
+	}
+	for _, t := range a.tables {
+		a.Summary.TotalSales += t.share
+	}
+	a.Summary.TableCount = len(a.tables)
+	if a.Summary.TableCount > 0 {
+		a.Summary.AverageSpend = a.Summary.TotalSales / float64(a.Summary.TableCount)
+	}
+	prevTables, err := a.tablesWithShare(db, previous)
+	if err != nil {
+		return nil, err
+	}
+	a.Summary.PreviousTableCount = len(prevTables)
+	for _, t := range prevTables {
+		a.Summary.PreviousTotalSales += t.share
+	}
+
+	// 期間内の来店記録
+	visitQuery := db.Where("user_id = ? AND visit_date >= ? AND visit_date < ?", input.UserID, from, to)
+	if input.HimeID != nil {
+		visitQuery = visitQuery.Where("hime_id = ?", *input.HimeID)
+	}
+	if err := visitQuery.Order("visit_date DESC").Find(&a.visits).Error; err != nil {
+		return nil, err
+	}
+	a.Summary.VisitCount = len(a.visits)
+
+	// 今後の予定
+	scheduleQuery := db.Where("user_id = ? AND scheduled_datetime >= ?", input.UserID, input.Now)
+	if input.HimeID != nil {
+		scheduleQuery = scheduleQuery.Where("hime_id = ?", *input.HimeID)
+	}
+	if err := scheduleQuery.Order("scheduled_datetime").Limit(10).Find(&a.schedules).Error; err != nil {
+		return nil, err
+	}
+
+	// 最終来店日（期間に関係なく、卓記録と来店記録の新しい方）
+	lastVisits, err := a.lastVisits(db)
+	if err != nil {
+		return nil, err
+	}
+	if input.HimeID != nil {
+		if last, ok := lastVisits[*input.HimeID]; ok {
+			a.Summary.LastVisitAt = &last
+		}
+	}
+
+	a.aggregate(lastVisits)
+	return a, nil
+}
+
+// findTables 期間内の会計済みの卓記録（姫を指定した場合はその姫が同席した卓のみ）
+func (a *CustomerAnalysis) findTables(db *gorm.DB, from, to time.Time) ([]models.TableRecord, error) {
+	query := db.Model(&models.TableRecord{}).
+		Where("table_record.user_id = ? AND table_record.datetime >= ? AND table_record.datetime < ?", a.Input.UserID, from, to).
+		Where("table_record.status = ?", models.TableStatusClosed)
+	if a.Input.HimeID != nil {
+		query = query.Where("table_record.id IN (?)",
+			db.Model(&models.TableHime{}).Select("table_id").Where("hime_id = ?", *a.Input.HimeID))
+	}
+	var records []models.TableRecord
+	err := query.Order("table_record.datetime DESC").Find(&records).Error
+	return records, err
+}
+
+// loadTables 卓記録に同席した姫・キャストを結合
+func (a *CustomerAnalysis) loadTables(db *gorm.DB, records []models.TableRecord) error {
+	tables, err := a.tablesWithShare(db, records)
+	if err != nil {
+		return err
+	}
+
+	tableIDs := make([]uint, len(records))
+	for i, r := range records {
+		tableIDs[i] = r.ID
+	}
+	var tableCasts []models.TableCast
+	if len(tableIDs) > 0 {
+		if err := db.Where("table_id IN ?", tableIDs).Find(&tableCasts).Error; err != nil {
+			return err
+		}
+	}
+	castIDs := make([]uint, 0, len(tableCasts))
+	for _, tc := range tableCasts {
+		castIDs = append(castIDs, tc.CastID)
+	}
+	castNames := map[uint]string{}
+	if len(castIDs) > 0 {
+		var casts []models.Cast
+		if err := db.Select("id, name").Where("id IN ?", castIDs).Find(&casts).Error; err != nil {
+			return err
+		}
+		for _, c := range casts {
+			castNames[c.ID] = c.Name
+		}
+	}
+
+	index := make(map[uint]int, len(tables))
+	for i, t := range tables {
+		index[t.record.ID] = i
+	}
+	for _, tc := range tableCasts {
+		name := castNames[tc.CastID]
+		if tc.Role == "help" {
+			name += "（ヘルプ）"
+		}
+		t := &tables[index[tc.TableID]]
+		t.casts = append(t.casts, name)
+	}
+	a.tables = tables
+	return nil
+}
+
+// tablesWithShare 卓記録ごとに同席した姫と、分析対象の負担額を求める
+// 姫全体を分析する場合は卓の合計額をそのまま使う（同じ卓を姫の数だけ数えない）
+func (a *CustomerAnalysis) tablesWithShare(db *gorm.DB, records []models.TableRecord) ([]analysisTable, error) {
+	tableIDs := make([]uint, len(records))
+	for i, r := range records {
+		tableIDs[i] = r.ID
+	}
+	var tableHimes []models.TableHime
+	if len(tableIDs) > 0 {
+		if err := db.Where("table_id IN ?", tableIDs).Find(&tableHimes).Error; err != nil {
+			return nil, err
+		}
+	}
+	himesByTable := make(map[uint][]models.TableHime)
+	for _, th := range tableHimes {
+		himesByTable[th.TableID] = append(himesByTable[th.TableID], th)
+	}
+
+	tables := make([]analysisTable, len(records))
+	for i, r := range records {
+		t := analysisTable{record: r, shares: HimeShares(himesByTable[r.ID], tableTotal(r))}
+		for _, th := range himesByTable[r.ID] {
+			t.himes = append(t.himes, a.himeNames[th.HimeID])
+		}
+		t.share = tableTotal(r)
+		if a.Input.HimeID != nil && len(himesByTable[r.ID]) > 0 {
+			t.share = t.shares[*a.Input.HimeID]
+		}
+		tables[i] = t
+	}
+	return tables, nil
+}
+
+// lastVisits 姫ごとの最終来店日時（卓記録と来店記録の新しい方）
+func (a *CustomerAnalysis) lastVisits(db *gorm.DB) (map[uint]time.Time, error) {
+	type lastVisit struct {
+		HimeID uint
+		Last   time.Time
+	}
+	var fromTables, fromVisits []lastVisit
+	if err := db.Model(&models.TableHime{}).
+		Select("table_hime.hime_id AS hime_id, MAX(table_record.datetime) AS last").
+		Joins("JOIN table_record ON table_record.id = table_hime.table_id").
+		Where("table_record.user_id = ? AND table_record.datetime < ? AND table_record.status <> ?", a.Input.UserID, a.Input.Now, models.TableStatusVoided).
+		Group("table_hime.hime_id").
+		Scan(&fromTables).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&models.VisitRecord{}).
+		Select("hime_id, MAX(visit_date) AS last").
+		Where("user_id = ? AND visit_date < ?", a.Input.UserID, a.Input.Now).
+		Group("hime_id").
+		Scan(&fromVisits).Error; err != nil {
+		return nil, err
+	}
+
+	result := make(map[uint]time.Time)
+	for _, v := range append(fromTables, fromVisits...) {
+		if v.Last.After(result[v.HimeID]) {
+			result[v.HimeID] = v.Last
+		}
+	}
+	return result, nil
+}
+
+// aggregate 姫ごとの売上・注文・曜日と時間帯の傾向を集計
+func (a *CustomerAnalysis) aggregate(lastVisits map[uint]time.Time) {
+	himeStats := map[string]*himeStat{}
+	items := map[string]*itemStat{}
+	for _, t := range a.tables {
+		dt := t.record.Datetime.In(time.Local)
+		a.weekdayCounts[dt.Weekday()]++
+		a.hourCounts[dt.Hour()]++
+
+		if t.record.SalesInfo != nil {
+			for _, item := range t.record.SalesInfo.OrderItems {
+				s, ok := items[item.Name]
+				if !ok {
+					s = &itemStat{name: item.Name}
+					items[item.Name] = s
+				}
+				s.quantity += item.Quantity
+				s.amount += item.Amount
+			}
+		}
+
+		// 姫全体の分析では、同席した姫それぞれの負担額を姫ごとの売上とする
+		if a.hime == nil {
+			for himeID, share := range t.shares {
+				name := a.himeNames[himeID]
+				s, ok := himeStats[name]
+				if !ok {
+					s = &himeStat{name: name}
+					himeStats[name] = s
+				}
+				s.sales += share
+				s.tableCount++
+			}
+		}
+	}
+
+	for _, s := range himeStats {
+		a.topHimes = append(a.topHimes, *s)
+	}
+	sort.Slice(a.topHimes, func(i, j int) bool {
+		if a.topHimes[i].sales != a.topHimes[j].sales {
+			return a.topHimes[i].sales > a.topHimes[j].sales
+		}
+		return a.topHimes[i].name < a.topHimes[j].name
+	})
+	if len(a.topHimes) > analysisMaxRanking {
+		a.topHimes = a.topHimes[:analysisMaxRanking]
+	}
+	a.Summary.HimeCount = len(himeStats)
+	if a.hime != nil {
+		a.Summary.HimeCount = 1
+	}
+
+	for _, s := range items {
+		a.topItems = append(a.topItems, *s)
+	}
+	sort.Slice(a.topItems, func(i, j int) bool {
+		if a.topItems[i].amount != a.topItems[j].amount {
+			return a.topItems[i].amount > a.topItems[j].amount
+		}
+		return a.topItems[i].name < a.topItems[j].name
+	})
+	if len(a.topItems) > analysisMaxRanking {
+		a.topItems = a.topItems[:analysisMaxRanking]
+	}
+
+	// 離脱リスク: 最終来店から一定日数以上経過した姫（最近まで来ていた順）
+	if a.hime == nil {
+		threshold := a.Input.Now.AddDate(0, 0, -analysisDormantDays)
+		for himeID, last := range lastVisits {
+			name, ok := a.himeNames[himeID]
+			if ok && last.Before(threshold) {
+				a.dormantHimes = append(a.dormantHimes, himeStat{name: name, lastVisit: last})
+			}
+		}
+		sort.Slice(a.dormantHimes, func(i, j int) bool {
+			return a.dormantHimes[i].lastVisit.After(a.dormantHimes[j].lastVisit)
+		})
+		if len(a.dormantHimes) > analysisMaxRanking {
+			a.dormantHimes = a.dormantHimes[:analysisMaxRanking]
+		}
+	}
+}
+
+// Request 集めたデータをもとに分析のプロンプトを組み立てる
+func (a *CustomerAnalysis) Request() LLMRequest {
+	return LLMRequest{
+		Messages: []LLMMessage{
+			{Role: LLMRoleSystem, Content: customerAnalysisSystemPrompt(a.Input.AnalysisType)},
+			{Role: LLMRoleUser, Content: a.Prompt()},
+		},
+		Temperature: 0.7,
+		MaxTokens:   1500,
+	}
+}
+
+// customerAnalysisSystemPrompt 分析の種類ごとの指示
+func customerAnalysisSystemPrompt(analysisType string) string {
+	base := `あなたは日本のホストクラブで働くホスト向けの顧客分析アドバイザーです。
+与えられた来店記録・卓記録・売上・メモ・好みのデータだけを根拠に、ホスト目線でわかりやすく日本語で分析してください。
+データにない数値や事実を作らないでください。データが少なく判断できない点は、その旨を短く書いてください。
+箇条書きと短めの文章で、ポジティブかつ現実的なアドバイスにしてください。
+
+出力は以下のセクション構成でお願いします：
+
+`
+	switch analysisType {
+	case AnalysisTypeSales:
+		return base + `1. 期間内の売上の概況（前の期間との比較を含む）
+2. 1卓あたりの単価と注文内容の傾向
+3. 売上を支えている要因・伸び悩んでいる要因
+4. 売上を伸ばすための具体的な施策（提案するボトル・タイミング・イベントなど）`
+	case AnalysisTypeVisit:
+		return base + `1. 来店頻度と来店間隔の傾向
+2. 来やすい曜日・時間帯
+3. リピートの状況と離脱リスク
+4. 次の来店につなげる連絡のタイミングと内容`
+	case AnalysisTypeRecommendation:
+		return base + `1. 今すぐ取るべきアクション（優先度順に3〜5個）
+2. 連絡の送り方・話題の提案（好みやメモの内容を踏まえて）
+3. 次回の来店時に用意すると喜ばれそうなもの（お酒・割物・演出など）
+4. 注意すべきポイント`
+	default:
+		return base + `1. 現状の概況（来店・売上の数値のまとめ）
+2. 来店と売上の傾向
+3. 好み・特徴から見える人物像や関係性
+4. 今後の改善提案`
+	}
+}
+
+// Prompt 分析に使うデータをプロンプト用のテキストにする
+func (a *CustomerAnalysis) Prompt() string {
+	var b strings.Builder
+	s := a.Summary
+
+	fmt.Fprintf(&b, "【分析期間】\n%s 〜 %s（%s）\n\n", formatDate(s.From), formatDate(s.To.Add(-time.Second)), periodLabel(a.Input.Period))
+
+	if a.hime != nil {
+		b.WriteString("【姫のプロフィール・好み】\n")
+		writeHimeProfile(&b, a.hime, a.tantoCastName)
+		b.WriteString("\n")
+	} else {
+		fmt.Fprintf(&b, "【対象】\n担当している姫全体（登録数: %d人）\n\n", len(a.himeNames))
+	}
+
+	b.WriteString("【期間内の集計】\n")
+	fmt.Fprintf(&b, "- 卓数: %d（前の期間: %d）\n", s.TableCount, s.PreviousTableCount)
+	fmt.Fprintf(&b, "- 売上合計: %s（前の期間: %s）\n", formatYen(s.TotalSales), formatYen(s.PreviousTotalSales))
+	fmt.Fprintf(&b, "- 1卓あたりの平均: %s\n", formatYen(s.AverageSpend))
+	fmt.Fprintf(&b, "- 来店記録: %d件\n", s.VisitCount)
+	if a.hime == nil {
+		fmt.Fprintf(&b, "- 来店した姫: %d人\n", s.HimeCount)
+	}
+	if s.LastVisitAt != nil {
+		fmt.Fprintf(&b, "- 最終来店: %s（%d日前）\n", formatDate(*s.LastVisitAt), int(a.Input.Now.Sub(*s.LastVisitAt).Hours()/24))
+	}
+	if a.hime != nil && len(a.tables) > 1 {
+		fmt.Fprintf(&b, "- 平均来店間隔: %.1f日\n", averageInterval(a.tables))
+	}
+	b.WriteString("\n")
+
+	if a.hime == nil {
+		if len(a.topHimes) > 0 {
+			b.WriteString("【売上上位の姫】\n")
+			for i, h := range a.topHimes {
+				fmt.Fprintf(&b, "%d. %s: %s（%d卓）\n", i+1, h.name, formatYen(h.sales), h.tableCount)
+			}
+			b.WriteString("\n")
+		}
+		if len(a.dormantHimes) > 0 {
+			fmt.Fprintf(&b, "【%d日以上来店がない姫】\n", analysisDormantDays)
+			for _, h := range a.dormantHimes {
+				fmt.Fprintf(&b, "- %s: 最終来店 %s\n", h.name, formatDate(h.lastVisit))
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	if len(a.topItems) > 0 {
+		b.WriteString("【よく注文されたメニュー（金額順）】\n")
+		for _, item := range a.topItems {
+			fmt.Fprintf(&b, "- %s: %d点 / %s\n", item.name, item.quantity, formatYen(item.amount))
+		}
+		b.WriteString("\n")
+	}
+
+	if len(a.tables) > 0 {
+		b.WriteString("【曜日・時間帯の傾向（卓数）】\n")
+		weekdays := []string{"日", "月", "火", "水", "木", "金", "土"}
+		var parts []string
+		for i, n := range a.weekdayCounts {
+			if n > 0 {
+				parts = append(parts, fmt.Sprintf("%s:%d", weekdays[i], n))
+			}
+		}
+		fmt.Fprintf(&b, "- 曜日: %s\n", strings.Join(parts, " "))
+		parts = parts[:0]
+		for h, n := range a.hourCounts {
+			if n > 0 {
+				parts = append(parts, fmt.Sprintf("%d時:%d", h, n))
+			}
+		}
+		fmt.Fprintf(&b, "- 時間帯: %s\n\n", strings.Join(parts, " "))
+	}
+
+	if a.hime != nil && len(a.tables) > 0 {
+		fmt.Fprintf(&b, "【卓記録（新しい順、最大%d件）】\n", analysisMaxTables)
+		for i, t := range a.tables {
+			if i >= analysisMaxTables {
+				break
+			}
+			a.writeTable(&b, t)
+		}
+		b.WriteString("\n")
+	}
+
+	if a.hime != nil && len(a.visits) > 0 {
+		fmt.Fprintf(&b, "【来店記録（新しい順、最大%d件）】\n", analysisMaxVisits)
+		for i, v := range a.visits {
+			if i >= analysisMaxVisits {
+				break
+			}
+			fmt.Fprintf(&b, "- %s", formatDateTime(v.VisitDate))
+			if v.Memo != nil && *v.Memo != "" {
+				fmt.Fprintf(&b, " メモ: %s", truncateRunes(*v.Memo, analysisMemoMaxRunes))
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+	}
+
+	if len(a.schedules) > 0 {
+		b.WriteString("【今後の予定】\n")
+		for _, sc := range a.schedules {
+			fmt.Fprintf(&b, "- %s", formatDateTime(sc.ScheduledDatetime))
+			if a.hime == nil {
+				fmt.Fprintf(&b, " %s", a.himeNames[sc.HimeID])
+			}
+			if sc.Memo != nil && *sc.Memo != "" {
+				fmt.Fprintf(&b, " メモ: %s", truncateRunes(*sc.Memo, analysisMemoMaxRunes))
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+	}
+
+	if s.TableCount == 0 && s.VisitCount == 0 {
+		b.WriteString("※期間内の卓記録・来店記録はありません。\n")
+	}
+	return b.String()
+}
+
+// writeHimeProfile 姫のプロフィール・好み・メモ
+func writeHimeProfile(b *strings.Builder, h *models.Hime, tantoCastName string) {
+	fmt.Fprintf(b, "- 名前: %s\n", string(h.Name))
+	if h.Age != nil {
+		fmt.Fprintf(b, "- 年齢: %d歳\n", *h.Age)
+	}
+	if h.Birthday != nil && *h.Birthday != "" {
+		fmt.Fprintf(b, "- 誕生日: %s\n", string(*h.Birthday))
+	}
+	if h.IsFirstVisit {
+		b.WriteString("- 初回来店の姫\n")
+	}
+	if tantoCastName != "" {
+		fmt.Fprintf(b, "- 担当キャスト: %s\n", tantoCastName)
+	}
+	preferences := []struct {
+		label string
+		value *string
+	}{
+		{"お酒の濃さ", h.DrinkPreference},
+		{"氷", h.Ice},
+		{"炭酸", h.Carbonation},
+		{"割物の好み", h.MixerPreference},
+		{"タバコの種類", h.TobaccoType},
+	}
+	for _, p := range preferences {
+		if p.value != nil && *p.value != "" {
+			fmt.Fprintf(b, "- %s: %s\n", p.label, *p.value)
+		}
+	}
+	if h.Smokes != nil {
+		if *h.Smokes {
+			b.WriteString("- タバコ: 吸う\n")
+		} else {
+			b.WriteString("- タバコ: 吸わない\n")
+		}
+	}
+
+	if len(h.Memos) > 0 {
+		memos := append(models.Memos(nil), h.Memos...)
+		sort.SliceStable(memos, func(i, j int) bool { return memos[i].CreatedAt > memos[j].CreatedAt })
+		fmt.Fprintf(b, "- メモ（新しい順、最大%d件）:\n", analysisMaxMemos)
+		for i, m := range memos {
+			if i >= analysisMaxMemos {
+				break
+			}
+			fmt.Fprintf(b, "  - %s\n", truncateRunes(m.Content, analysisMemoMaxRunes))
+		}
+	}
+}
+
+// writeTable 卓記録1件
+func (a *CustomerAnalysis) writeTable(b *strings.Builder, t analysisTable) {
+	r := t.record
+	fmt.Fprintf(b, "- %s", formatDateTime(r.Datetime))
+	if r.TableNumber != nil && *r.TableNumber != "" {
+		fmt.Fprintf(b, " 卓%s", *r.TableNumber)
+	}
+	if s := r.SalesInfo; s != nil {
+		if label := visitTypeLabel(s.VisitType); label != "" {
+			fmt.Fprintf(b, " / %s", label)
+		}
+		if s.StayHours > 0 {
+			fmt.Fprintf(b, " / %.1f時間", s.StayHours)
+		}
+	}
+	fmt.Fprintf(b, " / 合計%s", formatYen(tableTotal(r)))
+	if len(t.himes) > 1 {
+		fmt.Fprintf(b, "（%d人で割り勘、負担額: %s）", len(t.himes), formatYen(t.share))
+	}
+	if len(t.casts) > 0 {
+		fmt.Fprintf(b, " / キャスト: %s", strings.Join(t.casts, "、"))
+	}
+	if s := r.SalesInfo; s != nil && len(s.OrderItems) > 0 {
+		items := make([]string, len(s.OrderItems))
+		for i, item := range s.OrderItems {
+			items[i] = fmt.Sprintf("%s×%d", item.Name, item.Quantity)
+		}
+		fmt.Fprintf(b, " / 注文: %s", strings.Join(items, "、"))
+	}
+	if r.Memo != nil && *r.Memo != "" {
+		fmt.Fprintf(b, " / メモ: %s", truncateRunes(*r.Memo, analysisMemoMaxRunes))
+	}
+	b.WriteString("\n")
+}
+
+// tableTotal 卓の合計金額（売上情報がない場合は0）
+func tableTotal(r models.TableRecord) float64 {
+	if r.SalesInfo == nil {
+		return 0
+	}
+	return r.SalesInfo.Total
+}
+
+// averageInterval 卓記録の平均間隔（日）
+func averageInterval(tables []analysisTable) float64 {
+	newest := tables[0].record.Datetime
+	oldest := tables[len(tables)-1].record.Datetime
+	return newest.Sub(oldest).Hours() / 24 / float64(len(tables)-1)
+}
+
+func periodLabel(period string) string {
+	switch period {
+	case AnalysisPeriodWeek:
+		return "直近1週間"
+	case AnalysisPeriodYear:
+		return "直近1年"
+	}
+	return "直近1か月"
+}
+
+func visitTypeLabel(visitType string) string {
+	switch visitType {
+	case "normal":
+		return "通常"
+	case "first":
+		return "初回"
+	case "shimei":
+		return "指名あり"
+	}
+	return visitType
+}
+
+// formatYen 金額を「¥12,345」形式にする
+func formatYen(v float64) string {
+	n := int64(v + 0.5)
+	if v < 0 {
+		n = int64(v - 0.5)
+	}
+	sign := ""
+	if n < 0 {
+		sign = "-"
+		n = -n
+	}
+	digits := fmt.Sprintf("%d", n)
+	var out []byte
+	for i := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			out = append(out, ',')
+		}
+		out = append(out, digits[i])
+	}
+	return sign + "¥" + string(out)
+}
+
+func formatDate(t time.Time) string {
+	return t.In(time.Local).Format("2006-01-02")
+}
+
+func formatDateTime(t time.Time) string {
+	return t.In(time.Local).Format("2006-01-02 15:04")
+}
+
+// truncateRunes 文字数で切り詰める
+func truncateRunes(s string, max int) string {
+	r := []rune(strings.TrimSpace(s))
+	if len(r) <= max {
+		return string(r)
+	}
+	return string(r[:max]) + "…"
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hostnote/server/internal/models"
+)
+
+// TestAnalysisPeriodRange 分析期間ごとの開始日時をテスト
+func TestAnalysisPeriodRange(t *testing.T) {
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		period   string
+		wantFrom time.Time
+		wantErr  bool
+	}{
+		{"week", time.Date(2024, 3, 24, 12, 0, 0, 0, time.UTC), false},
+		{"month", time.Date(2024, 3, 2, 12, 0, 0, 0, time.UTC), false}, // 2月31日は3月2日に正規化される
+		{"", time.Date(2024, 3, 2, 12, 0, 0, 0, time.UTC), false},
+		{"year", time.Date(2023, 3, 31, 12, 0, 0, 0, time.UTC), false},
+		{"day", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		from, to, err := AnalysisPeriodRange(tt.period, now)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("AnalysisPeriodRange(%q) error = %v, wantErr %v", tt.period, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (!from.Equal(tt.wantFrom) || !to.Equal(now)) {
+			t.Errorf("AnalysisPeriodRange(%q) = %v - %v, want %v - %v", tt.period, from, to, tt.wantFrom, now)
+		}
+	}
+}
+
+// TestFormatYen 金額の表示形式をテスト
+func TestFormatYen(t *testing.T) {
+	tests := map[float64]string{
+		0:          "¥0",
+		999:        "¥999",
+		1000:       "¥1,000",
+		123456.6:   "¥123,457",
+		-1234567.0: "-¥1,234,567",
+	}
+	for v, want := range tests {
+		if got := formatYen(v); got != want {
+			t.Errorf("formatYen(%v) = %q, want %q", v, got, want)
+		}
+	}
+}
+
+// TestCustomerAnalysisPrompt 姫の分析プロンプトにプロフィール・集計・卓記録が含まれることをテスト
+func TestCustomerAnalysisPrompt(t *testing.T) {
+	now := time.Date(2024, 5, 31, 12, 0, 0, 0, time.Local)
+	himeID := uint(1)
+	drink := "濃いめ"
+	birthday := models.EncryptedString("1998-05-01")
+	tableNumber := "T-01"
+	lastVisit := now.AddDate(0, 0, -3)
+
+	a := &CustomerAnalysis{
+		Input: CustomerAnalysisInput{UserID: 1, HimeID: &himeID, AnalysisType: AnalysisTypeSales, Period: AnalysisPeriodMonth, Now: now},
+		Summary: AnalysisSummary{
+			From: now.AddDate(0, -1, 0), To: now,
+			TableCount: 2, TotalSales: 60000, AverageSpend: 30000, PreviousTotalSales: 20000, LastVisitAt: &lastVisit,
+		},
+		hime: &models.Hime{
+			ID: 1, Name: "あいり", Birthday: &birthday, DrinkPreference: &drink,
+			Memos: models.EncryptedMemos{{ID: "1", Content: "古いメモ", CreatedAt: "2024-01-01"}, {ID: "2", Content: "新しいメモ", CreatedAt: "2024-05-01"}},
+		},
+		tantoCastName: "レン",
+		tables: []analysisTable{
+			{
+				record: models.TableRecord{Datetime: lastVisit, TableNumber: &tableNumber, SalesInfo: &models.SalesInfo{
+					VisitType: "shimei", StayHours: 2, Total: 50000,
+					OrderItems: []models.OrderItem{{Name: "シャンパン", Quantity: 1, Amount: 30000}},
+				}},
+				himes: []string{"あいり", "みく"},
+				casts: []string{"レン"},
+				share: 25000,
+			},
+			{record: models.TableRecord{Datetime: now.AddDate(0, 0, -13), SalesInfo: &models.SalesInfo{Total: 35000}}, himes: []string{"あいり"}, share: 35000},
+		},
+	}
+
+	prompt := a.Prompt()
+	for _, want := range []string{
+		"名前: あいり", "誕生日: 1998-05-01", "担当キャスト: レン", "お酒の濃さ: 濃いめ",
+		"売上合計: ¥60,000（前の期間: ¥20,000）", "最終来店: 2024-05-28（3日前）", "平均来店間隔: 10.0日",
+		"卓T-01 / 指名あり / 2.0時間 / 合計¥50,000（2人で割り勘、負担額: ¥25,000）", "注文: シャンパン×1",
+	} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt does not contain %q\n%s", want, prompt)
+		}
+	}
+	if strings.Index(prompt, "新しいメモ") > strings.Index(prompt, "古いメモ") {
+		t.Error("メモは新しい順に並べる")
+	}
+
+	if system := customerAnalysisSystemPrompt(AnalysisTypeSales); !strings.Contains(system, "単価") {
+		t.Errorf("売上分析の指示が含まれていない: %s", system)
+	}
+}
+
+// TestCustomerAnalysisPromptWithoutData 記録がない場合はその旨を伝えることをテスト
+func TestCustomerAnalysisPromptWithoutData(t *testing.T) {
+	now := time.Now()
+	a := &CustomerAnalysis{
+		Input:     CustomerAnalysisInput{UserID: 1, AnalysisType: AnalysisTypeGeneral, Period: AnalysisPeriodWeek, Now: now},
+		Summary:   AnalysisSummary{From: now.AddDate(0, 0, -7), To: now},
+		himeNames: map[uint]string{1: "あいり"},
+	}
+	prompt := a.Prompt()
+	if !strings.Contains(prompt, "担当している姫全体（登録数: 1人）") || !strings.Contains(prompt, "期間内の卓記録・来店記録はありません") {
+		t.Errorf("unexpected prompt:\n%s", prompt)
+	}
+}
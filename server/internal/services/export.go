@@ -0,0 +1,664 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hostnote/server/internal/config"
+	"github.com/hostnote/server/internal/models"
+	"gorm.io/gorm"
+)
+
+// エクスポートの既定値（設定が読み込まれていない場合に使用）
+const (
+	defaultExportDir = "tmp/exports"
+	defaultExportTTL = 24 * time.Hour
+)
+
+// Exporter 個人データのエクスポート（ZIP作成）をバックグラウンドで実行
+type Exporter struct {
+	db  *gorm.DB
+	dir string
+	ttl time.Duration
+}
+
+// NewExporter エクスポーターを作成
+func NewExporter(db *gorm.DB, cfg *config.Config) *Exporter {
+	e := &Exporter{db: db, dir: defaultExportDir, ttl: defaultExportTTL}
+	if cfg != nil {
+		if cfg.ExportDir != "" {
+			e.dir = cfg.ExportDir
+		}
+		if cfg.ExportTTL > 0 {
+			e.ttl = cfg.ExportTTL
+		}
+	}
+	return e
+}
+
+// Start ジョブをバックグラウンドで実行
+func (e *Exporter) Start(jobID uint) {
+	go func() {
+		if err := e.Run(context.Background(), jobID); err != nil {
+			log.Printf("Error running export job %d: %v", jobID, err)
+		}
+	}()
+}
+
+// Run ジョブを実行してZIPを作成（作成待ちのジョブのみ）
+func (e *Exporter) Run(ctx context.Context, jobID uint) error {
+	db := e.db.WithContext(ctx)
+	now := time.Now()
+	result := db.Model(&models.ExportJob{}).
+		Where("id = ? AND status = ?", jobID, models.ExportStatusPending).
+		Updates(map[string]interface{}{"status": models.ExportStatusRunning, "started_at": now})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+
+	var job models.ExportJob
+	if err := db.First(&job, jobID).Error; err != nil {
+		return err
+	}
+
+	e.CleanupExpired()
+
+	fileName, size, err := e.writeFile(ctx, &job)
+	if err != nil {
+		db.Model(&job).Updates(map[string]interface{}{
+			"status": models.ExportStatusFailed,
+			"error":  truncate(err.Error(), 500),
+		})
+		return err
+	}
+
+	completedAt := time.Now()
+	return db.Model(&job).Updates(map[string]interface{}{
+		"status":       models.ExportStatusCompleted,
+		"file_name":    fileName,
+		"file_size":    size,
+		"completed_at": completedAt,
+		"expires_at":   completedAt.Add(e.ttl),
+	}).Error
+}
+
+// Path ジョブのZIPファイルのパス
+func (e *Exporter) Path(job *models.ExportJob) string {
+	return filepath.Join(e.dir, filepath.Base(job.FileName))
+}
+
+// RemoveFiles ジョブのZIPファイルを削除（アカウント削除時など）
+func (e *Exporter) RemoveFiles(jobs []models.ExportJob) {
+	for i := range jobs {
+		if jobs[i].FileName == "" {
+			continue
+		}
+		if err := os.Remove(e.Path(&jobs[i])); err != nil && !os.IsNotExist(err) {
+			log.Printf("Error removing export file %s: %v", jobs[i].FileName, err)
+		}
+	}
+}
+
+// CleanupExpired ダウンロード期限を過ぎたZIPファイルを削除
+func (e *Exporter) CleanupExpired() {
+	var jobs []models.ExportJob
+	if err := e.db.
+		Where("status = ? AND file_name != '' AND expires_at < ?", models.ExportStatusCompleted, time.Now()).
+		Find(&jobs).Error; err != nil {
+		log.Printf("Error fetching expired export jobs: %v", err)
+		return
+	}
+	e.RemoveFiles(jobs)
+	for _, job := range jobs {
+		e.db.Model(&job).Update("file_name", "")
+	}
+}
+
+// writeFile 一時ファイルにZIPを書き込み、完成後にリネームする
+func (e *Exporter) writeFile(ctx context.Context, job *models.ExportJob) (string, int64, error) {
+	if err := os.MkdirAll(e.dir, 0o700); err != nil {
+		return "", 0, err
+	}
+
+	fileName := fmt.Sprintf("hostnote-export-%d-%d.zip", job.UserID, job.ID)
+	tmp, err := os.CreateTemp(e.dir, fileName+".*.tmp")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := WriteUserExport(ctx, e.db, job.UserID, tmp); err != nil {
+		tmp.Close()
+		return "", 0, err
+	}
+	info, err := tmp.Stat()
+	if err != nil {
+		tmp.Close()
+		return "", 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", 0, err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(e.dir, fileName)); err != nil {
+		return "", 0, err
+	}
+	return fileName, info.Size(), nil
+}
+
+// exportTable 卓記録（関連する姫・キャストと注文を結合）
+type exportTable struct {
+	models.TableRecord
+	Himes  []exportRef         `json:"himes"`
+	Casts  []exportRef         `json:"casts"`
+	Orders []models.TableOrder `json:"orders"`
+}
+
+// exportAIAnalysis AI分析（AIへの指示を除いた会話履歴付き）
+type exportAIAnalysis struct {
+	models.AIAnalysis
+	Messages []models.AIMessage `json:"messages"`
+}
+
+// exportRef 関連する姫・キャストの参照
+type exportRef struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+	Role string `json:"role,omitempty"` // キャストのみ: main, help
+
+	Allocation *float64 `json:"allocation,omitempty"` // 姫のみ: 負担額
+}
+
+// exportPhoto ZIPに含める写真（data URLはファイルとして保存し、外部URLはURLのみ記録）
+type exportPhoto struct {
+	Entity   string
+	EntityID uint
+	Index    int
+	File     string
+	URL      string
+	data     []byte
+}
+
+// WriteUserExport ユーザーの個人データをZIP（JSONとCSV、写真）として書き込む
+func WriteUserExport(ctx context.Context, db *gorm.DB, userID uint, w io.Writer) error {
+	db = db.WithContext(ctx)
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return err
+	}
+
+	var himes []models.Hime
+	if err := db.Where("user_id = ?", userID).Order("id").Find(&himes).Error; err != nil {
+		return err
+	}
+	himeNames := make(map[uint]string, len(himes))
+	for _, h := range himes {
+		himeNames[h.ID] = string(h.Name)
+	}
+
+	var records []models.TableRecord
+	if err := db.Where("user_id = ?", userID).Order("datetime").Find(&records).Error; err != nil {
+		return err
+	}
+	tableIDs := make([]uint, len(records))
+	for i, r := range records {
+		tableIDs[i] = r.ID
+	}
+	var tableHimes []models.TableHime
+	var tableCasts []models.TableCast
+	var tableOrders []models.TableOrder
+	if len(tableIDs) > 0 {
+		if err := db.Where("table_id IN ?", tableIDs).Order("id").Find(&tableHimes).Error; err != nil {
+			return err
+		}
+		if err := db.Where("table_id IN ?", tableIDs).Order("id").Find(&tableCasts).Error; err != nil {
+			return err
+		}
+		if err := db.Where("table_id IN ?", tableIDs).Order("ordered_at, id").Find(&tableOrders).Error; err != nil {
+			return err
+		}
+	}
+
+	// 自分のキャスト情報と、卓記録に登場するキャスト
+	castIDs := make([]uint, 0, len(tableCasts))
+	for _, tc := range tableCasts {
+		castIDs = append(castIDs, tc.CastID)
+	}
+	var casts []models.Cast
+	if err := db.Where("user_id = ? OR id IN ?", userID, append(castIDs, 0)).Order("id").Find(&casts).Error; err != nil {
+		return err
+	}
+	castNames := make(map[uint]string, len(casts))
+	for _, c := range casts {
+		castNames[c.ID] = c.Name
+	}
+
+	tables := make([]exportTable, len(records))
+	tableIndex := make(map[uint]int, len(records))
+	for i, r := range records {
+		tables[i] = exportTable{TableRecord: r, Himes: []exportRef{}, Casts: []exportRef{}, Orders: []models.TableOrder{}}
+		tableIndex[r.ID] = i
+	}
+	for _, th := range tableHimes {
+		t := &tables[tableIndex[th.TableID]]
+		t.Himes = append(t.Himes, exportRef{ID: th.HimeID, Name: himeNames[th.HimeID], Allocation: th.Allocation})
+	}
+	for _, tc := range tableCasts {
+		t := &tables[tableIndex[tc.TableID]]
+		t.Casts = append(t.Casts, exportRef{ID: tc.CastID, Name: castNames[tc.CastID], Role: tc.Role})
+	}
+	for _, o := range tableOrders {
+		t := &tables[tableIndex[o.TableID]]
+		t.Orders = append(t.Orders, o)
+	}
+
+	var payments []models.Payment
+	if err := db.Where("user_id = ?", userID).Order("paid_at, id").Find(&payments).Error; err != nil {
+		return err
+	}
+	receivables, err := LoadReceivables(ctx, db.Where("user_id = ?", userID), time.Now())
+	if err != nil {
+		return err
+	}
+	var documents []models.IssuedDocument
+	if err := db.Where("user_id = ?", userID).Order("issued_at, id").Find(&documents).Error; err != nil {
+		return err
+	}
+
+	var visits []models.VisitRecord
+	if err := db.Where("user_id = ?", userID).Order("visit_date").Find(&visits).Error; err != nil {
+		return err
+	}
+	var schedules []models.Schedule
+	if err := db.Where("user_id = ?", userID).Order("scheduled_datetime").Find(&schedules).Error; err != nil {
+		return err
+	}
+	var analyses []models.AIAnalysis
+	if err := db.Where("user_id = ?", userID).Order("id").Find(&analyses).Error; err != nil {
+		return err
+	}
+	aiAnalyses := make([]exportAIAnalysis, len(analyses))
+	for i := range analyses {
+		aiAnalyses[i] = exportAIAnalysis{AIAnalysis: analyses[i], Messages: VisibleAIMessages(&analyses[i])}
+	}
+
+	// 写真をファイルに切り出し、JSONではZIP内のパスに置き換える
+	var photos []exportPhoto
+	for i := range himes {
+		himes[i].PhotoURL, himes[i].Photos = collectPhotos(&photos, "hime", himes[i].ID, himes[i].PhotoURL, himes[i].Photos)
+	}
+	for i := range casts {
+		casts[i].PhotoURL, casts[i].Photos = collectPhotos(&photos, "cast", casts[i].ID, casts[i].PhotoURL, casts[i].Photos)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	account := map[string]interface{}{
+		"id":         user.ID,
+		"username":   user.Username,
+		"email":      user.Email,
+		"role":       user.Role,
+		"createdAt":  user.CreatedAt,
+		"exportedAt": time.Now(),
+	}
+	files := []struct {
+		name string
+		v    interface{}
+	}{
+		{"account.json", account},
+		{"himes.json", himes},
+		{"casts.json", casts},
+		{"table_records.json", tables},
+		{"visits.json", visits},
+		{"schedules.json", schedules},
+		{"ai_analyses.json", aiAnalyses},
+		{"payments.json", payments},
+		{"receivables.json", receivables},
+		{"issued_documents.json", documents},
+	}
+	for _, f := range files {
+		if err := writeZipJSON(zw, f.name, f.v); err != nil {
+			return err
+		}
+	}
+
+	if err := writeZipCSV(zw, "himes.csv", himeCSV(himes, castNames)); err != nil {
+		return err
+	}
+	if err := writeZipCSV(zw, "casts.csv", castCSV(casts)); err != nil {
+		return err
+	}
+	tableRows, itemRows := tableCSV(tables)
+	if err := writeZipCSV(zw, "table_records.csv", tableRows); err != nil {
+		return err
+	}
+	if err := writeZipCSV(zw, "table_order_items.csv", itemRows); err != nil {
+		return err
+	}
+	if err := writeZipCSV(zw, "visits.csv", visitCSV(visits, himeNames)); err != nil {
+		return err
+	}
+	if err := writeZipCSV(zw, "schedules.csv", scheduleCSV(schedules, himeNames)); err != nil {
+		return err
+	}
+	if err := writeZipCSV(zw, "payments.csv", paymentCSV(payments, himeNames)); err != nil {
+		return err
+	}
+	if err := writeZipCSV(zw, "issued_documents.csv", documentCSV(documents)); err != nil {
+		return err
+	}
+
+	photoRows := [][]string{{"entity", "entityId", "index", "file", "url"}}
+	for _, p := range photos {
+		photoRows = append(photoRows, []string{p.Entity, uintString(p.EntityID), strconv.Itoa(p.Index), p.File, p.URL})
+		if p.File == "" {
+			continue
+		}
+		fw, err := zw.Create(p.File)
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(p.data); err != nil {
+			return err
+		}
+	}
+	if err := writeZipCSV(zw, "photos.csv", photoRows); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// collectPhotos 写真（メイン写真と写真一覧）を収集し、ZIP内のパスに置き換えた値を返す
+// インデックス0はメイン写真、1以降は写真一覧
+func collectPhotos(photos *[]exportPhoto, entity string, id uint, photoURL *string, list models.Photos) (*string, models.Photos) {
+	add := func(index int, value string) string {
+		photo := exportPhoto{Entity: entity, EntityID: id, Index: index}
+		if mimeType, data, ok := parseDataURL(value); ok {
+			photo.File = fmt.Sprintf("photos/%s/%d/%d%s", entity, id, index, photoExtension(mimeType))
+			photo.data = data
+			*photos = append(*photos, photo)
+			return photo.File
+		}
+		photo.URL = value
+		*photos = append(*photos, photo)
+		return value
+	}
+
+	if photoURL != nil && *photoURL != "" {
+		replaced := add(0, *photoURL)
+		photoURL = &replaced
+	}
+	replacedList := make(models.Photos, len(list))
+	for i, p := range list {
+		replacedList[i] = add(i+1, p)
+	}
+	return photoURL, replacedList
+}
+
+// parseDataURL "data:<mime>;base64,<data>" 形式の値をデコード
+func parseDataURL(value string) (string, []byte, bool) {
+	if !strings.HasPrefix(value, "data:") {
+		return "", nil, false
+	}
+	meta, encoded, ok := strings.Cut(strings.TrimPrefix(value, "data:"), ",")
+	if !ok || !strings.HasSuffix(meta, ";base64") {
+		return "", nil, false
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", nil, false
+	}
+	return strings.TrimSuffix(meta, ";base64"), data, true
+}
+
+// photoExtension MIMEタイプから拡張子を決める
+func photoExtension(mimeType string) string {
+	switch mimeType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/webp":
+		return ".webp"
+	case "image/gif":
+		return ".gif"
+	}
+	return ".bin"
+}
+
+func himeCSV(himes []models.Hime, castNames map[uint]string) [][]string {
+	rows := [][]string{{
+		"id", "name", "birthday", "age", "isFirstVisit", "tantoCast", "drinkPreference", "ice", "carbonation",
+		"mixerPreference", "smokes", "tobaccoType", "twitter", "instagram", "line", "memos", "createdAt", "updatedAt",
+	}}
+	for _, h := range himes {
+		tanto := ""
+		if h.TantoCastID != nil {
+			tanto = castNames[*h.TantoCastID]
+		}
+		var twitter, instagram, line string
+		if h.SnsInfo != nil {
+			twitter = snsString(h.SnsInfo.Twitter)
+			instagram = snsString(h.SnsInfo.Instagram)
+			line = snsString(h.SnsInfo.Line)
+		}
+		rows = append(rows, []string{
+			uintString(h.ID), string(h.Name), stringValue(h.Birthday.StringPtr()), intString(h.Age), strconv.FormatBool(h.IsFirstVisit),
+			tanto, stringValue(h.DrinkPreference), stringValue(h.Ice), stringValue(h.Carbonation),
+			stringValue(h.MixerPreference), boolString(h.Smokes), stringValue(h.TobaccoType), twitter, instagram, line,
+			memoString(models.Memos(h.Memos)), timeString(h.CreatedAt), timeString(h.UpdatedAt),
+		})
+	}
+	return rows
+}
+
+func castCSV(casts []models.Cast) [][]string {
+	rows := [][]string{{
+		"id", "name", "birthday", "age", "champagneCallSong", "drinkPreference", "ice", "carbonation",
+		"smokes", "tobaccoType", "memos", "createdAt", "updatedAt",
+	}}
+	for _, c := range casts {
+		rows = append(rows, []string{
+			uintString(c.ID), c.Name, stringValue(c.Birthday), intString(c.Age), stringValue(c.ChampagneCallSong),
+			stringValue(c.DrinkPreference), stringValue(c.Ice), stringValue(c.Carbonation),
+			boolString(c.Smokes), stringValue(c.TobaccoType), memoString(c.Memos), timeString(c.CreatedAt), timeString(c.UpdatedAt),
+		})
+	}
+	return rows
+}
+
+func tableCSV(tables []exportTable) ([][]string, [][]string) {
+	rows := [][]string{{
+		"id", "datetime", "tableNumber", "himes", "mainCasts", "helpCasts", "visitType", "stayHours",
+		"tableCharge", "shimeiFee", "subtotal", "tax", "total", "memo",
+	}}
+	items := [][]string{{"tableId", "datetime", "name", "quantity", "unitPrice", "amount"}}
+	for _, t := range tables {
+		var himes, mainCasts, helpCasts []string
+		for _, h := range t.Himes {
+			himes = append(himes, h.Name)
+		}
+		for _, c := range t.Casts {
+			if c.Role == "main" {
+				mainCasts = append(mainCasts, c.Name)
+			} else {
+				helpCasts = append(helpCasts, c.Name)
+			}
+		}
+
+		row := []string{
+			uintString(t.ID), timeString(t.Datetime), stringValue(t.TableNumber),
+			strings.Join(himes, " / "), strings.Join(mainCasts, " / "), strings.Join(helpCasts, " / "),
+		}
+		if s := t.SalesInfo; s != nil {
+			row = append(row, s.VisitType, floatString(s.StayHours), floatString(s.TableCharge), floatString(s.ShimeiFee),
+				floatString(s.Subtotal), floatString(s.Tax), floatString(s.Total))
+			for _, item := range s.OrderItems {
+				items = append(items, []string{
+					uintString(t.ID), timeString(t.Datetime), item.Name, strconv.Itoa(item.Quantity),
+					floatString(item.UnitPrice), floatString(item.Amount),
+				})
+			}
+		} else {
+			row = append(row, "", "", "", "", "", "", "")
+		}
+		rows = append(rows, append(row, stringValue(t.Memo)))
+	}
+	return rows, items
+}
+
+func visitCSV(visits []models.VisitRecord, himeNames map[uint]string) [][]string {
+	rows := [][]string{{"id", "visitDate", "himeId", "himeName", "memo"}}
+	for _, v := range visits {
+		rows = append(rows, []string{uintString(v.ID), timeString(v.VisitDate), uintString(v.HimeID), himeNames[v.HimeID], stringValue(v.Memo)})
+	}
+	return rows
+}
+
+func scheduleCSV(schedules []models.Schedule, himeNames map[uint]string) [][]string {
+	rows := [][]string{{"id", "scheduledDatetime", "himeId", "himeName", "memo", "notificationSent"}}
+	for _, s := range schedules {
+		rows = append(rows, []string{
+			uintString(s.ID), timeString(s.ScheduledDatetime), uintString(s.HimeID), himeNames[s.HimeID],
+			stringValue(s.Memo), strconv.FormatBool(s.NotificationSent),
+		})
+	}
+	return rows
+}
+
+func paymentCSV(payments []models.Payment, himeNames map[uint]string) [][]string {
+	rows := [][]string{{"id", "paidAt", "tableId", "himeId", "himeName", "method", "amount", "receivableId", "dueDate", "memo"}}
+	for _, p := range payments {
+		var receivableID, dueDate string
+		if p.ReceivableID != nil {
+			receivableID = uintString(*p.ReceivableID)
+		}
+		if p.DueDate != nil {
+			dueDate = timeString(*p.DueDate)
+		}
+		rows = append(rows, []string{
+			uintString(p.ID), timeString(p.PaidAt), uintString(p.TableID), uintString(p.HimeID), himeNames[p.HimeID],
+			p.Method, floatString(p.Amount), receivableID, dueDate, stringValue(p.Memo),
+		})
+	}
+	return rows
+}
+
+func documentCSV(documents []models.IssuedDocument) [][]string {
+	rows := [][]string{{"id", "issuedAt", "tableId", "type", "number", "addressee", "amount", "reissue"}}
+	for _, d := range documents {
+		rows = append(rows, []string{
+			uintString(d.ID), timeString(d.IssuedAt), uintString(d.TableID), d.Type, d.Number, string(d.Addressee),
+			floatString(d.Amount), strconv.FormatBool(d.Reissue),
+		})
+	}
+	return rows
+}
+
+// writeZipJSON ZIPにJSONファイルを追加
+func writeZipJSON(zw *zip.Writer, name string, v interface{}) error {
+	fw, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(fw)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// writeZipCSV ZIPにCSVファイルを追加（Excelで文字化けしないようBOM付きUTF-8）
+func writeZipCSV(zw *zip.Writer, name string, rows [][]string) error {
+	fw, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := fw.Write([]byte("\xEF\xBB\xBF")); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(fw)
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+func snsString(a *models.SnsAccount) string {
+	if a == nil {
+		return ""
+	}
+	if a.Username != nil && *a.Username != "" {
+		return *a.Username
+	}
+	return stringValue(a.URL)
+}
+
+// memoString メモを作成日時順に改行区切りで連結
+func memoString(memos models.Memos) string {
+	sorted := append(models.Memos(nil), memos...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].CreatedAt < sorted[j].CreatedAt })
+	parts := make([]string, len(sorted))
+	for i, m := range sorted {
+		parts[i] = m.Content
+	}
+	return strings.Join(parts, "\n")
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func intString(v *int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.Itoa(*v)
+}
+
+func boolString(v *bool) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatBool(*v)
+}
+
+func uintString(v uint) string {
+	return strconv.FormatUint(uint64(v), 10)
+}
+
+func floatString(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func timeString(t time.Time) string {
+	return t.Format(time.RFC3339)
+}
+
+// truncate 文字列を指定したバイト数以内に切り詰める
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max]
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hostnote/server/internal/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// TestCollectPhotos data URLの写真はZIP内のファイルに、外部URLはそのまま残ることをテスト
+func TestCollectPhotos(t *testing.T) {
+	main := "data:image/jpeg;base64,/9j/4AAQ"
+	list := models.Photos{"https://example.com/a.png", "data:image/webp;base64,UklGRg==", "data:text/plain,broken"}
+
+	var photos []exportPhoto
+	gotMain, gotList := collectPhotos(&photos, "hime", 7, &main, list)
+
+	if gotMain == nil || *gotMain != "photos/hime/7/0.jpg" {
+		t.Errorf("main photo = %v, want photos/hime/7/0.jpg", gotMain)
+	}
+	want := models.Photos{"https://example.com/a.png", "photos/hime/7/2.webp", "data:text/plain,broken"}
+	for i := range want {
+		if gotList[i] != want[i] {
+			t.Errorf("photos[%d] = %q, want %q", i, gotList[i], want[i])
+		}
+	}
+
+	if len(photos) != 4 {
+		t.Fatalf("collected %d photos, want 4", len(photos))
+	}
+	if string(photos[0].data) != "\xff\xd8\xff\xe0\x00\x10" {
+		t.Errorf("decoded data = %x", photos[0].data)
+	}
+	if photos[1].File != "" || photos[1].URL != "https://example.com/a.png" {
+		t.Errorf("external photo = %+v", photos[1])
+	}
+	if list[1] != "data:image/webp;base64,UklGRg==" {
+		t.Error("元の写真一覧を書き換えてはいけない")
+	}
+}
+
+// TestMemoString メモが作成日時順に連結されることをテスト
+func TestMemoString(t *testing.T) {
+	memos := models.Memos{
+		{ID: "2", Content: "2件目", CreatedAt: "2024-02-01T00:00:00Z"},
+		{ID: "1", Content: "1件目", CreatedAt: "2024-01-01T00:00:00Z"},
+	}
+	if got := memoString(memos); got != "1件目\n2件目" {
+		t.Errorf("memoString() = %q", got)
+	}
+	if memos[0].ID != "2" {
+		t.Error("元のメモの順序を変えてはいけない")
+	}
+}
+
+// TestWriteUserExportIncludesBilling 注文・負担額・支払い（売掛）・発行した書類がエクスポートに含まれることをテスト
+func TestWriteUserExportIncludesBilling(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:export?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("gorm.Open: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Hime{}, &models.Cast{}, &models.TableRecord{}, &models.TableHime{},
+		&models.TableCast{}, &models.TableOrder{}, &models.VisitRecord{}, &models.Schedule{}, &models.AIAnalysis{},
+		&models.Payment{}, &models.IssuedDocument{}); err != nil {
+		t.Fatalf("AutoMigrate: %v", err)
+	}
+
+	now := time.Now()
+	allocation := 30000.0
+	records := []interface{}{
+		&models.User{ID: 1, Username: "exporter"},
+		&models.Hime{ID: 1, UserID: 1, Name: "姫"},
+		&models.TableRecord{ID: 1, UserID: 1, Datetime: now, Status: models.TableStatusClosed},
+		&models.TableHime{TableID: 1, HimeID: 1, Allocation: &allocation},
+		&models.TableOrder{TableID: 1, MenuID: 3, HimeID: 1, Quantity: 2, OrderedBy: 1, OrderedAt: now},
+		&models.Payment{ID: 1, UserID: 1, TableID: 1, HimeID: 1, Method: models.PaymentMethodTab, Amount: 30000, PaidAt: now},
+		&models.IssuedDocument{UserID: 1, TableID: 1, Type: "receipt", Number: "R-000001", Addressee: "上様", Amount: 30000, IssuedAt: now},
+	}
+	for _, r := range records {
+		if err := db.Create(r).Error; err != nil {
+			t.Fatalf("create %T: %v", r, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := WriteUserExport(context.Background(), db, 1, &buf); err != nil {
+		t.Fatalf("WriteUserExport: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("zip.NewReader: %v", err)
+	}
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(b)
+	}
+
+	var tables []struct {
+		Himes  []exportRef         `json:"himes"`
+		Orders []models.TableOrder `json:"orders"`
+	}
+	if err := json.Unmarshal([]byte(files["table_records.json"]), &tables); err != nil {
+		t.Fatalf("table_records.json: %v", err)
+	}
+	if len(tables) != 1 || len(tables[0].Orders) != 1 || tables[0].Orders[0].Quantity != 2 {
+		t.Errorf("orders = %+v", tables)
+	}
+	if len(tables) == 1 && (len(tables[0].Himes) != 1 || tables[0].Himes[0].Allocation == nil || *tables[0].Himes[0].Allocation != allocation) {
+		t.Errorf("hime allocation = %+v", tables[0].Himes)
+	}
+
+	for name, want := range map[string]string{
+		"payments.json":         `"method": "tab"`,
+		"receivables.json":      `"outstanding": 30000`,
+		"issued_documents.json": `"number": "R-000001"`,
+		"payments.csv":          "30000",
+		"issued_documents.csv":  "上様",
+	} {
+		if !strings.Contains(files[name], want) {
+			t.Errorf("%s does not contain %s: %s", name, want, files[name])
+		}
+	}
+}
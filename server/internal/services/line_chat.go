@@ -0,0 +1,360 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// トークのメッセージの種類
+const (
+	ChatKindText    = "text"
+	ChatKindSticker = "sticker"
+	ChatKindPhoto   = "photo" // 写真・動画・アルバム
+	ChatKindVoice   = "voice"
+	ChatKindFile    = "file"
+	ChatKindCall    = "call"
+	ChatKindSystem  = "system" // 送信取消・退出など（送信者なし）
+)
+
+// chatConversationGap これ以上間が空いたら新しい会話とみなす
+const chatConversationGap = 6 * time.Hour
+
+var (
+	lineTitlePattern = regexp.MustCompile(`^\[LINE\]\s*(.+)$`)
+	// 2024/05/30(木)、2024/05/30（木）、2024.05.30 木曜日
+	lineDatePattern = regexp.MustCompile(`^(\d{4})[/.](\d{1,2})[/.](\d{1,2})(?:\s*[（(][^)）]*[)）]|\s+\S+曜日)?$`)
+	// 12:01, 午後0:01（iOSの古い形式）
+	lineTimePattern = regexp.MustCompile(`^(午前|午後)?(\d{1,2}):(\d{2})$`)
+)
+
+// lineMediaKinds 本文が置き換えられるメディア（日本語・英語の書き出し）
+var lineMediaKinds = map[string]string{
+	"[スタンプ]":          ChatKindSticker,
+	"[Sticker]":       ChatKindSticker,
+	"[写真]":            ChatKindPhoto,
+	"[Photo]":         ChatKindPhoto,
+	"[動画]":            ChatKindPhoto,
+	"[Video]":         ChatKindPhoto,
+	"[アルバム]":          ChatKindPhoto,
+	"[Album]":         ChatKindPhoto,
+	"[ボイスメッセージ]":      ChatKindVoice,
+	"[Voice message]": ChatKindVoice,
+	"[ファイル]":          ChatKindFile,
+	"[File]":          ChatKindFile,
+}
+
+// ChatMessage トーク履歴の1メッセージ
+type ChatMessage struct {
+	Time   time.Time // 書き出しにタイムゾーンはないため、表示された日時をUTCとして扱う
+	Sender string    // システムメッセージは空
+	Kind   string
+	Text   string
+}
+
+// ChatLog 読み込んだトーク履歴
+type ChatLog struct {
+	Title    string // 「あいりとのトーク履歴」
+	Messages []ChatMessage
+}
+
+// ParseLINEChat LINEの「トーク履歴を送信」で書き出したテキストを読み込む
+// 日付の見出しとメッセージ行が見つからない場合は LINE の形式ではないとして false を返す
+func ParseLINEChat(text string) (*ChatLog, bool) {
+	text = strings.TrimPrefix(strings.ReplaceAll(text, "\r\n", "\n"), "\ufeff")
+
+	chat := &ChatLog{}
+	var date time.Time
+	var current *ChatMessage
+	dates := 0
+
+	flush := func() {
+		if current == nil {
+			return
+		}
+		current.Text = unquoteLINEText(current.Text)
+		if kind, ok := lineMediaKinds[current.Text]; ok && current.Sender != "" {
+			current.Kind = kind
+		} else if strings.HasPrefix(current.Text, "☎") && current.Sender != "" {
+			current.Kind = ChatKindCall
+		}
+		chat.Messages = append(chat.Messages, *current)
+		current = nil
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if current == nil && len(chat.Messages) == 0 && dates == 0 {
+			if m := lineTitlePattern.FindStringSubmatch(trimmed); m != nil {
+				chat.Title = m[1]
+				continue
+			}
+		}
+		if m := lineDatePattern.FindStringSubmatch(trimmed); m != nil {
+			flush()
+			year, _ := strconv.Atoi(m[1])
+			month, _ := strconv.Atoi(m[2])
+			day, _ := strconv.Atoi(m[3])
+			date = time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+			dates++
+			continue
+		}
+
+		if !date.IsZero() {
+			if fields := strings.SplitN(line, "\t", 3); len(fields) >= 2 {
+				if t, ok := parseLINETime(date, fields[0]); ok {
+					flush()
+					current = &ChatMessage{Time: t, Kind: ChatKindText}
+					if len(fields) == 3 {
+						current.Sender = strings.TrimSpace(fields[1])
+						current.Text = fields[2]
+					} else {
+						current.Kind = ChatKindSystem
+						current.Text = fields[1]
+					}
+					continue
+				}
+			}
+		}
+
+		// 改行を含むメッセージの続き（引用符で囲まれた途中の空行も含む）
+		if current != nil && (trimmed != "" || isOpenLINEQuote(current.Text)) {
+			current.Text += "\n" + line
+		}
+	}
+	flush()
+
+	senders := 0
+	for _, m := range chat.Messages {
+		if m.Sender != "" {
+			senders++
+		}
+	}
+	if dates == 0 || senders == 0 {
+		return nil, false
+	}
+	return chat, true
+}
+
+// parseLINETime 日付と「12:01」「午後0:01」から日時を作る
+func parseLINETime(date time.Time, s string) (time.Time, bool) {
+	m := lineTimePattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return time.Time{}, false
+	}
+	hour, _ := strconv.Atoi(m[2])
+	minute, _ := strconv.Atoi(m[3])
+	if m[1] == "午後" && hour < 12 {
+		hour += 12
+	} else if m[1] == "午前" && hour == 12 {
+		hour = 0
+	}
+	if hour > 23 || minute > 59 {
+		return time.Time{}, false
+	}
+	return date.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute), true
+}
+
+// isOpenLINEQuote 改行を含むメッセージの引用符がまだ閉じていないか
+func isOpenLINEQuote(text string) bool {
+	if !strings.HasPrefix(text, `"`) {
+		return false
+	}
+	rest := strings.TrimSuffix(strings.ReplaceAll(text[1:], `""`, ""), "\n")
+	return !strings.HasSuffix(rest, `"`)
+}
+
+// unquoteLINEText 改行を含むメッセージは引用符で囲まれ、本文の引用符は二重になっている
+func unquoteLINEText(text string) string {
+	text = strings.TrimRight(text, "\n")
+	if strings.Contains(text, "\n") && len(text) >= 2 && strings.HasPrefix(text, `"`) && strings.HasSuffix(text, `"`) {
+		text = strings.ReplaceAll(text[1:len(text)-1], `""`, `"`)
+	}
+	return text
+}
+
+// ChatPartyStats 送信者ごとの集計
+type ChatPartyStats struct {
+	Name               string    `json:"name"`
+	Messages           int       `json:"messages"`
+	Stickers           int       `json:"stickers"`
+	Photos             int       `json:"photos"`
+	Characters         int       `json:"characters"`         // テキストの文字数
+	Replies            int       `json:"replies"`            // 相手のメッセージに続けて送った回数
+	AvgReplyMinutes    float64   `json:"avgReplyMinutes"`    // 返信までの平均時間
+	MedianReplyMinutes float64   `json:"medianReplyMinutes"` // 返信までの時間の中央値
+	Initiations        int       `json:"initiations"`        // 間が空いたあとに会話を始めた回数
+	LastMessageAt      time.Time `json:"lastMessageAt"`
+}
+
+// ChatStats トーク履歴の集計
+type ChatStats struct {
+	From       time.Time        `json:"from"`
+	To         time.Time        `json:"to"`
+	ActiveDays int              `json:"activeDays"` // やり取りのあった日数
+	Messages   int              `json:"messages"`
+	Parties    []ChatPartyStats `json:"parties"` // メッセージの多い順
+}
+
+// Stats 送信者ごとのメッセージ数・返信時間などを集計する
+// 返信時間は、相手の連続したメッセージの最後から自分が送るまでの時間
+func (l *ChatLog) Stats() *ChatStats {
+	stats := &ChatStats{}
+	parties := map[string]*ChatPartyStats{}
+	latencies := map[string][]time.Duration{}
+	days := map[string]bool{}
+	var prev *ChatMessage
+
+	for i := range l.Messages {
+		m := &l.Messages[i]
+		if m.Sender == "" {
+			continue
+		}
+		p, ok := parties[m.Sender]
+		if !ok {
+			p = &ChatPartyStats{Name: m.Sender}
+			parties[m.Sender] = p
+		}
+
+		if stats.Messages == 0 {
+			stats.From = m.Time
+		}
+		stats.To = m.Time
+		stats.Messages++
+		days[m.Time.Format("2006-01-02")] = true
+
+		p.Messages++
+		p.LastMessageAt = m.Time
+		switch m.Kind {
+		case ChatKindSticker:
+			p.Stickers++
+		case ChatKindPhoto:
+			p.Photos++
+		case ChatKindText:
+			p.Characters += utf8.RuneCountInString(m.Text)
+		}
+
+		if prev == nil || m.Time.Sub(prev.Time) >= chatConversationGap {
+			p.Initiations++
+		}
+		if prev != nil && prev.Sender != m.Sender {
+			p.Replies++
+			latencies[m.Sender] = append(latencies[m.Sender], m.Time.Sub(prev.Time))
+		}
+		prev = m
+	}
+
+	stats.ActiveDays = len(days)
+	for name, p := range parties {
+		if d := latencies[name]; len(d) > 0 {
+			sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+			var total time.Duration
+			for _, v := range d {
+				total += v
+			}
+			p.AvgReplyMinutes = total.Minutes() / float64(len(d))
+			p.MedianReplyMinutes = d[len(d)/2].Minutes()
+			if len(d)%2 == 0 {
+				p.MedianReplyMinutes = (d[len(d)/2-1] + d[len(d)/2]).Minutes() / 2
+			}
+		}
+		stats.Parties = append(stats.Parties, *p)
+	}
+	sort.Slice(stats.Parties, func(i, j int) bool {
+		if stats.Parties[i].Messages != stats.Parties[j].Messages {
+			return stats.Parties[i].Messages > stats.Parties[j].Messages
+		}
+		return stats.Parties[i].Name < stats.Parties[j].Name
+	})
+	return stats
+}
+
+// Transcript AIに渡す形に整えたトーク履歴（日付ごとに「時刻 送信者: 本文」の1行）
+// maxRunes を超える場合は新しいメッセージを優先し、古いメッセージを省略する
+func (l *ChatLog) Transcript(maxRunes int) string {
+	lines := make([]string, len(l.Messages))
+	for i, m := range l.Messages {
+		lines[i] = chatTranscriptLine(m)
+	}
+
+	// 新しい方から入る分だけ残す（日付の見出しは日ごとに1行）
+	start := len(l.Messages)
+	used := 0
+	lastDate := ""
+	for i := len(l.Messages) - 1; i >= 0; i-- {
+		cost := utf8.RuneCountInString(lines[i]) + 1
+		if date := chatDateLabel(l.Messages[i].Time); date != lastDate {
+			cost += utf8.RuneCountInString(date) + 1
+			lastDate = date
+		}
+		if used+cost > maxRunes && start < len(l.Messages) {
+			break
+		}
+		used += cost
+		start = i
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		fmt.Fprintf(&b, "（古いメッセージ%d件は省略）\n", start)
+	}
+	lastDate = ""
+	for i := start; i < len(l.Messages); i++ {
+		if date := chatDateLabel(l.Messages[i].Time); date != lastDate {
+			b.WriteString(date + "\n")
+			lastDate = date
+		}
+		b.WriteString(lines[i] + "\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// Summary 集計をAIに渡す文章にする
+func (s *ChatStats) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "期間: %s〜%s（やり取りのあった日: %d日、メッセージ: %d件）\n",
+		s.From.Format("2006/01/02"), s.To.Format("2006/01/02"), s.ActiveDays, s.Messages)
+	for _, p := range s.Parties {
+		fmt.Fprintf(&b, "- %s: メッセージ%d件（スタンプ%d・写真/動画%d）、文字数%d", p.Name, p.Messages, p.Stickers, p.Photos, p.Characters)
+		if p.Replies > 0 {
+			fmt.Fprintf(&b, "、返信までの時間 平均%s・中央値%s", formatMinutes(p.AvgReplyMinutes), formatMinutes(p.MedianReplyMinutes))
+		}
+		fmt.Fprintf(&b, "、会話を始めた回数%d回、最後の送信 %s\n", p.Initiations, p.LastMessageAt.Format("2006/01/02 15:04"))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// chatTranscriptLine 1メッセージを1行にする（改行は「 / 」に置き換える）
+func chatTranscriptLine(m ChatMessage) string {
+	text := strings.ReplaceAll(strings.TrimSpace(m.Text), "\n", " / ")
+	if m.Sender == "" {
+		return fmt.Sprintf("%s （%s）", m.Time.Format("15:04"), text)
+	}
+	return fmt.Sprintf("%s %s: %s", m.Time.Format("15:04"), m.Sender, text)
+}
+
+// chatDateLabel 日付の見出し
+func chatDateLabel(t time.Time) string {
+	return t.Format("2006/01/02") + "(" + []string{"日", "月", "火", "水", "木", "金", "土"}[t.Weekday()] + ")"
+}
+
+// formatMinutes 分を「5分」「2時間10分」「3日」のように表示する
+func formatMinutes(minutes float64) string {
+	m := int(minutes + 0.5)
+	switch {
+	case m < 60:
+		return fmt.Sprintf("%d分", m)
+	case m < 24*60:
+		if m%60 == 0 {
+			return fmt.Sprintf("%d時間", m/60)
+		}
+		return fmt.Sprintf("%d時間%d分", m/60, m%60)
+	default:
+		return fmt.Sprintf("%.1f日", minutes/(24*60))
+	}
+}
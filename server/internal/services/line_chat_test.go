@@ -0,0 +1,151 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const lineChatExport = "\ufeff[LINE] あいりとのトーク履歴\r\n" +
+	"保存日時：2024/05/31 12:34\r\n" +
+	"\r\n" +
+	"2024/05/30(木)\r\n" +
+	"12:00\tあいり\tおはよう\r\n" +
+	"12:01\tあいり\t[スタンプ]\r\n" +
+	"12:31\tレン\t\"おはよ！\r\n" +
+	"\r\n" +
+	"今日は\"\"同伴\"\"できる？\"\r\n" +
+	"13:00\tあいり\t[写真]\r\n" +
+	"13:10\tレン\t☎ 通話時間 5:12\r\n" +
+	"13:20\tあいりがメッセージの送信を取り消しました\r\n" +
+	"\r\n" +
+	"2024/05/31（金）\r\n" +
+	"午前9:00\tレン\t昨日はありがとう\r\n" +
+	"午後0:00\tあいり\tこちらこそ\r\n"
+
+// TestParseLINEChat 日付の見出し・改行を含むメッセージ・スタンプや写真・システムメッセージを読み込めることをテスト
+func TestParseLINEChat(t *testing.T) {
+	chat, ok := ParseLINEChat(lineChatExport)
+	if !ok {
+		t.Fatal("LINEのトーク履歴として読み込めない")
+	}
+	if chat.Title != "あいりとのトーク履歴" {
+		t.Errorf("Title = %q", chat.Title)
+	}
+
+	want := []ChatMessage{
+		{time.Date(2024, 5, 30, 12, 0, 0, 0, time.UTC), "あいり", ChatKindText, "おはよう"},
+		{time.Date(2024, 5, 30, 12, 1, 0, 0, time.UTC), "あいり", ChatKindSticker, "[スタンプ]"},
+		{time.Date(2024, 5, 30, 12, 31, 0, 0, time.UTC), "レン", ChatKindText, "おはよ！\n\n今日は\"同伴\"できる？"},
+		{time.Date(2024, 5, 30, 13, 0, 0, 0, time.UTC), "あいり", ChatKindPhoto, "[写真]"},
+		{time.Date(2024, 5, 30, 13, 10, 0, 0, time.UTC), "レン", ChatKindCall, "☎ 通話時間 5:12"},
+		{time.Date(2024, 5, 30, 13, 20, 0, 0, time.UTC), "", ChatKindSystem, "あいりがメッセージの送信を取り消しました"},
+		{time.Date(2024, 5, 31, 9, 0, 0, 0, time.UTC), "レン", ChatKindText, "昨日はありがとう"},
+		{time.Date(2024, 5, 31, 12, 0, 0, 0, time.UTC), "あいり", ChatKindText, "こちらこそ"},
+	}
+	if len(chat.Messages) != len(want) {
+		t.Fatalf("got %d messages, want %d: %+v", len(chat.Messages), len(want), chat.Messages)
+	}
+	for i, w := range want {
+		if got := chat.Messages[i]; !got.Time.Equal(w.Time) || got.Sender != w.Sender || got.Kind != w.Kind || got.Text != w.Text {
+			t.Errorf("message %d = %+v, want %+v", i, got, w)
+		}
+	}
+}
+
+// TestParseLINEChatNotLINE LINEの形式でないテキストは読み込まないことをテスト
+func TestParseLINEChatNotLINE(t *testing.T) {
+	tests := []string{
+		"",
+		"あいり: おはよう\nレン: おはよ！",
+		"2024/05/30(木)\n今日は楽しかった",
+	}
+	for _, text := range tests {
+		if chat, ok := ParseLINEChat(text); ok {
+			t.Errorf("ParseLINEChat(%q) = %+v, want not LINE", text, chat)
+		}
+	}
+}
+
+// TestChatLogStats 送信者ごとのメッセージ数と返信時間が集計されることをテスト
+func TestChatLogStats(t *testing.T) {
+	chat, _ := ParseLINEChat(lineChatExport)
+	stats := chat.Stats()
+
+	if stats.Messages != 7 || stats.ActiveDays != 2 || len(stats.Parties) != 2 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	airi, ren := stats.Parties[0], stats.Parties[1]
+	if airi.Name != "あいり" || airi.Messages != 4 || airi.Stickers != 1 || airi.Photos != 1 || airi.Characters != 9 {
+		t.Errorf("あいり = %+v", airi)
+	}
+	// あいりの返信: 13:00（レン12:31から29分）、翌12:00（レン9:00から180分）
+	if airi.Replies != 2 || airi.AvgReplyMinutes != 104.5 || airi.MedianReplyMinutes != 104.5 {
+		t.Errorf("あいりの返信 = %+v", airi)
+	}
+	// レンの返信: 12:31（30分）、13:10（10分）。翌9:00は自分の通話に続けて送ったため返信ではない
+	if ren.Name != "レン" || ren.Replies != 2 || ren.MedianReplyMinutes != 20 || ren.AvgReplyMinutes != 20 {
+		t.Errorf("レン = %+v", ren)
+	}
+	// 会話を始めたのは最初のあいりと、間が空いた翌朝のレン
+	if airi.Initiations != 1 || ren.Initiations != 1 {
+		t.Errorf("initiations = %d, %d", airi.Initiations, ren.Initiations)
+	}
+
+	summary := stats.Summary()
+	for _, want := range []string{"2024/05/30〜2024/05/31", "- あいり: メッセージ4件", "平均20分・中央値20分", "平均1時間45分"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary does not contain %q:\n%s", want, summary)
+		}
+	}
+}
+
+// TestChatLogTranscript 整えた履歴が文字数に収まるよう古いメッセージから省略されることをテスト
+func TestChatLogTranscript(t *testing.T) {
+	chat, _ := ParseLINEChat(lineChatExport)
+
+	full := chat.Transcript(10000)
+	if !strings.HasPrefix(full, "2024/05/30(木)\n12:00 あいり: おはよう\n") {
+		t.Errorf("transcript = %q", full)
+	}
+	for _, want := range []string{"12:31 レン: おはよ！ /  / 今日は\"同伴\"できる？", "13:20 （あいりがメッセージの送信を取り消しました）", "2024/05/31(金)\n09:00 レン: 昨日はありがとう"} {
+		if !strings.Contains(full, want) {
+			t.Errorf("transcript does not contain %q:\n%s", want, full)
+		}
+	}
+
+	short := chat.Transcript(60)
+	if short != "（古いメッセージ6件は省略）\n2024/05/31(金)\n09:00 レン: 昨日はありがとう\n12:00 あいり: こちらこそ" {
+		t.Errorf("truncated transcript = %q", short)
+	}
+
+	// 1件も入らない場合でも最新のメッセージは残す
+	if latest := chat.Transcript(1); !strings.HasSuffix(latest, "12:00 あいり: こちらこそ") || !strings.Contains(latest, "7件は省略") {
+		t.Errorf("transcript = %q", latest)
+	}
+}
+
+// TestConversationAnalysisRequestLINE LINEのトーク履歴は整えた履歴と集計がプロンプトに含まれることをテスト
+func TestConversationAnalysisRequestLINE(t *testing.T) {
+	req, stats := ConversationAnalysisRequest(ConversationAnalysisInput{SelfProfile: "レン", PartnerProfile: "あいり", ChatLog: lineChatExport})
+	if stats == nil || stats.Messages != 7 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	user := req.Messages[1].Content
+	for _, want := range []string{"【トーク履歴の集計（送信者ごと）】", "12:00 あいり: おはよう"} {
+		if !strings.Contains(user, want) {
+			t.Errorf("user message does not contain %q:\n%s", want, user)
+		}
+	}
+	if strings.Contains(user, "\t") || strings.Contains(user, "保存日時") {
+		t.Errorf("生の書き出しがそのまま含まれている:\n%s", user)
+	}
+
+	// 長いログは直近だけを渡す
+	long := strings.Repeat("あいり: 今日もおつかれさま\n", 1000) + "あいり: またね"
+	req, _ = ConversationAnalysisRequest(ConversationAnalysisInput{ChatLog: long})
+	user = req.Messages[1].Content
+	if !strings.Contains(user, "（前略）\nあいり: 今日もおつかれさま") || !strings.HasSuffix(user, "あいり: またね\n") || len([]rune(user)) > conversationChatLogMaxRunes+200 {
+		t.Errorf("truncated log: %d runes", len([]rune(user)))
+	}
+}
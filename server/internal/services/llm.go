@@ -0,0 +1,458 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hostnote/server/internal/config"
+)
+
+// ErrAIProviderNotConfigured AIのプロバイダー（APIキー等）が設定されていない
+var ErrAIProviderNotConfigured = errors.New("AI provider is not configured: set AI_PROVIDER or OPENAI_API_KEY")
+
+// LLMメッセージの役割
+const (
+	LLMRoleSystem    = "system"
+	LLMRoleUser      = "user"
+	LLMRoleAssistant = "assistant"
+)
+
+// DefaultAIBaseURL OpenAI APIのベースURL
+const DefaultAIBaseURL = "https://api.openai.com/v1"
+
+// LLMMessage 会話の1メッセージ
+type LLMMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// LLMRequest チャット補完のリクエスト
+type LLMRequest struct {
+	Messages    []LLMMessage
+	Temperature float32
+	MaxTokens   int
+	JSON        bool // 応答をJSONオブジェクトに限定する（対応していないAPIでは指示文のみで制御する）
+}
+
+// LLMUsage 消費したトークン数
+type LLMUsage struct {
+	PromptTokens     int `json:"promptTokens"`
+	CompletionTokens int `json:"completionTokens"`
+	TotalTokens      int `json:"totalTokens"`
+}
+
+// LLMResponse チャット補完の結果
+type LLMResponse struct {
+	Content string
+	Model   string
+	Usage   LLMUsage
+}
+
+// LLMDeltaFunc ストリーミング中に届いた応答の断片を受け取る（エラーを返すと中断する）
+type LLMDeltaFunc func(delta string) error
+
+// LLMProvider LLM呼び出しの抽象（OpenAI互換API・テスト用の固定応答を設定で切り替える）
+type LLMProvider interface {
+	Chat(ctx context.Context, req LLMRequest) (*LLMResponse, error)
+	// ChatStream 応答を断片ごとに onDelta へ渡し、完了後に全体を返す
+	ChatStream(ctx context.Context, req LLMRequest, onDelta LLMDeltaFunc) (*LLMResponse, error)
+}
+
+var llmProvider LLMProvider = disabledLLMProvider{}
+
+// InitLLMProvider 設定に応じてLLMプロバイダーを初期化
+// AI_PROVIDER が未指定の場合はAPIキーがあればOpenAI、なければ無効
+func InitLLMProvider(cfg *config.Config) error {
+	provider := cfg.AIProvider
+	if provider == "" {
+		provider = "none"
+		if cfg.AIAPIKey != "" {
+			provider = "openai"
+		}
+	}
+
+	switch provider {
+	case "openai":
+		baseURL := strings.TrimRight(cfg.AIBaseURL, "/")
+		if baseURL == "" {
+			baseURL = DefaultAIBaseURL
+		}
+		// 自前のOpenAI互換サーバーはAPIキー不要の場合がある
+		if cfg.AIAPIKey == "" && baseURL == DefaultAIBaseURL {
+			return fmt.Errorf("OPENAI_API_KEY is required for AI_PROVIDER=openai")
+		}
+		llmProvider = &OpenAICompatibleProvider{
+			BaseURL: baseURL,
+			APIKey:  cfg.AIAPIKey,
+			Model:   cfg.AIModel,
+			Timeout: cfg.AITimeout,
+		}
+	case "fake":
+		llmProvider = &FakeLLMProvider{}
+	case "none":
+		llmProvider = disabledLLMProvider{}
+	default:
+		return fmt.Errorf("unknown AI_PROVIDER: %s", cfg.AIProvider)
+	}
+	return nil
+}
+
+// Chat 設定されたLLMプロバイダーで応答を生成する
+func Chat(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	return llmProvider.Chat(ctx, req)
+}
+
+// ChatStream 設定されたLLMプロバイダーで応答を生成し、生成されたそばから onDelta へ渡す
+func ChatStream(ctx context.Context, req LLMRequest, onDelta LLMDeltaFunc) (*LLMResponse, error) {
+	return llmProvider.ChatStream(ctx, req, onDelta)
+}
+
+// SetLLMProvider LLMプロバイダーを差し替える（テスト用）
+func SetLLMProvider(p LLMProvider) {
+	llmProvider = p
+}
+
+// disabledLLMProvider AIが設定されていない場合のプロバイダー
+type disabledLLMProvider struct{}
+
+// Chat 常に ErrAIProviderNotConfigured を返す
+func (disabledLLMProvider) Chat(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	return nil, ErrAIProviderNotConfigured
+}
+
+// ChatStream 常に ErrAIProviderNotConfigured を返す
+func (disabledLLMProvider) ChatStream(ctx context.Context, req LLMRequest, onDelta LLMDeltaFunc) (*LLMResponse, error) {
+	return nil, ErrAIProviderNotConfigured
+}
+
+// OpenAICompatibleProvider OpenAIのChat Completions APIと互換のサーバーを呼び出す
+// BaseURL を変えることで自前のサーバー（vLLM・Ollama等）も利用できる
+type OpenAICompatibleProvider struct {
+	BaseURL string // 例: https://api.openai.com/v1
+	APIKey  string // 空の場合は Authorization ヘッダーを付けない
+	Model   string
+	Timeout time.Duration
+	Client  *http.Client // nilの場合は Timeout を使って作成
+}
+
+type openAIChatRequest struct {
+	Model          string                `json:"model"`
+	Messages       []LLMMessage          `json:"messages"`
+	Temperature    float32               `json:"temperature"`
+	MaxTokens      int                   `json:"max_tokens,omitempty"`
+	TopP           float32               `json:"top_p,omitempty"`
+	Stream         bool                  `json:"stream,omitempty"`
+	StreamOptions  *openAIStreamOptions  `json:"stream_options,omitempty"`
+	ResponseFormat *openAIResponseFormat `json:"response_format,omitempty"`
+}
+
+type openAIResponseFormat struct {
+	Type string `json:"type"` // json_object
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type openAIError struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+}
+
+type openAIChatResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage,omitempty"`
+	Error *openAIError `json:"error,omitempty"`
+}
+
+// openAIStreamChunk stream: true の場合に1行ずつ届く応答
+type openAIStreamChunk struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage,omitempty"`
+	Error *openAIError `json:"error,omitempty"`
+}
+
+// Chat Chat Completions APIを呼び出して応答本文を返す
+func (p *OpenAICompatibleProvider) Chat(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	resp, err := p.post(ctx, p.chatRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chat response: %w", err)
+	}
+
+	var chatResp openAIChatResponse
+	if err := json.Unmarshal(body, &chatResp); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("chat completion failed: status %d", resp.StatusCode)
+		}
+		return nil, fmt.Errorf("failed to decode chat response: %w", err)
+	}
+	if chatResp.Error != nil {
+		return nil, fmt.Errorf("chat completion failed: %s", chatResp.Error.Message)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("chat completion failed: status %d", resp.StatusCode)
+	}
+	if len(chatResp.Choices) == 0 {
+		return nil, fmt.Errorf("chat completion returned no choices")
+	}
+
+	result := &LLMResponse{Content: chatResp.Choices[0].Message.Content, Model: p.Model}
+	result.apply(chatResp.Model, chatResp.Usage)
+	return result, nil
+}
+
+// ChatStream stream: true でChat Completions APIを呼び出し、届いた断片を順に onDelta へ渡す
+// ctx がキャンセルされる（クライアントの切断等）とAPIへの接続も切る
+func (p *OpenAICompatibleProvider) ChatStream(ctx context.Context, req LLMRequest, onDelta LLMDeltaFunc) (*LLMResponse, error) {
+	chatReq := p.chatRequest(req)
+	chatReq.Stream = true
+	chatReq.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+
+	resp, err := p.post(ctx, chatReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp openAIChatResponse
+		if err := json.NewDecoder(resp.Body).Decode(&errResp); err == nil && errResp.Error != nil {
+			return nil, fmt.Errorf("chat completion failed: %s", errResp.Error.Message)
+		}
+		return nil, fmt.Errorf("chat completion failed: status %d", resp.StatusCode)
+	}
+
+	result := &LLMResponse{Model: p.Model}
+	var content strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue // 空行・コメント行
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk openAIStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("failed to decode chat stream: %w", err)
+		}
+		if chunk.Error != nil {
+			return nil, fmt.Errorf("chat completion failed: %s", chunk.Error.Message)
+		}
+		result.apply(chunk.Model, chunk.Usage)
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content == "" {
+				continue
+			}
+			content.WriteString(choice.Delta.Content)
+			if err := onDelta(choice.Delta.Content); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, fmt.Errorf("failed to read chat stream: %w", err)
+	}
+
+	result.Content = content.String()
+	return result, nil
+}
+
+// chatRequest APIに送るリクエストを組み立てる
+func (p *OpenAICompatibleProvider) chatRequest(req LLMRequest) openAIChatRequest {
+	chatReq := openAIChatRequest{
+		Model:       p.Model,
+		Messages:    req.Messages,
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxTokens,
+		TopP:        0.9,
+	}
+	if req.JSON {
+		chatReq.ResponseFormat = &openAIResponseFormat{Type: "json_object"}
+	}
+	return chatReq
+}
+
+// post /chat/completions にリクエストを送る
+func (p *OpenAICompatibleProvider) post(ctx context.Context, chatReq openAIChatRequest) (*http.Response, error) {
+	bodyBytes, err := json.Marshal(chatReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal chat request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.BaseURL+"/chat/completions", bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chat request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.APIKey)
+	}
+
+	resp, err := p.client().Do(httpReq)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, fmt.Errorf("failed to call %s: %w", p.BaseURL, err)
+	}
+	return resp, nil
+}
+
+// apply APIが返したモデル名とトークン数を反映する
+func (r *LLMResponse) apply(model string, usage *openAIUsage) {
+	if model != "" {
+		r.Model = model
+	}
+	if usage != nil {
+		r.Usage = LLMUsage{
+			PromptTokens:     usage.PromptTokens,
+			CompletionTokens: usage.CompletionTokens,
+			TotalTokens:      usage.TotalTokens,
+		}
+	}
+}
+
+// client HTTPクライアントを取得
+func (p *OpenAICompatibleProvider) client() *http.Client {
+	if p.Client != nil {
+		return p.Client
+	}
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	return &http.Client{Timeout: timeout}
+}
+
+// FakeLLMProvider 入力から決まった応答を返すプロバイダー（テスト・ローカル開発用）
+// 外部APIを呼ばず、同じ入力には常に同じ応答とトークン数を返す
+type FakeLLMProvider struct {
+	Response string // 空でない場合は常にこの本文を返す
+	Err      error  // nilでない場合は常にこのエラーを返す
+
+	mu       sync.Mutex
+	requests []LLMRequest
+}
+
+// FakeLLMModel FakeLLMProvider が返すモデル名
+const FakeLLMModel = "fake"
+
+// Chat リクエストを記録し、固定の応答を返す
+func (p *FakeLLMProvider) Chat(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	p.mu.Lock()
+	p.requests = append(p.requests, req)
+	p.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if p.Err != nil {
+		return nil, p.Err
+	}
+
+	content := p.Response
+	if content == "" {
+		content = fakeLLMContent(req)
+	}
+
+	prompt := 0
+	for _, m := range req.Messages {
+		prompt += fakeTokenCount(m.Content)
+	}
+	completion := fakeTokenCount(content)
+	return &LLMResponse{
+		Content: content,
+		Model:   FakeLLMModel,
+		Usage:   LLMUsage{PromptTokens: prompt, CompletionTokens: completion, TotalTokens: prompt + completion},
+	}, nil
+}
+
+// fakeStreamChunkRunes ChatStream で1回に渡す文字数
+const fakeStreamChunkRunes = 8
+
+// ChatStream Chat と同じ応答を数文字ずつ onDelta へ渡す
+func (p *FakeLLMProvider) ChatStream(ctx context.Context, req LLMRequest, onDelta LLMDeltaFunc) (*LLMResponse, error) {
+	resp, err := p.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	runes := []rune(resp.Content)
+	for i := 0; i < len(runes); i += fakeStreamChunkRunes {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		end := min(i+fakeStreamChunkRunes, len(runes))
+		if err := onDelta(string(runes[i:end])); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// Requests 受け取ったリクエストの一覧を取得
+func (p *FakeLLMProvider) Requests() []LLMRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]LLMRequest(nil), p.requests...)
+}
+
+// fakeLLMContent 最後のユーザーメッセージから決まる応答本文
+func fakeLLMContent(req LLMRequest) string {
+	var last string
+	for _, m := range req.Messages {
+		if m.Role == LLMRoleUser {
+			last = m.Content
+		}
+	}
+	h := fnv.New32a()
+	for _, m := range req.Messages {
+		h.Write([]byte(m.Role + "\x00" + m.Content + "\x00"))
+	}
+	return fmt.Sprintf("[fake:%08x] %s", h.Sum32(), truncateRunes(last, 100))
+}
+
+// fakeTokenCount 文字数からトークン数を概算（2文字で1トークン）
+func fakeTokenCount(s string) int {
+	return (len([]rune(s)) + 1) / 2
+}
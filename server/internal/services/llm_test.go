@@ -0,0 +1,261 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hostnote/server/internal/config"
+)
+
+// TestOpenAICompatibleProvider ベースURL・モデル・APIキーがリクエストに反映され、応答とトークン数が返ることをテスト
+func TestOpenAICompatibleProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer test-key" {
+			t.Errorf("Authorization = %q", got)
+		}
+		var req openAIChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if req.Model != "local-model" || len(req.Messages) != 2 || req.Messages[1].Content != "こんにちは" || req.MaxTokens != 100 {
+			t.Errorf("request = %+v", req)
+		}
+		w.Write([]byte(`{"model":"local-model-0613","choices":[{"message":{"content":"分析結果"}}],"usage":{"prompt_tokens":12,"completion_tokens":5,"total_tokens":17}}`))
+	}))
+	defer server.Close()
+
+	p := &OpenAICompatibleProvider{BaseURL: server.URL + "/v1", APIKey: "test-key", Model: "local-model"}
+	resp, err := p.Chat(context.Background(), LLMRequest{
+		Messages:  []LLMMessage{{Role: LLMRoleSystem, Content: "指示"}, {Role: LLMRoleUser, Content: "こんにちは"}},
+		MaxTokens: 100,
+	})
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if resp.Content != "分析結果" || resp.Model != "local-model-0613" {
+		t.Errorf("response = %+v", resp)
+	}
+	if resp.Usage != (LLMUsage{PromptTokens: 12, CompletionTokens: 5, TotalTokens: 17}) {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+}
+
+// TestOpenAICompatibleProviderError エラー応答がエラーとして返ることをテスト
+func TestOpenAICompatibleProviderError(t *testing.T) {
+	tests := []struct {
+		status  int
+		body    string
+		wantErr string
+	}{
+		{http.StatusUnauthorized, `{"error":{"message":"Incorrect API key","type":"invalid_request_error"}}`, "Incorrect API key"},
+		{http.StatusBadGateway, `<html>Bad Gateway</html>`, "status 502"},
+		{http.StatusOK, `{"choices":[]}`, "no choices"},
+	}
+
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "" {
+				t.Error("APIキーが空の場合は Authorization ヘッダーを付けない")
+			}
+			w.WriteHeader(tt.status)
+			w.Write([]byte(tt.body))
+		}))
+		p := &OpenAICompatibleProvider{BaseURL: server.URL, Model: "m"}
+		_, err := p.Chat(context.Background(), LLMRequest{Messages: []LLMMessage{{Role: LLMRoleUser, Content: "x"}}})
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("status %d: err = %v, want %q", tt.status, err, tt.wantErr)
+		}
+		server.Close()
+	}
+}
+
+// TestFakeLLMProvider 同じ入力には同じ応答を返し、リクエストが記録されることをテスト
+func TestFakeLLMProvider(t *testing.T) {
+	p := &FakeLLMProvider{}
+	req := LLMRequest{Messages: []LLMMessage{{Role: LLMRoleSystem, Content: "指示"}, {Role: LLMRoleUser, Content: "姫の分析をお願いします"}}}
+
+	first, err := p.Chat(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	second, _ := p.Chat(context.Background(), req)
+	if *first != *second {
+		t.Errorf("responses differ: %+v, %+v", first, second)
+	}
+	if !strings.HasPrefix(first.Content, "[fake:") || !strings.Contains(first.Content, "姫の分析をお願いします") {
+		t.Errorf("content = %q", first.Content)
+	}
+	if first.Model != FakeLLMModel || first.Usage.TotalTokens != first.Usage.PromptTokens+first.Usage.CompletionTokens {
+		t.Errorf("response = %+v", first)
+	}
+
+	other, _ := p.Chat(context.Background(), LLMRequest{Messages: []LLMMessage{{Role: LLMRoleUser, Content: "別の質問"}}})
+	if other.Content == first.Content {
+		t.Error("入力が違えば応答も変わる")
+	}
+	if len(p.Requests()) != 3 {
+		t.Errorf("recorded %d requests, want 3", len(p.Requests()))
+	}
+
+	failing := &FakeLLMProvider{Err: errors.New("boom")}
+	if _, err := failing.Chat(context.Background(), req); err == nil || err.Error() != "boom" {
+		t.Errorf("err = %v, want boom", err)
+	}
+}
+
+// TestInitLLMProvider 設定に応じたプロバイダーが選択されることをテスト
+func TestInitLLMProvider(t *testing.T) {
+	defer SetLLMProvider(disabledLLMProvider{})
+
+	tests := []struct {
+		name    string
+		cfg     config.Config
+		want    string
+		wantErr bool
+	}{
+		{"未設定", config.Config{}, "disabled", false},
+		{"APIキーのみ", config.Config{AIAPIKey: "sk-test", AIModel: "gpt-4o-mini"}, "openai", false},
+		{"互換サーバー（キーなし）", config.Config{AIProvider: "openai", AIBaseURL: "http://localhost:11434/v1/", AIModel: "llama3"}, "openai", false},
+		{"OpenAIでキーなし", config.Config{AIProvider: "openai", AIBaseURL: DefaultAIBaseURL}, "", true},
+		{"fake", config.Config{AIProvider: "fake", AIAPIKey: "sk-test"}, "fake", false},
+		{"none", config.Config{AIProvider: "none", AIAPIKey: "sk-test"}, "disabled", false},
+		{"不明", config.Config{AIProvider: "anthropic"}, "", true},
+	}
+
+	for _, tt := range tests {
+		SetLLMProvider(nil)
+		err := InitLLMProvider(&tt.cfg)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+
+		var got string
+		switch p := llmProvider.(type) {
+		case disabledLLMProvider:
+			got = "disabled"
+		case *FakeLLMProvider:
+			got = "fake"
+		case *OpenAICompatibleProvider:
+			got = "openai"
+			if strings.HasSuffix(p.BaseURL, "/") || p.Model != tt.cfg.AIModel {
+				t.Errorf("%s: provider = %+v", tt.name, p)
+			}
+		}
+		if got != tt.want {
+			t.Errorf("%s: provider = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestConversationAnalysisRequest 会話分析のプロンプトに入力が含まれることをテスト
+func TestConversationAnalysisRequest(t *testing.T) {
+	req, stats := ConversationAnalysisRequest(ConversationAnalysisInput{
+		SelfProfile: "レン", PartnerProfile: "あいり", Goal: "", ChatLog: "あいり: またね",
+	})
+	if stats != nil {
+		t.Errorf("LINEの形式でなければ集計しない: %+v", stats)
+	}
+	if len(req.Messages) != 2 || req.Messages[0].Role != LLMRoleSystem || req.Messages[1].Role != LLMRoleUser {
+		t.Fatalf("messages = %+v", req.Messages)
+	}
+	user := req.Messages[1].Content
+	for _, want := range []string{"レン", "あいり: またね", "（特になし）"} {
+		if !strings.Contains(user, want) {
+			t.Errorf("user message does not contain %q:\n%s", want, user)
+		}
+	}
+}
+
+// TestOpenAICompatibleProviderStream SSEで届いた断片が順に渡され、最後のチャンクのトークン数が反映されることをテスト
+func TestOpenAICompatibleProviderStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openAIChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if !req.Stream || req.StreamOptions == nil || !req.StreamOptions.IncludeUsage {
+			t.Errorf("request = %+v", req)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(": keep-alive\n\n" +
+			`data: {"model":"m-1","choices":[{"delta":{"role":"assistant","content":""}}]}` + "\n\n" +
+			`data: {"model":"m-1","choices":[{"delta":{"content":"現在の"}}]}` + "\n\n" +
+			`data: {"model":"m-1","choices":[{"delta":{"content":"関係性"}}]}` + "\n\n" +
+			`data: {"model":"m-1","choices":[],"usage":{"prompt_tokens":10,"completion_tokens":2,"total_tokens":12}}` + "\n\n" +
+			"data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	p := &OpenAICompatibleProvider{BaseURL: server.URL, Model: "m"}
+	var deltas []string
+	resp, err := p.ChatStream(context.Background(), LLMRequest{Messages: []LLMMessage{{Role: LLMRoleUser, Content: "x"}}}, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+	if strings.Join(deltas, "|") != "現在の|関係性" {
+		t.Errorf("deltas = %q", deltas)
+	}
+	if resp.Content != "現在の関係性" || resp.Model != "m-1" || resp.Usage.TotalTokens != 12 {
+		t.Errorf("response = %+v", resp)
+	}
+}
+
+// TestOpenAICompatibleProviderStreamAbort onDelta がエラーを返すと中断することをテスト
+func TestOpenAICompatibleProviderStreamAbort(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 3; i++ {
+			w.Write([]byte(`data: {"choices":[{"delta":{"content":"a"}}]}` + "\n\n"))
+		}
+	}))
+	defer server.Close()
+
+	stop := errors.New("stop")
+	calls := 0
+	p := &OpenAICompatibleProvider{BaseURL: server.URL, Model: "m"}
+	_, err := p.ChatStream(context.Background(), LLMRequest{}, func(string) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("err = %v, calls = %d", err, calls)
+	}
+}
+
+// TestFakeLLMProviderStream 断片をつなげると Chat と同じ応答になることをテスト
+func TestFakeLLMProviderStream(t *testing.T) {
+	p := &FakeLLMProvider{Response: "ホスト目線でわかりやすく分析した結果です"}
+	var b strings.Builder
+	chunks := 0
+	resp, err := p.ChatStream(context.Background(), LLMRequest{}, func(delta string) error {
+		b.WriteString(delta)
+		chunks++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+	if b.String() != resp.Content || chunks != 3 {
+		t.Errorf("streamed %q in %d chunks, want %q", b.String(), chunks, resp.Content)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.ChatStream(ctx, LLMRequest{}, func(string) error { return nil }); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
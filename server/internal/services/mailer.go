@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hostnote/server/internal/config"
+)
+
+// MailMessage 送信するメール
+type MailMessage struct {
+	To      string
+	Subject string
+	Body    string // text/plain
+}
+
+// Mailer メール送信の抽象（SMTP・ファイル・メモリを設定で切り替える）
+type Mailer interface {
+	Send(ctx context.Context, msg MailMessage) error
+}
+
+// memoryMailerLimit MemoryMailerが保持するメールの上限（古いものから破棄する）
+const memoryMailerLimit = 100
+
+var mailer Mailer = NewMemoryMailer()
+
+// InitMailer 設定に応じてメール送信方法を初期化
+// 本番（debug以外）でMAIL_DRIVERが未設定の場合は、パスワード再設定のメールが届かないため起動に失敗させる
+func InitMailer(cfg *config.Config) error {
+	if cfg.MailDriver == "" && cfg.Env != "debug" {
+		return fmt.Errorf("MAIL_DRIVER is required when GIN_MODE=%s", cfg.Env)
+	}
+
+	switch cfg.MailDriver {
+	case "smtp":
+		if cfg.SMTPHost == "" || cfg.MailFrom == "" {
+			return fmt.Errorf("SMTP_HOST and MAIL_FROM are required for MAIL_DRIVER=smtp")
+		}
+		mailer = &SMTPMailer{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.MailFrom,
+		}
+	case "file":
+		mailer = &FileMailer{Dir: cfg.MailFileDir}
+	case "", "memory":
+		if cfg.Env != "debug" {
+			log.Printf("⚠️  MAIL_DRIVER=memory: mail is not delivered (GIN_MODE=%s)", cfg.Env)
+		}
+		mailer = NewMemoryMailer()
+	default:
+		return fmt.Errorf("unknown MAIL_DRIVER: %s", cfg.MailDriver)
+	}
+	return nil
+}
+
+// SetMailer メール送信方法を差し替える（テスト用）
+func SetMailer(m Mailer) {
+	mailer = m
+}
+
+// SendMail 設定されたMailerでメールを送信
+func SendMail(ctx context.Context, msg MailMessage) error {
+	return mailer.Send(ctx, msg)
+}
+
+// SMTPMailer SMTPサーバー経由で送信（サーバーが対応していればSTARTTLSを使用）
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// Send SMTPでメールを送信
+func (m *SMTPMailer) Send(ctx context.Context, msg MailMessage) error {
+	addr := net.JoinHostPort(m.Host, m.Port)
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(addr, auth, m.From, []string{msg.To}, buildMIMEMessage(m.From, msg))
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// FileMailer メールを .eml ファイルとして保存（ローカル開発用）
+type FileMailer struct {
+	Dir string
+}
+
+// Send メールをファイルに書き出す
+func (m *FileMailer) Send(ctx context.Context, msg MailMessage) error {
+	dir := m.Dir
+	if dir == "" {
+		dir = "tmp/mail"
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s_%s.eml", time.Now().Format("20060102-150405.000000"), sanitizeFileName(msg.To))
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, buildMIMEMessage("noreply@localhost", msg), 0o600); err != nil {
+		return err
+	}
+	log.Printf("📧 Mail saved to %s", path)
+	return nil
+}
+
+// MemoryMailer 送信したメールをメモリに保持（テスト・開発用、直近 memoryMailerLimit 件まで）
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []MailMessage
+}
+
+// NewMemoryMailer MemoryMailerを作成
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+// Send メールをメモリに保存
+func (m *MemoryMailer) Send(ctx context.Context, msg MailMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	if len(m.messages) > memoryMailerLimit {
+		m.messages = append([]MailMessage(nil), m.messages[len(m.messages)-memoryMailerLimit:]...)
+	}
+	return nil
+}
+
+// Messages 保存されたメールの一覧を取得
+func (m *MemoryMailer) Messages() []MailMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]MailMessage(nil), m.messages...)
+}
+
+// buildMIMEMessage ヘッダー付きのメール本文を組み立てる（件名はUTF-8でエンコード）
+func buildMIMEMessage(from string, msg MailMessage) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + msg.To + "\r\n")
+	b.WriteString("Subject: " + mime.BEncoding.Encode("UTF-8", msg.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// sanitizeFileName メールアドレスをファイル名に使える形にする
+func sanitizeFileName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '.' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, s)
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hostnote/server/internal/config"
+)
+
+// TestMemoryMailer 送信したメールが保持されることをテスト
+func TestMemoryMailer(t *testing.T) {
+	m := NewMemoryMailer()
+	msg := MailMessage{To: "demo@example.com", Subject: "件名", Body: "本文"}
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	got := m.Messages()
+	if len(got) != 1 || got[0] != msg {
+		t.Errorf("Messages() = %v, want [%v]", got, msg)
+	}
+
+	// 上限を超えた分は古いものから破棄する
+	for i := 0; i < memoryMailerLimit; i++ {
+		m.Send(context.Background(), MailMessage{To: "demo@example.com", Subject: "新しい件名", Body: "本文"})
+	}
+	got = m.Messages()
+	if len(got) != memoryMailerLimit || got[0] == msg {
+		t.Errorf("len(Messages()) = %d, want %d without the oldest", len(got), memoryMailerLimit)
+	}
+}
+
+// TestFileMailer メールが .eml ファイルとして保存されることをテスト
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	m := &FileMailer{Dir: dir}
+	if err := m.Send(context.Background(), MailMessage{To: "demo@example.com", Subject: "パスワード再設定", Body: "1行目\n2行目"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("eml files = %v, err = %v", files, err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	for _, want := range []string{"To: demo@example.com\r\n", "Subject: =?UTF-8?b?", "1行目\r\n2行目"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("eml does not contain %q:\n%s", want, data)
+		}
+	}
+}
+
+// TestInitMailer 設定に応じたMailerが選択されることをテスト
+func TestInitMailer(t *testing.T) {
+	defer SetMailer(NewMemoryMailer())
+
+	tests := []struct {
+		cfg     config.Config
+		wantErr bool
+	}{
+		{config.Config{MailDriver: "memory"}, false},
+		{config.Config{MailDriver: "file", MailFileDir: t.TempDir()}, false},
+		{config.Config{MailDriver: "smtp", SMTPHost: "localhost", SMTPPort: "25", MailFrom: "noreply@example.com"}, false},
+		{config.Config{MailDriver: "smtp"}, true},
+		{config.Config{MailDriver: "unknown"}, true},
+		{config.Config{Env: "debug"}, false},
+		{config.Config{Env: "release"}, true},
+	}
+
+	for _, tt := range tests {
+		err := InitMailer(&tt.cfg)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("InitMailer(%q) error = %v, wantErr %v", tt.cfg.MailDriver, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hostnote/server/internal/models"
+	"gorm.io/gorm"
+)
+
+// 下書きの目的（フロントエンドの draftIntentOptions と対応）
+const (
+	DraftIntentThanks   = "thanks"   // 来店のお礼
+	DraftIntentBirthday = "birthday" // 誕生日のお祝い
+	DraftIntentDormant  = "dormant"  // しばらく来店がない姫への連絡
+	DraftIntentInvite   = "invite"   // 来店・イベントのお誘い
+)
+
+// 下書きの口調（フロントエンドの draftToneOptions と対応）
+const (
+	DraftToneSweet    = "sweet"    // 甘め
+	DraftToneFriendly = "friendly" // フランク
+	DraftTonePolite   = "polite"   // 丁寧
+)
+
+// DefaultDraftTones 口調を指定しない場合に作る下書き
+var DefaultDraftTones = []string{DraftToneSweet, DraftToneFriendly, DraftTonePolite}
+
+var (
+	// ErrInvalidDraftIntent 未対応の下書きの目的
+	ErrInvalidDraftIntent = errors.New("intent must be one of thanks, birthday, dormant, invite")
+	// ErrInvalidDraftTone 未対応の下書きの口調
+	ErrInvalidDraftTone = errors.New("tones must be sweet, friendly or polite")
+)
+
+// プロンプトに含める件数の上限
+const (
+	draftMaxTables = 5
+	draftMaxVisits = 5
+	// DraftNoteMaxLength 追加の指示の最大文字数
+	DraftNoteMaxLength = 500
+)
+
+// MessageDraftInput 営業LINEの下書きの対象
+type MessageDraftInput struct {
+	UserID uint
+	HimeID uint
+	Intent string
+	Tones  []string // 空の場合は DefaultDraftTones
+	Note   string   // 追加の指示（「来週のイベントに誘いたい」など）
+	Now    time.Time
+}
+
+// MessageDraft 口調ごとの下書き
+type MessageDraft struct {
+	Tone    string `json:"tone"` // AIの応答を読み取れなかった場合は空
+	Message string `json:"message"`
+}
+
+// MessageDrafts 下書きを作るために集めたデータ
+type MessageDrafts struct {
+	Input       MessageDraftInput
+	LastVisitAt *time.Time
+
+	hime          *models.Hime
+	tantoCastName string
+	self          *models.Cast // 自分のキャスト情報（未登録の場合はnil）
+	tables        []models.TableRecord
+	visits        []models.VisitRecord
+}
+
+// ValidDraftIntent 対応している下書きの目的か
+func ValidDraftIntent(intent string) bool {
+	switch intent {
+	case DraftIntentThanks, DraftIntentBirthday, DraftIntentDormant, DraftIntentInvite:
+		return true
+	}
+	return false
+}
+
+// CollectMessageDrafts 姫の好み・メモ・最近の卓記録と、自分のキャスト情報を集める
+// 指定した姫が存在しない場合は gorm.ErrRecordNotFound を返す
+func CollectMessageDrafts(ctx context.Context, db *gorm.DB, input MessageDraftInput) (*MessageDrafts, error) {
+	if !ValidDraftIntent(input.Intent) {
+		return nil, ErrInvalidDraftIntent
+	}
+	tones, err := normalizeDraftTones(input.Tones)
+	if err != nil {
+		return nil, err
+	}
+	input.Tones = tones
+	if input.Now.IsZero() {
+		input.Now = time.Now()
+	}
+	db = db.WithContext(ctx)
+
+	d := &MessageDrafts{Input: input}
+
+	var hime models.Hime
+	if err := db.Where("user_id = ? AND id = ?", input.UserID, input.HimeID).First(&hime).Error; err != nil {
+		return nil, err
+	}
+	d.hime = &hime
+	if hime.TantoCastID != nil {
+		var cast models.Cast
+		if err := db.Select("id, name").First(&cast, *hime.TantoCastID).Error; err == nil {
+			d.tantoCastName = cast.Name
+		}
+	}
+
+	var self models.Cast
+	if err := db.Where("user_id = ?", input.UserID).First(&self).Error; err == nil {
+		d.self = &self
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// 最近の卓記録と来店記録（メモを話題に使う、取り消した卓は除く）
+	if err := db.Where("user_id = ? AND datetime < ? AND status <> ? AND id IN (?)", input.UserID, input.Now, models.TableStatusVoided,
+		db.Model(&models.TableHime{}).Select("table_id").Where("hime_id = ?", input.HimeID)).
+		Order("datetime DESC").Limit(draftMaxTables).Find(&d.tables).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("user_id = ? AND hime_id = ? AND visit_date < ?", input.UserID, input.HimeID, input.Now).
+		Order("visit_date DESC").Limit(draftMaxVisits).Find(&d.visits).Error; err != nil {
+		return nil, err
+	}
+
+	var last time.Time
+	if len(d.tables) > 0 {
+		last = d.tables[0].Datetime
+	}
+	if len(d.visits) > 0 && d.visits[0].VisitDate.After(last) {
+		last = d.visits[0].VisitDate
+	}
+	if !last.IsZero() {
+		d.LastVisitAt = &last
+	}
+	return d, nil
+}
+
+// normalizeDraftTones 口調の重複を除き、未指定の場合は既定の口調にする
+func normalizeDraftTones(tones []string) ([]string, error) {
+	if len(tones) == 0 {
+		return DefaultDraftTones, nil
+	}
+	seen := map[string]bool{}
+	result := make([]string, 0, len(tones))
+	for _, tone := range tones {
+		if draftToneLabel(tone) == "" {
+			return nil, ErrInvalidDraftTone
+		}
+		if !seen[tone] {
+			seen[tone] = true
+			result = append(result, tone)
+		}
+	}
+	return result, nil
+}
+
+// Request 集めたデータをもとに下書きのプロンプトを組み立てる
+// 口調ごとの下書きをJSONで返すよう指示する
+func (d *MessageDrafts) Request() LLMRequest {
+	return LLMRequest{
+		Messages: []LLMMessage{
+			{Role: LLMRoleSystem, Content: d.systemPrompt()},
+			{Role: LLMRoleUser, Content: d.Prompt()},
+		},
+		Temperature: 0.9,
+		MaxTokens:   1200,
+		JSON:        true,
+	}
+}
+
+// systemPrompt 下書きの書き方と出力形式の指示
+func (d *MessageDrafts) systemPrompt() string {
+	var b strings.Builder
+	b.WriteString(`あなたは日本のホストクラブで働くホストの代わりに、姫（お客様）へ送るLINEの文面を考えるアシスタントです。
+与えられた姫のプロフィール・好み・メモ・最近の来店の様子と、ホスト自身のプロフィールをもとに、ホスト本人が送る自然なメッセージを日本語で作成してください。
+・メモにある話題や好みにさりげなく触れ、その姫のために書いた文面だとわかるようにする
+・データにない出来事や約束を作らない
+・金額や売上には触れない
+・LINEで送りやすい長さ（2〜6行程度）にし、絵文字は口調に合わせて控えめに使う
+
+`)
+	fmt.Fprintf(&b, "目的: %s\n", draftIntentInstruction(d.Input.Intent))
+	b.WriteString("\n次の口調ごとに1つずつ下書きを作成してください：\n")
+	for _, tone := range d.Input.Tones {
+		fmt.Fprintf(&b, "- %s: %s\n", tone, draftToneLabel(tone))
+	}
+	b.WriteString(`
+出力は次の形式のJSONオブジェクトのみとし、説明文は付けないでください：
+{"drafts":[{"tone":"口調のキー","message":"メッセージ本文"}]}`)
+	return b.String()
+}
+
+// Prompt 下書きに使うデータをプロンプト用のテキストにする
+func (d *MessageDrafts) Prompt() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "【今日の日付】\n%s\n\n", formatDate(d.Input.Now))
+
+	b.WriteString("【姫のプロフィール・好み】\n")
+	writeHimeProfile(&b, d.hime, d.tantoCastName)
+	if d.LastVisitAt != nil {
+		fmt.Fprintf(&b, "- 最終来店: %s（%d日前）\n", formatDate(*d.LastVisitAt), int(d.Input.Now.Sub(*d.LastVisitAt).Hours()/24))
+	} else {
+		b.WriteString("- 来店記録なし\n")
+	}
+	b.WriteString("\n")
+
+	if d.self != nil {
+		b.WriteString("【自分（ホスト）のプロフィール】\n")
+		writeCastProfile(&b, d.self)
+		b.WriteString("\n")
+	}
+
+	if len(d.tables) > 0 {
+		fmt.Fprintf(&b, "【最近の卓記録（新しい順、最大%d件）】\n", draftMaxTables)
+		for _, r := range d.tables {
+			fmt.Fprintf(&b, "- %s", formatDateTime(r.Datetime))
+			if s := r.SalesInfo; s != nil && len(s.OrderItems) > 0 {
+				items := make([]string, len(s.OrderItems))
+				for i, item := range s.OrderItems {
+					items[i] = fmt.Sprintf("%s×%d", item.Name, item.Quantity)
+				}
+				fmt.Fprintf(&b, " / 注文: %s", strings.Join(items, "、"))
+			}
+			if r.Memo != nil && *r.Memo != "" {
+				fmt.Fprintf(&b, " / メモ: %s", truncateRunes(*r.Memo, analysisMemoMaxRunes))
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+	}
+
+	if len(d.visits) > 0 {
+		fmt.Fprintf(&b, "【最近の来店記録（新しい順、最大%d件）】\n", draftMaxVisits)
+		for _, v := range d.visits {
+			fmt.Fprintf(&b, "- %s", formatDateTime(v.VisitDate))
+			if v.Memo != nil && *v.Memo != "" {
+				fmt.Fprintf(&b, " メモ: %s", truncateRunes(*v.Memo, analysisMemoMaxRunes))
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+	}
+
+	if note := strings.TrimSpace(d.Input.Note); note != "" {
+		fmt.Fprintf(&b, "【追加の指示】\n%s\n", truncateRunes(note, DraftNoteMaxLength))
+	}
+	return b.String()
+}
+
+// ParseMessageDrafts AIの応答から口調ごとの下書きを取り出す
+// JSONとして読み取れない場合は、応答全体を1つの下書きとして返す
+func ParseMessageDrafts(content string) []MessageDraft {
+	var parsed struct {
+		Drafts []MessageDraft `json:"drafts"`
+	}
+	start, end := strings.Index(content, "{"), strings.LastIndex(content, "}")
+	if start >= 0 && end > start {
+		if err := json.Unmarshal([]byte(content[start:end+1]), &parsed); err == nil {
+			drafts := make([]MessageDraft, 0, len(parsed.Drafts))
+			for _, draft := range parsed.Drafts {
+				draft.Message = strings.TrimSpace(draft.Message)
+				if draft.Message != "" {
+					drafts = append(drafts, draft)
+				}
+			}
+			if len(drafts) > 0 {
+				return drafts
+			}
+		}
+	}
+	if content = strings.TrimSpace(content); content == "" {
+		return []MessageDraft{}
+	}
+	return []MessageDraft{{Message: content}}
+}
+
+// writeCastProfile 自分のキャスト情報
+func writeCastProfile(b *strings.Builder, c *models.Cast) {
+	fmt.Fprintf(b, "- 名前: %s\n", c.Name)
+	if c.Age != nil {
+		fmt.Fprintf(b, "- 年齢: %d歳\n", *c.Age)
+	}
+	if c.Birthday != nil && *c.Birthday != "" {
+		fmt.Fprintf(b, "- 誕生日: %s\n", *c.Birthday)
+	}
+	if len(c.Memos) > 0 {
+		memos := append(models.Memos(nil), c.Memos...)
+		sort.SliceStable(memos, func(i, j int) bool { return memos[i].CreatedAt > memos[j].CreatedAt })
+		fmt.Fprintf(b, "- メモ（新しい順、最大%d件）:\n", analysisMaxMemos)
+		for i, m := range memos {
+			if i >= analysisMaxMemos {
+				break
+			}
+			fmt.Fprintf(b, "  - %s\n", truncateRunes(m.Content, analysisMemoMaxRunes))
+		}
+	}
+}
+
+// draftIntentInstruction 目的ごとの指示
+func draftIntentInstruction(intent string) string {
+	switch intent {
+	case DraftIntentThanks:
+		return "来店のお礼。最近の来店の様子（メモや注文）に触れて、楽しかった気持ちと感謝を伝える"
+	case DraftIntentBirthday:
+		return "誕生日のお祝い。登録されている誕生日に合わせて特別感のあるお祝いを伝える（来店の催促はしない）"
+	case DraftIntentDormant:
+		return "しばらく来店がない姫への連絡。最終来店からの日数を踏まえ、押しつけがましくならないよう近況を気にかける（来店を強く催促しない）"
+	case DraftIntentInvite:
+		return "次の来店やイベントのお誘い。好みや前回の話題に触れて、会いたい気持ちを自然に伝える"
+	}
+	return intent
+}
+
+// draftToneLabel 口調の説明（未対応の口調は空）
+func draftToneLabel(tone string) string {
+	switch tone {
+	case DraftToneSweet:
+		return "甘め（特別扱いしている気持ちが伝わる、恋人のような距離感）"
+	case DraftToneFriendly:
+		return "フランク（友達のような軽いノリ）"
+	case DraftTonePolite:
+		return "丁寧（落ち着いた大人っぽい言葉づかい）"
+	}
+	return ""
+}
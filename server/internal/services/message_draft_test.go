@@ -0,0 +1,104 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hostnote/server/internal/models"
+)
+
+// TestNormalizeDraftTones 口調の既定値・重複除去・未対応の口調をテスト
+func TestNormalizeDraftTones(t *testing.T) {
+	tests := []struct {
+		tones   []string
+		want    []string
+		wantErr bool
+	}{
+		{nil, DefaultDraftTones, false},
+		{[]string{"polite", "sweet", "polite"}, []string{"polite", "sweet"}, false},
+		{[]string{"sweet", "angry"}, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := normalizeDraftTones(tt.tones)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("normalizeDraftTones(%v) error = %v, wantErr %v", tt.tones, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("normalizeDraftTones(%v) = %v, want %v", tt.tones, got, tt.want)
+		}
+	}
+}
+
+// TestMessageDraftsRequest 下書きのプロンプトに姫・自分のプロフィール・最近のメモが含まれることをテスト
+func TestMessageDraftsRequest(t *testing.T) {
+	now := time.Date(2024, 5, 31, 12, 0, 0, 0, time.Local)
+	lastVisit := now.AddDate(0, 0, -40)
+	drink := "濃いめ"
+	tableMemo := "次はシャンパンタワーしたいと言っていた"
+
+	d := &MessageDrafts{
+		Input:       MessageDraftInput{UserID: 1, HimeID: 1, Intent: DraftIntentDormant, Tones: []string{DraftToneSweet, DraftTonePolite}, Note: "来週のイベントに触れて", Now: now},
+		LastVisitAt: &lastVisit,
+		hime:        &models.Hime{ID: 1, Name: "あいり", DrinkPreference: &drink},
+		self:        &models.Cast{Name: "レン", Memos: models.Memos{{Content: "趣味はサウナ", CreatedAt: "2024-05-01"}}},
+		tables: []models.TableRecord{{Datetime: lastVisit, Memo: &tableMemo, SalesInfo: &models.SalesInfo{
+			OrderItems: []models.OrderItem{{Name: "シャンパン", Quantity: 1}},
+		}}},
+	}
+
+	req := d.Request()
+	if !req.JSON || len(req.Messages) != 2 {
+		t.Fatalf("unexpected request: %+v", req)
+	}
+	system, prompt := req.Messages[0].Content, req.Messages[1].Content
+	for _, want := range []string{"しばらく来店がない", "- sweet: 甘め", "- polite: 丁寧", `{"drafts":`} {
+		if !strings.Contains(system, want) {
+			t.Errorf("system prompt does not contain %q\n%s", want, system)
+		}
+	}
+	if strings.Contains(system, "- friendly:") {
+		t.Error("指定していない口調が含まれている")
+	}
+	for _, want := range []string{
+		"名前: あいり", "お酒の濃さ: 濃いめ", "最終来店: 2024-04-21（40日前）",
+		"【自分（ホスト）のプロフィール】\n- 名前: レン", "趣味はサウナ",
+		"注文: シャンパン×1", "メモ: " + tableMemo, "【追加の指示】\n来週のイベントに触れて",
+	} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt does not contain %q\n%s", want, prompt)
+		}
+	}
+}
+
+// TestParseMessageDrafts AIの応答から下書きを取り出せることをテスト
+func TestParseMessageDrafts(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []MessageDraft
+	}{
+		{
+			name:    "json",
+			content: "```json\n{\"drafts\":[{\"tone\":\"sweet\",\"message\":\" 昨日はありがとう \"},{\"tone\":\"polite\",\"message\":\"\"}]}\n```",
+			want:    []MessageDraft{{Tone: "sweet", Message: "昨日はありがとう"}},
+		},
+		{
+			name:    "plain text",
+			content: " 昨日はありがとう！ ",
+			want:    []MessageDraft{{Message: "昨日はありがとう！"}},
+		},
+		{
+			name:    "empty",
+			content: "",
+			want:    []MessageDraft{},
+		},
+	}
+	for _, tt := range tests {
+		if got := ParseMessageDrafts(tt.content); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ParseMessageDrafts() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"strconv"
@@ -31,22 +32,37 @@ func (ns *NotificationScheduler) Start() {
 	log.Println("✅ Notification scheduler started")
 }
 
-// checkAndSendNotifications 通知をチェックして送信
+// checkAndSendNotifications 通知をチェックして送信（通知設定は店舗ごと）
 func (ns *NotificationScheduler) checkAndSendNotifications() {
-	// 来店予定通知をチェック
-	ns.checkVisitNotifications()
-	// 誕生日通知をチェック
-	ns.checkBirthdayNotifications()
+	var stores []models.Store
+	if err := ns.db.Find(&stores).Error; err != nil {
+		log.Printf("Error fetching stores for notification: %v", err)
+		return
+	}
+
+	for _, store := range stores {
+		// 来店予定通知をチェック
+		ns.checkVisitNotifications(store.ID)
+		// 誕生日通知をチェック
+		ns.checkBirthdayNotifications(store.ID)
+		// 売掛の期限超過通知をチェック
+		ns.checkReceivableNotifications(store.ID)
+	}
+}
+
+// storeMemberIDs 店舗に所属するユーザーIDのサブクエリ
+func (ns *NotificationScheduler) storeMemberIDs(storeID uint) *gorm.DB {
+	return ns.db.Model(&models.StoreMember{}).Select("user_id").Where("store_id = ?", storeID)
 }
 
 // checkVisitNotifications 来店予定通知をチェック
-func (ns *NotificationScheduler) checkVisitNotifications() {
+func (ns *NotificationScheduler) checkVisitNotifications(storeID uint) {
 	now := time.Now()
 
 	// 通知設定を取得（デフォルト: 30分前）
 	notificationMinutes := 30
 	var setting models.Setting
-	if err := ns.db.Where("`key` = ?", "visit_notification_minutes").First(&setting).Error; err == nil {
+	if err := ns.db.Where("store_id = ? AND `key` = ?", storeID, "visit_notification_minutes").First(&setting).Error; err == nil {
 		if minutes, err := parseInt(setting.Value); err == nil && minutes > 0 {
 			notificationMinutes = minutes
 		}
@@ -62,6 +78,7 @@ func (ns *NotificationScheduler) checkVisitNotifications() {
 	var schedules []models.Schedule
 	if err := ns.db.
 		Where("scheduled_datetime >= ? AND scheduled_datetime <= ? AND notification_sent = ?", startTime, endTime, false).
+		Where("user_id IN (?)", ns.storeMemberIDs(storeID)).
 		Preload("Hime").
 		Preload("User").
 		Find(&schedules).Error; err != nil {
@@ -84,7 +101,7 @@ func (ns *NotificationScheduler) checkVisitNotifications() {
 		// 通知を送信
 		himeName := "不明"
 		if schedule.Hime != nil {
-			himeName = schedule.Hime.Name
+			himeName = string(schedule.Hime.Name)
 		}
 
 		title := "来店予定のお知らせ"
@@ -115,14 +132,14 @@ func (ns *NotificationScheduler) checkVisitNotifications() {
 }
 
 // checkBirthdayNotifications 誕生日通知をチェック
-func (ns *NotificationScheduler) checkBirthdayNotifications() {
+func (ns *NotificationScheduler) checkBirthdayNotifications(storeID uint) {
 	now := time.Now()
 	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
 
 	// 通知設定を取得（デフォルト: 1日前）
 	notificationDays := 1
 	var setting models.Setting
-	if err := ns.db.Where("`key` = ?", "birthday_notification_days").First(&setting).Error; err == nil {
+	if err := ns.db.Where("store_id = ? AND `key` = ?", storeID, "birthday_notification_days").First(&setting).Error; err == nil {
 		if days, err := parseInt(setting.Value); err == nil && days >= 0 {
 			notificationDays = days
 		}
@@ -142,6 +159,7 @@ func (ns *NotificationScheduler) checkBirthdayNotifications() {
 	var himes []models.Hime
 	if err := ns.db.
 		Where("birthday IS NOT NULL AND birthday != ''").
+		Where("user_id IN (?)", ns.storeMemberIDs(storeID)).
 		Preload("User").
 		Find(&himes).Error; err != nil {
 		log.Printf("Error fetching himes for birthday notification: %v", err)
@@ -152,6 +170,7 @@ func (ns *NotificationScheduler) checkBirthdayNotifications() {
 	var casts []models.Cast
 	if err := ns.db.
 		Where("birthday IS NOT NULL AND birthday != '' AND user_id IS NOT NULL").
+		Where("user_id IN (?)", ns.storeMemberIDs(storeID)).
 		Preload("User").
 		Find(&casts).Error; err != nil {
 		log.Printf("Error fetching casts for birthday notification: %v", err)
@@ -166,7 +185,7 @@ func (ns *NotificationScheduler) checkBirthdayNotifications() {
 
 	for _, hime := range himes {
 		if hime.Birthday != nil && *hime.Birthday != "" {
-			birthday, err := time.Parse("2006-01-02", *hime.Birthday)
+			birthday, err := time.Parse("2006-01-02", string(*hime.Birthday))
 			if err != nil {
 				continue
 			}
@@ -174,7 +193,7 @@ func (ns *NotificationScheduler) checkBirthdayNotifications() {
 				birthdayPeople = append(birthdayPeople, struct {
 					name   string
 					userID uint
-				}{name: hime.Name, userID: hime.UserID})
+				}{name: string(hime.Name), userID: hime.UserID})
 			}
 		}
 	}
@@ -239,6 +258,97 @@ func (ns *NotificationScheduler) checkBirthdayNotifications() {
 	}
 }
 
+// checkReceivableNotifications 回収期限を過ぎた売掛を通知（1日1回、設定した時刻以降）
+func (ns *NotificationScheduler) checkReceivableNotifications(storeID uint) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	// 通知設定を取得（デフォルト: 12時）
+	reminderHour := 12
+	var setting models.Setting
+	if err := ns.db.Where("store_id = ? AND `key` = ?", storeID, "receivable_reminder_hour").First(&setting).Error; err == nil {
+		if hour, err := parseInt(setting.Value); err == nil && hour >= 0 && hour < 24 {
+			reminderHour = hour
+		}
+	}
+	if now.Hour() < reminderHour {
+		return
+	}
+
+	// 期限を過ぎ、今日まだ通知していない売掛を取得
+	scope := ns.db.
+		Where("due_date < ? AND (reminder_sent_at IS NULL OR reminder_sent_at < ?)", today, today).
+		Where("user_id IN (?)", ns.storeMemberIDs(storeID))
+	receivables, err := LoadReceivables(context.Background(), scope, now)
+	if err != nil {
+		log.Printf("Error fetching receivables for notification: %v", err)
+		return
+	}
+
+	// ユーザーごとにまとめる
+	userReceivables := make(map[uint][]Receivable)
+	for _, r := range OverdueReceivables(receivables) {
+		userReceivables[r.UserID] = append(userReceivables[r.UserID], r)
+	}
+
+	for userID, list := range userReceivables {
+		// ユーザーのプッシュトークンを取得
+		var tokens []models.PushToken
+		if err := ns.db.Where("user_id = ?", userID).Find(&tokens).Error; err != nil {
+			log.Printf("Error fetching push tokens for user %d: %v", userID, err)
+			continue
+		}
+
+		if len(tokens) == 0 {
+			continue
+		}
+
+		// 通知を送信
+		title := "売掛の回収期限のお知らせ"
+		body := formatReceivableReminder(list)
+
+		tokenStrings := make([]string, len(tokens))
+		for i, t := range tokens {
+			tokenStrings[i] = t.Token
+		}
+
+		data := map[string]string{
+			"type": "receivable",
+		}
+
+		if err := SendNotificationToMultiple(tokenStrings, title, body, data); err != nil {
+			log.Printf("Error sending receivable notification: %v", err)
+			continue
+		}
+
+		// 通知日時を更新（同じ日に再通知しない）
+		ids := make([]uint, len(list))
+		for i, r := range list {
+			ids[i] = r.ID
+		}
+		if err := ns.db.Model(&models.Payment{}).Where("id IN ?", ids).Update("reminder_sent_at", now).Error; err != nil {
+			log.Printf("Error updating payment reminder_sent_at: %v", err)
+		}
+	}
+}
+
+// formatReceivableReminder 期限超過の売掛の通知文を作成
+func formatReceivableReminder(list []Receivable) string {
+	var total float64
+	for _, r := range list {
+		total += r.Outstanding
+	}
+	himeName := list[0].HimeName
+	if himeName == "" {
+		himeName = "不明"
+	}
+	body := fmt.Sprintf("%sさんの売掛の回収期限が過ぎています（未回収 %.0f円）", himeName, list[0].Outstanding)
+	if len(list) > 1 {
+		body = fmt.Sprintf("%sさんなど%d件の売掛の回収期限が過ぎています（未回収 合計%.0f円）", himeName, len(list), total)
+	}
+	return body
+}
+
 // parseInt 文字列を整数に変換
 func parseInt(s string) (int, error) {
 	return strconv.Atoi(s)
@@ -77,3 +77,16 @@ func TestParseInt(t *testing.T) {
 		}
 	}
 }
+
+// TestFormatReceivableReminder 売掛の期限超過通知の文面をテスト
+func TestFormatReceivableReminder(t *testing.T) {
+	one := []Receivable{{HimeName: "あい", Outstanding: 20000}}
+	if got, want := formatReceivableReminder(one), "あいさんの売掛の回収期限が過ぎています（未回収 20000円）"; got != want {
+		t.Errorf("formatReceivableReminder() = %q, want %q", got, want)
+	}
+
+	many := append(one, Receivable{HimeName: "ゆめ", Outstanding: 5000})
+	if got, want := formatReceivableReminder(many), "あいさんなど2件の売掛の回収期限が過ぎています（未回収 合計25000円）"; got != want {
+		t.Errorf("formatReceivableReminder() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hostnote/server/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrTableNotOpen 接客中ではない卓への注文・会計
+	ErrTableNotOpen = errors.New("接客中の卓ではありません")
+	// ErrTableNotClosed 会計済みの卓にのみできる操作（支払い・再オープン）
+	ErrTableNotClosed = errors.New("会計済みの卓ではありません")
+	// ErrTableClosed 会計済み・取り消し済みの卓の変更
+	ErrTableClosed = errors.New("会計済みの卓は変更できません。変更する場合は卓を再オープンしてください")
+	// ErrTableHasPayments 支払いを登録済みの卓の再オープン・取り消し
+	ErrTableHasPayments = errors.New("支払いを登録済みの卓は再オープン・取り消しできません。先に支払いを削除してください")
+	// ErrTableHasDocuments 領収書・請求書を発行済みの卓の再オープン・取り消し
+	ErrTableHasDocuments = errors.New("領収書・請求書を発行済みの卓は再オープン・取り消しできません")
+)
+
+// LockTable 卓記録を行ロックして取得（状態の変更と注文の追加を直列化する）
+func LockTable(tx *gorm.DB, userID, tableID uint) (*models.TableRecord, error) {
+	var record models.TableRecord
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND id = ?", userID, tableID).
+		First(&record).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// LockOpenTable 接客中の卓記録を行ロックして取得
+func LockOpenTable(tx *gorm.DB, userID, tableID uint) (*models.TableRecord, error) {
+	record, err := LockTable(tx, userID, tableID)
+	if err != nil {
+		return nil, err
+	}
+	if record.Status != models.TableStatusOpen {
+		return nil, ErrTableNotOpen
+	}
+	return record, nil
+}
+
+// StayHours 入店から指定日時までの滞在時間（時間）
+func StayHours(start, end time.Time) float64 {
+	if !end.After(start) {
+		return 0
+	}
+	return end.Sub(start).Hours()
+}
+
+// OrderItemsFromLines 注文をメニュー・姫・注文時の単価ごとにまとめて売上情報の注文アイテムにする
+// 注文時の単価を記録した注文はその単価で、記録のない注文は会計の計算で単価が決まる
+func OrderItemsFromLines(lines []models.TableOrder) []models.OrderItem {
+	type key struct {
+		menuID, himeID uint
+		fixed          bool
+		unitPrice      float64
+	}
+	index := make(map[key]int)
+	items := []models.OrderItem{}
+	for _, l := range lines {
+		k := key{menuID: l.MenuID, himeID: l.HimeID}
+		if l.UnitPrice != nil {
+			k.fixed, k.unitPrice = true, *l.UnitPrice
+		}
+		if i, ok := index[k]; ok {
+			items[i].Quantity += l.Quantity
+			continue
+		}
+		index[k] = len(items)
+		item := models.OrderItem{MenuID: l.MenuID, HimeID: l.HimeID, Quantity: l.Quantity}
+		if k.fixed {
+			unitPrice := k.unitPrice
+			item.FixedUnitPrice = &unitPrice
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// RunningBill 接客中の卓の会計を計算する（延長は入店日時から until までの滞在時間で数える）
+// 再オープンした卓は、元の会計の滞在時間と料金ルールで計算する
+func RunningBill(ctx context.Context, db *gorm.DB, storeID uint, record *models.TableRecord, until time.Time) (*models.SalesInfo, error) {
+	var lines []models.TableOrder
+	if err := db.WithContext(ctx).Where("table_id = ?", record.ID).Order("ordered_at, id").Find(&lines).Error; err != nil {
+		return nil, err
+	}
+
+	order := &models.SalesInfo{}
+	if record.SalesInfo != nil {
+		order.VisitType = record.SalesInfo.VisitType
+		order.SplitMode = record.SalesInfo.SplitMode
+	}
+	order.OrderItems = OrderItemsFromLines(lines)
+	order.StayHours = StayHours(record.Datetime, until)
+	if record.ReopenedStayHours != nil {
+		order.StayHours = *record.ReopenedStayHours
+	}
+
+	var rule *models.PricingRule
+	var err error
+	if record.ReopenedPricingRuleVersion != nil {
+		rule, err = LoadPricingRuleVersion(ctx, db, storeID, *record.ReopenedPricingRuleVersion)
+	} else {
+		rule, err = LoadPricingRule(ctx, db, storeID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return calculateBillWithRule(ctx, db, storeID, rule, order, record.Datetime)
+}
+
+// LinesFromOrderItems 会計済みの売上情報の注文を卓の注文に戻す（再オープン用）
+// 単価は会計した時の単価のままにする（メニューの価格を変更していても元の金額で会計し直す）
+// メニューIDのない注文（メニュー登録前の記録など）は戻せないためエラーにする
+func LinesFromOrderItems(tableID, orderedBy uint, items []models.OrderItem, at time.Time) ([]models.TableOrder, error) {
+	lines := make([]models.TableOrder, 0, len(items))
+	for _, item := range items {
+		if item.MenuID == 0 {
+			return nil, fmt.Errorf("%w: %s（メニューが特定できないため再オープンできません）", ErrUnknownMenuItem, item.Name)
+		}
+		unitPrice := item.UnitPrice
+		lines = append(lines, models.TableOrder{
+			TableID:   tableID,
+			MenuID:    item.MenuID,
+			HimeID:    item.HimeID,
+			Quantity:  item.Quantity,
+			UnitPrice: &unitPrice,
+			OrderedBy: orderedBy,
+			OrderedAt: at,
+		})
+	}
+	return lines, nil
+}
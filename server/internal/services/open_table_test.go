@@ -0,0 +1,113 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hostnote/server/internal/models"
+)
+
+// TestStayHours 入店から指定日時までの滞在時間をテスト
+func TestStayHours(t *testing.T) {
+	start := time.Date(2026, 3, 1, 20, 0, 0, 0, time.Local)
+	tests := []struct {
+		name string
+		end  time.Time
+		want float64
+	}{
+		{"1時間半", start.Add(90 * time.Minute), 1.5},
+		{"入店時刻", start, 0},
+		{"入店前", start.Add(-time.Hour), 0},
+		{"日付をまたぐ", time.Date(2026, 3, 2, 1, 0, 0, 0, time.Local), 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StayHours(start, tt.end); got != tt.want {
+				t.Errorf("StayHours() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestOrderItemsFromLines 注文がメニューと姫・注文時の単価ごとにまとまることをテスト
+func TestOrderItemsFromLines(t *testing.T) {
+	lines := []models.TableOrder{
+		{MenuID: 1, Quantity: 2},
+		{MenuID: 2, HimeID: 5, Quantity: 1},
+		{MenuID: 1, Quantity: 1},
+		{MenuID: 2, HimeID: 6, Quantity: 1},
+		{MenuID: 2, HimeID: 5, Quantity: 3},
+	}
+	want := []models.OrderItem{
+		{MenuID: 1, Quantity: 3},
+		{MenuID: 2, HimeID: 5, Quantity: 4},
+		{MenuID: 2, HimeID: 6, Quantity: 1},
+	}
+
+	got := OrderItemsFromLines(lines)
+	if len(got) != len(want) {
+		t.Fatalf("len = %d, want %d (%+v)", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("items[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	if got := OrderItemsFromLines(nil); got == nil || len(got) != 0 {
+		t.Errorf("OrderItemsFromLines(nil) = %#v, want empty slice", got)
+	}
+
+	// 注文時の単価が異なる注文は別の品目にし、単価を引き継ぐ
+	oldPrice, newPrice := 5000.0, 6000.0
+	priced := OrderItemsFromLines([]models.TableOrder{
+		{MenuID: 1, Quantity: 1, UnitPrice: &oldPrice},
+		{MenuID: 1, Quantity: 2, UnitPrice: &newPrice},
+		{MenuID: 1, Quantity: 1, UnitPrice: &oldPrice},
+	})
+	if len(priced) != 2 || priced[0].Quantity != 2 || *priced[0].FixedUnitPrice != 5000 || priced[1].Quantity != 2 || *priced[1].FixedUnitPrice != 6000 {
+		t.Errorf("priced items = %+v, want 2 x 5000 and 2 x 6000", priced)
+	}
+}
+
+// TestLinesFromOrderItems 再オープン時に売上情報の注文を卓の注文に戻すことをテスト
+func TestLinesFromOrderItems(t *testing.T) {
+	at := time.Date(2026, 3, 1, 23, 0, 0, 0, time.Local)
+	items := []models.OrderItem{
+		{MenuID: 1, Name: "ボトル", Quantity: 2, UnitPrice: 5000, Amount: 10000},
+		{MenuID: 3, HimeID: 7, Name: "シャンパン", Quantity: 1, UnitPrice: 20000, Amount: 20000},
+	}
+
+	lines, err := LinesFromOrderItems(10, 2, items, at)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("len = %d, want 2", len(lines))
+	}
+	// 単価は会計した時の単価のまま戻す
+	if lines[1].UnitPrice == nil || *lines[1].UnitPrice != 20000 {
+		t.Errorf("lines[1].UnitPrice = %v, want 20000", lines[1].UnitPrice)
+	}
+	got := lines[1]
+	got.UnitPrice = nil
+	want := models.TableOrder{TableID: 10, MenuID: 3, HimeID: 7, Quantity: 1, OrderedBy: 2, OrderedAt: at}
+	if got != want {
+		t.Errorf("lines[1] = %+v, want %+v", got, want)
+	}
+
+	// 戻した注文は元の注文アイテムと同じ品目・数量になる
+	back := OrderItemsFromLines(lines)
+	for i, item := range back {
+		if item.MenuID != items[i].MenuID || item.HimeID != items[i].HimeID || item.Quantity != items[i].Quantity {
+			t.Errorf("round trip items[%d] = %+v, want menu %d hime %d x%d", i, item, items[i].MenuID, items[i].HimeID, items[i].Quantity)
+		}
+	}
+
+	// メニューIDのない注文は戻せない
+	_, err = LinesFromOrderItems(10, 2, []models.OrderItem{{Name: "手入力", Quantity: 1, Amount: 3000}}, at)
+	if !errors.Is(err, ErrUnknownMenuItem) {
+		t.Errorf("error = %v, want ErrUnknownMenuItem", err)
+	}
+}
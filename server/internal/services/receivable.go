@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hostnote/server/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInvalidPaymentMethod 支払い方法が不正
+	ErrInvalidPaymentMethod = errors.New("支払い方法は cash, card, tab のいずれかを指定してください")
+	// ErrInvalidPaymentAmount 支払い金額が不正
+	ErrInvalidPaymentAmount = errors.New("支払い金額は0より大きい値を指定してください")
+	// ErrPaymentExceedsBalance 支払い金額が残高を超えている
+	ErrPaymentExceedsBalance = errors.New("支払い金額が残高を超えています")
+	// ErrReceivableRepayment 売掛の回収を売掛で支払うことはできない
+	ErrReceivableRepayment = errors.New("売掛の回収は現金またはカードで記録してください")
+)
+
+// Receivable 売掛と回収状況
+type Receivable struct {
+	models.Payment
+	HimeName    string  `json:"himeName"`
+	Paid        float64 `json:"paid"`        // 回収済みの金額
+	Outstanding float64 `json:"outstanding"` // 未回収の金額
+	Overdue     bool    `json:"overdue"`     // 回収期限を過ぎて未回収
+}
+
+// HimeReceivables 姫ごとの売掛
+type HimeReceivables struct {
+	HimeID      uint         `json:"himeId"`
+	HimeName    string       `json:"himeName"`
+	Outstanding float64      `json:"outstanding"`
+	Overdue     float64      `json:"overdue"` // 期限超過分の未回収金額
+	Receivables []Receivable `json:"receivables"`
+}
+
+// ValidatePayment 支払いを検証する（売掛以外は回収期限を持たない）
+func ValidatePayment(p *models.Payment) error {
+	switch p.Method {
+	case models.PaymentMethodCash, models.PaymentMethodCard:
+		p.DueDate = nil
+	case models.PaymentMethodTab:
+		if p.ReceivableID != nil {
+			return ErrReceivableRepayment
+		}
+	default:
+		return ErrInvalidPaymentMethod
+	}
+	if p.Amount <= 0 {
+		return ErrInvalidPaymentAmount
+	}
+	return nil
+}
+
+// IsOverdue 回収期限（日付）を過ぎているか（期限日の当日中は期限内）
+func IsOverdue(dueDate *time.Time, now time.Time) bool {
+	if dueDate == nil {
+		return false
+	}
+	due := time.Date(dueDate.Year(), dueDate.Month(), dueDate.Day(), 0, 0, 0, 0, now.Location())
+	return !now.Before(due.AddDate(0, 0, 1))
+}
+
+// LoadReceivables 売掛と回収状況を取得
+// scope には売掛を絞り込む条件（user_id など）を指定したクエリを渡す
+func LoadReceivables(ctx context.Context, scope *gorm.DB, now time.Time) ([]Receivable, error) {
+	db := scope.Session(&gorm.Session{NewDB: true}).WithContext(ctx)
+
+	var tabs []models.Payment
+	if err := scope.WithContext(ctx).
+		Where("method = ?", models.PaymentMethodTab).
+		Order("due_date IS NULL, due_date, id").
+		Find(&tabs).Error; err != nil {
+		return nil, err
+	}
+	if len(tabs) == 0 {
+		return []Receivable{}, nil
+	}
+
+	tabIDs := make([]uint, len(tabs))
+	himeIDs := make([]uint, 0, len(tabs))
+	for i, t := range tabs {
+		tabIDs[i] = t.ID
+		himeIDs = append(himeIDs, t.HimeID)
+	}
+
+	var sums []struct {
+		ReceivableID uint
+		Paid         float64
+	}
+	if err := db.Model(&models.Payment{}).
+		Select("receivable_id, SUM(amount) AS paid").
+		Where("receivable_id IN ?", tabIDs).
+		Group("receivable_id").
+		Scan(&sums).Error; err != nil {
+		return nil, err
+	}
+	paid := make(map[uint]float64, len(sums))
+	for _, s := range sums {
+		paid[s.ReceivableID] = s.Paid
+	}
+
+	var himes []models.Hime
+	if err := db.Select("id, name").Where("id IN ?", himeIDs).Find(&himes).Error; err != nil {
+		return nil, err
+	}
+	names := make(map[uint]string, len(himes))
+	for _, h := range himes {
+		names[h.ID] = string(h.Name)
+	}
+
+	return BuildReceivables(tabs, paid, names, now), nil
+}
+
+// BuildReceivables 売掛ごとに回収済み金額から未回収の金額と期限超過を計算
+func BuildReceivables(tabs []models.Payment, paid map[uint]float64, himeNames map[uint]string, now time.Time) []Receivable {
+	receivables := make([]Receivable, len(tabs))
+	for i, t := range tabs {
+		outstanding := t.Amount - paid[t.ID]
+		if outstanding < 0 {
+			outstanding = 0
+		}
+		receivables[i] = Receivable{
+			Payment:     t,
+			HimeName:    himeNames[t.HimeID],
+			Paid:        paid[t.ID],
+			Outstanding: outstanding,
+			Overdue:     outstanding > 0 && IsOverdue(t.DueDate, now),
+		}
+	}
+	return receivables
+}
+
+// OutstandingReceivables 未回収の売掛だけを返す
+func OutstandingReceivables(receivables []Receivable) []Receivable {
+	result := []Receivable{}
+	for _, r := range receivables {
+		if r.Outstanding > 0 {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// OverdueReceivables 期限を過ぎて未回収の売掛だけを返す
+func OverdueReceivables(receivables []Receivable) []Receivable {
+	result := []Receivable{}
+	for _, r := range receivables {
+		if r.Overdue {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// GroupReceivablesByHime 売掛を姫ごとにまとめる（未回収の金額が多い順）
+func GroupReceivablesByHime(receivables []Receivable) []HimeReceivables {
+	index := make(map[uint]int)
+	groups := []HimeReceivables{}
+	for _, r := range receivables {
+		i, ok := index[r.HimeID]
+		if !ok {
+			i = len(groups)
+			index[r.HimeID] = i
+			groups = append(groups, HimeReceivables{HimeID: r.HimeID, HimeName: r.HimeName, Receivables: []Receivable{}})
+		}
+		g := &groups[i]
+		g.Outstanding += r.Outstanding
+		if r.Overdue {
+			g.Overdue += r.Outstanding
+		}
+		g.Receivables = append(g.Receivables, r)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].Outstanding > groups[j].Outstanding
+	})
+	return groups
+}
+
+// RecordTablePayment 卓記録の支払い（現金・カード・売掛）を記録する
+// 支払いは会計済みの卓にのみ登録でき、合計は会計の合計を超えられない
+// 会計や再オープンと同時に登録しても整合するよう卓記録の行をロックして確認する
+func RecordTablePayment(ctx context.Context, db *gorm.DB, record *models.TableRecord, payment *models.Payment) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		locked, err := LockTable(tx, record.UserID, record.ID)
+		if err != nil {
+			return err
+		}
+		if locked.Status != models.TableStatusClosed {
+			return ErrTableNotClosed
+		}
+		record = locked
+
+		if record.SalesInfo != nil && record.SalesInfo.Total > 0 {
+			var paid float64
+			if err := tx.Model(&models.Payment{}).
+				Select("COALESCE(SUM(amount), 0)").
+				Where("table_id = ? AND receivable_id IS NULL", record.ID).
+				Scan(&paid).Error; err != nil {
+				return err
+			}
+			if paid+payment.Amount > record.SalesInfo.Total {
+				return fmt.Errorf("%w（残り%.0f円）", ErrPaymentExceedsBalance, record.SalesInfo.Total-paid)
+			}
+		}
+
+		payment.TableID = record.ID
+		return tx.Create(payment).Error
+	})
+}
+
+// RecordReceivablePayment 売掛の回収（一部回収を含む）を記録する
+// 同時に回収を記録しても残高を超えないよう、売掛の行をロックして確認する
+func RecordReceivablePayment(ctx context.Context, db *gorm.DB, userID, receivableID uint, payment *models.Payment) error {
+	if payment.Method == models.PaymentMethodTab {
+		return ErrReceivableRepayment
+	}
+	if err := ValidatePayment(payment); err != nil {
+		return err
+	}
+
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var tab models.Payment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND user_id = ? AND method = ?", receivableID, userID, models.PaymentMethodTab).
+			First(&tab).Error; err != nil {
+			return err
+		}
+
+		var paid float64
+		if err := tx.Model(&models.Payment{}).
+			Select("COALESCE(SUM(amount), 0)").
+			Where("receivable_id = ?", tab.ID).
+			Scan(&paid).Error; err != nil {
+			return err
+		}
+		if paid+payment.Amount > tab.Amount {
+			return fmt.Errorf("%w（未回収%.0f円）", ErrPaymentExceedsBalance, tab.Amount-paid)
+		}
+
+		payment.UserID = userID
+		payment.TableID = tab.TableID
+		payment.HimeID = tab.HimeID
+		payment.ReceivableID = &tab.ID
+		return tx.Create(payment).Error
+	})
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hostnote/server/internal/models"
+)
+
+func receivableTestDate(day int) *time.Time {
+	t := time.Date(2024, 6, day, 0, 0, 0, 0, time.Local)
+	return &t
+}
+
+// TestValidatePayment 支払い方法と金額の検証をテスト
+func TestValidatePayment(t *testing.T) {
+	receivableID := uint(1)
+	tests := []struct {
+		name    string
+		payment models.Payment
+		want    error
+	}{
+		{"cash", models.Payment{Method: models.PaymentMethodCash, Amount: 1000}, nil},
+		{"tab", models.Payment{Method: models.PaymentMethodTab, Amount: 1000, DueDate: receivableTestDate(30)}, nil},
+		{"method", models.Payment{Method: "bitcoin", Amount: 1000}, ErrInvalidPaymentMethod},
+		{"amount", models.Payment{Method: models.PaymentMethodCard, Amount: 0}, ErrInvalidPaymentAmount},
+		{"tab repayment", models.Payment{Method: models.PaymentMethodTab, Amount: 1000, ReceivableID: &receivableID}, ErrReceivableRepayment},
+	}
+	for _, tt := range tests {
+		if err := ValidatePayment(&tt.payment); !errors.Is(err, tt.want) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	// 売掛以外の回収期限は保存しない
+	card := models.Payment{Method: models.PaymentMethodCard, Amount: 1000, DueDate: receivableTestDate(30)}
+	if err := ValidatePayment(&card); err != nil || card.DueDate != nil {
+		t.Errorf("ValidatePayment(card) = %v, dueDate = %v, want nil", err, card.DueDate)
+	}
+}
+
+// TestIsOverdue 期限日の当日中は期限内、翌日から期限超過になることをテスト
+func TestIsOverdue(t *testing.T) {
+	due := receivableTestDate(10)
+	tests := []struct {
+		now  time.Time
+		want bool
+	}{
+		{time.Date(2024, 6, 10, 23, 59, 0, 0, time.Local), false},
+		{time.Date(2024, 6, 11, 0, 0, 0, 0, time.Local), true},
+	}
+	for _, tt := range tests {
+		if got := IsOverdue(due, tt.now); got != tt.want {
+			t.Errorf("IsOverdue(%v) = %v, want %v", tt.now, got, tt.want)
+		}
+	}
+	if IsOverdue(nil, time.Now()) {
+		t.Error("IsOverdue(nil) = true, want false")
+	}
+}
+
+// TestGroupReceivablesByHime 回収済み金額から未回収・期限超過を計算し、姫ごとにまとめることをテスト
+func TestGroupReceivablesByHime(t *testing.T) {
+	tabs := []models.Payment{
+		{ID: 1, HimeID: 10, Method: models.PaymentMethodTab, Amount: 30000, DueDate: receivableTestDate(1)},
+		{ID: 2, HimeID: 20, Method: models.PaymentMethodTab, Amount: 50000, DueDate: receivableTestDate(30)},
+		{ID: 3, HimeID: 10, Method: models.PaymentMethodTab, Amount: 20000},
+		{ID: 4, HimeID: 20, Method: models.PaymentMethodTab, Amount: 10000, DueDate: receivableTestDate(1)},
+	}
+	paid := map[uint]float64{1: 10000, 4: 10000}
+	names := map[uint]string{10: "あい", 20: "ゆめ"}
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.Local)
+
+	receivables := BuildReceivables(tabs, paid, names, now)
+	if r := receivables[0]; r.Paid != 10000 || r.Outstanding != 20000 || !r.Overdue || r.HimeName != "あい" {
+		t.Errorf("receivable 1 = %+v", r)
+	}
+	if r := receivables[3]; r.Outstanding != 0 || r.Overdue {
+		t.Errorf("settled receivable = %+v, want not overdue", r)
+	}
+	if got := OverdueReceivables(receivables); len(got) != 1 || got[0].ID != 1 {
+		t.Errorf("OverdueReceivables() = %+v, want only ID 1", got)
+	}
+
+	groups := GroupReceivablesByHime(OutstandingReceivables(receivables))
+	if len(groups) != 2 {
+		t.Fatalf("len(groups) = %d, want 2", len(groups))
+	}
+	if g := groups[0]; g.HimeID != 20 || g.Outstanding != 50000 || g.Overdue != 0 || len(g.Receivables) != 1 {
+		t.Errorf("groups[0] = %+v", g)
+	}
+	if g := groups[1]; g.HimeID != 10 || g.Outstanding != 40000 || g.Overdue != 20000 || len(g.Receivables) != 2 {
+		t.Errorf("groups[1] = %+v", g)
+	}
+}
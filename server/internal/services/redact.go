@@ -0,0 +1,280 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hostnote/server/internal/models"
+	"gorm.io/gorm"
+)
+
+// 伏せる個人情報の種類（プレースホルダーは [PHONE_1] の形式）
+const (
+	redactKindURL     = "URL"
+	redactKindEmail   = "EMAIL"
+	redactKindPhone   = "PHONE"
+	redactKindAddress = "ADDRESS"
+	redactKindName    = "NAME"
+)
+
+// redactNameMinRunes これより短い名前は誤検出が多いため伏せない
+const redactNameMinRunes = 2
+
+// redactPlaceholderMaxLen プレースホルダーの最大長（ストリーミングで断片をまたぐ場合の保留に使う）
+const redactPlaceholderMaxLen = 24
+
+// redactionInstruction 伏せた箇所をそのまま使うようAIに伝える指示
+const redactionInstruction = "\n\n※ [NAME_1] や [PHONE_1] のような表記は個人情報を伏せたものです。推測して書き換えず、必要な場合はそのままの表記で使ってください。"
+
+const (
+	digit    = `[0-9０-９]`
+	phoneSep = `[-‐－−ー ]?`
+	kanjiNum = `[0-9０-９一二三四五六七八九十]+`
+	blockSep = `[-‐－−]`
+	// 番地: 1-2-3, 1丁目2番3号, 1番地 など
+	addressBlock = kanjiNum + `(?:丁目|番地|番|号|` + blockSep + `[0-9０-９]+)(?:` + kanjiNum + `(?:丁目|番地|番|号)|` + blockSep + `[0-9０-９]+)*`
+	// 市区町村と町名（都道府県がない場合は前の文章を巻き込まないよう漢字のみ）
+	municipality      = `[\p{Han}\p{Hiragana}\p{Katakana}]{1,6}?[市区町村郡]`
+	municipalityKanji = `\p{Han}{1,5}?[市区町村郡]`
+	townName          = `[\p{Han}\p{Hiragana}\p{Katakana}ー]{0,12}?`
+	prefecture        = `(?:東京都|北海道|京都府|大阪府|\p{Han}{2,3}県)`
+)
+
+var (
+	redactURLPattern   = regexp.MustCompile(`(?:https?://|www\.)[^\s<>"'（）()「」『』、。]+`)
+	redactEmailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	redactPhonePattern = regexp.MustCompile(`(?:\+81` + phoneSep + `|[0０])` + digit + `{1,4}` + phoneSep + digit + `{1,4}` + phoneSep + digit + `{3,4}`)
+	// 都道府県から始まる住所（番地は任意）と、市区町村から始まる番地付きの住所
+	redactAddressPattern = regexp.MustCompile(prefecture + municipality + `(?:` + townName + addressBlock + `)?|` + municipalityKanji + townName + addressBlock)
+	// AIが括弧を全角にしたり外したりしても復元できるようにする
+	redactRestorePattern = regexp.MustCompile(`[\[［【]?\b(URL|EMAIL|PHONE|ADDRESS|NAME)_(\d+)\b[\]］】]?`)
+)
+
+// Redactor AIに送る文章から個人情報をプレースホルダーに置き換え、AIの応答で元に戻す
+// 同じ値は同じプレースホルダーになるため、AIは別の人物・連絡先として区別できる
+type Redactor struct {
+	names    []string          // 既知の姫の名前（長い順）
+	values   map[string]string // プレースホルダーのキー（PHONE_1）→ 元の値
+	keys     map[string]string // 種類と元の値 → キー
+	counters map[string]int
+}
+
+// NewRedactor 既知の姫の名前を伏せる対象に加えてRedactorを作成
+func NewRedactor(names []string) *Redactor {
+	seen := make(map[string]bool, len(names))
+	r := &Redactor{values: map[string]string{}, keys: map[string]string{}, counters: map[string]int{}}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if utf8.RuneCountInString(name) < redactNameMinRunes || seen[name] {
+			continue
+		}
+		seen[name] = true
+		r.names = append(r.names, name)
+	}
+	// 「あいり」より先に「あいりん」を置き換える
+	sort.SliceStable(r.names, func(i, j int) bool {
+		return utf8.RuneCountInString(r.names[i]) > utf8.RuneCountInString(r.names[j])
+	})
+	return r
+}
+
+// Redact 個人情報をプレースホルダーに置き換える
+// URL内のメールアドレスや数字を二重に置き換えないよう、URL→メール→電話番号→住所→名前の順に処理する
+func (r *Redactor) Redact(s string) string {
+	s = r.replacePattern(s, redactURLPattern, redactKindURL, nil)
+	s = r.replacePattern(s, redactEmailPattern, redactKindEmail, nil)
+	s = r.replacePattern(s, redactPhonePattern, redactKindPhone, isPhoneNumber)
+	s = r.replacePattern(s, redactAddressPattern, redactKindAddress, nil)
+	for _, name := range r.names {
+		if strings.Contains(s, name) {
+			s = strings.ReplaceAll(s, name, r.placeholder(redactKindName, name))
+		}
+	}
+	return s
+}
+
+// Restore プレースホルダーを元の値に戻す（知らないプレースホルダーはそのまま）
+func (r *Redactor) Restore(s string) string {
+	if len(r.values) == 0 {
+		return s
+	}
+	return redactRestorePattern.ReplaceAllStringFunc(s, func(match string) string {
+		sub := redactRestorePattern.FindStringSubmatch(match)
+		if value, ok := r.values[sub[1]+"_"+sub[2]]; ok {
+			return value
+		}
+		return match
+	})
+}
+
+// RedactRequest AIへの指示以外のメッセージを伏せたリクエストを作成
+// 伏せた箇所があった場合は、そのまま使うよう指示を加える
+func (r *Redactor) RedactRequest(req LLMRequest) LLMRequest {
+	redacted := req
+	redacted.Messages = make([]LLMMessage, len(req.Messages))
+	for i, m := range req.Messages {
+		if m.Role != LLMRoleSystem {
+			m.Content = r.Redact(m.Content)
+		}
+		redacted.Messages[i] = m
+	}
+	if len(r.values) > 0 {
+		for i, m := range redacted.Messages {
+			if m.Role == LLMRoleSystem {
+				redacted.Messages[i].Content += redactionInstruction
+				break
+			}
+		}
+	}
+	return redacted
+}
+
+// replacePattern 正規表現に一致した箇所を置き換える（valid が false を返した箇所は残す）
+func (r *Redactor) replacePattern(s string, pattern *regexp.Regexp, kind string, valid func(s string, start, end int) bool) string {
+	matches := pattern.FindAllStringIndex(s, -1)
+	if len(matches) == 0 {
+		return s
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		if valid != nil && !valid(s, m[0], m[1]) {
+			continue
+		}
+		b.WriteString(s[last:m[0]])
+		b.WriteString(r.placeholder(kind, s[m[0]:m[1]]))
+		last = m[1]
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// placeholder 値に対応するプレースホルダーを取得（初めての値は番号を振る）
+func (r *Redactor) placeholder(kind, value string) string {
+	if key, ok := r.keys[kind+"\x00"+value]; ok {
+		return "[" + key + "]"
+	}
+	r.counters[kind]++
+	key := fmt.Sprintf("%s_%d", kind, r.counters[kind])
+	r.keys[kind+"\x00"+value] = key
+	r.values[key] = value
+	return "[" + key + "]"
+}
+
+// isPhoneNumber 桁数と前後の文字から電話番号か判定する（日付や金額の一部を除外）
+func isPhoneNumber(s string, start, end int) bool {
+	if before, _ := utf8.DecodeLastRuneInString(s[:start]); isDigitRune(before) {
+		return false
+	}
+	if after, _ := utf8.DecodeRuneInString(s[end:]); isDigitRune(after) {
+		return false
+	}
+
+	digits := 0
+	for _, c := range s[start:end] {
+		if isDigitRune(c) {
+			digits++
+		}
+	}
+	if strings.HasPrefix(s[start:end], "+81") {
+		return digits >= 11 && digits <= 12 // 81 + 先頭の0を除いた9〜10桁
+	}
+	return digits == 10 || digits == 11
+}
+
+// isDigitRune 半角・全角の数字か
+func isDigitRune(c rune) bool {
+	return (c >= '0' && c <= '9') || (c >= '０' && c <= '９')
+}
+
+// RedactionStream ストリーミングの断片をまたいだプレースホルダーを復元する
+type RedactionStream struct {
+	r       *Redactor
+	pending string
+}
+
+// Stream ストリーミング用の復元器を作成
+func (r *Redactor) Stream() *RedactionStream {
+	return &RedactionStream{r: r}
+}
+
+// Write 断片を受け取り、送ってよい部分を復元して返す
+// プレースホルダーの途中で切れている可能性がある末尾は次の断片まで保留する
+func (s *RedactionStream) Write(delta string) string {
+	s.pending += delta
+	split := len(s.pending)
+	for split > 0 && len(s.pending)-split < redactPlaceholderMaxLen {
+		c, size := utf8.DecodeLastRuneInString(s.pending[:split])
+		if !isPlaceholderRune(c) {
+			break
+		}
+		split -= size
+	}
+	if len(s.pending)-split >= redactPlaceholderMaxLen {
+		split = len(s.pending)
+	}
+
+	out := s.r.Restore(s.pending[:split])
+	s.pending = s.pending[split:]
+	return out
+}
+
+// Flush 保留していた残りを復元して返す
+func (s *RedactionStream) Flush() string {
+	out := s.r.Restore(s.pending)
+	s.pending = ""
+	return out
+}
+
+// isPlaceholderRune プレースホルダーに含まれうる文字か
+func isPlaceholderRune(c rune) bool {
+	return (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' || strings.ContainsRune("[]［］【】", c)
+}
+
+// HimeNames ユーザーの姫の名前一覧を取得（伏せる対象に使う）
+func HimeNames(ctx context.Context, db *gorm.DB, userID uint) ([]string, error) {
+	var names []models.EncryptedString
+	if err := db.WithContext(ctx).Model(&models.Hime{}).Where("user_id = ?", userID).Pluck("name", &names).Error; err != nil {
+		return nil, err
+	}
+	result := make([]string, len(names))
+	for i, name := range names {
+		result[i] = string(name)
+	}
+	return result, nil
+}
+
+// ChatRedacted 個人情報を伏せてAIに送り、応答で元に戻す
+func ChatRedacted(ctx context.Context, r *Redactor, req LLMRequest) (*LLMResponse, error) {
+	resp, err := Chat(ctx, r.RedactRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	resp.Content = r.Restore(resp.Content)
+	return resp, nil
+}
+
+// ChatStreamRedacted 個人情報を伏せてAIに送り、元に戻した断片を onDelta へ渡す
+func ChatStreamRedacted(ctx context.Context, r *Redactor, req LLMRequest, onDelta LLMDeltaFunc) (*LLMResponse, error) {
+	stream := r.Stream()
+	resp, err := ChatStream(ctx, r.RedactRequest(req), func(delta string) error {
+		if out := stream.Write(delta); out != "" {
+			return onDelta(out)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out := stream.Flush(); out != "" {
+		if err := onDelta(out); err != nil {
+			return nil, err
+		}
+	}
+	resp.Content = r.Restore(resp.Content)
+	return resp, nil
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestRedactorRedact 電話番号・メール・住所・URL・姫の名前がプレースホルダーに置き換わることをテスト
+func TestRedactorRedact(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"携帯電話", "電話は090-1234-5678だよ", "電話は[PHONE_1]だよ"},
+		{"区切りなし", "09012345678に連絡して", "[PHONE_1]に連絡して"},
+		{"全角・長音符区切り", "０９０ー１２３４ー５６７８", "[PHONE_1]"},
+		{"固定電話", "お店は03-1234-5678", "お店は[PHONE_1]"},
+		{"国際表記", "+81 90 1234 5678", "[PHONE_1]"},
+		{"日付は電話番号ではない", "2024-05-01に来店", "2024-05-01に来店"},
+		{"金額は電話番号ではない", "合計0120000円", "合計0120000円"},
+		{"メール", "aiko.t+line@example.co.jp に送った", "[EMAIL_1] に送った"},
+		{"URL", "インスタ https://instagram.com/airi_123?igsh=abc 見て", "インスタ [URL_1] 見て"},
+		{"URL内のメールは二重に伏せない", "http://example.com/?to=a@example.com", "[URL_1]"},
+		{"住所", "東京都新宿区歌舞伎町1-2-3に住んでる", "[ADDRESS_1]に住んでる"},
+		{"丁目番地", "大阪府大阪市中央区難波1丁目2番3号の近く", "[ADDRESS_1]の近く"},
+		{"都道府県なしの番地付き住所", "家は渋谷区道玄坂2-10-7です", "家は[ADDRESS_1]です"},
+		{"番地のない地名は残す", "新宿で飲んだ", "新宿で飲んだ"},
+		{"姫の名前", "あいりちゃんとみくが来た", "[NAME_1]ちゃんと[NAME_2]が来た"},
+		{"長い名前を優先", "あいりんって呼んで", "[NAME_1]って呼んで"},
+		{"同じ値は同じプレースホルダー", "090-1111-2222と090-3333-4444、また090-1111-2222", "[PHONE_1]と[PHONE_2]、また[PHONE_1]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRedactor([]string{"あいり", "あいりん", "みく", "愛", " "})
+			if got := r.Redact(tt.in); got != tt.want {
+				t.Errorf("Redact(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRedactorRestore AIの応答のプレースホルダーが元の値に戻ることをテスト
+func TestRedactorRestore(t *testing.T) {
+	r := NewRedactor([]string{"あいり"})
+	r.Redact("あいりの番号は090-1234-5678")
+
+	tests := map[string]string{
+		"[NAME_1]さんには[PHONE_1]へ連絡": "あいりさんには090-1234-5678へ連絡",
+		"NAME_1さんは優しい":             "あいりさんは優しい",
+		"【NAME_1】さん、［PHONE_1］":     "あいりさん、090-1234-5678",
+		"[NAME_2]さんと[EMAIL_1]":     "[NAME_2]さんと[EMAIL_1]",
+		"NAME_12":                  "NAME_12",
+	}
+	for in, want := range tests {
+		if got := r.Restore(in); got != want {
+			t.Errorf("Restore(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// TestRedactorRedactRequest 指示以外のメッセージだけを伏せ、伏せた場合は指示を加えることをテスト
+func TestRedactorRedactRequest(t *testing.T) {
+	req := LLMRequest{Messages: []LLMMessage{
+		{Role: LLMRoleSystem, Content: "あいりについて分析"},
+		{Role: LLMRoleUser, Content: "あいり: 090-1234-5678"},
+	}}
+
+	got := NewRedactor([]string{"あいり"}).RedactRequest(req)
+	if !strings.HasPrefix(got.Messages[0].Content, "あいりについて分析") || !strings.Contains(got.Messages[0].Content, "[NAME_1]") {
+		t.Errorf("system = %q", got.Messages[0].Content)
+	}
+	if got.Messages[1].Content != "[NAME_1]: [PHONE_1]" {
+		t.Errorf("user = %q", got.Messages[1].Content)
+	}
+	if req.Messages[1].Content != "あいり: 090-1234-5678" {
+		t.Error("元のリクエストを書き換えてはいけない")
+	}
+
+	plain := NewRedactor(nil).RedactRequest(LLMRequest{Messages: []LLMMessage{{Role: LLMRoleSystem, Content: "指示"}, {Role: LLMRoleUser, Content: "こんにちは"}}})
+	if plain.Messages[0].Content != "指示" {
+		t.Errorf("伏せた箇所がなければ指示は加えない: %q", plain.Messages[0].Content)
+	}
+}
+
+// TestRedactionStream 断片をまたいだプレースホルダーも復元されることをテスト
+func TestRedactionStream(t *testing.T) {
+	r := NewRedactor([]string{"あいり"})
+	r.Redact("あいり 090-1234-5678")
+
+	s := r.Stream()
+	var b strings.Builder
+	for _, delta := range []string{"今日は[NA", "ME_1]さん", "に[PHO", "NE_1", "]で連絡。ABC"} {
+		out := s.Write(delta)
+		if strings.Contains(out, "NAME_") || strings.Contains(out, "PHONE_") {
+			t.Errorf("placeholder leaked: %q", out)
+		}
+		b.WriteString(out)
+	}
+	b.WriteString(s.Flush())
+
+	if want := "今日はあいりさんに090-1234-5678で連絡。ABC"; b.String() != want {
+		t.Errorf("streamed %q, want %q", b.String(), want)
+	}
+}
+
+// TestChatRedacted AIには伏せた文章が送られ、応答は元に戻ることをテスト
+func TestChatRedacted(t *testing.T) {
+	fake := &FakeLLMProvider{Response: "[NAME_1]さんに[PHONE_1]から連絡しましょう"}
+	SetLLMProvider(fake)
+	defer SetLLMProvider(disabledLLMProvider{})
+
+	req := LLMRequest{Messages: []LLMMessage{{Role: LLMRoleSystem, Content: "指示"}, {Role: LLMRoleUser, Content: "あいりの番号は090-1234-5678"}}}
+
+	resp, err := ChatRedacted(context.Background(), NewRedactor([]string{"あいり"}), req)
+	if err != nil {
+		t.Fatalf("ChatRedacted: %v", err)
+	}
+	if resp.Content != "あいりさんに090-1234-5678から連絡しましょう" {
+		t.Errorf("content = %q", resp.Content)
+	}
+	sent := fake.Requests()[0].Messages[1].Content
+	if strings.Contains(sent, "あいり") || strings.Contains(sent, "090") {
+		t.Errorf("個人情報がAIに送られている: %q", sent)
+	}
+
+	var streamed strings.Builder
+	resp, err = ChatStreamRedacted(context.Background(), NewRedactor([]string{"あいり"}), req, func(delta string) error {
+		streamed.WriteString(delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ChatStreamRedacted: %v", err)
+	}
+	if streamed.String() != resp.Content || resp.Content != "あいりさんに090-1234-5678から連絡しましょう" {
+		t.Errorf("streamed %q, content %q", streamed.String(), resp.Content)
+	}
+}
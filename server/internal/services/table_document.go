@@ -0,0 +1,311 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hostnote/server/internal/models"
+	"gorm.io/gorm"
+)
+
+// 領収書・請求書の設定キー（店舗ごと）
+const (
+	SettingInvoiceRegistrationNumber = "invoice_registration_number" // 適格請求書発行事業者の登録番号（T＋13桁）
+	SettingInvoiceIssuerName         = "invoice_issuer_name"         // 発行事業者の名称（未設定の場合は店舗名）
+)
+
+var (
+	// ErrInvalidDocumentType 未対応の書類の種類
+	ErrInvalidDocumentType = errors.New("type は receipt, invoice のいずれかを指定してください")
+	// ErrInvalidRegistrationNumber 登録番号の形式が正しくない
+	ErrInvalidRegistrationNumber = errors.New("登録番号は T に続く13桁の数字で指定してください")
+	// ErrRegistrationNumberRequired 登録番号を設定せずに請求書を発行しようとした
+	ErrRegistrationNumberRequired = errors.New("請求書を発行するには設定で適格請求書発行事業者の登録番号を登録してください")
+	// ErrNothingReceived 受領済みの金額がない卓の領収書
+	ErrNothingReceived = errors.New("受領済みの金額がないため領収書を発行できません")
+	// ErrNothingOutstanding 未回収の売掛がない卓の請求書
+	ErrNothingOutstanding = errors.New("未回収の売掛がないため請求書を発行できません")
+)
+
+var registrationNumberPattern = regexp.MustCompile(`^T[0-9]{13}$`)
+
+// NormalizeRegistrationNumber 登録番号の表記（全角・ハイフン・小文字）をそろえて検証する
+func NormalizeRegistrationNumber(s string) (string, error) {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r >= '０' && r <= '９':
+			return r - '０' + '0'
+		case r == 'Ｔ' || r == 't':
+			return 'T'
+		case r == '-' || r == '－' || r == ' ' || r == '　':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(s))
+	if !registrationNumberPattern.MatchString(s) {
+		return "", ErrInvalidRegistrationNumber
+	}
+	return s, nil
+}
+
+// DocumentLine 書類の明細行
+type DocumentLine struct {
+	Name      string
+	Quantity  int
+	UnitPrice float64
+	Amount    float64
+}
+
+// TaxBreakdown 税率ごとの対象金額（税込）と消費税額
+type TaxBreakdown struct {
+	Rate   float64
+	Amount float64
+	Tax    float64
+}
+
+// DocumentPayments 卓の支払い状況
+type DocumentPayments struct {
+	Received    float64    // 受領済みの金額（現金・カード、売掛の回収を含む）
+	Outstanding float64    // 未回収の売掛
+	DueDate     *time.Time // 未回収の売掛のうち最も早い回収期限
+}
+
+// TableDocument 卓記録から作る領収書・請求書
+type TableDocument struct {
+	Type               string
+	Number             string
+	Reissue            bool
+	IssuedAt           time.Time
+	IssuerName         string
+	StoreName          string
+	RegistrationNumber string // 未設定の場合は空（領収書のみ発行できる）
+	Addressee          string
+	Description        string // 領収書の但し書き
+	TransactionDate    time.Time
+	Sales              models.SalesInfo
+	Lines              []DocumentLine
+	TaxBreakdown       []TaxBreakdown
+	Payments           DocumentPayments
+	Amount             float64 // 領収金額・請求金額
+}
+
+// TableDocumentInput 書類の発行条件
+type TableDocumentInput struct {
+	UserID      uint
+	StoreID     uint
+	TableID     uint
+	Type        string
+	Addressee   string
+	Description string // 省略時は「飲食代」
+	Now         time.Time
+}
+
+// DocumentLines 売上情報を書類の明細行にする（消費税は明細に含めない）
+func DocumentLines(s *models.SalesInfo) []DocumentLine {
+	lines := []DocumentLine{}
+	add := func(name string, quantity int, unitPrice, amount float64) {
+		if amount != 0 {
+			lines = append(lines, DocumentLine{Name: name, Quantity: quantity, UnitPrice: unitPrice, Amount: amount})
+		}
+	}
+
+	if s.SetPrice == 0 && s.ExtensionFee == 0 && s.Surcharge == 0 {
+		// 料金ルール導入前の記録はテーブルチャージの内訳がない
+		add("テーブルチャージ", 1, s.TableCharge, s.TableCharge)
+	} else {
+		add("セット料金", 1, s.SetPrice, s.SetPrice)
+		if s.ExtensionCount > 0 {
+			add("延長料金", s.ExtensionCount, s.ExtensionFee/float64(s.ExtensionCount), s.ExtensionFee)
+		}
+		add("深夜料金等", 1, s.Surcharge, s.Surcharge)
+	}
+	for _, item := range s.OrderItems {
+		add(item.Name, item.Quantity, item.UnitPrice, item.Amount)
+	}
+	add("指名料", 1, s.ShimeiFee, s.ShimeiFee)
+	if s.ServiceCharge != 0 {
+		add(fmt.Sprintf("サービス料（%g%%）", s.ServiceChargeRate), 1, s.ServiceCharge, s.ServiceCharge)
+	}
+	return lines
+}
+
+// TaxBreakdownOf 税率ごとの内訳（税込の合計を対象額とし、税込みの定額料金に含まれる消費税は切り捨てで加える）
+func TaxBreakdownOf(s *models.SalesInfo) []TaxBreakdown {
+	if s.TaxRate <= 0 {
+		return []TaxBreakdown{}
+	}
+	included := math.Floor(s.TaxIncluded * s.TaxRate / (100 + s.TaxRate))
+	return []TaxBreakdown{{Rate: s.TaxRate, Amount: s.Total, Tax: s.Tax + included}}
+}
+
+// SummarizeTablePayments 卓の支払い（売掛の回収を含む）から受領済みの金額と未回収の売掛をまとめる
+// 支払いの記録がない卓は会計時に全額を受領したものとする
+func SummarizeTablePayments(payments []models.Payment, total float64) DocumentPayments {
+	if len(payments) == 0 {
+		return DocumentPayments{Received: total}
+	}
+
+	repaid := make(map[uint]float64)
+	for _, p := range payments {
+		if p.ReceivableID != nil {
+			repaid[*p.ReceivableID] += p.Amount
+		}
+	}
+
+	var summary DocumentPayments
+	for _, p := range payments {
+		if p.Method != models.PaymentMethodTab {
+			summary.Received += p.Amount
+			continue
+		}
+		outstanding := p.Amount - repaid[p.ID]
+		if outstanding <= 0 {
+			continue
+		}
+		summary.Outstanding += outstanding
+		if p.DueDate != nil && (summary.DueDate == nil || p.DueDate.Before(*summary.DueDate)) {
+			due := *p.DueDate
+			summary.DueDate = &due
+		}
+	}
+	return summary
+}
+
+// IssueTableDocument 会計済みの卓記録の領収書・請求書を発行し、PDFを返す
+// 発行の記録（書類番号・再発行）とPDFの作成は同じトランザクションで行い、作成に失敗した場合は記録しない
+func IssueTableDocument(ctx context.Context, db *gorm.DB, in TableDocumentInput) (*TableDocument, []byte, error) {
+	if in.Type != models.DocumentTypeReceipt && in.Type != models.DocumentTypeInvoice {
+		return nil, nil, ErrInvalidDocumentType
+	}
+
+	var doc *TableDocument
+	var pdf []byte
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if doc, err = buildTableDocument(tx, in); err != nil {
+			return err
+		}
+
+		var issued int64
+		if err := tx.Model(&models.IssuedDocument{}).
+			Where("user_id = ? AND table_id = ? AND type = ?", in.UserID, in.TableID, in.Type).
+			Count(&issued).Error; err != nil {
+			return err
+		}
+		record := models.IssuedDocument{
+			UserID:    in.UserID,
+			TableID:   in.TableID,
+			Type:      in.Type,
+			Addressee: models.EncryptedString(in.Addressee),
+			Amount:    doc.Amount,
+			Reissue:   issued > 0,
+			IssuedAt:  in.Now,
+		}
+		if err := tx.Create(&record).Error; err != nil {
+			return err
+		}
+		// 書類番号は発行の記録のIDで採番する
+		prefix := "R"
+		if in.Type == models.DocumentTypeInvoice {
+			prefix = "I"
+		}
+		record.Number = fmt.Sprintf("%s-%06d", prefix, record.ID)
+		if err := tx.Model(&record).Update("number", record.Number).Error; err != nil {
+			return err
+		}
+		doc.Number = record.Number
+		doc.Reissue = record.Reissue
+
+		var buf bytes.Buffer
+		if err := RenderTableDocument(doc, &buf); err != nil {
+			return err
+		}
+		pdf = buf.Bytes()
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return doc, pdf, nil
+}
+
+// buildTableDocument 卓記録・支払い・店舗の設定から書類の内容を組み立てる
+func buildTableDocument(tx *gorm.DB, in TableDocumentInput) (*TableDocument, error) {
+	var record models.TableRecord
+	if err := tx.Where("user_id = ? AND id = ?", in.UserID, in.TableID).First(&record).Error; err != nil {
+		return nil, err
+	}
+	if record.Status != models.TableStatusClosed || record.SalesInfo == nil {
+		return nil, ErrTableNotClosed
+	}
+
+	var payments []models.Payment
+	if err := tx.Where("table_id = ?", record.ID).Order("paid_at, id").Find(&payments).Error; err != nil {
+		return nil, err
+	}
+
+	var store models.Store
+	if err := tx.Select("id, name").First(&store, in.StoreID).Error; err != nil {
+		return nil, err
+	}
+	var settings []models.Setting
+	if err := tx.Where("store_id = ? AND `key` IN ?", in.StoreID,
+		[]string{SettingInvoiceRegistrationNumber, SettingInvoiceIssuerName}).Find(&settings).Error; err != nil {
+		return nil, err
+	}
+
+	doc := &TableDocument{
+		Type:            in.Type,
+		IssuedAt:        in.Now,
+		IssuerName:      store.Name,
+		StoreName:       store.Name,
+		Addressee:       strings.TrimSpace(in.Addressee),
+		Description:     strings.TrimSpace(in.Description),
+		TransactionDate: record.Datetime,
+		Sales:           *record.SalesInfo,
+		Lines:           DocumentLines(record.SalesInfo),
+		TaxBreakdown:    TaxBreakdownOf(record.SalesInfo),
+		Payments:        SummarizeTablePayments(payments, record.SalesInfo.Total),
+	}
+	if doc.Description == "" {
+		doc.Description = "飲食代"
+	}
+	for _, s := range settings {
+		switch s.Key {
+		case SettingInvoiceRegistrationNumber:
+			if strings.TrimSpace(s.Value) == "" {
+				continue
+			}
+			number, err := NormalizeRegistrationNumber(s.Value)
+			if err != nil {
+				return nil, fmt.Errorf("設定の%w", err)
+			}
+			doc.RegistrationNumber = number
+		case SettingInvoiceIssuerName:
+			if name := strings.TrimSpace(s.Value); name != "" {
+				doc.IssuerName = name
+			}
+		}
+	}
+
+	switch in.Type {
+	case models.DocumentTypeReceipt:
+		if doc.Amount = doc.Payments.Received; doc.Amount <= 0 {
+			return nil, ErrNothingReceived
+		}
+	case models.DocumentTypeInvoice:
+		if doc.RegistrationNumber == "" {
+			return nil, ErrRegistrationNumberRequired
+		}
+		if doc.Amount = doc.Payments.Outstanding; doc.Amount <= 0 {
+			return nil, ErrNothingOutstanding
+		}
+	}
+	return doc, nil
+}
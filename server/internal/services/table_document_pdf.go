@@ -0,0 +1,288 @@
+package services
+
+import (
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/hostnote/server/internal/config"
+	"github.com/hostnote/server/internal/models"
+	"github.com/signintech/gopdf"
+)
+
+// documentFontFamily PDF内でのフォント名
+const documentFontFamily = "jp"
+
+// defaultDocumentFont 同梱の日本語フォント（M+ 1p、ライセンスは fonts/LICENSE）
+//
+//go:embed fonts/mplus-1p-regular.ttf
+var defaultDocumentFont []byte
+
+// documentFont PDFに埋め込む日本語フォント（TrueType）
+var documentFont = defaultDocumentFont
+
+// 書類のレイアウト（A4縦、mm）
+const (
+	documentMargin    = 20.0
+	documentHeight    = 297.0
+	documentWidth     = 170.0 // 余白を除いた幅
+	documentLineH     = 7.0
+	documentNameWidth = 90.0
+	documentQtyWidth  = 20.0
+	documentNumWidth  = 30.0
+)
+
+// InitDocumentFont 領収書・請求書PDFのフォントを読み込む（未設定の場合は同梱のフォントを使う）
+func InitDocumentFont(cfg *config.Config) error {
+	if cfg.PDFFontPath == "" {
+		documentFont = defaultDocumentFont
+		return nil
+	}
+	font, err := os.ReadFile(cfg.PDFFontPath)
+	if err != nil {
+		return err
+	}
+	// 読み込めないフォントは起動時に検出する
+	if _, err := newDocumentPDF(font); err != nil {
+		return fmt.Errorf("%s: %w", cfg.PDFFontPath, err)
+	}
+	documentFont = font
+	return nil
+}
+
+// SetDocumentFont PDFに埋め込むフォントを差し替える（テスト用、nil は同梱のフォント）
+func SetDocumentFont(font []byte) {
+	if font == nil {
+		font = defaultDocumentFont
+	}
+	documentFont = font
+}
+
+// documentPDF 書類のPDF（セルを左上から順に並べ、最初のエラーを保持する）
+type documentPDF struct {
+	pdf gopdf.GoPdf
+	err error
+}
+
+// cellStyle セルの書式
+type cellStyle struct {
+	align  int  // gopdf.Left, gopdf.Center, gopdf.Right
+	border int  // gopdf.AllBorders, gopdf.Bottom など
+	fill   bool // 背景を塗る
+	ln     bool // 書いた後に次の行の左端へ移動する（false は右隣へ）
+}
+
+// newDocumentPDF A4縦のPDFを作成してフォントを登録する
+func newDocumentPDF(font []byte) (*documentPDF, error) {
+	p := &documentPDF{}
+	p.pdf.Start(gopdf.Config{PageSize: *gopdf.PageSizeA4, Unit: gopdf.UnitMM})
+	if err := p.pdf.AddTTFFontData(documentFontFamily, font); err != nil {
+		return nil, err
+	}
+	p.pdf.SetMargins(documentMargin, documentMargin, documentMargin, documentMargin)
+	p.pdf.SetTextColor(0, 0, 0)
+	p.pdf.SetLineWidth(0.2)
+	p.pdf.SetFillColor(240, 240, 240)
+	return p, nil
+}
+
+// setFont フォントサイズを変更
+func (p *documentPDF) setFont(size float64) {
+	if p.err == nil {
+		p.err = p.pdf.SetFont(documentFontFamily, "", size)
+	}
+}
+
+// cell 現在位置にセルを書く
+func (p *documentPDF) cell(w, h float64, text string, style cellStyle) {
+	if p.err != nil {
+		return
+	}
+	x, y := p.pdf.GetX(), p.pdf.GetY()
+	// 下余白にかかる場合は改ページする
+	if y+h > documentHeight-documentMargin {
+		p.pdf.AddPage()
+		y = documentMargin
+		p.pdf.SetXY(x, y)
+	}
+	if style.fill {
+		p.pdf.RectFromUpperLeftWithStyle(x, y, w, h, "F")
+	}
+	if style.align == 0 {
+		style.align = gopdf.Left
+	}
+	opt := gopdf.CellOption{Align: style.align | gopdf.Middle, Border: style.border}
+	if err := p.pdf.CellWithOption(&gopdf.Rect{W: w, H: h}, text, opt); err != nil {
+		p.err = err
+		return
+	}
+	if style.ln {
+		p.pdf.SetXY(documentMargin, y+h)
+		return
+	}
+	p.pdf.SetXY(x+w, y)
+}
+
+// ln 改行して左端へ移動する
+func (p *documentPDF) ln(h float64) {
+	p.pdf.SetXY(documentMargin, p.pdf.GetY()+h)
+}
+
+// setX 行内の位置を左余白からの距離で指定する
+func (p *documentPDF) setX(offset float64) {
+	p.pdf.SetX(documentMargin + offset)
+}
+
+// fitText 幅に収まらない文字列を末尾を省略して切り詰める
+func (p *documentPDF) fitText(s string, width float64) string {
+	if p.err != nil || p.textWidth(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && p.textWidth(string(runes)+"…") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
+
+// textWidth 現在のフォントでの文字列の幅
+func (p *documentPDF) textWidth(s string) float64 {
+	w, err := p.pdf.MeasureTextWidth(s)
+	if err != nil && p.err == nil {
+		p.err = err
+	}
+	return w
+}
+
+// RenderTableDocument 領収書・請求書をPDFにする（フォントはサブセットとしてPDFに埋め込む）
+func RenderTableDocument(doc *TableDocument, w io.Writer) error {
+	title := "領収書"
+	if doc.Type == models.DocumentTypeInvoice {
+		title = "請求書"
+	}
+	if doc.Reissue {
+		title += "（再発行）"
+	}
+
+	p, err := newDocumentPDF(documentFont)
+	if err != nil {
+		return err
+	}
+	p.pdf.SetInfo(gopdf.PdfInfo{Title: title + " " + doc.Number, Author: doc.IssuerName, CreationDate: doc.IssuedAt})
+	p.pdf.AddPage()
+	p.pdf.SetXY(documentMargin, documentMargin)
+
+	// 表題と書類番号・発行日
+	p.setFont(22)
+	p.cell(documentWidth, 14, title, cellStyle{align: gopdf.Center, ln: true})
+	p.setFont(10)
+	p.cell(documentWidth, 5, "No. "+doc.Number, cellStyle{align: gopdf.Right, ln: true})
+	p.cell(documentWidth, 5, "発行日 "+formatJapaneseDate(doc.IssuedAt), cellStyle{align: gopdf.Right, ln: true})
+	p.ln(4)
+
+	// 宛名（領収書で省略した場合は「上様」）
+	addressee := doc.Addressee
+	if addressee == "" && doc.Type == models.DocumentTypeReceipt {
+		addressee = "上"
+	}
+	p.setFont(14)
+	p.cell(100, 9, p.fitText(addressee, 90)+" 様", cellStyle{border: gopdf.Bottom, ln: true})
+	p.ln(6)
+
+	// 金額
+	label := "金額"
+	if doc.Type == models.DocumentTypeInvoice {
+		label = "ご請求金額"
+	}
+	p.setFont(12)
+	p.cell(40, 14, label, cellStyle{align: gopdf.Center, border: gopdf.AllBorders, fill: true})
+	p.setFont(20)
+	p.cell(80, 14, formatYen(doc.Amount)+"-", cellStyle{align: gopdf.Center, border: gopdf.AllBorders, ln: true})
+	p.setFont(10)
+	switch doc.Type {
+	case models.DocumentTypeReceipt:
+		p.cell(120, 6, "但し "+p.fitText(doc.Description, 100)+"として", cellStyle{ln: true})
+		p.cell(120, 6, "上記正に領収いたしました。", cellStyle{ln: true})
+	case models.DocumentTypeInvoice:
+		p.cell(120, 6, "下記の通りご請求申し上げます。", cellStyle{ln: true})
+		if doc.Payments.DueDate != nil {
+			p.cell(120, 6, "お支払期限 "+formatJapaneseDate(*doc.Payments.DueDate), cellStyle{ln: true})
+		}
+	}
+	p.ln(4)
+
+	// 発行事業者
+	issuer := []string{doc.IssuerName}
+	if doc.StoreName != "" && doc.StoreName != doc.IssuerName {
+		issuer = append(issuer, doc.StoreName)
+	}
+	if doc.RegistrationNumber != "" {
+		issuer = append(issuer, "登録番号 "+doc.RegistrationNumber)
+	}
+	for _, line := range issuer {
+		p.setX(100)
+		p.cell(documentWidth-100, 6, p.fitText(line, documentWidth-100), cellStyle{ln: true})
+	}
+	p.ln(4)
+
+	// 明細
+	header := cellStyle{align: gopdf.Center, border: gopdf.AllBorders, fill: true}
+	p.cell(documentWidth, 6, "ご利用日 "+formatJapaneseDate(doc.TransactionDate), cellStyle{ln: true})
+	p.cell(documentNameWidth, documentLineH, "品目", header)
+	p.cell(documentQtyWidth, documentLineH, "数量", header)
+	p.cell(documentNumWidth, documentLineH, "単価", header)
+	p.cell(documentNumWidth, documentLineH, "金額", cellStyle{align: gopdf.Center, border: gopdf.AllBorders, fill: true, ln: true})
+	for _, line := range doc.Lines {
+		p.cell(documentNameWidth, documentLineH, p.fitText(line.Name, documentNameWidth-2), cellStyle{border: gopdf.AllBorders})
+		p.cell(documentQtyWidth, documentLineH, fmt.Sprintf("%d", line.Quantity), cellStyle{align: gopdf.Right, border: gopdf.AllBorders})
+		p.cell(documentNumWidth, documentLineH, formatYen(line.UnitPrice), cellStyle{align: gopdf.Right, border: gopdf.AllBorders})
+		p.cell(documentNumWidth, documentLineH, formatYen(line.Amount), cellStyle{align: gopdf.Right, border: gopdf.AllBorders, ln: true})
+	}
+
+	// 合計（消費税は外税の金額）
+	totals := []struct {
+		label  string
+		amount float64
+	}{
+		{fmt.Sprintf("消費税（%g%%）", doc.Sales.TaxRate), doc.Sales.Tax},
+		{"合計", doc.Sales.Total},
+	}
+	if doc.Payments.Received > 0 && doc.Type == models.DocumentTypeInvoice {
+		totals = append(totals, struct {
+			label  string
+			amount float64
+		}{"お支払い済み", doc.Payments.Received})
+	}
+	for _, t := range totals {
+		p.setX(documentNameWidth + documentQtyWidth)
+		p.cell(documentNumWidth, documentLineH, t.label, header)
+		p.cell(documentNumWidth, documentLineH, formatYen(t.amount), cellStyle{align: gopdf.Right, border: gopdf.AllBorders, ln: true})
+	}
+	p.ln(4)
+
+	// 税率ごとの内訳（適格請求書の記載事項）
+	p.cell(documentWidth, 6, "税率ごとの内訳", cellStyle{ln: true})
+	if len(doc.TaxBreakdown) == 0 {
+		p.cell(documentWidth, 6, "消費税の対象となる取引はありません", cellStyle{ln: true})
+	}
+	for _, b := range doc.TaxBreakdown {
+		p.cell(documentWidth, 6, fmt.Sprintf("%g%%対象 %s（税込）　消費税 %s", b.Rate, formatYen(b.Amount), formatYen(b.Tax)), cellStyle{ln: true})
+	}
+	if doc.Sales.TaxIncluded > 0 {
+		p.cell(documentWidth, 6, "※初回料金は税込みの金額です", cellStyle{ln: true})
+	}
+
+	if p.err != nil {
+		return p.err
+	}
+	_, err = p.pdf.WriteTo(w)
+	return err
+}
+
+// formatJapaneseDate 日付を「2026年3月1日」形式にする
+func formatJapaneseDate(t time.Time) string {
+	t = t.In(time.Local)
+	return fmt.Sprintf("%d年%d月%d日", t.Year(), t.Month(), t.Day())
+}
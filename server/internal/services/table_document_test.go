@@ -0,0 +1,167 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hostnote/server/internal/config"
+	"github.com/hostnote/server/internal/models"
+)
+
+// TestNormalizeRegistrationNumber 登録番号の表記ゆれと不正な形式をテスト
+func TestNormalizeRegistrationNumber(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"T1234567890123", "T1234567890123", false},
+		{" t1234-5678-90123 ", "T1234567890123", false},
+		{"Ｔ１２３４５６７８９０１２３", "T1234567890123", false},
+		{"1234567890123", "", true},
+		{"T123456789012", "", true},
+		{"T12345678901234", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeRegistrationNumber(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr && !errors.Is(err, ErrInvalidRegistrationNumber) {
+			t.Errorf("%q: error = %v, want ErrInvalidRegistrationNumber", tt.in, err)
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestDocumentLines 売上情報の明細行をテスト（消費税は明細に含めない）
+func TestDocumentLines(t *testing.T) {
+	sales := &models.SalesInfo{
+		TableCharge:       8000,
+		SetPrice:          5000,
+		ExtensionCount:    2,
+		ExtensionFee:      3000,
+		OrderItems:        []models.OrderItem{{Name: "ボトル", Quantity: 2, UnitPrice: 5000, Amount: 10000}},
+		ShimeiFee:         2000,
+		ServiceChargeRate: 20,
+		ServiceCharge:     4000,
+		Tax:               2400,
+		TaxRate:           10,
+	}
+	want := []DocumentLine{
+		{"セット料金", 1, 5000, 5000},
+		{"延長料金", 2, 1500, 3000},
+		{"ボトル", 2, 5000, 10000},
+		{"指名料", 1, 2000, 2000},
+		{"サービス料（20%）", 1, 4000, 4000},
+	}
+	got := DocumentLines(sales)
+	if len(got) != len(want) {
+		t.Fatalf("len = %d, want %d (%+v)", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("lines[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	// 料金ルール導入前の記録はテーブルチャージを1行にする
+	legacy := DocumentLines(&models.SalesInfo{TableCharge: 6000})
+	if len(legacy) != 1 || legacy[0].Name != "テーブルチャージ" || legacy[0].Amount != 6000 {
+		t.Errorf("legacy lines = %+v", legacy)
+	}
+}
+
+// TestTaxBreakdownOf 税率ごとの内訳をテスト（税込みの定額料金に含まれる消費税を加える）
+func TestTaxBreakdownOf(t *testing.T) {
+	tests := []struct {
+		name  string
+		sales models.SalesInfo
+		want  []TaxBreakdown
+	}{
+		{"外税", models.SalesInfo{TaxRate: 10, Tax: 1000, Total: 11000}, []TaxBreakdown{{10, 11000, 1000}}},
+		{"初回の定額料金", models.SalesInfo{TaxRate: 10, Tax: 500, TaxIncluded: 3000, Total: 8500}, []TaxBreakdown{{10, 8500, 772}}},
+		{"非課税", models.SalesInfo{Total: 5000}, []TaxBreakdown{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TaxBreakdownOf(&tt.sales)
+			if len(got) != len(tt.want) {
+				t.Fatalf("len = %d, want %d (%+v)", len(got), len(tt.want), got)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("breakdown[%d] = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// TestSummarizeTablePayments 受領済みの金額と未回収の売掛の集計をテスト
+func TestSummarizeTablePayments(t *testing.T) {
+	if got := SummarizeTablePayments(nil, 30000); got.Received != 30000 || got.Outstanding != 0 {
+		t.Errorf("no payments = %+v, want all received", got)
+	}
+
+	early := time.Date(2026, 3, 10, 0, 0, 0, 0, time.Local)
+	late := time.Date(2026, 3, 31, 0, 0, 0, 0, time.Local)
+	tab1, tab2 := uint(2), uint(3)
+	payments := []models.Payment{
+		{ID: 1, Method: models.PaymentMethodCash, Amount: 10000},
+		{ID: 2, Method: models.PaymentMethodTab, Amount: 15000, DueDate: &late},
+		{ID: 3, Method: models.PaymentMethodTab, Amount: 5000, DueDate: &early},
+		{ID: 4, Method: models.PaymentMethodCash, Amount: 6000, ReceivableID: &tab1},
+		{ID: 5, Method: models.PaymentMethodCash, Amount: 5000, ReceivableID: &tab2},
+	}
+	got := SummarizeTablePayments(payments, 30000)
+	if got.Received != 21000 || got.Outstanding != 9000 {
+		t.Errorf("summary = %+v, want received 21000, outstanding 9000", got)
+	}
+	// 回収済みの売掛の期限は含めない
+	if got.DueDate == nil || !got.DueDate.Equal(late) {
+		t.Errorf("due date = %v, want %v", got.DueDate, late)
+	}
+}
+
+// TestRenderTableDocument 同梱の日本語フォントでPDFを作成できることをテスト
+func TestRenderTableDocument(t *testing.T) {
+	if err := InitDocumentFont(&config.Config{}); err != nil {
+		t.Fatalf("InitDocumentFont: %v", err)
+	}
+
+	sales := models.SalesInfo{SetPrice: 5000, TableCharge: 5000, TaxRate: 10, Tax: 500, Total: 5500}
+	for _, docType := range []string{models.DocumentTypeReceipt, models.DocumentTypeInvoice} {
+		doc := &TableDocument{
+			Type:               docType,
+			Number:             "R-000001",
+			IssuedAt:           time.Now(),
+			IssuerName:         "株式会社ホストノート",
+			StoreName:          "本店",
+			RegistrationNumber: "T1234567890123",
+			Description:        "飲食代",
+			TransactionDate:    time.Now(),
+			Sales:              sales,
+			Lines:              DocumentLines(&sales),
+			TaxBreakdown:       TaxBreakdownOf(&sales),
+			Payments:           DocumentPayments{Outstanding: 5500},
+			Amount:             5500,
+		}
+		var buf bytes.Buffer
+		if err := RenderTableDocument(doc, &buf); err != nil {
+			t.Fatalf("%s: %v", docType, err)
+		}
+		if !bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")) {
+			t.Errorf("%s: output is not a PDF", docType)
+		}
+		if !bytes.Contains(buf.Bytes(), []byte("/FontFile2")) {
+			t.Errorf("%s: font is not embedded", docType)
+		}
+	}
+}
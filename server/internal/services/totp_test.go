@@ -0,0 +1,73 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 付録Bのテスト用シークレット（ASCII "12345678901234567890"）
+const rfcTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// TestTOTPCode RFC 6238 のテストベクタ（SHA1、下6桁）で検証
+func TestTOTPCode(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		got, err := totpCode(rfcTOTPSecret, tt.unix/totpPeriod)
+		if err != nil {
+			t.Fatalf("totpCode(%d): %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("totpCode(%d) = %v, want %v", tt.unix, got, tt.want)
+		}
+	}
+}
+
+// TestValidateTOTP 時計のずれの許容と使用済みステップの拒否をテスト
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := now.Unix() / totpPeriod
+
+	tests := []struct {
+		name     string
+		code     string
+		lastStep int64
+		wantOK   bool
+	}{
+		{"現在のコード", "050471", 0, true},
+		{"1ステップ前のコード", "081804", 0, true},
+		{"桁数不足", "05047", 0, false},
+		{"不一致", "000000", 0, false},
+		{"使用済みステップ", "050471", step, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := ValidateTOTP(rfcTOTPSecret, tt.code, now, tt.lastStep)
+		if ok != tt.wantOK {
+			t.Errorf("%s: ValidateTOTP ok = %v, want %v", tt.name, ok, tt.wantOK)
+		}
+		if ok && got < step-totpSkew {
+			t.Errorf("%s: ValidateTOTP step = %d, out of window", tt.name, got)
+		}
+	}
+}
+
+// TestTOTPURI otpauth URIにシークレットと発行者が含まれることをテスト
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("HostNote", "demo", rfcTOTPSecret)
+	for _, want := range []string{"otpauth://totp/HostNote:demo?", "secret=" + rfcTOTPSecret, "issuer=HostNote"} {
+		if !strings.Contains(uri, want) {
+			t.Errorf("TOTPURI = %s, want contains %s", uri, want)
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/hostnote/server/internal/config"
 	"github.com/hostnote/server/internal/database"
+	"github.com/hostnote/server/internal/fieldcrypt"
 	"github.com/hostnote/server/internal/handlers"
 	"github.com/hostnote/server/internal/middleware"
 	"github.com/hostnote/server/internal/services"
@@ -21,6 +22,11 @@ func main() {
 		log.Printf("Warning: .env file not found, using environment variables: %v", err)
 	}
 
+	// 個人情報カラムの暗号化鍵を読み込む
+	if err := fieldcrypt.Init(config.AppConfig); err != nil {
+		log.Fatalf("Failed to initialize field encryption: %v", err)
+	}
+
 	// データベース接続
 	db, err := database.Connect()
 	if err != nil {
@@ -32,6 +38,26 @@ func main() {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
 
+	// 監査ログを記録するコールバックを登録
+	if err := services.RegisterAuditCallbacks(db); err != nil {
+		log.Fatalf("Failed to register audit callbacks: %v", err)
+	}
+
+	// メール送信を初期化
+	if err := services.InitMailer(config.AppConfig); err != nil {
+		log.Fatalf("Failed to initialize mailer: %v", err)
+	}
+
+	// AI分析のLLMプロバイダーを初期化
+	if err := services.InitLLMProvider(config.AppConfig); err != nil {
+		log.Fatalf("Failed to initialize AI provider: %v", err)
+	}
+
+	// 領収書・請求書PDFのフォントを読み込む
+	if err := services.InitDocumentFont(config.AppConfig); err != nil {
+		log.Fatalf("Failed to load PDF font: %v", err)
+	}
+
 	// Firebase Admin SDKを初期化
 	if err := services.InitFCM(); err != nil {
 		log.Printf("Warning: Failed to initialize FCM: %v", err)